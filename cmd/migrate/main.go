@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"grimdank-database/config"
+	"grimdank-database/database"
+	"grimdank-database/migrations"
+	"grimdank-database/repositories"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: migrate [flags] status|up|down\n\n")
+	fmt.Fprintf(os.Stderr, "  status  List migrations and whether they have been applied\n")
+	fmt.Fprintf(os.Stderr, "  up      Apply pending migrations\n")
+	fmt.Fprintf(os.Stderr, "  down    Roll back applied migrations, most recent first\n\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		dryRun = flag.Bool("dry-run", false, "Report what would change without writing anything")
+		target = flag.Int("to", 0, "up: stop after this version (default: apply all)")
+		steps  = flag.Int("steps", 1, "down: number of migrations to roll back")
+	)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+	command := flag.Arg(0)
+
+	cfg := config.LoadConfig()
+	if cfg.StorageBackend == config.StorageBackendMemory {
+		fmt.Fprintln(os.Stderr, "Migrations need a MongoDB database; the memory backend starts empty")
+		os.Exit(1)
+	}
+
+	db, err := database.Connect(cfg.MongoURI, cfg.Database, cfg.DatabaseTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Disconnect()
+
+	migrator, err := migrations.NewMigrator(repositories.NewMongoStore(db.Database), migrations.All())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid migrations: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	switch command {
+	case "status":
+		err = printStatus(ctx, migrator)
+	case "up":
+		var results []migrations.Result
+		results, err = migrator.Up(ctx, *target, *dryRun)
+		printResults(results, *dryRun)
+	case "down":
+		var results []migrations.Result
+		results, err = migrator.Down(ctx, *steps, *dryRun)
+		printResults(results, *dryRun)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printStatus(ctx context.Context, migrator *migrations.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if status.Applied {
+			fmt.Printf("  %03d  %-30s  applied %s\n", status.Version, status.Name, status.AppliedAt.Format(time.RFC3339))
+		} else {
+			fmt.Printf("  %03d  %-30s  pending\n", status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+func printResults(results []migrations.Result, dryRun bool) {
+	if len(results) == 0 {
+		fmt.Println("Nothing to do")
+		return
+	}
+
+	verb := "changed"
+	if dryRun {
+		verb = "would change"
+	}
+	for _, result := range results {
+		fmt.Printf("  %-4s %03d  %-30s  %s %d documents\n", result.Direction, result.Version, result.Name, verb, result.Documents)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"grimdank-database/config"
+	"grimdank-database/database"
+	"grimdank-database/migrations"
+	"grimdank-database/repositories"
+	"grimdank-database/services"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: snapshot [flags] export|verify|restore FILE\n\n")
+	fmt.Fprintf(os.Stderr, "  export   Write every collection to FILE\n")
+	fmt.Fprintf(os.Stderr, "  verify   Check FILE against its manifest without touching the database\n")
+	fmt.Fprintf(os.Stderr, "  restore  Write FILE to the database\n\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		mode   = flag.String("mode", string(services.RestoreMerge), "restore: merge keeps existing data, replace empties the collections first")
+		dryRun = flag.Bool("dry-run", false, "restore: report what would change without writing anything")
+	)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		usage()
+		os.Exit(2)
+	}
+	command, path := flag.Arg(0), flag.Arg(1)
+
+	restoreMode, err := services.ParseRestoreMode(*mode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+
+	cfg := config.LoadConfig()
+	if cfg.StorageBackend == config.StorageBackendMemory {
+		fmt.Fprintln(os.Stderr, "Snapshots need a MongoDB database; the memory backend starts empty")
+		os.Exit(1)
+	}
+
+	db, err := database.Connect(cfg.MongoURI, cfg.Database, cfg.DatabaseTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	store := repositories.NewMongoStore(db.Database)
+	snapshots := services.NewSnapshotService(
+		repositories.NewWorkspaceRepository(store.Collection("workspaces")),
+		repositories.NewRuleRepository(store.Collection("rules")),
+		repositories.NewWeaponRepository(store.Collection("weapons")),
+		repositories.NewWarGearRepository(store.Collection("wargear")),
+		repositories.NewUnitRepository(store.Collection("units")),
+		repositories.NewArmyBookRepository(store.Collection("armybooks")),
+		repositories.NewArmyListRepository(store.Collection("armylists")),
+		repositories.NewFactionRepository(store.Collection("factions")),
+		repositories.NewPurgeLogRepository(store.Collection("purges")),
+		db.UnitOfWork(ctx),
+		migrations.Latest(),
+	)
+
+	switch command {
+	case "export":
+		err = export(ctx, snapshots, path)
+	case "verify":
+		err = verify(snapshots, path)
+	case "restore":
+		err = restore(ctx, snapshots, path, restoreMode, *dryRun)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func export(ctx context.Context, snapshots *services.SnapshotService, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := snapshots.Export(ctx, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	printManifest(manifest)
+	fmt.Printf("✅ Wrote %s\n", path)
+	return nil
+}
+
+func verify(snapshots *services.SnapshotService, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	manifest, err := snapshots.Verify(file)
+	if err != nil {
+		return err
+	}
+
+	printManifest(manifest)
+	fmt.Printf("✅ %s is intact\n", path)
+	return nil
+}
+
+func restore(ctx context.Context, snapshots *services.SnapshotService, path string, mode services.RestoreMode, dryRun bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	report, err := snapshots.Restore(ctx, file, mode, dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "restored"
+	if dryRun {
+		verb = "would restore"
+	}
+	fmt.Printf("Snapshot of %s, %s with %s\n", report.Manifest.CreatedAt.Format(time.RFC3339), verb, report.Mode)
+	for _, collection := range report.Collections {
+		fmt.Printf("  %-10s  %5d archived  %5d inserted  %5d removed  %5d existing  %5d remapped\n",
+			collection.Name, collection.Archived, collection.Inserted, collection.Removed, collection.Existing, len(collection.Remapped))
+		for _, remapped := range collection.Remapped {
+			fmt.Printf("      %s -> %s  %s\n", remapped.From.Hex(), remapped.To.Hex(), remapped.Name)
+		}
+	}
+	return nil
+}
+
+func printManifest(manifest *services.SnapshotManifest) {
+	fmt.Printf("Snapshot of %s, format %d, schema version %d\n", manifest.CreatedAt.Format(time.RFC3339), manifest.FormatVersion, manifest.SchemaVersion)
+	for _, collection := range manifest.Collections {
+		fmt.Printf("  %-10s  %5d documents  sha256 %s\n", collection.Name, collection.Count, collection.SHA256)
+	}
+}
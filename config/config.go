@@ -8,13 +8,50 @@ import (
 	"github.com/joho/godotenv"
 )
 
+// Supported storage backends
+const (
+	StorageBackendMongo  = "mongo"
+	StorageBackendMemory = "memory"
+)
+
+// Supported asset storage drivers
+const (
+	AssetStorageGridFS     = "gridfs"
+	AssetStorageFilesystem = "filesystem"
+)
+
+// Points recalculation triggers
+const (
+	PointsRecalculationAuto   = "auto"   // recalculate dependents after every change
+	PointsRecalculationManual = "manual" // only when asked through the API
+)
+
+// Index sync modes applied on startup
+const (
+	IndexSyncApply = "apply" // create missing indexes and rebuild changed ones
+	IndexSyncCheck = "check" // only report drift
+	IndexSyncOff   = "off"
+)
+
 type Config struct {
-	MongoURI          string
-	Database          string
-	ServerPort        string
-	DatabaseTimeout   int // in seconds
-	Environment       string
-	EnvironmentConfig *EnvironmentConfig
+	MongoURI            string
+	Database            string
+	ServerPort          string
+	DatabaseTimeout     int    // in seconds
+	StorageBackend      string // "mongo" or "memory"
+	TrashRetention      int    // in days, 0 keeps deleted items forever
+	IndexSync           string // "apply", "check" or "off"
+	AdminToken          string // bearer token for the admin endpoints, which are off without one
+	WorkspaceDomain     string // requests to <workspace>.<domain> work in that workspace
+	CacheTTL            int    // in seconds, 0 turns the rule, weapon and wargear caches off
+	CacheSize           int    // entries per cache
+	AssetStorage        string // "gridfs" or "filesystem", empty picks the one that suits the storage backend
+	AssetDir            string // where the filesystem driver keeps uploads
+	MaxAssetSizeMB      int    // largest accepted upload
+	PointsRecalculation string // "auto" or "manual"
+	PointsSeasonReload  int    // in seconds, how often points seasons are reread; 0 only on change
+	Environment         string
+	EnvironmentConfig   *EnvironmentConfig
 }
 
 func LoadConfig() *Config {
@@ -28,12 +65,24 @@ func LoadConfig() *Config {
 	envConfig := GetEnvironmentConfig(env)
 
 	config := &Config{
-		MongoURI:          getEnv("MONGODB_URI", "mongodb://localhost:27017"),
-		Database:          getEnv("DATABASE_NAME", "grimdank_db"),
-		ServerPort:        getEnv("SERVER_PORT", "8080"),
-		DatabaseTimeout:   getEnvInt("DATABASE_TIMEOUT", 10),
-		Environment:       env,
-		EnvironmentConfig: envConfig,
+		MongoURI:            getEnv("MONGODB_URI", "mongodb://localhost:27017"),
+		Database:            getEnv("DATABASE_NAME", "grimdank_db"),
+		ServerPort:          getEnv("SERVER_PORT", "8080"),
+		DatabaseTimeout:     getEnvInt("DATABASE_TIMEOUT", 10),
+		StorageBackend:      getEnv("STORAGE_BACKEND", StorageBackendMongo),
+		TrashRetention:      getEnvInt("TRASH_RETENTION_DAYS", 30),
+		IndexSync:           getEnv("INDEX_SYNC", IndexSyncApply),
+		AdminToken:          getEnv("ADMIN_TOKEN", ""),
+		WorkspaceDomain:     getEnv("WORKSPACE_DOMAIN", ""),
+		CacheTTL:            getEnvInt("CACHE_TTL_SECONDS", 300),
+		CacheSize:           getEnvInt("CACHE_SIZE", 10000),
+		AssetStorage:        getEnv("ASSET_STORAGE", ""),
+		AssetDir:            getEnv("ASSET_DIR", "data/assets"),
+		MaxAssetSizeMB:      getEnvInt("MAX_ASSET_SIZE_MB", 5),
+		PointsRecalculation: getEnv("POINTS_RECALCULATION", PointsRecalculationAuto),
+		PointsSeasonReload:  getEnvInt("POINTS_SEASON_RELOAD_SECONDS", 30),
+		Environment:         env,
+		EnvironmentConfig:   envConfig,
 	}
 
 	// Validate configuration
@@ -47,6 +96,15 @@ func LoadConfig() *Config {
 	log.Printf("  Database: %s", config.Database)
 	log.Printf("  Server Port: %s", config.ServerPort)
 	log.Printf("  Database Timeout: %d seconds", config.DatabaseTimeout)
+	log.Printf("  Storage Backend: %s", config.StorageBackend)
+	log.Printf("  Trash Retention: %d days", config.TrashRetention)
+	log.Printf("  Index Sync: %s", config.IndexSync)
+	log.Printf("  Admin Endpoints: %t", config.AdminToken != "")
+	log.Printf("  Workspace Domain: %s", config.WorkspaceDomain)
+	log.Printf("  Cache: %ds TTL, %d entries", config.CacheTTL, config.CacheSize)
+	log.Printf("  Asset Storage: %s, up to %d MB", config.AssetStorageDriver(), config.MaxAssetSizeMB)
+	log.Printf("  Points Recalculation: %s", config.PointsRecalculation)
+	log.Printf("  Points Season Reload: %d seconds", config.PointsSeasonReload)
 	log.Printf("  Debug Mode: %t", envConfig.DebugMode)
 	log.Printf("  Log Level: %s", envConfig.LogLevel)
 	log.Printf("  Metrics Enabled: %t", envConfig.EnableMetrics)
@@ -55,6 +113,18 @@ func LoadConfig() *Config {
 	return config
 }
 
+// AssetStorageDriver returns the asset storage driver to use. GridFS needs
+// MongoDB, so without a database uploads go to the filesystem.
+func (c *Config) AssetStorageDriver() string {
+	if c.AssetStorage != "" {
+		return c.AssetStorage
+	}
+	if c.StorageBackend == StorageBackendMemory {
+		return AssetStorageFilesystem
+	}
+	return AssetStorageGridFS
+}
+
 // maskURI masks sensitive parts of the MongoDB URI for logging
 func maskURI(uri string) string {
 	if len(uri) > 20 {
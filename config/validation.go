@@ -69,6 +69,39 @@ func ValidateConfig(cfg *Config, validationCfg *ValidationConfig) error {
 		errors = append(errors, ValidationError{Field: "DatabaseTimeout", Message: err.Error()})
 	}
 
+	// Validate storage backend
+	if err := validateStorageBackend(cfg.StorageBackend); err != nil {
+		errors = append(errors, ValidationError{Field: "StorageBackend", Message: err.Error()})
+	}
+
+	// Validate index sync mode
+	if err := validateIndexSync(cfg.IndexSync); err != nil {
+		errors = append(errors, ValidationError{Field: "IndexSync", Message: err.Error()})
+	}
+
+	// Validate asset storage
+	if err := validateAssetStorage(cfg.AssetStorage, cfg.StorageBackend); err != nil {
+		errors = append(errors, ValidationError{Field: "AssetStorage", Message: err.Error()})
+	}
+	if cfg.MaxAssetSizeMB <= 0 {
+		errors = append(errors, ValidationError{Field: "MaxAssetSizeMB", Message: "max asset size must be positive"})
+	}
+
+	// Validate points recalculation trigger
+	if err := validatePointsRecalculation(cfg.PointsRecalculation); err != nil {
+		errors = append(errors, ValidationError{Field: "PointsRecalculation", Message: err.Error()})
+	}
+
+	// Validate points season reload interval
+	if cfg.PointsSeasonReload < 0 {
+		errors = append(errors, ValidationError{Field: "PointsSeasonReload", Message: "points season reload interval cannot be negative"})
+	}
+
+	// Validate trash retention
+	if cfg.TrashRetention < 0 {
+		errors = append(errors, ValidationError{Field: "TrashRetention", Message: "trash retention cannot be negative"})
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf("configuration validation failed: %v", errors)
 	}
@@ -161,6 +194,49 @@ func validateDatabaseTimeout(timeout int, validationCfg *ValidationConfig) error
 	return nil
 }
 
+// validateStorageBackend validates the storage backend name (empty means the default)
+func validateStorageBackend(backend string) error {
+	switch backend {
+	case "", StorageBackendMongo, StorageBackendMemory:
+		return nil
+	default:
+		return fmt.Errorf("storage backend must be %q or %q", StorageBackendMongo, StorageBackendMemory)
+	}
+}
+
+func validateIndexSync(mode string) error {
+	switch mode {
+	case "", IndexSyncApply, IndexSyncCheck, IndexSyncOff:
+		return nil
+	default:
+		return fmt.Errorf("index sync must be %q, %q or %q", IndexSyncApply, IndexSyncCheck, IndexSyncOff)
+	}
+}
+
+func validatePointsRecalculation(trigger string) error {
+	switch trigger {
+	case "", PointsRecalculationAuto, PointsRecalculationManual:
+		return nil
+	default:
+		return fmt.Errorf("points recalculation must be %q or %q", PointsRecalculationAuto, PointsRecalculationManual)
+	}
+}
+
+// validateAssetStorage validates the asset storage driver (empty means the default)
+func validateAssetStorage(driver, backend string) error {
+	switch driver {
+	case "", AssetStorageFilesystem:
+		return nil
+	case AssetStorageGridFS:
+		if backend == StorageBackendMemory {
+			return fmt.Errorf("asset storage %q needs the %q storage backend", AssetStorageGridFS, StorageBackendMongo)
+		}
+		return nil
+	default:
+		return fmt.Errorf("asset storage must be %q or %q", AssetStorageGridFS, AssetStorageFilesystem)
+	}
+}
+
 // EnvironmentConfig holds environment-specific configuration
 type EnvironmentConfig struct {
 	Environment    string
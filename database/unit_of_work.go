@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UnitOfWork runs a group of writes, possibly across collections, so that
+// they take effect together or not at all
+type UnitOfWork interface {
+	// Do runs fn. The storage calls fn makes with the context it is given
+	// belong to the unit; if fn returns an error, they are rolled back.
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+	// Transactional reports whether the unit is backed by a database
+	// transaction rather than by undoing writes one at a time
+	Transactional() bool
+}
+
+// TransactionUnitOfWork runs units of work in MongoDB session transactions,
+// which need a replica set or a sharded cluster
+type TransactionUnitOfWork struct {
+	client *mongo.Client
+}
+
+// NewTransactionUnitOfWork creates a unit of work backed by transactions on client
+func NewTransactionUnitOfWork(client *mongo.Client) *TransactionUnitOfWork {
+	return &TransactionUnitOfWork{
+		client: client,
+	}
+}
+
+// Do runs fn in a transaction. The driver retries fn on transient
+// transaction errors, so fn may run more than once. A unit started inside
+// another joins the outer transaction.
+func (u *TransactionUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := u.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	var hooks *commitHooks
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		// Hooks from an attempt that was retried must not run
+		hooks = &commitHooks{}
+		return nil, fn(context.WithValue(sessionCtx, commitHooksKey{}, hooks))
+	})
+	if err != nil {
+		return err
+	}
+	hooks.run(ctx)
+	return nil
+}
+
+func (u *TransactionUnitOfWork) Transactional() bool {
+	return true
+}
+
+// SequentialUnitOfWork is the fallback for deployments without transactions,
+// such as a standalone server or the in-memory backend. Writes happen one by
+// one as usual; when fn fails, the writes that registered an undo with
+// OnRollback are undone, most recent first. Writes without an undo stay.
+type SequentialUnitOfWork struct{}
+
+func (SequentialUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, joined := ctx.Value(undoLogKey{}).(*undoLog); joined {
+		return fn(ctx)
+	}
+
+	undo := &undoLog{}
+	hooks := &commitHooks{}
+	err := fn(context.WithValue(context.WithValue(ctx, undoLogKey{}, undo), commitHooksKey{}, hooks))
+	if err == nil {
+		hooks.run(ctx)
+		return nil
+	}
+
+	// Undo even when fn failed because ctx was cancelled
+	if undoErr := undo.rollback(context.WithoutCancel(ctx)); undoErr != nil {
+		return fmt.Errorf("%w (rollback incomplete: %v)", err, undoErr)
+	}
+	return err
+}
+
+func (SequentialUnitOfWork) Transactional() bool {
+	return false
+}
+
+type undoLogKey struct{}
+
+// undoLog collects the compensating writes of a sequential unit of work
+type undoLog struct {
+	mu    sync.Mutex
+	steps []func(ctx context.Context) error
+}
+
+func (l *undoLog) add(step func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.steps = append(l.steps, step)
+}
+
+// rollback runs every undo step, newest first, and returns the first failure
+func (l *undoLog) rollback(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var first error
+	for i := len(l.steps) - 1; i >= 0; i-- {
+		if err := l.steps[i](ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// NeedsUndo reports whether writes made with ctx must register an undo,
+// i.e. whether ctx belongs to a sequential unit of work
+func NeedsUndo(ctx context.Context) bool {
+	_, ok := ctx.Value(undoLogKey{}).(*undoLog)
+	return ok
+}
+
+// OnRollback registers undo to run if the sequential unit of work ctx
+// belongs to fails. Inside a transaction or outside any unit of work it does
+// nothing, so writes can register their undo unconditionally.
+func OnRollback(ctx context.Context, undo func(ctx context.Context) error) {
+	if pending, ok := ctx.Value(undoLogKey{}).(*undoLog); ok {
+		pending.add(undo)
+	}
+}
+
+type commitHooksKey struct{}
+
+// commitHooks collects what to run once a unit of work has committed
+type commitHooks struct {
+	mu    sync.Mutex
+	hooks []func(ctx context.Context)
+}
+
+func (h *commitHooks) add(hook func(ctx context.Context)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, hook)
+}
+
+func (h *commitHooks) run(ctx context.Context) {
+	h.mu.Lock()
+	hooks := h.hooks
+	h.mu.Unlock()
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+}
+
+// AfterCommit runs hook once the unit of work ctx belongs to has committed,
+// or right away outside any unit of work. Hooks of a unit that fails never
+// run, so side effects such as notifications only follow writes that stuck.
+// hook gets the context the unit was started with, which no longer belongs
+// to it, so the writes hook makes are not part of the finished unit.
+func AfterCommit(ctx context.Context, hook func(ctx context.Context)) {
+	if hooks, ok := ctx.Value(commitHooksKey{}).(*commitHooks); ok {
+		hooks.add(hook)
+		return
+	}
+	hook(ctx)
+}
+
+// SupportsTransactions reports whether the deployment can run multi-document
+// transactions. Standalone servers cannot; replica sets and sharded clusters can.
+func (db *Database) SupportsTransactions(ctx context.Context) (bool, error) {
+	var hello bson.M
+	err := db.Database.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		// Servers before 4.4.2 only know the legacy name
+		if err := db.Database.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello); err != nil {
+			return false, err
+		}
+	}
+
+	_, replicaSet := hello["setName"]
+	return replicaSet || hello["msg"] == "isdbgrid", nil
+}
+
+// UnitOfWork returns a transaction-backed unit of work when the deployment
+// supports transactions, and the sequential fallback otherwise
+func (db *Database) UnitOfWork(ctx context.Context) UnitOfWork {
+	supported, err := db.SupportsTransactions(ctx)
+	if err != nil {
+		log.Printf("⚠️ Could not check for transaction support, falling back to sequential writes: %v", err)
+		return SequentialUnitOfWork{}
+	}
+	if !supported {
+		log.Println("⚠️ MongoDB is a standalone server without transactions, falling back to sequential writes")
+		return SequentialUnitOfWork{}
+	}
+	return NewTransactionUnitOfWork(db.Client)
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSequentialUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+	failure := errors.New("failed")
+
+	t.Run("Undoes in reverse order on failure", func(t *testing.T) {
+		var undone []int
+		err := SequentialUnitOfWork{}.Do(ctx, func(ctx context.Context) error {
+			for i := 1; i <= 3; i++ {
+				step := i
+				OnRollback(ctx, func(ctx context.Context) error {
+					undone = append(undone, step)
+					return nil
+				})
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("Expected %v, got %v", failure, err)
+		}
+		if !reflect.DeepEqual(undone, []int{3, 2, 1}) {
+			t.Errorf("Expected undo order [3 2 1], got %v", undone)
+		}
+	})
+
+	t.Run("Keeps writes on success", func(t *testing.T) {
+		undone := false
+		err := SequentialUnitOfWork{}.Do(ctx, func(ctx context.Context) error {
+			OnRollback(ctx, func(ctx context.Context) error {
+				undone = true
+				return nil
+			})
+			return nil
+		})
+		if err != nil || undone {
+			t.Errorf("Expected no error and no undo, got %v, undone %v", err, undone)
+		}
+	})
+
+	t.Run("Nested unit joins the outer one", func(t *testing.T) {
+		undone := false
+		err := SequentialUnitOfWork{}.Do(ctx, func(ctx context.Context) error {
+			if err := (SequentialUnitOfWork{}).Do(ctx, func(ctx context.Context) error {
+				OnRollback(ctx, func(ctx context.Context) error {
+					undone = true
+					return nil
+				})
+				return nil
+			}); err != nil {
+				return err
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) || !undone {
+			t.Errorf("Expected the outer failure to undo the nested write, got %v, undone %v", err, undone)
+		}
+	})
+
+	t.Run("Reports an incomplete rollback", func(t *testing.T) {
+		err := SequentialUnitOfWork{}.Do(ctx, func(ctx context.Context) error {
+			OnRollback(ctx, func(ctx context.Context) error {
+				return errors.New("undo failed")
+			})
+			return failure
+		})
+		if !errors.Is(err, failure) || err.Error() != "failed (rollback incomplete: undo failed)" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Commit hooks run after the outermost unit succeeds", func(t *testing.T) {
+		var committed []string
+		err := SequentialUnitOfWork{}.Do(ctx, func(ctx context.Context) error {
+			err := (SequentialUnitOfWork{}).Do(ctx, func(ctx context.Context) error {
+				AfterCommit(ctx, func(context.Context) { committed = append(committed, "inner") })
+				return nil
+			})
+			if len(committed) != 0 {
+				t.Error("Expected hooks of a nested unit to wait for the outer unit")
+			}
+			AfterCommit(ctx, func(ctx context.Context) {
+				if NeedsUndo(ctx) {
+					t.Error("Expected a hook to run outside the finished unit")
+				}
+				committed = append(committed, "outer")
+			})
+			return err
+		})
+		if err != nil || !reflect.DeepEqual(committed, []string{"inner", "outer"}) {
+			t.Errorf("Expected both hooks in order, got %v (err %v)", committed, err)
+		}
+	})
+
+	t.Run("Commit hooks of a failed unit never run", func(t *testing.T) {
+		ran := false
+		SequentialUnitOfWork{}.Do(ctx, func(ctx context.Context) error {
+			AfterCommit(ctx, func(context.Context) { ran = true })
+			return failure
+		})
+		if ran {
+			t.Error("Expected the hook of a failed unit not to run")
+		}
+	})
+
+	t.Run("Outside a unit nothing is recorded", func(t *testing.T) {
+		if NeedsUndo(ctx) {
+			t.Error("Expected a plain context not to need undo")
+		}
+		OnRollback(ctx, func(ctx context.Context) error { return nil })
+
+		ran := false
+		AfterCommit(ctx, func(context.Context) { ran = true })
+		if !ran {
+			t.Error("Expected a commit hook outside a unit to run right away")
+		}
+	})
+}
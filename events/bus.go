@@ -0,0 +1,152 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Handler handles one event. An error or a panic counts as a failed
+// delivery, which is retried if the subscription asks for it.
+type Handler func(ctx context.Context, event Event) error
+
+// subscription is one subscriber and how its events are delivered
+type subscription struct {
+	name     string
+	handler  Handler
+	async    bool
+	attempts int
+	backoff  time.Duration
+}
+
+// Option configures how a subscriber's events are delivered
+type Option func(*subscription)
+
+// Async delivers events on their own goroutine, so the publisher does not
+// wait for the subscriber. Without it, Publish runs the subscriber and its
+// retries before returning.
+func Async() Option {
+	return func(s *subscription) {
+		s.async = true
+	}
+}
+
+// WithRetry delivers an event up to attempts times until the subscriber
+// succeeds, waiting backoff after the first failure and twice as long after
+// each one after that
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(s *subscription) {
+		if attempts > 0 {
+			s.attempts = attempts
+		}
+		s.backoff = backoff
+	}
+}
+
+// Bus delivers events in process to the subscribers of their type. A failing
+// or panicking subscriber is logged and never affects the publisher or the
+// other subscribers.
+type Bus struct {
+	mu       sync.RWMutex
+	byType   map[reflect.Type][]*subscription
+	all      []*subscription
+	inFlight sync.WaitGroup
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		byType: make(map[reflect.Type][]*subscription),
+	}
+}
+
+// Subscribe registers handler for events of type E, e.g. events.UnitUpdated
+func Subscribe[E Event](bus *Bus, name string, handler func(ctx context.Context, event E) error, opts ...Option) {
+	eventType := reflect.TypeOf((*E)(nil)).Elem()
+	sub := newSubscription(name, func(ctx context.Context, event Event) error {
+		return handler(ctx, event.(E))
+	}, opts)
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.byType[eventType] = append(bus.byType[eventType], sub)
+}
+
+// SubscribeAll registers handler for every event
+func (b *Bus) SubscribeAll(name string, handler Handler, opts ...Option) {
+	sub := newSubscription(name, handler, opts)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.all = append(b.all, sub)
+}
+
+func newSubscription(name string, handler Handler, opts []Option) *subscription {
+	sub := &subscription{name: name, handler: handler, attempts: 1}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	return sub
+}
+
+// Publish delivers event to its subscribers in the order they subscribed.
+// Asynchronous subscribers get a context that is not cancelled with ctx.
+// Publishing on a nil bus does nothing.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	subs := make([]*subscription, 0, len(b.byType[reflect.TypeOf(event)])+len(b.all))
+	subs = append(subs, b.byType[reflect.TypeOf(event)]...)
+	subs = append(subs, b.all...)
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		if !sub.async {
+			sub.deliver(ctx, event)
+			continue
+		}
+		b.inFlight.Add(1)
+		go func(sub *subscription) {
+			defer b.inFlight.Done()
+			sub.deliver(context.WithoutCancel(ctx), event)
+		}(sub)
+	}
+}
+
+// Wait blocks until every asynchronous delivery in flight has finished
+func (b *Bus) Wait() {
+	b.inFlight.Wait()
+}
+
+// deliver runs the handler until it succeeds or runs out of attempts
+func (s *subscription) deliver(ctx context.Context, event Event) {
+	delay := s.backoff
+	var err error
+	for attempt := 1; attempt <= s.attempts; attempt++ {
+		if err = s.call(ctx, event); err == nil {
+			return
+		}
+		if attempt < s.attempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	log.Printf("⚠️ Event subscriber %s failed on %s %s after %d attempt(s): %v",
+		s.name, event.Name(), event.Change().EntityID.Hex(), s.attempts, err)
+}
+
+// call runs the handler once, turning a panic into an error
+func (s *subscription) call(ctx context.Context, event Event) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v\n%s", recovered, debug.Stack())
+		}
+	}()
+	return s.handler(ctx, event)
+}
@@ -0,0 +1,117 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"grimdank-database/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEventNames(t *testing.T) {
+	if name := (UnitUpdated{}).Name(); name != "UnitUpdated" {
+		t.Errorf("Expected UnitUpdated, got %s", name)
+	}
+	if name := (ArmyBookDeleted{}).Name(); name != "ArmyBookDeleted" {
+		t.Errorf("Expected ArmyBookDeleted, got %s", name)
+	}
+}
+
+func TestSubscribeByType(t *testing.T) {
+	bus := NewBus()
+	var updated, created int
+	Subscribe(bus, "updated", func(ctx context.Context, event RuleUpdated) error {
+		if event.Before.Name != "Old" || event.After.Name != "New" {
+			t.Errorf("Unexpected payload: %+v", event)
+		}
+		updated++
+		return nil
+	})
+	Subscribe(bus, "created", func(ctx context.Context, event RuleCreated) error {
+		created++
+		return nil
+	})
+
+	bus.Publish(context.Background(), RuleUpdated{Before: models.Rule{Name: "Old"}, After: models.Rule{Name: "New"}})
+	if updated != 1 || created != 0 {
+		t.Errorf("Expected only the RuleUpdated subscriber to run, got updated %d created %d", updated, created)
+	}
+}
+
+func TestPanicIsolation(t *testing.T) {
+	bus := NewBus()
+	ran := false
+	bus.SubscribeAll("panics", func(ctx context.Context, event Event) error {
+		panic("boom")
+	})
+	bus.SubscribeAll("after", func(ctx context.Context, event Event) error {
+		ran = true
+		return nil
+	})
+
+	bus.Publish(context.Background(), UnitCreated{})
+	if !ran {
+		t.Error("Expected a panicking subscriber not to stop the others")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	bus := NewBus()
+	var calls int32
+	bus.SubscribeAll("flaky", func(ctx context.Context, event Event) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}, WithRetry(5, 0), Async())
+
+	bus.Publish(context.Background(), WeaponDeleted{})
+	bus.Wait()
+	if calls != 3 {
+		t.Errorf("Expected delivery to stop after the first success on attempt 3, got %d calls", calls)
+	}
+}
+
+func TestAsyncOutlivesCancelledContext(t *testing.T) {
+	bus := NewBus()
+	var cancelled atomic.Bool
+	bus.SubscribeAll("async", func(ctx context.Context, event Event) error {
+		cancelled.Store(ctx.Err() != nil)
+		return nil
+	}, Async())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bus.Publish(ctx, FactionCreated{})
+	cancel()
+	bus.Wait()
+	if cancelled.Load() {
+		t.Error("Expected an asynchronous subscriber not to see the publisher's cancellation")
+	}
+}
+
+func TestFromDocuments(t *testing.T) {
+	id := primitive.NewObjectID()
+	meta := Meta{EntityType: "units", EntityID: id, Version: 2}
+	before := bson.M{"_id": id, "name": "Guard", "defense": int32(4), "version": int32(1)}
+	after := bson.M{"_id": id, "name": "Guard", "defense": int32(3), "version": int32(2)}
+
+	event, err := FromDocuments(ActionUpdated, meta, before, after)
+	if err != nil {
+		t.Fatalf("Failed to build event: %v", err)
+	}
+	updated, ok := event.(UnitUpdated)
+	if !ok {
+		t.Fatalf("Expected a UnitUpdated, got %T", event)
+	}
+	if updated.Before.Defense != 4 || updated.After.Defense != 3 || updated.Change().EntityID != id {
+		t.Errorf("Unexpected event: %+v", updated)
+	}
+
+	if _, err := FromDocuments(ActionCreated, Meta{EntityType: "dice"}, nil, after); err == nil {
+		t.Error("Expected an unknown entity type to fail")
+	}
+}
@@ -0,0 +1,169 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"grimdank-database/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Event is a change to an entity that has been written successfully
+type Event interface {
+	// Name identifies the kind of event, e.g. "UnitUpdated"
+	Name() string
+	// Change describes which entity changed, who changed it and when
+	Change() Meta
+}
+
+// Meta is what every event carries besides its payload
+type Meta struct {
+	EntityType string             `json:"entityType"` // the collection, e.g. "units"
+	EntityID   primitive.ObjectID `json:"entityId"`
+	Version    int                `json:"version"` // the entity's version after the change
+	Actor      string             `json:"actor"`
+	Reason     string             `json:"reason,omitempty"`
+	At         time.Time          `json:"at"`
+}
+
+func (m Meta) Change() Meta {
+	return m
+}
+
+// Created reports a new entity
+type Created[T any] struct {
+	Meta
+	After T `json:"after"`
+}
+
+// Updated reports a change to an existing entity, including restoring it
+// from the trash and reverting it to an earlier revision
+type Updated[T any] struct {
+	Meta
+	Before T `json:"before"`
+	After  T `json:"after"`
+}
+
+// Deleted reports an entity moved to the trash. Before is the entity as it
+// was just before the delete.
+type Deleted[T any] struct {
+	Meta
+	Before T `json:"before"`
+}
+
+func (Created[T]) Name() string { return entityName[T]() + "Created" }
+func (Updated[T]) Name() string { return entityName[T]() + "Updated" }
+func (Deleted[T]) Name() string { return entityName[T]() + "Deleted" }
+
+// entityName is the Go name of an entity type, e.g. "ArmyBook"
+func entityName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().Name()
+}
+
+// The events of each entity type
+type (
+	RuleCreated = Created[models.Rule]
+	RuleUpdated = Updated[models.Rule]
+	RuleDeleted = Deleted[models.Rule]
+
+	WeaponCreated = Created[models.Weapon]
+	WeaponUpdated = Updated[models.Weapon]
+	WeaponDeleted = Deleted[models.Weapon]
+
+	WarGearCreated = Created[models.WarGear]
+	WarGearUpdated = Updated[models.WarGear]
+	WarGearDeleted = Deleted[models.WarGear]
+
+	UnitCreated = Created[models.Unit]
+	UnitUpdated = Updated[models.Unit]
+	UnitDeleted = Deleted[models.Unit]
+
+	ArmyBookCreated = Created[models.ArmyBook]
+	ArmyBookUpdated = Updated[models.ArmyBook]
+	ArmyBookDeleted = Deleted[models.ArmyBook]
+
+	ArmyListCreated = Created[models.ArmyList]
+	ArmyListUpdated = Updated[models.ArmyList]
+	ArmyListDeleted = Deleted[models.ArmyList]
+
+	FactionCreated = Created[models.Faction]
+	FactionUpdated = Updated[models.Faction]
+	FactionDeleted = Deleted[models.Faction]
+)
+
+// SeasonChanged reports that another points season became current, or that
+// the current season's settings changed. The built-in season has no ID.
+type SeasonChanged struct {
+	Meta
+	Season models.PointsSeason `json:"season"`
+}
+
+func (SeasonChanged) Name() string { return "SeasonChanged" }
+
+// Action is the kind of change an event reports
+type Action string
+
+const (
+	ActionCreated Action = "created"
+	ActionUpdated Action = "updated"
+	ActionDeleted Action = "deleted"
+)
+
+// kind builds the typed events of one entity type from stored documents
+type kind func(action Action, meta Meta, before, after bson.M) (Event, error)
+
+func kindOf[T any]() kind {
+	return func(action Action, meta Meta, before, after bson.M) (Event, error) {
+		switch action {
+		case ActionCreated:
+			var event Created[T]
+			event.Meta = meta
+			return event, decode(after, &event.After)
+		case ActionUpdated:
+			var event Updated[T]
+			event.Meta = meta
+			if err := decode(before, &event.Before); err != nil {
+				return nil, err
+			}
+			return event, decode(after, &event.After)
+		case ActionDeleted:
+			var event Deleted[T]
+			event.Meta = meta
+			return event, decode(before, &event.Before)
+		}
+		return nil, fmt.Errorf("unknown event action %q", action)
+	}
+}
+
+// kinds maps each collection to the entity type stored in it
+var kinds = map[string]kind{
+	"rules":     kindOf[models.Rule](),
+	"weapons":   kindOf[models.Weapon](),
+	"wargear":   kindOf[models.WarGear](),
+	"units":     kindOf[models.Unit](),
+	"armybooks": kindOf[models.ArmyBook](),
+	"armylists": kindOf[models.ArmyList](),
+	"factions":  kindOf[models.Faction](),
+}
+
+// FromDocuments builds the typed event for a change to a stored document of
+// meta.EntityType. before is unused for creates and after for deletes.
+func FromDocuments(action Action, meta Meta, before, after bson.M) (Event, error) {
+	build, ok := kinds[meta.EntityType]
+	if !ok {
+		return nil, fmt.Errorf("no events for entity type %q", meta.EntityType)
+	}
+	return build(action, meta, before, after)
+}
+
+// decode converts a stored document into its entity type
+func decode(document bson.M, result interface{}) error {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, result)
+}
@@ -4,13 +4,13 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
 
 	"grimdank-database/models"
 	"grimdank-database/services"
+	"grimdank-database/utils"
 )
 
 // WeaponHandler handles HTTP requests for weapon operations
@@ -35,7 +35,11 @@ func (h *WeaponHandler) CreateWeapon(w http.ResponseWriter, r *http.Request) {
 
 	createdWeapon, err := h.service.CreateWeapon(r.Context(), &weapon)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
@@ -60,65 +64,45 @@ func (h *WeaponHandler) GetWeapon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, weapon.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(weapon)
 }
 
 // GetWeapons handles GET /weapons - retrieves weapons with pagination
 func (h *WeaponHandler) GetWeapons(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	skipStr := r.URL.Query().Get("skip")
-	name := r.URL.Query().Get("name")
-
-	limit := int64(50)
-	skip := int64(0)
-
-	if limitStr != "" {
-		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
-			limit = l
-		}
-	}
-
-	if skipStr != "" {
-		if s, err := strconv.ParseInt(skipStr, 10, 64); err == nil {
-			skip = s
-		}
-	}
-
-	var weapons []models.Weapon
-	var err error
-
-	if name != "" {
-		weapons, err = h.service.SearchWeaponsByName(r.Context(), name, limit, skip)
-	} else {
-		weapons, err = h.service.GetAllWeapons(r.Context(), limit, skip)
+	opts, err := listOptionsFromRequest(r, 50)
+	if err != nil {
+		writeListError(w, err)
+		return
 	}
+	name := r.URL.Query().Get("name")
 
+	weapons, nextCursor, err := h.service.ListWeapons(r.Context(), name, opts)
 	if err != nil {
 		log.Printf("Error in GetWeapons: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeListError(w, err)
 		return
 	}
 
 	// Get total count for pagination
-	var totalCount int64
-	if name != "" {
-		totalCount, err = h.service.CountWeaponsByName(r.Context(), name)
-	} else {
-		totalCount, err = h.service.CountWeapons(r.Context())
-	}
+	totalCount, err := h.service.CountWeaponsMatching(r.Context(), name, opts.Filter)
 	if err != nil {
 		http.Error(w, "Failed to get count", http.StatusInternalServerError)
 		return
 	}
 
 	response := map[string]interface{}{
-		"data":  weapons,
-		"total": totalCount,
-		"limit": limit,
-		"skip":  skip,
+		"data":       weapons,
+		"total":      totalCount,
+		"limit":      opts.Limit,
+		"skip":       opts.Skip,
+		"nextCursor": nextCursor,
 	}
 
+	if nextCursor != "" {
+		w.Header().Set("X-Next-Cursor", nextCursor)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -133,9 +117,19 @@ func (h *WeaponHandler) UpdateWeapon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.service.UpdateWeapon(r.Context(), id, &weapon)
+	fromIfMatch, err := applyIfMatch(r, &weapon.Version)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.UpdateWeapon(r.Context(), id, &weapon)
+	if err != nil {
+		if utils.IsVersionConflictError(err) {
+			writeVersionConflict(w, err, fromIfMatch)
+		} else if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Weapon not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -150,6 +144,7 @@ func (h *WeaponHandler) UpdateWeapon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, updatedWeapon.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedWeapon)
 }
@@ -158,9 +153,17 @@ func (h *WeaponHandler) DeleteWeapon(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	err := h.service.DeleteWeapon(r.Context(), id)
+	policy, err := deletePolicyFromRequest(r)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.DeleteWeaponWithPolicy(r.Context(), id, policy)
+	if err != nil {
+		if services.IsReferencedError(err) {
+			writeReferencedError(w, err)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Weapon not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -168,7 +171,7 @@ func (h *WeaponHandler) DeleteWeapon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeDeleteReport(w, report)
 }
 
 // WarGear Handler
@@ -191,7 +194,11 @@ func (h *WarGearHandler) CreateWarGear(w http.ResponseWriter, r *http.Request) {
 
 	createdWarGear, err := h.service.CreateWarGear(r.Context(), &wargear)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
@@ -214,46 +221,26 @@ func (h *WarGearHandler) GetWarGear(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, wargear.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(wargear)
 }
 
 func (h *WarGearHandler) GetAllWarGear(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	skipStr := r.URL.Query().Get("skip")
-	name := r.URL.Query().Get("name")
-
-	limit := int64(50)
-	skip := int64(0)
-
-	if limitStr != "" {
-		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
-			limit = l
-		}
-	}
-
-	if skipStr != "" {
-		if s, err := strconv.ParseInt(skipStr, 10, 64); err == nil {
-			skip = s
-		}
-	}
-
-	var wargear []models.WarGear
-	var err error
-
-	if name != "" {
-		wargear, err = h.service.SearchWarGearByName(r.Context(), name, limit, skip)
-	} else {
-		wargear, err = h.service.GetAllWarGear(r.Context(), limit, skip)
+	opts, err := listOptionsFromRequest(r, 50)
+	if err != nil {
+		writeListError(w, err)
+		return
 	}
+	name := r.URL.Query().Get("name")
 
+	wargear, nextCursor, err := h.service.ListWarGear(r.Context(), name, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeListError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(wargear)
+	writeList(w, r, wargear, nextCursor)
 }
 
 func (h *WarGearHandler) UpdateWarGear(w http.ResponseWriter, r *http.Request) {
@@ -266,9 +253,19 @@ func (h *WarGearHandler) UpdateWarGear(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.service.UpdateWarGear(r.Context(), id, &wargear)
+	fromIfMatch, err := applyIfMatch(r, &wargear.Version)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.UpdateWarGear(r.Context(), id, &wargear)
+	if err != nil {
+		if utils.IsVersionConflictError(err) {
+			writeVersionConflict(w, err, fromIfMatch)
+		} else if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "WarGear not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -283,6 +280,7 @@ func (h *WarGearHandler) UpdateWarGear(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, updatedWarGear.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedWarGear)
 }
@@ -291,9 +289,17 @@ func (h *WarGearHandler) DeleteWarGear(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	err := h.service.DeleteWarGear(r.Context(), id)
+	policy, err := deletePolicyFromRequest(r)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.DeleteWarGearWithPolicy(r.Context(), id, policy)
+	if err != nil {
+		if services.IsReferencedError(err) {
+			writeReferencedError(w, err)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "WarGear not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -301,7 +307,7 @@ func (h *WarGearHandler) DeleteWarGear(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeDeleteReport(w, report)
 }
 
 // Unit Handler
@@ -324,7 +330,11 @@ func (h *UnitHandler) CreateUnit(w http.ResponseWriter, r *http.Request) {
 
 	createdUnit, err := h.service.CreateUnit(r.Context(), &unit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
@@ -347,46 +357,26 @@ func (h *UnitHandler) GetUnit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, unit.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(unit)
 }
 
 func (h *UnitHandler) GetUnits(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	skipStr := r.URL.Query().Get("skip")
-	name := r.URL.Query().Get("name")
-
-	limit := int64(50)
-	skip := int64(0)
-
-	if limitStr != "" {
-		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
-			limit = l
-		}
-	}
-
-	if skipStr != "" {
-		if s, err := strconv.ParseInt(skipStr, 10, 64); err == nil {
-			skip = s
-		}
-	}
-
-	var units []models.Unit
-	var err error
-
-	if name != "" {
-		units, err = h.service.SearchUnitsByName(r.Context(), name, limit, skip)
-	} else {
-		units, err = h.service.GetAllUnits(r.Context(), limit, skip)
+	opts, err := listOptionsFromRequest(r, 50)
+	if err != nil {
+		writeListError(w, err)
+		return
 	}
+	name := r.URL.Query().Get("name")
 
+	units, nextCursor, err := h.service.ListUnits(r.Context(), name, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeListError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(units)
+	writeList(w, r, units, nextCursor)
 }
 
 func (h *UnitHandler) UpdateUnit(w http.ResponseWriter, r *http.Request) {
@@ -399,9 +389,19 @@ func (h *UnitHandler) UpdateUnit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.service.UpdateUnit(r.Context(), id, &unit)
+	fromIfMatch, err := applyIfMatch(r, &unit.Version)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.UpdateUnit(r.Context(), id, &unit)
+	if err != nil {
+		if utils.IsVersionConflictError(err) {
+			writeVersionConflict(w, err, fromIfMatch)
+		} else if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Unit not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -416,6 +416,7 @@ func (h *UnitHandler) UpdateUnit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, updatedUnit.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedUnit)
 }
@@ -424,9 +425,17 @@ func (h *UnitHandler) DeleteUnit(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	err := h.service.DeleteUnit(r.Context(), id)
+	policy, err := deletePolicyFromRequest(r)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.DeleteUnitWithPolicy(r.Context(), id, policy)
+	if err != nil {
+		if services.IsReferencedError(err) {
+			writeReferencedError(w, err)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Unit not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -434,7 +443,7 @@ func (h *UnitHandler) DeleteUnit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeDeleteReport(w, report)
 }
 
 // ArmyBook Handler
@@ -457,7 +466,11 @@ func (h *ArmyBookHandler) CreateArmyBook(w http.ResponseWriter, r *http.Request)
 
 	createdArmyBook, err := h.service.CreateArmyBook(r.Context(), &armyBook)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
@@ -480,46 +493,26 @@ func (h *ArmyBookHandler) GetArmyBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, armyBook.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(armyBook)
 }
 
 func (h *ArmyBookHandler) GetArmyBooks(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	skipStr := r.URL.Query().Get("skip")
-	name := r.URL.Query().Get("name")
-
-	limit := int64(50)
-	skip := int64(0)
-
-	if limitStr != "" {
-		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
-			limit = l
-		}
-	}
-
-	if skipStr != "" {
-		if s, err := strconv.ParseInt(skipStr, 10, 64); err == nil {
-			skip = s
-		}
-	}
-
-	var armyBooks []models.ArmyBook
-	var err error
-
-	if name != "" {
-		armyBooks, err = h.service.SearchArmyBooksByName(r.Context(), name, limit, skip)
-	} else {
-		armyBooks, err = h.service.GetAllArmyBooks(r.Context(), limit, skip)
+	opts, err := listOptionsFromRequest(r, 50)
+	if err != nil {
+		writeListError(w, err)
+		return
 	}
+	name := r.URL.Query().Get("name")
 
+	armyBooks, nextCursor, err := h.service.ListArmyBooks(r.Context(), name, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeListError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(armyBooks)
+	writeList(w, r, armyBooks, nextCursor)
 }
 
 func (h *ArmyBookHandler) UpdateArmyBook(w http.ResponseWriter, r *http.Request) {
@@ -532,9 +525,19 @@ func (h *ArmyBookHandler) UpdateArmyBook(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := h.service.UpdateArmyBook(r.Context(), id, &armyBook)
+	fromIfMatch, err := applyIfMatch(r, &armyBook.Version)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.UpdateArmyBook(r.Context(), id, &armyBook)
+	if err != nil {
+		if utils.IsVersionConflictError(err) {
+			writeVersionConflict(w, err, fromIfMatch)
+		} else if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "ArmyBook not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -549,6 +552,7 @@ func (h *ArmyBookHandler) UpdateArmyBook(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	setETag(w, updatedArmyBook.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedArmyBook)
 }
@@ -590,7 +594,11 @@ func (h *ArmyListHandler) CreateArmyList(w http.ResponseWriter, r *http.Request)
 
 	createdArmyList, err := h.service.CreateArmyList(r.Context(), &armyList)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
@@ -613,46 +621,26 @@ func (h *ArmyListHandler) GetArmyList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, armyList.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(armyList)
 }
 
 func (h *ArmyListHandler) GetArmyLists(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	skipStr := r.URL.Query().Get("skip")
-	name := r.URL.Query().Get("name")
-
-	limit := int64(50)
-	skip := int64(0)
-
-	if limitStr != "" {
-		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
-			limit = l
-		}
-	}
-
-	if skipStr != "" {
-		if s, err := strconv.ParseInt(skipStr, 10, 64); err == nil {
-			skip = s
-		}
-	}
-
-	var armyLists []models.ArmyList
-	var err error
-
-	if name != "" {
-		armyLists, err = h.service.SearchArmyListsByName(r.Context(), name, limit, skip)
-	} else {
-		armyLists, err = h.service.GetAllArmyLists(r.Context(), limit, skip)
+	opts, err := listOptionsFromRequest(r, 50)
+	if err != nil {
+		writeListError(w, err)
+		return
 	}
+	name := r.URL.Query().Get("name")
 
+	armyLists, nextCursor, err := h.service.ListArmyLists(r.Context(), name, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeListError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(armyLists)
+	writeList(w, r, armyLists, nextCursor)
 }
 
 func (h *ArmyListHandler) UpdateArmyList(w http.ResponseWriter, r *http.Request) {
@@ -665,9 +653,19 @@ func (h *ArmyListHandler) UpdateArmyList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := h.service.UpdateArmyList(r.Context(), id, &armyList)
+	fromIfMatch, err := applyIfMatch(r, &armyList.Version)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.UpdateArmyList(r.Context(), id, &armyList)
+	if err != nil {
+		if utils.IsVersionConflictError(err) {
+			writeVersionConflict(w, err, fromIfMatch)
+		} else if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "ArmyList not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -682,6 +680,7 @@ func (h *ArmyListHandler) UpdateArmyList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	setETag(w, updatedArmyList.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedArmyList)
 }
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// multipartOverhead allows for the form's boundaries and headers on top of the file itself
+const multipartOverhead = 64 << 10
+
+type AssetHandler struct {
+	service *services.AssetService
+}
+
+func NewAssetHandler(service *services.AssetService) *AssetHandler {
+	return &AssetHandler{
+		service: service,
+	}
+}
+
+// UploadAsset handles POST /assets - a multipart form with the image in a "file" field
+func (h *AssetHandler) UploadAsset(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.service.MaxSize()+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected a multipart/form-data upload", http.StatusBadRequest)
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			http.Error(w, `Missing the "file" field`, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeAssetError(w, err)
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		asset, err := h.service.UploadAsset(r.Context(), part.FileName(), part.Header.Get("Content-Type"), part)
+		if err != nil {
+			writeAssetError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(asset)
+		return
+	}
+}
+
+// GetAsset handles GET /assets/{id} - the asset's metadata
+func (h *AssetHandler) GetAsset(w http.ResponseWriter, r *http.Request) {
+	asset, err := h.service.GetAsset(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeAssetError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(asset)
+}
+
+// DownloadAsset handles GET /assets/{id}/content
+func (h *AssetHandler) DownloadAsset(w http.ResponseWriter, r *http.Request) {
+	h.download(w, r, false)
+}
+
+// DownloadThumbnail handles GET /assets/{id}/thumbnail
+func (h *AssetHandler) DownloadThumbnail(w http.ResponseWriter, r *http.Request) {
+	h.download(w, r, true)
+}
+
+// download serves an asset's bytes. Assets never change, so clients may
+// cache them for as long as they like.
+func (h *AssetHandler) download(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	asset, data, err := h.service.ReadAsset(r.Context(), mux.Vars(r)["id"], thumbnail)
+	if err != nil {
+		writeAssetError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", asset.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": asset.Filename}))
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	http.ServeContent(w, r, asset.Filename, asset.CreatedAt, bytes.NewReader(data))
+}
+
+// DeleteAsset handles DELETE /assets/{id}
+func (h *AssetHandler) DeleteAsset(w http.ResponseWriter, r *http.Request) {
+	err := h.service.DeleteAsset(r.Context(), mux.Vars(r)["id"])
+	if services.IsReferencedError(err) {
+		var referencedErr services.ReferencedError
+		errors.As(err, &referencedErr)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":      err.Error(),
+			"references": referencedErr.References,
+			"hint":       "remove the asset from the referencing documents' assetIds first",
+		})
+		return
+	}
+	if err != nil {
+		writeAssetError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeAssetError maps asset service errors to HTTP responses
+func writeAssetError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, services.ErrAssetTooLarge), errors.As(err, &tooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case utils.IsValidationError(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, primitive.ErrInvalidHex):
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
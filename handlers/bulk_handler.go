@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// BulkUpdateRequest is the body of a bulk update. Filter takes the same
+// field:operator:value conditions as the list endpoints.
+type BulkUpdateRequest struct {
+	Filter  []string               `json:"filter"`
+	Set     map[string]interface{} `json:"set"`
+	Confirm string                 `json:"confirm"` // the confirmation of a dry run; empty for a dry run
+}
+
+// BulkDeleteRequest is the body of a bulk delete
+type BulkDeleteRequest struct {
+	Filter  []string `json:"filter"`
+	Policy  string   `json:"policy"` // reject (default), detach or cascade
+	Confirm string   `json:"confirm"`
+}
+
+type BulkHandler struct {
+	service *services.BulkService
+}
+
+func NewBulkHandler(service *services.BulkService) *BulkHandler {
+	return &BulkHandler{
+		service: service,
+	}
+}
+
+// BulkUpdate handles POST /{type}/bulk/update - sets fields on every entity
+// matching a filter. Without confirm it only reports what would change.
+func (h *BulkHandler) BulkUpdate(w http.ResponseWriter, r *http.Request) {
+	var req BulkUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := services.ParseFilter(req.Filter)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	report, err := h.service.Update(r.Context(), mux.Vars(r)["type"], filter, req.Set, req.Confirm)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// BulkDelete handles POST /{type}/bulk/delete - moves every entity matching a
+// filter to the trash. Without confirm it only reports what would happen.
+func (h *BulkHandler) BulkDelete(w http.ResponseWriter, r *http.Request) {
+	var req BulkDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := services.ParseFilter(req.Filter)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	policy, err := services.ParseDeletePolicy(req.Policy)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	report, err := h.service.Delete(r.Context(), mux.Vars(r)["type"], filter, policy, req.Confirm)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *BulkHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case utils.IsValidationError(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrBulkPlanChanged):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
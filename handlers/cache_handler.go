@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"grimdank-database/services"
+)
+
+type CacheHandler struct {
+	caches *services.Caches
+}
+
+func NewCacheHandler(caches *services.Caches) *CacheHandler {
+	return &CacheHandler{
+		caches: caches,
+	}
+}
+
+// GetStats handles GET /cache/stats - reports the hits and misses of each cache
+func (h *CacheHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.caches.Stats())
+}
+
+// Flush handles POST /admin/cache/flush - empties every cache, e.g. after
+// restoring a snapshot from the command line
+func (h *CacheHandler) Flush(w http.ResponseWriter, r *http.Request) {
+	h.caches.Flush()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"grimdank-database/services"
+)
+
+// deletePolicyFromRequest reads the ?policy=reject|cascade|detach parameter of a delete
+func deletePolicyFromRequest(r *http.Request) (services.DeletePolicy, error) {
+	return services.ParseDeletePolicy(r.URL.Query().Get("policy"))
+}
+
+// writeDeleteReport answers a successful delete. Nothing else changed when the
+// report is empty, so it keeps the plain 204; otherwise the detached or cascaded
+// references are returned.
+func writeDeleteReport(w http.ResponseWriter, report *services.DeleteReport) {
+	if len(report.Detached) == 0 && len(report.Cascaded) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// writeReferencedError answers a rejected delete with 409 and the documents that block it
+func writeReferencedError(w http.ResponseWriter, err error) {
+	var referencedErr services.ReferencedError
+	errors.As(err, &referencedErr)
+
+	response := map[string]interface{}{
+		"error":      err.Error(),
+		"references": referencedErr.References,
+		"hint":       "retry with ?policy=detach to remove the references or ?policy=cascade to delete the referencing documents",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"grimdank-database/utils"
+)
+
+// setETag advertises the entity version so clients can send it back in If-Match.
+// Documents written before versioning was introduced have no version and get no ETag.
+func setETag(w http.ResponseWriter, version int) {
+	if version > 0 {
+		w.Header().Set("ETag", strconv.Quote(strconv.Itoa(version)))
+	}
+}
+
+// applyIfMatch copies the version from an If-Match header onto the expected
+// version of an update, taking precedence over any version in the body.
+// It reports whether a version was taken from the header so that a mismatch
+// can be answered with 412 instead of 409.
+func applyIfMatch(r *http.Request, version *int) (bool, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return false, nil
+	}
+
+	if strings.Contains(header, ",") {
+		return false, errors.New("If-Match must contain a single ETag")
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		return false, fmt.Errorf("invalid If-Match header: %s", header)
+	}
+
+	expected, err := strconv.Atoi(unquoted)
+	if err != nil || expected < 1 {
+		return false, fmt.Errorf("invalid If-Match header: %s", header)
+	}
+
+	*version = expected
+	return true, nil
+}
+
+// writeVersionConflict reports an update made against a stale version.
+// Conditional requests get 412 Precondition Failed, body versions get 409 Conflict.
+func writeVersionConflict(w http.ResponseWriter, err error, fromIfMatch bool) {
+	var conflictErr utils.VersionConflictError
+	if errors.As(err, &conflictErr) {
+		setETag(w, conflictErr.CurrentVersion)
+	}
+
+	status := http.StatusConflict
+	if fromIfMatch {
+		status = http.StatusPreconditionFailed
+	}
+	http.Error(w, err.Error(), status)
+}
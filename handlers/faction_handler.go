@@ -4,13 +4,13 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
 
 	"grimdank-database/models"
 	"grimdank-database/services"
+	"grimdank-database/utils"
 )
 
 type FactionHandler struct {
@@ -32,7 +32,13 @@ func (h *FactionHandler) CreateFaction(w http.ResponseWriter, r *http.Request) {
 
 	createdFaction, err := h.service.CreateFaction(r.Context(), &faction)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if utils.IsForbiddenError(err) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
@@ -54,48 +60,27 @@ func (h *FactionHandler) GetFaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, faction.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(faction)
 }
 
 func (h *FactionHandler) GetFactions(w http.ResponseWriter, r *http.Request) {
 	log.Println("GetFactions handler called!")
-	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	skipStr := r.URL.Query().Get("skip")
-	name := r.URL.Query().Get("name")
-
-	limit := 0
-	skip := 0
-
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
-			limit = parsedLimit
-		}
-	}
-
-	if skipStr != "" {
-		if parsedSkip, err := strconv.Atoi(skipStr); err == nil {
-			skip = parsedSkip
-		}
-	}
-
-	var factions []models.Faction
-	var err error
-
-	if name != "" {
-		factions, err = h.service.SearchFactionsByName(r.Context(), name, limit, skip)
-	} else {
-		factions, err = h.service.GetAllFactions(r.Context(), limit, skip)
+	opts, err := listOptionsFromRequest(r, 0)
+	if err != nil {
+		writeListError(w, err)
+		return
 	}
+	name := r.URL.Query().Get("name")
 
+	factions, nextCursor, err := h.service.ListFactions(r.Context(), name, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeListError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(factions)
+	writeList(w, r, factions, nextCursor)
 }
 
 func (h *FactionHandler) UpdateFaction(w http.ResponseWriter, r *http.Request) {
@@ -108,9 +93,21 @@ func (h *FactionHandler) UpdateFaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.service.UpdateFaction(r.Context(), id, &faction)
+	fromIfMatch, err := applyIfMatch(r, &faction.Version)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.UpdateFaction(r.Context(), id, &faction)
+	if err != nil {
+		if utils.IsVersionConflictError(err) {
+			writeVersionConflict(w, err, fromIfMatch)
+		} else if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if utils.IsForbiddenError(err) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Faction not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -125,6 +122,7 @@ func (h *FactionHandler) UpdateFaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, updatedFaction.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedFaction)
 }
@@ -135,7 +133,9 @@ func (h *FactionHandler) DeleteFaction(w http.ResponseWriter, r *http.Request) {
 
 	err := h.service.DeleteFaction(r.Context(), id)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if utils.IsForbiddenError(err) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Faction not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"grimdank-database/models"
 	"grimdank-database/services"
+	"grimdank-database/utils"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -20,6 +23,7 @@ type ImportHandler struct {
 	armyBookService *services.ArmyBookService
 	armyListService *services.ArmyListService
 	factionService  *services.FactionService
+	unitOfWork      services.UnitOfWork
 }
 
 func NewImportHandler(
@@ -30,6 +34,7 @@ func NewImportHandler(
 	armyBookService *services.ArmyBookService,
 	armyListService *services.ArmyListService,
 	factionService *services.FactionService,
+	unitOfWork services.UnitOfWork,
 ) *ImportHandler {
 	return &ImportHandler{
 		ruleService:     ruleService,
@@ -39,9 +44,29 @@ func NewImportHandler(
 		armyBookService: armyBookService,
 		armyListService: armyListService,
 		factionService:  factionService,
+		unitOfWork:      unitOfWork,
 	}
 }
 
+// runImport runs an import. By default documents are inserted in order and
+// a failure keeps the ones before it; with ?atomic=true the import is all
+// or nothing and a failure leaves the collection as it was.
+func (h *ImportHandler) runImport(r *http.Request, run func(ctx context.Context) error) error {
+	atomic := false
+	if value := r.URL.Query().Get("atomic"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return utils.NewValidationError("atomic", "must be true or false")
+		}
+		atomic = parsed
+	}
+
+	if !atomic {
+		return run(r.Context())
+	}
+	return h.unitOfWork.Do(r.Context(), run)
+}
+
 // Import Rules
 func (h *ImportHandler) ImportRules(w http.ResponseWriter, r *http.Request) {
 	var rules []models.Rule
@@ -50,7 +75,11 @@ func (h *ImportHandler) ImportRules(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	importedIDs, err := h.ruleService.BulkImportRules(r.Context(), rules)
+	var importedIDs []string
+	err := h.runImport(r, func(ctx context.Context) (err error) {
+		importedIDs, err = h.ruleService.BulkImportRules(ctx, rules)
+		return err
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -75,7 +104,11 @@ func (h *ImportHandler) ImportWeapons(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	importedIDs, err := h.weaponService.BulkImportWeapons(r.Context(), weapons)
+	var importedIDs []string
+	err := h.runImport(r, func(ctx context.Context) (err error) {
+		importedIDs, err = h.weaponService.BulkImportWeapons(ctx, weapons)
+		return err
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -100,7 +133,11 @@ func (h *ImportHandler) ImportWarGear(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	importedIDs, err := h.wargearService.BulkImportWarGear(r.Context(), wargear)
+	var importedIDs []string
+	err := h.runImport(r, func(ctx context.Context) (err error) {
+		importedIDs, err = h.wargearService.BulkImportWarGear(ctx, wargear)
+		return err
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -125,7 +162,11 @@ func (h *ImportHandler) ImportUnits(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	importedIDs, err := h.unitService.BulkImportUnits(r.Context(), units)
+	var importedIDs []string
+	err := h.runImport(r, func(ctx context.Context) (err error) {
+		importedIDs, err = h.unitService.BulkImportUnits(ctx, units)
+		return err
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -150,7 +191,11 @@ func (h *ImportHandler) ImportArmyBooks(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	importedIDs, err := h.armyBookService.BulkImportArmyBooks(r.Context(), armyBooks)
+	var importedIDs []string
+	err := h.runImport(r, func(ctx context.Context) (err error) {
+		importedIDs, err = h.armyBookService.BulkImportArmyBooks(ctx, armyBooks)
+		return err
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -175,7 +220,11 @@ func (h *ImportHandler) ImportArmyLists(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	importedIDs, err := h.armyListService.BulkImportArmyLists(r.Context(), armyLists)
+	var importedIDs []string
+	err := h.runImport(r, func(ctx context.Context) (err error) {
+		importedIDs, err = h.armyListService.BulkImportArmyLists(ctx, armyLists)
+		return err
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -200,7 +249,11 @@ func (h *ImportHandler) ImportFactions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	importedIDs, err := h.factionService.BulkImportFactions(r.Context(), factions)
+	var importedIDs []primitive.ObjectID
+	err := h.runImport(r, func(ctx context.Context) (err error) {
+		importedIDs, err = h.factionService.BulkImportFactions(ctx, factions)
+		return err
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"grimdank-database/services"
+	"grimdank-database/utils"
+)
+
+// listOptionsFromRequest reads the limit, skip, sort, cursor and filter query
+// parameters. Limit and skip values that don't parse fall back to the defaults;
+// a malformed filter is an error.
+func listOptionsFromRequest(r *http.Request, defaultLimit int64) (services.ListOptions, error) {
+	query := r.URL.Query()
+	opts := services.ListOptions{Limit: defaultLimit, Cursor: query.Get("cursor")}
+
+	if l, err := strconv.ParseInt(query.Get("limit"), 10, 64); err == nil {
+		opts.Limit = l
+	}
+	if s, err := strconv.ParseInt(query.Get("skip"), 10, 64); err == nil {
+		opts.Skip = s
+	}
+	opts.Sort, opts.Descending = services.ParseSort(query.Get("sort"))
+
+	filter, err := services.ParseFilter(query["filter"])
+	if err != nil {
+		return opts, err
+	}
+	opts.Filter = filter
+
+	return opts, nil
+}
+
+// writeList answers a list request. The next cursor is always sent in the
+// X-Next-Cursor header; requests that page with ?cursor= (empty for the first
+// page) get {data, nextCursor} instead of the bare array.
+func writeList(w http.ResponseWriter, r *http.Request, items interface{}, nextCursor string) {
+	if nextCursor != "" {
+		w.Header().Set("X-Next-Cursor", nextCursor)
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	if !r.URL.Query().Has("cursor") {
+		json.NewEncoder(w).Encode(items)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":       items,
+		"nextCursor": nextCursor,
+	})
+}
+
+// writeListError answers a failed list request; bad sort, cursor or filter values are the client's fault
+func writeListError(w http.ResponseWriter, err error) {
+	if utils.IsValidationError(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -24,8 +24,9 @@ func NewPointsHandler(rulePointsService *services.RulePointsService) *PointsHand
 
 // CalculatePointsRequest represents a request to calculate points
 type CalculatePointsRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Effects     []models.RuleEffect `json:"effects"`
 }
 
 // CalculatePointsResponse represents the response from points calculation
@@ -41,11 +42,16 @@ func (h *PointsHandler) CalculatePoints(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
 		return
 	}
+	if err := services.ValidateRuleEffects(req.Effects); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Create a temporary rule for calculation
 	rule := &models.Rule{
 		Name:        req.Name,
 		Description: req.Description,
+		Effects:     req.Effects,
 	}
 
 	// Calculate points
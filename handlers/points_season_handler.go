@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"grimdank-database/models"
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type PointsSeasonHandler struct {
+	service *services.PointsSeasonService
+}
+
+func NewPointsSeasonHandler(service *services.PointsSeasonService) *PointsSeasonHandler {
+	return &PointsSeasonHandler{
+		service: service,
+	}
+}
+
+// GetSeasons handles GET /points/seasons - lists every season, flagging the current one
+func (h *PointsSeasonHandler) GetSeasons(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.service.ListSeasons())
+}
+
+// GetSeason handles GET /points/seasons/{name}
+func (h *PointsSeasonHandler) GetSeason(w http.ResponseWriter, r *http.Request) {
+	season, err := h.service.Season(mux.Vars(r)["name"])
+	writeSeason(w, season, err, false)
+}
+
+// CreateSeason handles POST /admin/points/seasons - adds a season, which
+// becomes current if the body says so
+func (h *PointsSeasonHandler) CreateSeason(w http.ResponseWriter, r *http.Request) {
+	var season models.PointsSeason
+	if err := json.NewDecoder(r.Body).Decode(&season); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.CreateSeason(r.Context(), &season)
+	if err != nil {
+		writeSeason(w, nil, err, false)
+		return
+	}
+
+	setETag(w, created.Version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// UpdateSeason handles PUT /admin/points/seasons/{name} - replaces a season's
+// settings, taking effect with the next calculation
+func (h *PointsSeasonHandler) UpdateSeason(w http.ResponseWriter, r *http.Request) {
+	var season models.PointsSeason
+	if err := json.NewDecoder(r.Body).Decode(&season); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	fromIfMatch, err := applyIfMatch(r, &season.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.service.UpdateSeason(r.Context(), mux.Vars(r)["name"], &season)
+	writeSeason(w, updated, err, fromIfMatch)
+}
+
+// ActivateSeason handles POST /admin/points/seasons/{name}/activate - makes
+// the season the current one
+func (h *PointsSeasonHandler) ActivateSeason(w http.ResponseWriter, r *http.Request) {
+	season, err := h.service.ActivateSeason(r.Context(), mux.Vars(r)["name"])
+	writeSeason(w, season, err, false)
+}
+
+// DeleteSeason handles DELETE /admin/points/seasons/{name}
+func (h *PointsSeasonHandler) DeleteSeason(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteSeason(r.Context(), mux.Vars(r)["name"]); err != nil {
+		writeSeason(w, nil, err, false)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReloadSeasons handles POST /admin/points/seasons/reload - picks up seasons
+// edited directly in the database without waiting for the next reload
+func (h *PointsSeasonHandler) ReloadSeasons(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Reload(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.GetSeasons(w, r)
+}
+
+func writeSeason(w http.ResponseWriter, season *models.PointsSeason, err error, fromIfMatch bool) {
+	switch {
+	case err == nil:
+		setETag(w, season.Version)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(season)
+	case utils.IsValidationError(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case utils.IsVersionConflictError(err):
+		writeVersionConflict(w, err, fromIfMatch)
+	case utils.IsDuplicateError(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"grimdank-database/services"
+
+	"github.com/gorilla/mux"
+)
+
+type PopulatedArmyHandler struct {
+	armyBookService   *services.ArmyBookService
+	armyListService   *services.ArmyListService
+	populationService *services.PopulationService
+}
+
+func NewPopulatedArmyHandler(armyBookService *services.ArmyBookService, armyListService *services.ArmyListService, populationService *services.PopulationService) *PopulatedArmyHandler {
+	return &PopulatedArmyHandler{
+		armyBookService:   armyBookService,
+		armyListService:   armyListService,
+		populationService: populationService,
+	}
+}
+
+// GetPopulatedArmyBook handles GET /armybooks/{id}/populated - returns an army
+// book with its units and rules in place of their IDs
+func (h *PopulatedArmyHandler) GetPopulatedArmyBook(w http.ResponseWriter, r *http.Request) {
+	armyBook, err := h.armyBookService.GetArmyBookByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeAttachError(w, err)
+		return
+	}
+
+	populated, err := h.populationService.PopulateArmyBook(r.Context(), armyBook)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(populated)
+}
+
+// GetPopulatedArmyList handles GET /armylists/{id}/populated - returns an army
+// list with its units in place of their IDs
+func (h *PopulatedArmyHandler) GetPopulatedArmyList(w http.ResponseWriter, r *http.Request) {
+	armyList, err := h.armyListService.GetArmyListByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeAttachError(w, err)
+		return
+	}
+
+	populated, err := h.populationService.PopulateArmyList(r.Context(), armyList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(populated)
+}
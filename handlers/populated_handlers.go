@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"grimdank-database/models"
 	"grimdank-database/services"
+	"grimdank-database/utils"
 
 	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type PopulatedWeaponHandler struct {
@@ -86,38 +89,13 @@ func (h *PopulatedWeaponHandler) AddRuleToWeapon(w http.ResponseWriter, r *http.
 		return
 	}
 
-	err := h.populationService.AddRuleToWeapon(r.Context(), weaponID, request.RuleID, request.Tier)
+	populatedWeapon, err := h.populationService.AddRuleToWeapon(r.Context(), weaponID, request.RuleID, request.Tier)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Return updated weapon with rules
-	weapon, err := h.weaponService.GetWeaponByID(r.Context(), weaponID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	populatedWeapon, err := h.populationService.PopulateWeaponRules(r.Context(), weapon)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeAttachError(w, err)
 		return
 	}
 
-	totalPoints, err := h.populationService.CalculateTotalPoints(r.Context(), weapon)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	response := map[string]interface{}{
-		"weapon":      populatedWeapon,
-		"totalPoints": totalPoints,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.writeWeaponWithPoints(w, r, populatedWeapon)
 }
 
 // RemoveRuleFromWeapon removes a rule from a weapon
@@ -126,26 +104,18 @@ func (h *PopulatedWeaponHandler) RemoveRuleFromWeapon(w http.ResponseWriter, r *
 	weaponID := vars["id"]
 	ruleID := vars["ruleId"]
 
-	err := h.populationService.RemoveRuleFromWeapon(r.Context(), weaponID, ruleID)
+	populatedWeapon, err := h.populationService.RemoveRuleFromWeapon(r.Context(), weaponID, ruleID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeAttachError(w, err)
 		return
 	}
 
-	// Return updated weapon
-	weapon, err := h.weaponService.GetWeaponByID(r.Context(), weaponID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	populatedWeapon, err := h.populationService.PopulateWeaponRules(r.Context(), weapon)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	h.writeWeaponWithPoints(w, r, populatedWeapon)
+}
 
-	totalPoints, err := h.populationService.CalculateTotalPoints(r.Context(), weapon)
+// writeWeaponWithPoints responds with a weapon and its total points including rules
+func (h *PopulatedWeaponHandler) writeWeaponWithPoints(w http.ResponseWriter, r *http.Request, populatedWeapon *models.PopulatedWeapon) {
+	totalPoints, err := h.populationService.CalculateTotalPoints(r.Context(), &populatedWeapon.Weapon)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -218,3 +188,19 @@ func (h *PopulatedWeaponHandler) GetWeaponsWithRules(w http.ResponseWriter, r *h
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(populatedWeapons)
 }
+
+// writeAttachError maps the errors of attaching and detaching references to status codes
+func writeAttachError(w http.ResponseWriter, err error) {
+	switch {
+	case utils.IsValidationError(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, primitive.ErrInvalidHex):
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+	case utils.IsAlreadyAttachedError(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
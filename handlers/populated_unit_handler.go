@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"grimdank-database/models"
+	"grimdank-database/services"
+)
+
+type PopulatedUnitHandler struct {
+	populationService *services.PopulationService
+}
+
+func NewPopulatedUnitHandler(populationService *services.PopulationService) *PopulatedUnitHandler {
+	return &PopulatedUnitHandler{
+		populationService: populationService,
+	}
+}
+
+// GetPopulatedUnit handles GET /units/{id}/populated - returns a unit with its
+// rules, weapons and wargear in place of their IDs
+func (h *PopulatedUnitHandler) GetPopulatedUnit(w http.ResponseWriter, r *http.Request) {
+	unit, err := h.populationService.GetPopulatedUnit(r.Context(), mux.Vars(r)["id"])
+	writeUnit(w, unit, err)
+}
+
+// AddRuleToUnit handles POST /units/{id}/rules - attaches a rule at a tier
+func (h *PopulatedUnitHandler) AddRuleToUnit(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		RuleID string `json:"ruleId"`
+		Tier   int    `json:"tier"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	unit, err := h.populationService.AddRuleToUnit(r.Context(), mux.Vars(r)["id"], request.RuleID, request.Tier)
+	writeUnit(w, unit, err)
+}
+
+// RemoveRuleFromUnit handles DELETE /units/{id}/rules/{ruleId}
+func (h *PopulatedUnitHandler) RemoveRuleFromUnit(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	unit, err := h.populationService.RemoveRuleFromUnit(r.Context(), vars["id"], vars["ruleId"])
+	writeUnit(w, unit, err)
+}
+
+// AddWeaponToUnit handles POST /units/{id}/weapons - makes a weapon available to the unit
+func (h *PopulatedUnitHandler) AddWeaponToUnit(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		WeaponID string `json:"weaponId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	unit, err := h.populationService.AddWeaponToUnit(r.Context(), mux.Vars(r)["id"], request.WeaponID)
+	writeUnit(w, unit, err)
+}
+
+// RemoveWeaponFromUnit handles DELETE /units/{id}/weapons/{weaponId} - also unequips the weapon
+func (h *PopulatedUnitHandler) RemoveWeaponFromUnit(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	unit, err := h.populationService.RemoveWeaponFromUnit(r.Context(), vars["id"], vars["weaponId"])
+	writeUnit(w, unit, err)
+}
+
+// AddWarGearToUnit handles POST /units/{id}/wargear - makes a wargear item available to the unit
+func (h *PopulatedUnitHandler) AddWarGearToUnit(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		WarGearID string `json:"wargearId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	unit, err := h.populationService.AddWarGearToUnit(r.Context(), mux.Vars(r)["id"], request.WarGearID)
+	writeUnit(w, unit, err)
+}
+
+// RemoveWarGearFromUnit handles DELETE /units/{id}/wargear/{wargearId} - also unequips the item
+func (h *PopulatedUnitHandler) RemoveWarGearFromUnit(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	unit, err := h.populationService.RemoveWarGearFromUnit(r.Context(), vars["id"], vars["wargearId"])
+	writeUnit(w, unit, err)
+}
+
+func writeUnit(w http.ResponseWriter, unit *models.PopulatedUnit, err error) {
+	if err != nil {
+		writeAttachError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(unit)
+}
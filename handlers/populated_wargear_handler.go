@@ -89,22 +89,9 @@ func (h *PopulatedWarGearHandler) AddRuleToWarGear(w http.ResponseWriter, r *htt
 		return
 	}
 
-	err := h.populationService.AddRuleToWarGear(r.Context(), wargearID, request.RuleID, request.Tier)
+	populatedWarGear, err := h.populationService.AddRuleToWarGear(r.Context(), wargearID, request.RuleID, request.Tier)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Return updated wargear with rules
-	wargear, err := h.wargearService.GetWarGearByID(r.Context(), wargearID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	populatedWarGear, err := h.populationService.PopulateWarGearRules(r.Context(), wargear)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeAttachError(w, err)
 		return
 	}
 
@@ -125,22 +112,9 @@ func (h *PopulatedWarGearHandler) RemoveRuleFromWarGear(w http.ResponseWriter, r
 		return
 	}
 
-	err = h.populationService.RemoveRuleFromWarGear(r.Context(), wargearID, ruleObjectID)
+	populatedWarGear, err := h.populationService.RemoveRuleFromWarGear(r.Context(), wargearID, ruleObjectID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Return updated wargear with rules
-	wargear, err := h.wargearService.GetWarGearByID(r.Context(), wargearID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	populatedWarGear, err := h.populationService.PopulateWarGearRules(r.Context(), wargear)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeAttachError(w, err)
 		return
 	}
 
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RecalculationHandler struct {
+	service *services.RecalculationService
+}
+
+func NewRecalculationHandler(service *services.RecalculationService) *RecalculationHandler {
+	return &RecalculationHandler{
+		service: service,
+	}
+}
+
+// RecalculateEverything handles POST /points/recalculate
+func (h *RecalculationHandler) RecalculateEverything(w http.ResponseWriter, r *http.Request) {
+	report, err := h.service.RecalculateEverything(r.Context())
+	writeRecalculationReport(w, report, err)
+}
+
+// Recalculate handles POST /points/recalculate/{type}/{id} - the entity and
+// everything whose points depend on it
+func (h *RecalculationHandler) Recalculate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	report, err := h.service.Recalculate(r.Context(), vars["type"], vars["id"])
+	writeRecalculationReport(w, report, err)
+}
+
+func writeRecalculationReport(w http.ResponseWriter, report *services.RecalculationReport, err error) {
+	if err != nil {
+		switch {
+		case utils.IsValidationError(err):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, primitive.ErrInvalidHex):
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
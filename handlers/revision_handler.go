@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Headers that say who makes a change and why; they end up in the revision history
+const (
+	ActorHeader        = "X-Actor"
+	ChangeReasonHeader = "X-Change-Reason"
+)
+
+// WithChange is middleware that attributes the writes of a request to the
+// actor and reason given in its X-Actor and X-Change-Reason headers
+func WithChange(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := strings.TrimSpace(r.Header.Get(ActorHeader))
+		reason := strings.TrimSpace(r.Header.Get(ChangeReasonHeader))
+		next.ServeHTTP(w, r.WithContext(services.WithChange(r.Context(), actor, reason)))
+	})
+}
+
+type RevisionHandler struct {
+	service *services.RevisionService
+}
+
+func NewRevisionHandler(service *services.RevisionService) *RevisionHandler {
+	return &RevisionHandler{
+		service: service,
+	}
+}
+
+// GetRevisions handles GET /revisions/{type}/{id} - lists an entity's revisions, newest first
+func (h *RevisionHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	limit := int64(50) // default limit
+	skip := int64(0)   // default skip
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
+			limit = l
+		}
+	}
+
+	if skipStr := r.URL.Query().Get("skip"); skipStr != "" {
+		if s, err := strconv.ParseInt(skipStr, 10, 64); err == nil {
+			skip = s
+		}
+	}
+
+	revisions, err := h.service.ListRevisions(r.Context(), vars["type"], vars["id"], limit, skip)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// GetRevision handles GET /revisions/{type}/{id}/{version}
+func (h *RevisionHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	version, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	revision, err := h.service.GetRevision(r.Context(), vars["type"], vars["id"], version)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revision)
+}
+
+// DiffRevisions handles GET /revisions/{type}/{id}/diff?from=&to= - the fields
+// that changed between two versions
+func (h *RevisionHandler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "from must be a version number", http.StatusBadRequest)
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "to must be a version number", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := h.service.Diff(r.Context(), vars["type"], vars["id"], from, to)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// RevertToRevision handles POST /revisions/{type}/{id}/{version}/revert and
+// responds with the revision the revert created
+func (h *RevisionHandler) RevertToRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	version, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	revision, err := h.service.Revert(r.Context(), vars["type"], vars["id"], version)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	setETag(w, revision.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revision)
+}
+
+func (h *RevisionHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case utils.IsValidationError(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, primitive.ErrInvalidHex):
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+	case utils.IsDuplicateError(err), utils.IsVersionConflictError(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
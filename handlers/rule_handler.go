@@ -3,11 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"grimdank-database/models"
 	"grimdank-database/services"
+	"grimdank-database/utils"
 
 	"github.com/gorilla/mux"
 )
@@ -31,7 +31,11 @@ func (h *RuleHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
 
 	createdRule, err := h.service.CreateRule(r.Context(), &rule)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
@@ -54,47 +58,26 @@ func (h *RuleHandler) GetRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, rule.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(rule)
 }
 
 func (h *RuleHandler) GetRules(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	skipStr := r.URL.Query().Get("skip")
-	name := r.URL.Query().Get("name")
-
-	limit := int64(50) // default limit
-	skip := int64(0)   // default skip
-
-	if limitStr != "" {
-		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
-			limit = l
-		}
-	}
-
-	if skipStr != "" {
-		if s, err := strconv.ParseInt(skipStr, 10, 64); err == nil {
-			skip = s
-		}
-	}
-
-	var rules []models.Rule
-	var err error
-
-	if name != "" {
-		rules, err = h.service.SearchRulesByName(r.Context(), name, limit, skip)
-	} else {
-		rules, err = h.service.GetAllRules(r.Context(), limit, skip)
+	opts, err := listOptionsFromRequest(r, 50)
+	if err != nil {
+		writeListError(w, err)
+		return
 	}
+	name := r.URL.Query().Get("name")
 
+	rules, nextCursor, err := h.service.ListRules(r.Context(), name, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeListError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(rules)
+	writeList(w, r, rules, nextCursor)
 }
 
 func (h *RuleHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
@@ -107,9 +90,19 @@ func (h *RuleHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.service.UpdateRule(r.Context(), id, &rule)
+	fromIfMatch, err := applyIfMatch(r, &rule.Version)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.UpdateRule(r.Context(), id, &rule)
+	if err != nil {
+		if utils.IsVersionConflictError(err) {
+			writeVersionConflict(w, err, fromIfMatch)
+		} else if utils.IsDuplicateError(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Rule not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -124,6 +117,7 @@ func (h *RuleHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, updatedRule.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedRule)
 }
@@ -132,9 +126,17 @@ func (h *RuleHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	err := h.service.DeleteRule(r.Context(), id)
+	policy, err := deletePolicyFromRequest(r)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.DeleteRuleWithPolicy(r.Context(), id, policy)
+	if err != nil {
+		if services.IsReferencedError(err) {
+			writeReferencedError(w, err)
+		} else if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Rule not found", http.StatusNotFound)
 		} else {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -142,5 +144,5 @@ func (h *RuleHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeDeleteReport(w, report)
 }
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"grimdank-database/services"
+	"grimdank-database/utils"
+)
+
+type SearchHandler struct {
+	service *services.SearchService
+}
+
+func NewSearchHandler(service *services.SearchService) *SearchHandler {
+	return &SearchHandler{
+		service: service,
+	}
+}
+
+// Search handles GET /search?q= - full-text search across names and descriptions.
+// types takes a comma-separated list of entity types to narrow the search.
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 0 // service default
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 0 {
+			http.Error(w, "limit must be a non-negative whole number", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	var types []string
+	if typesStr := query.Get("types"); typesStr != "" {
+		for _, entityType := range strings.Split(typesStr, ",") {
+			if entityType = strings.TrimSpace(entityType); entityType != "" {
+				types = append(types, entityType)
+			}
+		}
+	}
+
+	results, err := h.service.Search(r.Context(), query.Get("q"), types, limit)
+	if err != nil {
+		if utils.IsValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"grimdank-database/services"
+	"grimdank-database/utils"
+)
+
+// MaxSnapshotSize is the largest archive the restore and verify endpoints accept
+const MaxSnapshotSize = 512 << 20
+
+// RequireAdminToken is middleware that only lets requests through that carry
+// token as a bearer token in their Authorization header
+func RequireAdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Admin token required", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type SnapshotHandler struct {
+	service *services.SnapshotService
+	// caches are flushed after a restore, which bypasses the services
+	caches *services.Caches
+}
+
+func NewSnapshotHandler(service *services.SnapshotService, caches *services.Caches) *SnapshotHandler {
+	return &SnapshotHandler{
+		service: service,
+		caches:  caches,
+	}
+}
+
+// ExportSnapshot handles GET /admin/snapshot - downloads every collection as a
+// single archive
+func (h *SnapshotHandler) ExportSnapshot(w http.ResponseWriter, r *http.Request) {
+	// Build the archive first so a failed export is an error rather than a truncated download
+	var archive bytes.Buffer
+	manifest, err := h.service.Export(r.Context(), &archive)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("grimdank-snapshot-%s.tar.gz", manifest.CreatedAt.Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(archive.Len()))
+	archive.WriteTo(w)
+}
+
+// VerifySnapshot handles POST /admin/snapshot/verify - checks an archive sent
+// as the request body and returns its manifest
+func (h *SnapshotHandler) VerifySnapshot(w http.ResponseWriter, r *http.Request) {
+	archive, ok := readArchive(w, r)
+	if !ok {
+		return
+	}
+
+	manifest, err := h.service.Verify(archive)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// RestoreSnapshot handles POST /admin/snapshot/restore?mode=&dryRun= - restores
+// an archive sent as the request body. mode is merge (default) or replace.
+func (h *SnapshotHandler) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	mode, err := services.ParseRestoreMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "dryRun must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	archive, ok := readArchive(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.Restore(r.Context(), archive, mode, dryRun)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if !dryRun {
+		h.caches.Flush()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// readArchive reads the archive in the request body, writing an error response
+// if it cannot
+func readArchive(w http.ResponseWriter, r *http.Request) (*bytes.Reader, bool) {
+	archive, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxSnapshotSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Archive is larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Failed to read archive", http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return bytes.NewReader(archive), true
+}
+
+func (h *SnapshotHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case utils.IsValidationError(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
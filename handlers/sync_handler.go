@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"grimdank-database/services"
+)
+
+type SyncHandler struct {
+	service *services.SyncService
+}
+
+func NewSyncHandler(service *services.SyncService) *SyncHandler {
+	return &SyncHandler{
+		service: service,
+	}
+}
+
+// GetChanges handles GET /sync?since= - everything created, updated or deleted
+// after since, an RFC 3339 timestamp. Without since it returns everything.
+// Clients pass the serverTime of the response as since on their next sync.
+func (h *SyncHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, sinceStr)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp, e.g. 2024-01-02T15:04:05Z", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	changes, err := h.service.ChangesSince(r.Context(), since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
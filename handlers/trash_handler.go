@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TrashHandler struct {
+	service *services.TrashService
+}
+
+func NewTrashHandler(service *services.TrashService) *TrashHandler {
+	return &TrashHandler{
+		service: service,
+	}
+}
+
+// GetTrash handles GET /trash/{type} - lists deleted entities of one type
+func (h *TrashHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	entityType := mux.Vars(r)["type"]
+
+	limit := int64(50) // default limit
+	skip := int64(0)   // default skip
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
+			limit = l
+		}
+	}
+
+	if skipStr := r.URL.Query().Get("skip"); skipStr != "" {
+		if s, err := strconv.ParseInt(skipStr, 10, 64); err == nil {
+			skip = s
+		}
+	}
+
+	items, total, err := h.service.ListTrash(r.Context(), entityType, limit, skip)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"type":          entityType,
+		"items":         items,
+		"total":         total,
+		"retentionDays": int(h.service.Retention().Hours() / 24),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RestoreFromTrash handles POST /trash/{type}/{id}/restore
+func (h *TrashHandler) RestoreFromTrash(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.service.Restore(r.Context(), vars["type"], vars["id"]); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PurgeFromTrash handles DELETE /trash/{type}/{id} - permanently deletes one entity
+func (h *TrashHandler) PurgeFromTrash(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.service.Purge(r.Context(), vars["type"], vars["id"]); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PurgeExpired handles POST /trash/purge - permanently deletes everything past retention
+func (h *TrashHandler) PurgeExpired(w http.ResponseWriter, r *http.Request) {
+	purged, err := h.service.PurgeExpired(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"purged":        purged,
+		"retentionDays": int(h.service.Retention().Hours() / 24),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *TrashHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case utils.IsValidationError(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, primitive.ErrInvalidHex):
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
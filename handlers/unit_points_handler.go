@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"grimdank-database/models"
 	"grimdank-database/services"
@@ -33,7 +34,8 @@ type CalculateUnitPointsResponse struct {
 	Breakdown   *services.UnitPointsBreakdown `json:"breakdown"`
 }
 
-// CalculateUnitPoints calculates points for a unit
+// CalculateUnitPoints calculates points for a unit, in the current season or
+// the one named by the season query parameter
 func (h *UnitPointsHandler) CalculateUnitPoints(w http.ResponseWriter, r *http.Request) {
 	var req CalculateUnitPointsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -47,9 +49,13 @@ func (h *UnitPointsHandler) CalculateUnitPoints(w http.ResponseWriter, r *http.R
 	}
 
 	// Calculate points
-	breakdown, err := h.unitPointsService.CalculateUnitPoints(r.Context(), req.Unit)
+	breakdown, err := h.unitPointsService.CalculateUnitPointsInSeason(r.Context(), req.Unit, r.URL.Query().Get("season"))
 	if err != nil {
-		http.Error(w, "Failed to calculate unit points: "+err.Error(), http.StatusInternalServerError)
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to calculate unit points: "+err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
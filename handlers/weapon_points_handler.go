@@ -9,17 +9,30 @@ import (
 
 // WeaponPointsHandler handles weapon points calculation requests
 type WeaponPointsHandler struct {
-	calculator *services.WeaponPointsCalculator
+	seasons *services.PointsSeasonService
 }
 
 // NewWeaponPointsHandler creates a new weapon points handler
-func NewWeaponPointsHandler() *WeaponPointsHandler {
+func NewWeaponPointsHandler(seasons *services.PointsSeasonService) *WeaponPointsHandler {
 	return &WeaponPointsHandler{
-		calculator: services.NewWeaponPointsCalculator(),
+		seasons: seasons,
 	}
 }
 
-// CalculateWeaponPoints calculates points for a weapon based on its stats
+// calculator returns a calculator for the season named by the season query
+// parameter, or the current one
+func (h *WeaponPointsHandler) calculator(w http.ResponseWriter, r *http.Request) (*services.WeaponPointsCalculator, bool) {
+	season, err := h.seasons.Season(r.URL.Query().Get("season"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	return services.NewWeaponPointsCalculatorWithConfig(&season.Weapons), true
+}
+
+// CalculateWeaponPoints calculates points for a weapon based on its stats, in
+// the current season or the one named by the season query parameter. The
+// breakdown has both the legacy and the statistical result.
 func (h *WeaponPointsHandler) CalculateWeaponPoints(w http.ResponseWriter, r *http.Request) {
 	var stats services.WeaponStats
 	if err := json.NewDecoder(r.Body).Decode(&stats); err != nil {
@@ -32,12 +45,23 @@ func (h *WeaponPointsHandler) CalculateWeaponPoints(w http.ResponseWriter, r *ht
 		http.Error(w, "Weapon type is required", http.StatusBadRequest)
 		return
 	}
+	for _, rule := range stats.Rules {
+		if err := services.ValidateRuleEffects(rule.Effects); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	calculator, ok := h.calculator(w, r)
+	if !ok {
+		return
+	}
 
 	// Calculate points
-	points := h.calculator.CalculateWeaponPoints(stats)
+	points := calculator.CalculateWeaponPoints(stats)
 
 	// Get detailed breakdown
-	breakdown := h.calculator.GetWeaponStatsBreakdown(stats)
+	breakdown := calculator.GetWeaponStatsBreakdown(stats)
 
 	response := map[string]interface{}{
 		"points":    points,
@@ -62,8 +86,19 @@ func (h *WeaponPointsHandler) GetWeaponPointsBreakdown(w http.ResponseWriter, r
 		http.Error(w, "Weapon type is required", http.StatusBadRequest)
 		return
 	}
+	for _, rule := range stats.Rules {
+		if err := services.ValidateRuleEffects(rule.Effects); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	calculator, ok := h.calculator(w, r)
+	if !ok {
+		return
+	}
 
-	breakdown := h.calculator.GetWeaponStatsBreakdown(stats)
+	breakdown := calculator.GetWeaponStatsBreakdown(stats)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(breakdown)
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"grimdank-database/services"
+	"grimdank-database/utils"
+)
+
+type WhatIfHandler struct {
+	service *services.WhatIfService
+}
+
+func NewWhatIfHandler(service *services.WhatIfService) *WhatIfHandler {
+	return &WhatIfHandler{
+		service: service,
+	}
+}
+
+// WhatIf handles POST /points/what-if - reports what proposed rule, weapon,
+// wargear or season changes would do to points, without saving them
+func (h *WhatIfHandler) WhatIf(w http.ResponseWriter, r *http.Request) {
+	var proposal services.WhatIfProposal
+	if err := json.NewDecoder(r.Body).Decode(&proposal); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.WhatIf(r.Context(), &proposal)
+	if err != nil {
+		switch {
+		case utils.IsValidationError(err):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"grimdank-database/models"
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// WorkspaceHeader names the workspace a request works in
+const WorkspaceHeader = "X-Workspace"
+
+// ResolveWorkspace is middleware that scopes a request to the workspace named
+// in its X-Workspace header or, when domain is set, by the subdomain it was
+// sent to: a request to warband.example.com with domain example.com works in
+// the warband workspace. Requests naming neither use the default workspace.
+// It must run after WithChange, as membership is checked against the actor.
+func ResolveWorkspace(service *services.WorkspaceService, domain string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			slug := workspaceOf(r, domain)
+			if slug == "" {
+				slug = services.DefaultWorkspace
+			}
+
+			if err := service.Authorize(r.Context(), slug); err != nil {
+				writeWorkspaceError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(services.WithWorkspace(r.Context(), slug)))
+		})
+	}
+}
+
+// workspaceOf returns the workspace a request names, if any
+func workspaceOf(r *http.Request, domain string) string {
+	if slug := strings.TrimSpace(r.Header.Get(WorkspaceHeader)); slug != "" {
+		return strings.ToLower(slug)
+	}
+	if domain == "" {
+		return ""
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	suffix := "." + strings.ToLower(domain)
+	if !strings.HasSuffix(host, suffix) {
+		return ""
+	}
+	return strings.TrimSuffix(host, suffix)
+}
+
+type WorkspaceHandler struct {
+	service *services.WorkspaceService
+}
+
+func NewWorkspaceHandler(service *services.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		service: service,
+	}
+}
+
+// CreateWorkspace handles POST /workspaces - creates a workspace owned by the caller
+func (h *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	var workspace models.Workspace
+	if err := json.NewDecoder(r.Body).Decode(&workspace); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.CreateWorkspace(r.Context(), &workspace)
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetWorkspaces handles GET /workspaces - lists the workspaces the caller is a member of
+func (h *WorkspaceHandler) GetWorkspaces(w http.ResponseWriter, r *http.Request) {
+	workspaces, err := h.service.ListWorkspaces(r.Context())
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workspaces)
+}
+
+// GetWorkspace handles GET /workspaces/{slug}
+func (h *WorkspaceHandler) GetWorkspace(w http.ResponseWriter, r *http.Request) {
+	workspace, err := h.service.GetWorkspace(r.Context(), mux.Vars(r)["slug"])
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workspace)
+}
+
+// SetMember handles PUT /workspaces/{slug}/members/{actor} - adds a member or
+// changes their role, given as {"role": "owner"} or {"role": "member"}
+func (h *WorkspaceHandler) SetMember(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	vars := mux.Vars(r)
+	workspace, err := h.service.SetMember(r.Context(), vars["slug"], vars["actor"], req.Role)
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workspace)
+}
+
+// RemoveMember handles DELETE /workspaces/{slug}/members/{actor}
+func (h *WorkspaceHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	workspace, err := h.service.RemoveMember(r.Context(), vars["slug"], vars["actor"])
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workspace)
+}
+
+// GetStats handles GET /workspaces/{slug}/stats - counts the workspace's entities
+func (h *WorkspaceHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.service.Stats(r.Context(), mux.Vars(r)["slug"])
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+func writeWorkspaceError(w http.ResponseWriter, err error) {
+	switch {
+	case utils.IsValidationError(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case utils.IsDuplicateError(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case utils.IsForbiddenError(err):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -8,7 +8,9 @@ import (
 
 	"grimdank-database/config"
 	"grimdank-database/database"
+	"grimdank-database/events"
 	"grimdank-database/handlers"
+	"grimdank-database/migrations"
 	"grimdank-database/repositories"
 	"grimdank-database/services"
 
@@ -19,52 +21,183 @@ func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
-	// Connect to database with resilience
-	log.Println("Initializing resilient database connection...")
+	// Select the storage backend
+	var store repositories.Store
+	var db *database.ResilientDatabase
+	var unitOfWork services.UnitOfWork
+
+	if cfg.StorageBackend == config.StorageBackendMemory {
+		log.Println("⚠️ Using in-memory storage backend - data will be lost when the server stops")
+		store = repositories.NewMemoryStore()
+		unitOfWork = services.SequentialUnitOfWork{}
+	} else {
+		// Connect to database with resilience
+		log.Println("Initializing resilient database connection...")
+
+		// Convert environment config to database config
+		retryConfig := &database.RetryConfig{
+			MaxRetries:      cfg.EnvironmentConfig.RetryConfig.MaxRetries,
+			InitialDelay:    cfg.EnvironmentConfig.RetryConfig.InitialDelay,
+			MaxDelay:        cfg.EnvironmentConfig.RetryConfig.MaxDelay,
+			BackoffFactor:   cfg.EnvironmentConfig.RetryConfig.BackoffFactor,
+			RetryableErrors: cfg.EnvironmentConfig.RetryConfig.RetryableErrors,
+		}
 
-	// Convert environment config to database config
-	retryConfig := &database.RetryConfig{
-		MaxRetries:      cfg.EnvironmentConfig.RetryConfig.MaxRetries,
-		InitialDelay:    cfg.EnvironmentConfig.RetryConfig.InitialDelay,
-		MaxDelay:        cfg.EnvironmentConfig.RetryConfig.MaxDelay,
-		BackoffFactor:   cfg.EnvironmentConfig.RetryConfig.BackoffFactor,
-		RetryableErrors: cfg.EnvironmentConfig.RetryConfig.RetryableErrors,
-	}
+		var err error
+		db, err = database.NewResilientDatabase(cfg.MongoURI, cfg.Database, cfg.DatabaseTimeout, retryConfig)
+		if err != nil {
+			log.Fatal("❌ Failed to connect to database:", err)
+		}
+		defer func() {
+			log.Println("Disconnecting from database...")
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := db.DisconnectWithRetry(ctx); err != nil {
+				log.Printf("Error disconnecting from database: %v", err)
+			}
+		}()
 
-	db, err := database.NewResilientDatabase(cfg.MongoURI, cfg.Database, cfg.DatabaseTimeout, retryConfig)
-	if err != nil {
-		log.Fatal("❌ Failed to connect to database:", err)
+		store = repositories.NewMongoStore(db.Database.Database)
+
+		// Use transactions for multi-document writes when the deployment has them
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.DatabaseTimeout)*time.Second)
+		unitOfWork = db.Database.UnitOfWork(ctx)
+		cancel()
 	}
-	defer func() {
-		log.Println("Disconnecting from database...")
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := db.DisconnectWithRetry(ctx); err != nil {
-			log.Printf("Error disconnecting from database: %v", err)
+
+	// Initialize repositories. Entity collections are scoped to the workspace
+	// of each request; the workspaces themselves, the points seasons and the
+	// purge log, which every workspace shares, are not.
+	workspaceRepo := repositories.NewWorkspaceRepository(store.Collection("workspaces"))
+	pointsSeasonRepo := repositories.NewPointsSeasonRepository(store.Collection("pointsseasons"))
+	purgeLogRepo := repositories.NewPurgeLogRepository(store.Collection("purges"))
+	scoped := repositories.NewWorkspaceStore(store)
+	ruleRepo := repositories.NewRuleRepository(scoped.Collection("rules"))
+	weaponRepo := repositories.NewWeaponRepository(scoped.Collection("weapons"))
+	wargearRepo := repositories.NewWarGearRepository(scoped.Collection("wargear"))
+	unitRepo := repositories.NewUnitRepository(scoped.Collection("units"))
+	armyBookRepo := repositories.NewArmyBookRepository(scoped.Collection("armybooks"))
+	armyListRepo := repositories.NewArmyListRepository(scoped.Collection("armylists"))
+	factionRepo := repositories.NewFactionRepository(scoped.Collection("factions"))
+	revisionRepo := repositories.NewRevisionRepository(scoped.Collection("revisions"))
+	assetRepo := repositories.NewAssetRepository(scoped.Collection("assets"))
+
+	// Uploaded images go to GridFS, or to a directory during development
+	var blobs repositories.BlobStore
+	if cfg.AssetStorageDriver() == config.AssetStorageGridFS {
+		blobs = repositories.NewGridFSBlobStore(db.Database.Database, "assetblobs")
+	} else {
+		fileBlobs, err := repositories.NewFileBlobStore(cfg.AssetDir)
+		if err != nil {
+			log.Fatal("❌ Failed to open the asset directory:", err)
 		}
-	}()
+		blobs = fileBlobs
+	}
+
+	// Reconcile the indexes each repository declares
+	if cfg.IndexSync != config.IndexSyncOff {
+		syncIndexes(cfg.IndexSync == config.IndexSyncApply, map[string]repositories.IndexedRepository{
+			"rules":         ruleRepo,
+			"weapons":       weaponRepo,
+			"wargear":       wargearRepo,
+			"units":         unitRepo,
+			"armybooks":     armyBookRepo,
+			"armylists":     armyListRepo,
+			"factions":      factionRepo,
+			"revisions":     revisionRepo,
+			"workspaces":    workspaceRepo,
+			"assets":        assetRepo,
+			"pointsseasons": pointsSeasonRepo,
+			"purges":        purgeLogRepo,
+		})
+	}
 
-	// Initialize repositories
-	ruleRepo := repositories.NewRuleRepository(db.Collection("rules"))
-	weaponRepo := repositories.NewWeaponRepository(db.Collection("weapons"))
-	wargearRepo := repositories.NewWarGearRepository(db.Collection("wargear"))
-	unitRepo := repositories.NewUnitRepository(db.Collection("units"))
-	armyBookRepo := repositories.NewArmyBookRepository(db.Collection("armybooks"))
-	armyListRepo := repositories.NewArmyListRepository(db.Collection("armylists"))
-	factionRepo := repositories.NewFactionRepository(db.Collection("factions"))
+	// Schema migrations are applied with cmd/migrate; only warn about pending ones here
+	if cfg.StorageBackend != config.StorageBackendMemory {
+		warnPendingMigrations(store)
+	}
+
+	// Services announce every successful write on the event bus
+	bus := events.NewBus()
+	registerSubscribers(bus)
 
 	// Initialize services
-	ruleService := services.NewRuleService(ruleRepo)
-	weaponService := services.NewWeaponService(weaponRepo)
-	wargearService := services.NewWarGearService(wargearRepo)
-	unitService := services.NewUnitService(unitRepo)
-	armyBookService := services.NewArmyBookService(armyBookRepo)
-	armyListService := services.NewArmyListService(armyListRepo)
-	factionService := services.NewFactionService(factionRepo)
+	revisionService := services.NewRevisionService(revisionRepo, ruleRepo, weaponRepo, wargearRepo, unitRepo, armyBookRepo, armyListRepo, factionRepo, unitOfWork, bus)
+	referenceService := services.NewReferenceService(ruleRepo, weaponRepo, wargearRepo, unitRepo, armyBookRepo, armyListRepo, revisionService, unitOfWork)
+	ruleService := services.NewRuleService(ruleRepo, referenceService, revisionService)
+	weaponService := services.NewWeaponService(weaponRepo, referenceService, revisionService)
+	wargearService := services.NewWarGearService(wargearRepo, referenceService, revisionService)
+	unitService := services.NewUnitService(unitRepo, referenceService, revisionService)
+	armyBookService := services.NewArmyBookService(armyBookRepo, revisionService)
+	armyListService := services.NewArmyListService(armyListRepo, revisionService)
+	factionService := services.NewFactionService(factionRepo, revisionService)
+
+	// Cache the rule, weapon and wargear lookups points calculations repeat,
+	// dropping entities as the services change them
+	caches := services.NewCaches(time.Duration(cfg.CacheTTL)*time.Second, cfg.CacheSize)
+	caches.Subscribe(bus)
+	ruleService.UseCache(caches.Rules)
+	weaponService.UseCache(caches.Weapons)
+	wargearService.UseCache(caches.WarGear)
+	if cfg.StorageBackend != config.StorageBackendMemory {
+		migrationCtx, stopWatching := context.WithCancel(context.Background())
+		defer stopWatching()
+		go flushCachesOnMigration(migrationCtx, store, caches)
+	}
+
+	// Load the points seasons, and reread them now and then for edits made
+	// by other servers or directly in the database
+	pointsSeasonService := services.NewPointsSeasonService(pointsSeasonRepo, bus)
+	if err := pointsSeasonService.Reload(context.Background()); err != nil {
+		log.Printf("Failed to load points seasons, using the built-in season: %v", err)
+	}
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	if cfg.PointsSeasonReload > 0 {
+		go pointsSeasonService.RunReloadLoop(reloadCtx, time.Duration(cfg.PointsSeasonReload)*time.Second)
+	}
 
 	// Initialize points services
 	rulePointsService := services.NewRulePointsService(ruleService)
+	rulePointsService.UseSeasons(pointsSeasonService)
 	unitPointsService := services.NewUnitPointsService(ruleService, weaponService, wargearService)
+	unitPointsService.UseSeasons(pointsSeasonService)
+
+	// Keep stored weapon, wargear, unit and army list points in line with
+	// the rules and stats they are calculated from
+	recalculationService := services.NewRecalculationService(ruleRepo, weaponRepo, wargearRepo, unitRepo, armyListRepo, unitPointsService, revisionService)
+	if cfg.PointsRecalculation != config.PointsRecalculationManual {
+		recalculationService.Subscribe(bus)
+	}
+
+	// Try out balance changes on the points they would affect, in memory
+	whatIfService := services.NewWhatIfService(ruleRepo, weaponRepo, wargearRepo, unitRepo, armyListRepo, unitPointsService)
+
+	// Initialize trash service and purge expired items in the background
+	trashRetention := time.Duration(cfg.TrashRetention) * 24 * time.Hour
+	trashService := services.NewTrashService(ruleRepo, weaponRepo, wargearRepo, unitRepo, armyBookRepo, armyListRepo, factionRepo, revisionService, purgeLogRepo, trashRetention)
+	trashService.UseCaches(caches)
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	go trashService.RunPurgeLoop(repositories.AllWorkspaces(purgeCtx), time.Hour)
+
+	// Initialize search service across the text-indexed collections
+	searchService := services.NewSearchService(ruleRepo, weaponRepo, wargearRepo, unitRepo, armyBookRepo, factionRepo)
+
+	// Initialize bulk service for filter-based updates and deletes
+	bulkService := services.NewBulkService(ruleService, weaponService, wargearService, unitService, armyBookService, armyListService, factionService, referenceService)
+
+	// Initialize sync service for clients that download changes incrementally
+	syncService := services.NewSyncService(ruleRepo, weaponRepo, wargearRepo, unitRepo, armyBookRepo, armyListRepo, factionRepo, purgeLogRepo, trashRetention)
+
+	// Initialize snapshot service for backups of every collection
+	snapshotService := services.NewSnapshotService(workspaceRepo, ruleRepo, weaponRepo, wargearRepo, unitRepo, armyBookRepo, armyListRepo, factionRepo, purgeLogRepo, unitOfWork, migrations.Latest())
+
+	// Initialize workspace service for workspaces and their members
+	workspaceService := services.NewWorkspaceService(workspaceRepo, ruleRepo, weaponRepo, wargearRepo, unitRepo, armyBookRepo, armyListRepo, factionRepo)
+
+	// Initialize asset service for uploaded images and their thumbnails
+	assetService := services.NewAssetService(assetRepo, blobs, unitRepo, wargearRepo, factionRepo, int64(cfg.MaxAssetSizeMB)<<20)
 
 	// Initialize population service for reference-based operations
 	populationService := services.NewPopulationService(ruleService, weaponService, wargearService, unitService)
@@ -77,18 +210,40 @@ func main() {
 	armyBookHandler := handlers.NewArmyBookHandler(armyBookService)
 	armyListHandler := handlers.NewArmyListHandler(armyListService)
 	factionHandler := handlers.NewFactionHandler(factionService)
-	importHandler := handlers.NewImportHandler(ruleService, weaponService, wargearService, unitService, armyBookService, armyListService, factionService)
+	importHandler := handlers.NewImportHandler(ruleService, weaponService, wargearService, unitService, armyBookService, armyListService, factionService, unitOfWork)
 	pointsHandler := handlers.NewPointsHandler(rulePointsService)
 	unitPointsHandler := handlers.NewUnitPointsHandler(unitPointsService, false)
 	populatedWeaponHandler := handlers.NewPopulatedWeaponHandler(weaponService, populationService)
 	populatedWarGearHandler := handlers.NewPopulatedWarGearHandler(wargearService, populationService)
-	weaponPointsHandler := handlers.NewWeaponPointsHandler()
+	populatedUnitHandler := handlers.NewPopulatedUnitHandler(populationService)
+	populatedArmyHandler := handlers.NewPopulatedArmyHandler(armyBookService, armyListService, populationService)
+	weaponPointsHandler := handlers.NewWeaponPointsHandler(pointsSeasonService)
+	pointsSeasonHandler := handlers.NewPointsSeasonHandler(pointsSeasonService)
+	recalculationHandler := handlers.NewRecalculationHandler(recalculationService)
+	whatIfHandler := handlers.NewWhatIfHandler(whatIfService)
+	trashHandler := handlers.NewTrashHandler(trashService)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	revisionHandler := handlers.NewRevisionHandler(revisionService)
+	syncHandler := handlers.NewSyncHandler(syncService)
+	bulkHandler := handlers.NewBulkHandler(bulkService)
+	snapshotHandler := handlers.NewSnapshotHandler(snapshotService, caches)
+	cacheHandler := handlers.NewCacheHandler(caches)
+	workspaceHandler := handlers.NewWorkspaceHandler(workspaceService)
+	assetHandler := handlers.NewAssetHandler(assetService)
 
 	// Setup routes
 	router := mux.NewRouter()
 
 	// Health check endpoint
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		// The in-memory backend has no connection to test
+		if db == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"status":"healthy","database":"memory"}`))
+			return
+		}
+
 		// Test database connection
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -142,6 +297,13 @@ func main() {
 	api.HandleFunc("/units/{id}", unitHandler.GetUnit).Methods("GET")
 	api.HandleFunc("/units/{id}", unitHandler.UpdateUnit).Methods("PUT")
 	api.HandleFunc("/units/{id}", unitHandler.DeleteUnit).Methods("DELETE")
+	api.HandleFunc("/units/{id}/populated", populatedUnitHandler.GetPopulatedUnit).Methods("GET")
+	api.HandleFunc("/units/{id}/rules", populatedUnitHandler.AddRuleToUnit).Methods("POST")
+	api.HandleFunc("/units/{id}/rules/{ruleId}", populatedUnitHandler.RemoveRuleFromUnit).Methods("DELETE")
+	api.HandleFunc("/units/{id}/weapons", populatedUnitHandler.AddWeaponToUnit).Methods("POST")
+	api.HandleFunc("/units/{id}/weapons/{weaponId}", populatedUnitHandler.RemoveWeaponFromUnit).Methods("DELETE")
+	api.HandleFunc("/units/{id}/wargear", populatedUnitHandler.AddWarGearToUnit).Methods("POST")
+	api.HandleFunc("/units/{id}/wargear/{wargearId}", populatedUnitHandler.RemoveWarGearFromUnit).Methods("DELETE")
 
 	// ArmyBook routes
 	api.HandleFunc("/armybooks", armyBookHandler.CreateArmyBook).Methods("POST")
@@ -149,6 +311,7 @@ func main() {
 	api.HandleFunc("/armybooks/{id}", armyBookHandler.GetArmyBook).Methods("GET")
 	api.HandleFunc("/armybooks/{id}", armyBookHandler.UpdateArmyBook).Methods("PUT")
 	api.HandleFunc("/armybooks/{id}", armyBookHandler.DeleteArmyBook).Methods("DELETE")
+	api.HandleFunc("/armybooks/{id}/populated", populatedArmyHandler.GetPopulatedArmyBook).Methods("GET")
 
 	// Faction routes
 	log.Println("Registering faction routes...")
@@ -165,6 +328,14 @@ func main() {
 	api.HandleFunc("/armylists/{id}", armyListHandler.GetArmyList).Methods("GET")
 	api.HandleFunc("/armylists/{id}", armyListHandler.UpdateArmyList).Methods("PUT")
 	api.HandleFunc("/armylists/{id}", armyListHandler.DeleteArmyList).Methods("DELETE")
+	api.HandleFunc("/armylists/{id}/populated", populatedArmyHandler.GetPopulatedArmyList).Methods("GET")
+
+	// Asset routes
+	api.HandleFunc("/assets", assetHandler.UploadAsset).Methods("POST")
+	api.HandleFunc("/assets/{id}", assetHandler.GetAsset).Methods("GET")
+	api.HandleFunc("/assets/{id}/content", assetHandler.DownloadAsset).Methods("GET")
+	api.HandleFunc("/assets/{id}/thumbnail", assetHandler.DownloadThumbnail).Methods("GET")
+	api.HandleFunc("/assets/{id}", assetHandler.DeleteAsset).Methods("DELETE")
 
 	// Import routes
 	api.HandleFunc("/import/rules", importHandler.ImportRules).Methods("POST")
@@ -176,12 +347,67 @@ func main() {
 	api.HandleFunc("/import/factions", importHandler.ImportFactions).Methods("POST")
 	api.HandleFunc("/import/template/{type}", importHandler.GetImportTemplate).Methods("GET")
 
+	// Search routes
+	api.HandleFunc("/search", searchHandler.Search).Methods("GET")
+
+	// Bulk routes
+	api.HandleFunc("/{type}/bulk/update", bulkHandler.BulkUpdate).Methods("POST")
+	api.HandleFunc("/{type}/bulk/delete", bulkHandler.BulkDelete).Methods("POST")
+
+	// Sync routes
+	api.HandleFunc("/sync", syncHandler.GetChanges).Methods("GET")
+
+	// Trash routes
+	api.HandleFunc("/trash/purge", trashHandler.PurgeExpired).Methods("POST")
+	api.HandleFunc("/trash/{type}", trashHandler.GetTrash).Methods("GET")
+	api.HandleFunc("/trash/{type}/{id}", trashHandler.PurgeFromTrash).Methods("DELETE")
+	api.HandleFunc("/trash/{type}/{id}/restore", trashHandler.RestoreFromTrash).Methods("POST")
+
+	// Revision history routes
+	api.HandleFunc("/revisions/{type}/{id}", revisionHandler.GetRevisions).Methods("GET")
+	api.HandleFunc("/revisions/{type}/{id}/diff", revisionHandler.DiffRevisions).Methods("GET")
+	api.HandleFunc("/revisions/{type}/{id}/{version:[0-9]+}", revisionHandler.GetRevision).Methods("GET")
+	api.HandleFunc("/revisions/{type}/{id}/{version:[0-9]+}/revert", revisionHandler.RevertToRevision).Methods("POST")
+
+	// Workspace routes
+	api.HandleFunc("/workspaces", workspaceHandler.CreateWorkspace).Methods("POST")
+	api.HandleFunc("/workspaces", workspaceHandler.GetWorkspaces).Methods("GET")
+	api.HandleFunc("/workspaces/{slug}", workspaceHandler.GetWorkspace).Methods("GET")
+	api.HandleFunc("/workspaces/{slug}/members/{actor}", workspaceHandler.SetMember).Methods("PUT")
+	api.HandleFunc("/workspaces/{slug}/members/{actor}", workspaceHandler.RemoveMember).Methods("DELETE")
+	api.HandleFunc("/workspaces/{slug}/stats", workspaceHandler.GetStats).Methods("GET")
+
+	// Cache routes
+	api.HandleFunc("/cache/stats", cacheHandler.GetStats).Methods("GET")
+
+	// Admin routes, only served when an admin token is configured
+	if cfg.AdminToken != "" {
+		admin := api.PathPrefix("/admin").Subrouter()
+		admin.Use(handlers.RequireAdminToken(cfg.AdminToken))
+		admin.HandleFunc("/snapshot", snapshotHandler.ExportSnapshot).Methods("GET")
+		admin.HandleFunc("/snapshot/verify", snapshotHandler.VerifySnapshot).Methods("POST")
+		admin.HandleFunc("/snapshot/restore", snapshotHandler.RestoreSnapshot).Methods("POST")
+		admin.HandleFunc("/cache/flush", cacheHandler.Flush).Methods("POST")
+		admin.HandleFunc("/points/seasons", pointsSeasonHandler.CreateSeason).Methods("POST")
+		admin.HandleFunc("/points/seasons/reload", pointsSeasonHandler.ReloadSeasons).Methods("POST")
+		admin.HandleFunc("/points/seasons/{name}", pointsSeasonHandler.UpdateSeason).Methods("PUT")
+		admin.HandleFunc("/points/seasons/{name}", pointsSeasonHandler.DeleteSeason).Methods("DELETE")
+		admin.HandleFunc("/points/seasons/{name}/activate", pointsSeasonHandler.ActivateSeason).Methods("POST")
+	} else {
+		log.Println("ADMIN_TOKEN is not set; admin endpoints are disabled")
+	}
+
 	// Points calculation routes
 	api.HandleFunc("/points/calculate", pointsHandler.CalculatePoints).Methods("POST")
 	api.HandleFunc("/points/calculate/{id}", pointsHandler.CalculatePointsForRule).Methods("GET")
 	api.HandleFunc("/points/update/{id}", pointsHandler.UpdateRuleWithCalculatedPoints).Methods("PUT")
 	api.HandleFunc("/points/bulk", pointsHandler.BulkCalculatePoints).Methods("POST")
 	api.HandleFunc("/points/breakdown/{id}", pointsHandler.GetPointsBreakdown).Methods("GET")
+	api.HandleFunc("/points/recalculate", recalculationHandler.RecalculateEverything).Methods("POST")
+	api.HandleFunc("/points/recalculate/{type}/{id}", recalculationHandler.Recalculate).Methods("POST")
+	api.HandleFunc("/points/what-if", whatIfHandler.WhatIf).Methods("POST")
+	api.HandleFunc("/points/seasons", pointsSeasonHandler.GetSeasons).Methods("GET")
+	api.HandleFunc("/points/seasons/{name}", pointsSeasonHandler.GetSeason).Methods("GET")
 
 	// Unit points calculation routes
 	api.HandleFunc("/calculate-unit-points", unitPointsHandler.CalculateUnitPoints).Methods("POST")
@@ -195,7 +421,8 @@ func main() {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, X-Actor, X-Change-Reason, X-Workspace")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Next-Cursor")
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -206,7 +433,87 @@ func main() {
 		})
 	})
 
+	// Attribute writes to the actor and reason the client gives
+	router.Use(handlers.WithChange)
+
+	// Scope every request to the workspace it names, if the actor may use it
+	router.Use(handlers.ResolveWorkspace(workspaceService, cfg.WorkspaceDomain))
+
 	// Start server
 	log.Printf("Server starting on port %s", cfg.ServerPort)
 	log.Fatal(http.ListenAndServe(":"+cfg.ServerPort, router))
 }
+
+// registerSubscribers subscribes everything that reacts to changes to the event bus
+func registerSubscribers(bus *events.Bus) {
+	bus.SubscribeAll("change-log", func(ctx context.Context, event events.Event) error {
+		change := event.Change()
+		log.Printf("%s %s (version %d) by %s", event.Name(), change.EntityID.Hex(), change.Version, change.Actor)
+		return nil
+	}, events.Async())
+}
+
+// syncIndexes reconciles declared indexes and logs any drift. Failures are
+// logged rather than fatal so that existing duplicates don't keep the server down.
+func syncIndexes(apply bool, repos map[string]repositories.IndexedRepository) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	reports, err := repositories.ReconcileIndexes(ctx, repos, apply)
+	if err != nil {
+		log.Printf("⚠️ Index sync failed: %v", err)
+	}
+
+	for _, report := range reports {
+		if !report.HasDrift() && len(report.Errors) == 0 {
+			continue
+		}
+		if len(report.Missing) > 0 {
+			log.Printf("Indexes missing on %s: %v", report.Collection, report.Missing)
+		}
+		if len(report.Changed) > 0 {
+			log.Printf("Indexes changed on %s: %v", report.Collection, report.Changed)
+		}
+		if len(report.Unmanaged) > 0 {
+			log.Printf("Indexes on %s not declared by the repository: %v", report.Collection, report.Unmanaged)
+		}
+		if len(report.Created) > 0 {
+			log.Printf("✅ Created indexes on %s: %v", report.Collection, report.Created)
+		}
+		for _, message := range report.Errors {
+			log.Printf("⚠️ Index error on %s: %s", report.Collection, message)
+		}
+	}
+	log.Println("Index sync complete")
+}
+
+// flushCachesOnMigration empties the caches whenever cmd/migrate applies or
+// rolls back a migration, as those rewrite documents without the services
+func flushCachesOnMigration(ctx context.Context, store repositories.Store, caches *services.Caches) {
+	migrator, err := migrations.NewMigrator(store, migrations.All())
+	if err != nil {
+		return
+	}
+	migrator.Watch(ctx, time.Minute, func() {
+		log.Println("Schema migrations changed, flushing caches")
+		caches.Flush()
+	})
+}
+
+// warnPendingMigrations logs migrations that have not been applied to the database
+func warnPendingMigrations(store repositories.Store) {
+	migrator, err := migrations.NewMigrator(store, migrations.All())
+	if err != nil {
+		log.Printf("⚠️ Invalid migrations: %v", err)
+		return
+	}
+
+	pending, err := migrator.Pending(context.Background())
+	if err != nil {
+		log.Printf("⚠️ Failed to check migrations: %v", err)
+		return
+	}
+	if len(pending) > 0 {
+		log.Printf("⚠️ %d schema migrations pending; run `go run ./cmd/migrate up`", len(pending))
+	}
+}
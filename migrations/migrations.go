@@ -0,0 +1,248 @@
+// Package migrations applies ordered schema changes to stored documents and
+// records which ones have run in the schema_migrations collection.
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"grimdank-database/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const appliedCollection = "schema_migrations"
+
+// Step performs one direction of a migration and returns the number of
+// documents it changed. With dryRun set it must not write anything and returns
+// the number of documents it would change. Steps must be idempotent: running
+// one again after a partial failure only touches what is left.
+type Step func(ctx context.Context, store repositories.Store, dryRun bool) (int64, error)
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      Step
+	Down    Step // nil when the change cannot be rolled back
+}
+
+// AppliedMigration is the record kept in schema_migrations
+type AppliedMigration struct {
+	Version   int       `bson:"version" json:"version"`
+	Name      string    `bson:"name" json:"name"`
+	AppliedAt time.Time `bson:"appliedAt" json:"appliedAt"`
+}
+
+// Status describes one known migration and whether it has been applied
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Result reports what running one migration did, or would do on a dry run
+type Result struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Direction string `json:"direction"` // "up" or "down"
+	Documents int64  `json:"documents"`
+	DryRun    bool   `json:"dryRun"`
+}
+
+// Migrator runs migrations against a store
+type Migrator struct {
+	store      repositories.Store
+	applied    repositories.Collection
+	migrations []Migration
+}
+
+// NewMigrator orders the migrations by version and checks that versions are unique
+func NewMigrator(store repositories.Store, migrations []Migration) (*Migrator, error) {
+	ordered := make([]Migration, len(migrations))
+	copy(ordered, migrations)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Version < ordered[j].Version
+	})
+
+	for i, migration := range ordered {
+		if migration.Version <= 0 {
+			return nil, fmt.Errorf("migration %q must have a positive version", migration.Name)
+		}
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %d (%s) has no up step", migration.Version, migration.Name)
+		}
+		if i > 0 && ordered[i-1].Version == migration.Version {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", ordered[i-1].Name, migration.Name, migration.Version)
+		}
+	}
+
+	return &Migrator{
+		store:      store,
+		applied:    store.Collection(appliedCollection),
+		migrations: ordered,
+	}, nil
+}
+
+// Status lists every known migration in version order
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet, in version order
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies pending migrations in order, up to and including target (0 applies all).
+// A dry run reports each step on its own; later steps may touch more
+// documents once earlier ones have really run.
+func (m *Migrator) Up(ctx context.Context, target int, dryRun bool) ([]Result, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, migration := range pending {
+		if target > 0 && migration.Version > target {
+			break
+		}
+
+		count, err := migration.Up(ctx, m.store, dryRun)
+		if err != nil {
+			return results, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		results = append(results, Result{Version: migration.Version, Name: migration.Name, Direction: "up", Documents: count, DryRun: dryRun})
+
+		if dryRun {
+			continue
+		}
+		record := AppliedMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+		if _, err := m.applied.InsertOne(ctx, record); err != nil {
+			return results, fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		}
+	}
+	return results, nil
+}
+
+// Down rolls back the given number of most recently applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int, dryRun bool) ([]Result, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for i := len(m.migrations) - 1; i >= 0 && len(results) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == nil {
+			return results, fmt.Errorf("migration %d (%s) cannot be rolled back", migration.Version, migration.Name)
+		}
+
+		count, err := migration.Down(ctx, m.store, dryRun)
+		if err != nil {
+			return results, fmt.Errorf("rollback of migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		results = append(results, Result{Version: migration.Version, Name: migration.Name, Direction: "down", Documents: count, DryRun: dryRun})
+
+		if dryRun {
+			continue
+		}
+		if _, err := m.applied.DeleteOne(ctx, bson.M{"version": migration.Version}); err != nil {
+			return results, fmt.Errorf("failed to unrecord migration %d: %w", migration.Version, err)
+		}
+	}
+	return results, nil
+}
+
+// Watch calls changed whenever the applied migrations differ from what they
+// were at the previous check, every interval until ctx is cancelled. It lets
+// a server notice cmd/migrate rewriting its documents. Checks that fail are
+// skipped.
+func (m *Migrator) Watch(ctx context.Context, interval time.Duration, changed func()) {
+	last, err := m.appliedState(ctx)
+	known := err == nil
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			state, err := m.appliedState(ctx)
+			if err != nil {
+				continue
+			}
+			if known && state != last {
+				changed()
+			}
+			last, known = state, true
+		}
+	}
+}
+
+// appliedState sums up the applied migrations and when each was applied, so
+// that rolling one back and applying it again also reads as a change
+func (m *Migrator) appliedState(ctx context.Context) (string, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var state strings.Builder
+	for _, migration := range m.migrations {
+		if record, ok := applied[migration.Version]; ok {
+			fmt.Fprintf(&state, "%d@%d;", record.Version, record.AppliedAt.UnixNano())
+		}
+	}
+	return state.String(), nil
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int]AppliedMigration, error) {
+	var records []AppliedMigration
+	if err := m.applied.Find(ctx, bson.M{}, &records, options.Find()); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", appliedCollection, err)
+	}
+
+	applied := make(map[int]AppliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
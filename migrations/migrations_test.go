@@ -0,0 +1,313 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grimdank-database/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestNewMigratorRejectsDuplicateVersions(t *testing.T) {
+	step := func(ctx context.Context, store repositories.Store, dryRun bool) (int64, error) { return 0, nil }
+	_, err := NewMigrator(repositories.NewMemoryStore(), []Migration{
+		{Version: 1, Name: "first", Up: step},
+		{Version: 1, Name: "second", Up: step},
+	})
+	if err == nil {
+		t.Error("Expected duplicate versions to be rejected")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := repositories.NewMemoryStore()
+	step := func(ctx context.Context, store repositories.Store, dryRun bool) (int64, error) { return 0, nil }
+	all := []Migration{{Version: 1, Name: "first", Up: step, Down: step}}
+
+	// Another process, like cmd/migrate, runs the migrations
+	watcher, _ := NewMigrator(store, all)
+	runner, _ := NewMigrator(store, all)
+
+	changed := make(chan struct{}, 10)
+	go watcher.Watch(ctx, 5*time.Millisecond, func() { changed <- struct{}{} })
+	expect := func(want bool, what string) {
+		select {
+		case <-changed:
+			if !want {
+				t.Errorf("Expected no change to be reported %s", what)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if want {
+				t.Errorf("Expected a change to be reported %s", what)
+			}
+		}
+	}
+
+	expect(false, "before anything ran")
+	if _, err := runner.Up(ctx, 0, false); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	expect(true, "after up")
+	expect(false, "once it was reported")
+	if _, err := runner.Down(ctx, 1, false); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	expect(true, "after down")
+}
+
+func TestRemoveWarGearType(t *testing.T) {
+	ctx := context.Background()
+	store := repositories.NewMemoryStore()
+	wargear := store.Collection("wargear")
+
+	wargear.InsertOne(ctx, bson.M{"name": "Frag Grenades", "type": "Grenade"})
+	wargear.InsertOne(ctx, bson.M{"name": "Power Sword", "type": "Melee"})
+	wargear.InsertOne(ctx, bson.M{"name": "Iron Halo"})
+
+	migrator, err := NewMigrator(store, []Migration{removeWarGearType()})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	countTyped := func() int64 {
+		count, err := wargear.CountDocuments(ctx, bson.M{"type": bson.M{"$exists": true}})
+		if err != nil {
+			t.Fatalf("Failed to count wargear: %v", err)
+		}
+		return count
+	}
+
+	t.Run("Dry Run", func(t *testing.T) {
+		results, err := migrator.Up(ctx, 0, true)
+		if err != nil {
+			t.Fatalf("Dry run failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Documents != 2 {
+			t.Errorf("Expected dry run to report 2 documents, got %+v", results)
+		}
+		if countTyped() != 2 {
+			t.Error("Expected dry run to leave documents unchanged")
+		}
+
+		pending, _ := migrator.Pending(ctx)
+		if len(pending) != 1 {
+			t.Errorf("Expected dry run not to record the migration, got %d pending", len(pending))
+		}
+	})
+
+	t.Run("Up", func(t *testing.T) {
+		results, err := migrator.Up(ctx, 0, false)
+		if err != nil {
+			t.Fatalf("Up failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Documents != 2 {
+			t.Errorf("Expected 2 documents migrated, got %+v", results)
+		}
+		if countTyped() != 0 {
+			t.Error("Expected type field to be removed")
+		}
+
+		status, _ := migrator.Status(ctx)
+		if !status[0].Applied || status[0].AppliedAt == nil {
+			t.Errorf("Expected migration to be recorded as applied, got %+v", status[0])
+		}
+
+		results, err = migrator.Up(ctx, 0, false)
+		if err != nil || len(results) != 0 {
+			t.Errorf("Expected nothing to run a second time, got %+v (%v)", results, err)
+		}
+	})
+
+	t.Run("Down", func(t *testing.T) {
+		results, err := migrator.Down(ctx, 1, false)
+		if err != nil {
+			t.Fatalf("Down failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Documents != 2 {
+			t.Errorf("Expected 2 documents restored, got %+v", results)
+		}
+
+		var restored bson.M
+		if err := wargear.FindOne(ctx, bson.M{"name": "Power Sword"}, &restored); err != nil {
+			t.Fatalf("Failed to find wargear: %v", err)
+		}
+		if restored["type"] != "Melee" {
+			t.Errorf("Expected type to be restored, got %v", restored["type"])
+		}
+
+		var backups []bson.M
+		store.Collection(backupCollection).Find(ctx, bson.M{}, &backups, options.Find())
+		if len(backups) != 0 {
+			t.Errorf("Expected backups to be cleared, got %d", len(backups))
+		}
+
+		pending, _ := migrator.Pending(ctx)
+		if len(pending) != 1 {
+			t.Errorf("Expected migration to be pending again, got %d pending", len(pending))
+		}
+	})
+}
+
+func TestBackfillTimestamps(t *testing.T) {
+	ctx := context.Background()
+	store := repositories.NewMemoryStore()
+	rules := store.Collection("rules")
+	factions := store.Collection("factions")
+
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	deletedAt := createdAt.Add(48 * time.Hour)
+	stamped := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	liveID := primitive.NewObjectIDFromTimestamp(createdAt)
+	trashedID := primitive.NewObjectIDFromTimestamp(createdAt)
+	rules.InsertOne(ctx, bson.M{"_id": liveID, "name": "Stealth"})
+	rules.InsertOne(ctx, bson.M{"_id": trashedID, "name": "Fearless", "deletedAt": deletedAt})
+	factions.InsertOne(ctx, bson.M{"name": "Orks", "createdAt": stamped, "updatedAt": stamped})
+
+	migrator, err := NewMigrator(store, []Migration{backfillTimestamps()})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	results, err := migrator.Up(ctx, 0, false)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Documents != 2 {
+		t.Errorf("Expected 2 documents backfilled, got %+v", results)
+	}
+
+	timestamps := func(collection repositories.Collection, filter bson.M) (time.Time, time.Time) {
+		var doc struct {
+			CreatedAt time.Time `bson:"createdAt"`
+			UpdatedAt time.Time `bson:"updatedAt"`
+		}
+		if err := collection.FindOne(ctx, filter, &doc); err != nil {
+			t.Fatalf("Failed to read %v: %v", filter, err)
+		}
+		return doc.CreatedAt, doc.UpdatedAt
+	}
+
+	created, updated := timestamps(rules, bson.M{"_id": liveID})
+	if !created.Equal(createdAt) || !updated.Equal(createdAt) {
+		t.Errorf("Expected live rule stamped with its ID's time %v, got %v / %v", createdAt, created, updated)
+	}
+
+	created, updated = timestamps(rules, bson.M{"_id": trashedID})
+	if !created.Equal(createdAt) || !updated.Equal(deletedAt) {
+		t.Errorf("Expected trashed rule updated when deleted, got %v / %v", created, updated)
+	}
+
+	created, updated = timestamps(factions, bson.M{"name": "Orks"})
+	if !created.Equal(stamped) || !updated.Equal(stamped) {
+		t.Errorf("Expected existing timestamps to be kept, got %v / %v", created, updated)
+	}
+
+	t.Run("Down", func(t *testing.T) {
+		// Edited since, so its updatedAt is no longer the backfilled one
+		rules.UpdateOne(ctx, bson.M{"_id": trashedID}, bson.M{"$set": bson.M{"updatedAt": stamped}})
+
+		results, err := migrator.Down(ctx, 1, false)
+		if err != nil {
+			t.Fatalf("Down failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Documents != 2 {
+			t.Errorf("Expected 2 documents rolled back, got %+v", results)
+		}
+
+		has := func(collection repositories.Collection, filter bson.M) (bool, bool) {
+			var doc bson.M
+			if err := collection.FindOne(ctx, filter, &doc); err != nil {
+				t.Fatalf("Failed to read %v: %v", filter, err)
+			}
+			_, created := doc["createdAt"]
+			_, updated := doc["updatedAt"]
+			return created, updated
+		}
+		if created, updated := has(rules, bson.M{"_id": liveID}); created || updated {
+			t.Errorf("Expected the backfilled timestamps to be unset, got createdAt %v, updatedAt %v", created, updated)
+		}
+		if created, updated := has(rules, bson.M{"_id": trashedID}); created || !updated {
+			t.Errorf("Expected only the edited updatedAt to be kept, got createdAt %v, updatedAt %v", created, updated)
+		}
+		if created, updated := has(factions, bson.M{"name": "Orks"}); !created || !updated {
+			t.Error("Expected timestamps the migration didn't set to be kept")
+		}
+		if count, _ := store.Collection(backupCollection).CountDocuments(ctx, bson.M{}); count != 0 {
+			t.Errorf("Expected the notes of the backfill to be removed, got %d", count)
+		}
+	})
+}
+
+func TestAssignDefaultWorkspace(t *testing.T) {
+	ctx := context.Background()
+	store := repositories.NewMemoryStore()
+	rules := store.Collection("rules")
+	revisions := store.Collection("revisions")
+
+	rules.InsertOne(ctx, bson.M{"name": "Stealth"})
+	rules.InsertOne(ctx, bson.M{"name": "Fearless", "workspace": "warband"})
+	revisions.InsertOne(ctx, bson.M{"entityType": "rule", "version": 1})
+
+	migrator, err := NewMigrator(store, []Migration{assignDefaultWorkspace()})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	preview, err := migrator.Up(ctx, 0, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(preview) != 1 || preview[0].Documents != 2 {
+		t.Errorf("Expected 2 documents to be assigned, got %+v", preview)
+	}
+
+	if _, err := migrator.Up(ctx, 0, false); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	workspaceOf := func(collection repositories.Collection, filter bson.M) string {
+		var doc struct {
+			Workspace string `bson:"workspace"`
+		}
+		if err := collection.FindOne(ctx, filter, &doc); err != nil {
+			t.Fatalf("Failed to read %v: %v", filter, err)
+		}
+		return doc.Workspace
+	}
+
+	if got := workspaceOf(rules, bson.M{"name": "Stealth"}); got != repositories.DefaultWorkspace {
+		t.Errorf("Expected the rule moved to the default workspace, got %q", got)
+	}
+	if got := workspaceOf(rules, bson.M{"name": "Fearless"}); got != "warband" {
+		t.Errorf("Expected an assigned workspace to be kept, got %q", got)
+	}
+	if got := workspaceOf(revisions, bson.M{"entityType": "rule"}); got != repositories.DefaultWorkspace {
+		t.Errorf("Expected the revision moved to the default workspace, got %q", got)
+	}
+
+	t.Run("Down", func(t *testing.T) {
+		results, err := migrator.Down(ctx, 1, false)
+		if err != nil {
+			t.Fatalf("Down failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Documents != 2 {
+			t.Errorf("Expected 2 documents rolled back, got %+v", results)
+		}
+		if count, _ := rules.CountDocuments(ctx, bson.M{"workspace": bson.M{"$exists": false}}); count != 1 {
+			t.Errorf("Expected the rule's workspace to be unset, got %d rules without one", count)
+		}
+		if got := workspaceOf(rules, bson.M{"name": "Fearless"}); got != "warband" {
+			t.Errorf("Expected an assigned workspace to be kept, got %q", got)
+		}
+		if count, _ := revisions.CountDocuments(ctx, bson.M{"workspace": bson.M{"$exists": false}}); count != 1 {
+			t.Error("Expected the revision's workspace to be unset")
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package migrations
+
+import "grimdank-database/repositories"
+
+// All returns every migration in version order. New migrations are appended
+// with the next version number; released versions must never be renumbered.
+func All() []Migration {
+	return []Migration{
+		removeWarGearType(),
+		backfillTimestamps(),
+		assignDefaultWorkspace(),
+	}
+}
+
+// Latest returns the version of the newest migration, which is the schema
+// version of a fully migrated database
+func Latest() int {
+	latest := 0
+	for _, migration := range All() {
+		if migration.Version > latest {
+			latest = migration.Version
+		}
+	}
+	return latest
+}
+
+// removeWarGearType drops the type field that was removed from the WarGear model
+// (see WARGEAR_TYPE_REMOVAL_AND_AUTO_POINTS.md) but stayed on older documents
+func removeWarGearType() Migration {
+	up, down := removeField(1, "wargear", "type")
+	return Migration{
+		Version: 1,
+		Name:    "remove_wargear_type",
+		Up:      up,
+		Down:    down,
+	}
+}
+
+// backfillTimestamps gives documents written before every entity carried
+// createdAt and updatedAt both timestamps, so the sync endpoint can see them
+func backfillTimestamps() Migration {
+	up, down := fillTimestamps(2, "rules", "weapons", "wargear", "units", "armybooks", "armylists", "factions")
+	return Migration{
+		Version: 2,
+		Name:    "backfill_timestamps",
+		Up:      up,
+		Down:    down,
+	}
+}
+
+// assignDefaultWorkspace moves documents written before workspaces existed
+// into the default workspace, which every request without one works in
+func assignDefaultWorkspace() Migration {
+	up, down := fillField(3, repositories.WorkspaceField, repositories.DefaultWorkspace, "rules", "weapons", "wargear", "units", "armybooks", "armylists", "factions", "revisions")
+	return Migration{
+		Version: 3,
+		Name:    "assign_default_workspace",
+		Up:      up,
+		Down:    down,
+	}
+}
@@ -0,0 +1,268 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"grimdank-database/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// backupCollection keeps the values removed by removeField so they can be
+// restored, and notes the values the fill steps set so they can be unset
+const backupCollection = "schema_migration_backups"
+
+// fieldBackup is one removed value, or one value set where there was none
+type fieldBackup struct {
+	Migration  int         `bson:"migration"`
+	Collection string      `bson:"collection"`
+	DocumentID interface{} `bson:"documentId"`
+	Field      string      `bson:"field"`
+	Value      interface{} `bson:"value"`
+	Filled     bool        `bson:"filled,omitempty"`
+}
+
+// removeField builds the steps for dropping a top-level field that is no
+// longer part of a model. Up saves each value before unsetting it and Down
+// puts the saved values back.
+func removeField(version int, collection, field string) (up, down Step) {
+	up = func(ctx context.Context, store repositories.Store, dryRun bool) (int64, error) {
+		documents := store.Collection(collection)
+		backups := store.Collection(backupCollection)
+
+		var docs []bson.M
+		if err := documents.Find(ctx, bson.M{field: bson.M{"$exists": true}}, &docs, options.Find()); err != nil {
+			return 0, err
+		}
+		if dryRun {
+			return int64(len(docs)), nil
+		}
+
+		var changed int64
+		for _, doc := range docs {
+			backup := bson.M{"migration": version, "collection": collection, "documentId": doc["_id"], "field": field}
+
+			// A backup may already exist if a previous run stopped halfway
+			if _, err := backups.DeleteMany(ctx, backup); err != nil {
+				return changed, err
+			}
+			if _, err := backups.InsertOne(ctx, fieldBackup{
+				Migration:  version,
+				Collection: collection,
+				DocumentID: doc["_id"],
+				Field:      field,
+				Value:      doc[field],
+			}); err != nil {
+				return changed, fmt.Errorf("failed to back up %s.%s: %w", collection, field, err)
+			}
+
+			matched, err := documents.UpdateOne(ctx, bson.M{"_id": doc["_id"]}, bson.M{"$unset": bson.M{field: ""}})
+			if err != nil {
+				return changed, err
+			}
+			changed += matched
+		}
+		return changed, nil
+	}
+
+	down = func(ctx context.Context, store repositories.Store, dryRun bool) (int64, error) {
+		documents := store.Collection(collection)
+		backups := store.Collection(backupCollection)
+
+		var saved []fieldBackup
+		filter := bson.M{"migration": version, "collection": collection, "field": field}
+		if err := backups.Find(ctx, filter, &saved, options.Find()); err != nil {
+			return 0, err
+		}
+		if dryRun {
+			return int64(len(saved)), nil
+		}
+
+		var changed int64
+		for _, backup := range saved {
+			// Documents that got the field again since are left alone
+			matched, err := documents.UpdateOne(ctx,
+				bson.M{"_id": backup.DocumentID, field: bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{field: backup.Value}})
+			if err != nil {
+				return changed, err
+			}
+			changed += matched
+
+			if _, err := backups.DeleteMany(ctx, bson.M{"migration": version, "collection": collection, "documentId": backup.DocumentID, "field": field}); err != nil {
+				return changed, err
+			}
+		}
+		return changed, nil
+	}
+
+	return up, down
+}
+
+// fillTimestamps builds the steps for setting createdAt and updatedAt where a
+// document lacks them. A document is taken to be created when its ObjectID
+// was generated and last updated then, or when it was moved to the trash.
+// Timestamps that are already there are left alone. Down unsets the ones Up
+// set.
+func fillTimestamps(version int, collections ...string) (up, down Step) {
+	up = func(ctx context.Context, store repositories.Store, dryRun bool) (int64, error) {
+		missing := bson.M{"$or": bson.A{
+			bson.M{"createdAt": bson.M{"$exists": false}},
+			bson.M{"updatedAt": bson.M{"$exists": false}},
+		}}
+
+		var changed int64
+		for _, collection := range collections {
+			documents := store.Collection(collection)
+
+			var docs []bson.M
+			if err := documents.Find(ctx, missing, &docs, options.Find()); err != nil {
+				return changed, err
+			}
+			if dryRun {
+				changed += int64(len(docs))
+				continue
+			}
+
+			for _, doc := range docs {
+				fields := missingTimestamps(doc)
+				for field, value := range fields {
+					if err := noteFilled(ctx, store, version, collection, doc["_id"], field, value); err != nil {
+						return changed, err
+					}
+				}
+				matched, err := documents.UpdateOne(ctx, bson.M{"_id": doc["_id"]}, bson.M{"$set": fields})
+				if err != nil {
+					return changed, fmt.Errorf("failed to backfill timestamps of %s %v: %w", collection, doc["_id"], err)
+				}
+				changed += matched
+			}
+		}
+		return changed, nil
+	}
+	return up, unfill(version)
+}
+
+// fillField builds the steps for setting field to value on every document
+// of the collections that lacks it. Values that are already there are left
+// alone. Down unsets the ones Up set.
+func fillField(version int, field string, value interface{}, collections ...string) (up, down Step) {
+	up = func(ctx context.Context, store repositories.Store, dryRun bool) (int64, error) {
+		missing := bson.M{field: bson.M{"$exists": false}}
+
+		var changed int64
+		for _, collection := range collections {
+			documents := store.Collection(collection)
+
+			var docs []bson.M
+			if err := documents.Find(ctx, missing, &docs, options.Find()); err != nil {
+				return changed, err
+			}
+			if dryRun {
+				changed += int64(len(docs))
+				continue
+			}
+
+			for _, doc := range docs {
+				if err := noteFilled(ctx, store, version, collection, doc["_id"], field, value); err != nil {
+					return changed, err
+				}
+				matched, err := documents.UpdateOne(ctx, bson.M{"_id": doc["_id"]}, bson.M{"$set": bson.M{field: value}})
+				if err != nil {
+					return changed, fmt.Errorf("failed to set %s of %s %v: %w", field, collection, doc["_id"], err)
+				}
+				changed += matched
+			}
+		}
+		return changed, nil
+	}
+	return up, unfill(version)
+}
+
+// noteFilled records that a migration is about to set a field the document
+// lacks, so unfill can unset it again
+func noteFilled(ctx context.Context, store repositories.Store, version int, collection string, id interface{}, field string, value interface{}) error {
+	backups := store.Collection(backupCollection)
+	backup := bson.M{"migration": version, "collection": collection, "documentId": id, "field": field}
+
+	// A note may already exist if a previous run stopped halfway
+	if _, err := backups.DeleteMany(ctx, backup); err != nil {
+		return err
+	}
+	if _, err := backups.InsertOne(ctx, fieldBackup{
+		Migration:  version,
+		Collection: collection,
+		DocumentID: id,
+		Field:      field,
+		Value:      value,
+		Filled:     true,
+	}); err != nil {
+		return fmt.Errorf("failed to back up %s.%s: %w", collection, field, err)
+	}
+	return nil
+}
+
+// unfill builds the step that rolls back a fill: it unsets every field the
+// migration set, unless it has been changed since
+func unfill(version int) Step {
+	return func(ctx context.Context, store repositories.Store, dryRun bool) (int64, error) {
+		backups := store.Collection(backupCollection)
+
+		var filled []fieldBackup
+		if err := backups.Find(ctx, bson.M{"migration": version, "filled": true}, &filled, options.Find()); err != nil {
+			return 0, err
+		}
+		if dryRun {
+			return int64(len(filled)), nil
+		}
+
+		// A document counts once however many of its fields are unset
+		changed := map[string]bool{}
+		for _, backup := range filled {
+			// Values written since are left alone
+			matched, err := store.Collection(backup.Collection).UpdateOne(ctx,
+				bson.M{"_id": backup.DocumentID, backup.Field: backup.Value},
+				bson.M{"$unset": bson.M{backup.Field: ""}})
+			if err != nil {
+				return int64(len(changed)), err
+			}
+			if matched > 0 {
+				changed[fmt.Sprintf("%s %v", backup.Collection, backup.DocumentID)] = true
+			}
+
+			if _, err := backups.DeleteMany(ctx, bson.M{"migration": version, "collection": backup.Collection, "documentId": backup.DocumentID, "field": backup.Field}); err != nil {
+				return int64(len(changed)), err
+			}
+		}
+		return int64(len(changed)), nil
+	}
+}
+
+// missingTimestamps works out the timestamps a document lacks
+func missingTimestamps(doc bson.M) bson.M {
+	createdAt, hasCreatedAt := doc["createdAt"].(primitive.DateTime)
+	if !hasCreatedAt {
+		createdAt = primitive.NewDateTimeFromTime(time.Now())
+		if id, ok := doc["_id"].(primitive.ObjectID); ok {
+			createdAt = primitive.NewDateTimeFromTime(id.Timestamp())
+		}
+	}
+
+	updatedAt := createdAt
+	if deletedAt, ok := doc["deletedAt"].(primitive.DateTime); ok && deletedAt > updatedAt {
+		updatedAt = deletedAt
+	}
+
+	fields := bson.M{}
+	if _, ok := doc["createdAt"]; !ok {
+		fields["createdAt"] = createdAt
+	}
+	if _, ok := doc["updatedAt"]; !ok {
+		fields["updatedAt"] = updatedAt
+	}
+	return fields
+}
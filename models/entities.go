@@ -3,15 +3,35 @@ package models
 import (
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Rule represents a game rule
 type Rule struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Version     int                `bson:"version" json:"version"`
+	Workspace   string             `bson:"workspace" json:"workspace"`
 	Name        string             `bson:"name" json:"name" validate:"required"`
 	Description string             `bson:"description" json:"description"`
 	Points      []int              `bson:"points" json:"points"`
+	Effects     []RuleEffect       `bson:"effects" json:"effects,omitempty"` // priced instead of the description when present
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+	DeletedAt   *time.Time         `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
+}
+
+// RuleEffect is a machine-readable effect of a rule, as the Universal Special
+// Rules describe them: a change to a stat, such as {stat: Defense, modifier:
+// +tier} or {stat: Range, add: 6*tier}, a trigger that limits when the rule
+// applies, such as {trigger: once_per_game}, or both. Amounts are products of
+// numbers and "tier", with an optional sign.
+type RuleEffect struct {
+	Stat     string `bson:"stat,omitempty" json:"stat,omitempty"`         // Defense, Ranged, Melee, Morale, Speed, Charge, AP, Attacks or Range
+	Modifier string `bson:"modifier,omitempty" json:"modifier,omitempty"` // change to the stat's rolls
+	Add      string `bson:"add,omitempty" json:"add,omitempty"`           // amount added to the stat, e.g. inches of range
+	Trigger  string `bson:"trigger,omitempty" json:"trigger,omitempty"`   // limits this effect, or the whole rule when it has no stat
+	Aura     bool   `bson:"aura,omitempty" json:"aura,omitempty"`         // also applies to nearby friendly units, as Leadership does
 }
 
 // RuleReference represents a reference to a rule with optional tier selection
@@ -29,28 +49,41 @@ type WeaponReference struct {
 
 // Weapon represents a weapon in the game
 type Weapon struct {
-	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name    string             `bson:"name" json:"name" validate:"required"`
-	Type    string             `bson:"type" json:"type"`
-	Range   int                `bson:"range" json:"range"`
-	AP      string             `bson:"ap" json:"ap"`
-	Attacks int                `bson:"attacks" json:"attacks"`
-	Rules   []RuleReference    `bson:"rules" json:"rules"`
-	Points  int                `bson:"points" json:"points"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Version   int                `bson:"version" json:"version"`
+	Workspace string             `bson:"workspace" json:"workspace"`
+	Name      string             `bson:"name" json:"name" validate:"required"`
+	Type      string             `bson:"type" json:"type"`
+	Range     int                `bson:"range" json:"range"`
+	AP        string             `bson:"ap" json:"ap"`
+	Attacks   int                `bson:"attacks" json:"attacks"`
+	Rules     []RuleReference    `bson:"rules" json:"rules"`
+	Points    int                `bson:"points" json:"points"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+	DeletedAt *time.Time         `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
 }
 
 // WarGear represents wargear items
 type WarGear struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name        string             `bson:"name" json:"name" validate:"required"`
-	Description string             `bson:"description" json:"description"`
-	Points      int                `bson:"points" json:"points"`
-	Rules       []RuleReference    `bson:"rules" json:"rules"`
+	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Version     int                  `bson:"version" json:"version"`
+	Workspace   string               `bson:"workspace" json:"workspace"`
+	Name        string               `bson:"name" json:"name" validate:"required"`
+	Description string               `bson:"description" json:"description"`
+	Points      int                  `bson:"points" json:"points"`
+	Rules       []RuleReference      `bson:"rules" json:"rules"`
+	AssetIDs    []primitive.ObjectID `bson:"assetIds" json:"assetIds"`
+	CreatedAt   time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time            `bson:"updatedAt" json:"updatedAt"`
+	DeletedAt   *time.Time           `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
 }
 
 // Unit represents a game unit
 type Unit struct {
 	ID               primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Version          int                  `bson:"version" json:"version"`
+	Workspace        string               `bson:"workspace" json:"workspace"`
 	Name             string               `bson:"name" json:"name" validate:"required"`
 	Type             string               `bson:"type" json:"type"`
 	Melee            int                  `bson:"melee" json:"melee"`
@@ -65,27 +98,42 @@ type Unit struct {
 	AvailableWarGear []primitive.ObjectID `bson:"availableWarGearIds" json:"availableWarGearIds"`
 	Weapons          []WeaponReference    `bson:"weapons" json:"weapons"`
 	WarGear          []primitive.ObjectID `bson:"warGearIds" json:"warGearIds"`
+	AssetIDs         []primitive.ObjectID `bson:"assetIds" json:"assetIds"` // artwork for the unit card
+	CreatedAt        time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt        time.Time            `bson:"updatedAt" json:"updatedAt"`
+	DeletedAt        *time.Time           `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
 }
 
 // ArmyBook represents an army book
 type ArmyBook struct {
 	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Version     int                  `bson:"version" json:"version"`
+	Workspace   string               `bson:"workspace" json:"workspace"`
 	Name        string               `bson:"name" json:"name" validate:"required"`
 	FactionID   primitive.ObjectID   `bson:"factionId" json:"factionId"`
 	Description string               `bson:"description" json:"description"`
 	Units       []primitive.ObjectID `bson:"unitIds" json:"unitIds"`
 	Rules       []RuleReference      `bson:"rules" json:"rules"`
+	CreatedAt   time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time            `bson:"updatedAt" json:"updatedAt"`
+	DeletedAt   *time.Time           `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
 }
 
 // ArmyList represents a player's army list
 type ArmyList struct {
 	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Version     int                  `bson:"version" json:"version"`
+	Workspace   string               `bson:"workspace" json:"workspace"`
 	Name        string               `bson:"name" json:"name" validate:"required"`
 	Player      string               `bson:"player" json:"player"`
 	FactionID   primitive.ObjectID   `bson:"factionId" json:"factionId"`
 	Points      int                  `bson:"points" json:"points"`
+	PointsLimit int                  `bson:"pointsLimit" json:"pointsLimit"` // 0 for no limit
 	Units       []primitive.ObjectID `bson:"unitIds" json:"unitIds"`
 	Description string               `bson:"description" json:"description"`
+	CreatedAt   time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time            `bson:"updatedAt" json:"updatedAt"`
+	DeletedAt   *time.Time           `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
 }
 
 // RuleWithTier represents a rule with tier information
@@ -96,23 +144,75 @@ type RuleWithTier struct {
 
 // Faction represents a game faction
 type Faction struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Version     int                  `bson:"version" json:"version"`
+	Workspace   string               `bson:"workspace" json:"workspace"`
+	Name        string               `bson:"name" json:"name"`
+	Description string               `bson:"description" json:"description"`
+	Type        string               `bson:"type" json:"type"`         // "Official" or "Custom"
+	AssetIDs    []primitive.ObjectID `bson:"assetIds" json:"assetIds"` // faction icons
+	CreatedAt   time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time            `bson:"updatedAt" json:"updatedAt"`
+	DeletedAt   *time.Time           `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
+}
+
+// Faction types. Official factions are shared read-only with every workspace.
+const (
+	FactionTypeOfficial = "Official"
+	FactionTypeCustom   = "Custom"
+)
+
+// Workspace is a dataset of its own for one group. Entities name the
+// workspace they belong to by its slug.
+type Workspace struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Slug        string             `bson:"slug" json:"slug"`
 	Name        string             `bson:"name" json:"name"`
 	Description string             `bson:"description" json:"description"`
-	Type        string             `bson:"type" json:"type"` // "Official" or "Custom"
+	Members     []WorkspaceMember  `bson:"members" json:"members"`
 	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
 
+// Workspace member roles. Owners manage the members.
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleMember = "member"
+)
+
+// WorkspaceMember is an actor who may use a workspace
+type WorkspaceMember struct {
+	Actor   string    `bson:"actor" json:"actor"`
+	Role    string    `bson:"role" json:"role"`
+	AddedAt time.Time `bson:"addedAt" json:"addedAt"`
+}
+
+// Revision is an immutable copy of an entity as it was right after one
+// change. Version is the entity's version at that point, so revisions line
+// up with ETags.
+type Revision struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EntityType string             `bson:"entityType" json:"entityType"`
+	EntityID   primitive.ObjectID `bson:"entityId" json:"entityId"`
+	Version    int                `bson:"version" json:"version"`
+	Action     string             `bson:"action" json:"action"` // "create", "update", "delete" or "revert"
+	Actor      string             `bson:"actor" json:"actor"`
+	Reason     string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Timestamp  time.Time          `bson:"timestamp" json:"timestamp"`
+	Document   bson.M             `bson:"document" json:"document"`
+}
+
 // Populated entities for API responses (when you need the full data)
 type PopulatedWeapon struct {
 	Weapon
-	PopulatedRules []RuleWithTier `json:"populatedRules"`
+	PopulatedRules    []RuleWithTier     `json:"populatedRules"`
+	MissingReferences []MissingReference `json:"missingReferences,omitempty"`
 }
 
 type PopulatedWarGear struct {
 	WarGear
-	PopulatedRules []Rule `json:"populatedRules"`
+	PopulatedRules    []Rule             `json:"populatedRules"`
+	MissingReferences []MissingReference `json:"missingReferences,omitempty"`
 }
 
 // PopulatedWeaponReference combines weapon details with quantity/type info
@@ -122,6 +222,13 @@ type PopulatedWeaponReference struct {
 	Type     string `json:"type"`
 }
 
+// MissingReference is a reference to an entity that no longer exists or is
+// in the trash. Field is the referencing field, e.g. "availableWeaponIds".
+type MissingReference struct {
+	Field string             `json:"field"`
+	ID    primitive.ObjectID `json:"id"`
+}
+
 type PopulatedUnit struct {
 	Unit
 	PopulatedRules            []Rule                     `json:"populatedRules"`
@@ -129,15 +236,157 @@ type PopulatedUnit struct {
 	PopulatedAvailableWarGear []WarGear                  `json:"populatedAvailableWarGear"`
 	PopulatedWeapons          []PopulatedWeaponReference `json:"populatedWeapons"`
 	PopulatedWarGear          []WarGear                  `json:"populatedWarGear"`
+	MissingReferences         []MissingReference         `json:"missingReferences,omitempty"`
 }
 
 type PopulatedArmyBook struct {
 	ArmyBook
-	PopulatedUnits []Unit `json:"populatedUnits"`
-	PopulatedRules []Rule `json:"populatedRules"`
+	PopulatedUnits    []Unit             `json:"populatedUnits"`
+	PopulatedRules    []Rule             `json:"populatedRules"`
+	MissingReferences []MissingReference `json:"missingReferences,omitempty"`
 }
 
 type PopulatedArmyList struct {
 	ArmyList
-	PopulatedUnits []Unit `json:"populatedUnits"`
+	PopulatedUnits    []Unit             `json:"populatedUnits"`
+	MissingReferences []MissingReference `json:"missingReferences,omitempty"`
+}
+
+// Asset is an uploaded image. Its bytes are kept in the asset store; this is
+// the metadata that describes them.
+type Asset struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Workspace   string             `bson:"workspace" json:"workspace"`
+	Filename    string             `bson:"filename" json:"filename"`
+	ContentType string             `bson:"contentType" json:"contentType"`
+	Size        int64              `bson:"size" json:"size"`
+	Width       int                `bson:"width" json:"width"`
+	Height      int                `bson:"height" json:"height"`
+	Thumbnail   *Thumbnail         `bson:"thumbnail,omitempty" json:"thumbnail,omitempty"` // only for PNG and JPEG
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// Thumbnail is a scaled-down copy of an asset, stored alongside it
+type Thumbnail struct {
+	ID     primitive.ObjectID `bson:"id" json:"id"`
+	Size   int64              `bson:"size" json:"size"`
+	Width  int                `bson:"width" json:"width"`
+	Height int                `bson:"height" json:"height"`
+}
+
+// PointsSeason is a named set of the weights and thresholds the points
+// calculators use. One season is current; the others are kept so that costs
+// can be calculated as they were in earlier seasons. Should two seasons be
+// marked current, the one activated last is.
+type PointsSeason struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	Current     bool               `bson:"current" json:"current"`
+	ActivatedAt time.Time          `bson:"activatedAt,omitempty" json:"activatedAt,omitempty"`
+	Rules       RulePointsConfig   `bson:"rules" json:"rules"`
+	Weapons     WeaponPointsConfig `bson:"weapons" json:"weapons"`
+	Units       UnitPointsConfig   `bson:"units" json:"units"`
+	Version     int                `bson:"version" json:"version"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// RulePointsConfig drives the analysis of rule text into points
+type RulePointsConfig struct {
+	// Keyword analysis thresholds
+	HighImpactThreshold int `bson:"highImpactThreshold" json:"highImpactThreshold"`
+	StrongThreshold     int `bson:"strongThreshold" json:"strongThreshold"`
+	ModerateThreshold   int `bson:"moderateThreshold" json:"moderateThreshold"`
+
+	// Complexity analysis thresholds
+	HighComplexityThreshold   int `bson:"highComplexityThreshold" json:"highComplexityThreshold"`
+	MediumComplexityThreshold int `bson:"mediumComplexityThreshold" json:"mediumComplexityThreshold"`
+
+	// Numerical value thresholds
+	OverpoweredThreshold       int `bson:"overpoweredThreshold" json:"overpoweredThreshold"`
+	StrongNumericalThreshold   int `bson:"strongNumericalThreshold" json:"strongNumericalThreshold"`
+	ModerateNumericalThreshold int `bson:"moderateNumericalThreshold" json:"moderateNumericalThreshold"`
+
+	// Multiplier bounds
+	MaxMultiplier float64 `bson:"maxMultiplier" json:"maxMultiplier"`
+	MinMultiplier float64 `bson:"minMultiplier" json:"minMultiplier"`
+
+	// Complexity bounds
+	MaxComplexity int `bson:"maxComplexity" json:"maxComplexity"`
+	MinComplexity int `bson:"minComplexity" json:"minComplexity"`
+
+	// Frequency analysis keywords
+	PassiveKeywords  []string `bson:"passiveKeywords" json:"passiveKeywords"`
+	LimitedKeywords  []string `bson:"limitedKeywords" json:"limitedKeywords"`
+	FrequentKeywords []string `bson:"frequentKeywords" json:"frequentKeywords"`
+
+	// High impact keywords
+	HighImpactKeywords []string `bson:"highImpactKeywords" json:"highImpactKeywords"`
+
+	// Complexity keywords
+	ComplexityKeywords []string `bson:"complexityKeywords" json:"complexityKeywords"`
+
+	// Base effectiveness keywords, counted per level
+	OverpoweredKeywords []string `bson:"overpoweredKeywords" json:"overpoweredKeywords"`
+	StrongKeywords      []string `bson:"strongKeywords" json:"strongKeywords"`
+	ModerateKeywords    []string `bson:"moderateKeywords" json:"moderateKeywords"`
+	MinimalKeywords     []string `bson:"minimalKeywords" json:"minimalKeywords"`
+
+	// Keywords mapped to "minimal", "moderate", "strong" or "overpowered"
+	BaseEffectivenessKeywords map[string]string `bson:"baseEffectivenessKeywords" json:"baseEffectivenessKeywords"`
+
+	// Rule effects: points per point of change to each stat, the share of the
+	// cost paid for each trigger and the cost of an aura relative to the same
+	// effect on the unit alone. Stats and triggers left out, and an aura
+	// multiplier of 0, fall back to the built-in values.
+	EffectWeights      map[string]float64 `bson:"effectWeights" json:"effectWeights"`
+	TriggerMultipliers map[string]float64 `bson:"triggerMultipliers" json:"triggerMultipliers"`
+	AuraMultiplier     float64            `bson:"auraMultiplier" json:"auraMultiplier"`
+}
+
+// WeaponPointsConfig drives the calculation of weapon points from stats
+type WeaponPointsConfig struct {
+	RangedWeights StatWeights `bson:"rangedWeights" json:"rangedWeights"`
+	MeleeWeights  StatWeights `bson:"meleeWeights" json:"meleeWeights"`
+	MaxRange      int         `bson:"maxRange" json:"maxRange"`   // longer ranges score the same
+	ScaleBase     float64     `bson:"scaleBase" json:"scaleBase"` // points grow as ScaleBase^(score/2)
+	MinPoints     int         `bson:"minPoints" json:"minPoints"`
+	MaxPoints     int         `bson:"maxPoints" json:"maxPoints"`
+
+	// Pricing is "legacy" (the default) for the weighted stat scores above, or
+	// "statistical" to price by expected wounds
+	Pricing     string                   `bson:"pricing" json:"pricing"`
+	Statistical StatisticalWeaponPricing `bson:"statistical" json:"statistical"`
+}
+
+// StatisticalWeaponPricing prices weapons by the wounds they are expected to
+// cause in one activation against a spread of defensive profiles. Without
+// defenses the built-in settings are used.
+type StatisticalWeaponPricing struct {
+	Skill          int              `bson:"skill" json:"skill"` // hit target of the wielder when it isn't known
+	Defenses       []DefenseProfile `bson:"defenses" json:"defenses"`
+	PointsPerWound float64          `bson:"pointsPerWound" json:"pointsPerWound"`
+	RangeBonus     float64          `bson:"rangeBonus" json:"rangeBonus"` // share of the points added per 12" of range, for ranged weapons
+}
+
+// DefenseProfile is a target's Defense and how common such targets are
+type DefenseProfile struct {
+	Defense int     `bson:"defense" json:"defense"`
+	Weight  float64 `bson:"weight" json:"weight"`
+}
+
+// StatWeights is how much each weapon stat counts towards its score
+type StatWeights struct {
+	Range   float64 `bson:"range" json:"range"`
+	Attacks float64 `bson:"attacks" json:"attacks"`
+	AP      float64 `bson:"ap" json:"ap"`
+}
+
+// UnitPointsConfig is the unit base cost formula:
+// (melee + ranged + morale + defense) * StatMultiplier + BaseCost, at least MinCost
+type UnitPointsConfig struct {
+	StatMultiplier int `bson:"statMultiplier" json:"statMultiplier"`
+	BaseCost       int `bson:"baseCost" json:"baseCost"`
+	MinCost        int `bson:"minCost" json:"minCost"`
 }
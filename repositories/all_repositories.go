@@ -2,11 +2,12 @@ package repositories
 
 import (
 	"context"
+	"time"
+
 	"grimdank-database/models"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // WarGear Repository
@@ -14,13 +15,29 @@ type WarGearRepository struct {
 	*BaseRepository
 }
 
-func NewWarGearRepository(collection *mongo.Collection) *WarGearRepository {
+func NewWarGearRepository(collection Collection) *WarGearRepository {
+	base := NewBaseRepository(collection).
+		withSortField(SortByPoints, "points").
+		withFilterFields(map[string]FilterField{
+			"points":       numberField("points"),
+			"rules.ruleId": referenceField("rules.ruleId"),
+		})
 	return &WarGearRepository{
-		BaseRepository: NewBaseRepository(collection),
+		BaseRepository: base,
 	}
 }
 
+// Indexes declares the indexes wargear relies on
+func (r *WarGearRepository) Indexes() []IndexSpec {
+	return []IndexSpec{uniqueNameIndex(), fieldIndex("rules.ruleId"), fieldIndex("assetIds"), fieldIndex("updatedAt"), textSearchIndex("name", "description")}
+}
+
 func (r *WarGearRepository) CreateWarGear(ctx context.Context, wargear *models.WarGear) (string, error) {
+	wargear.Version = 1
+	wargear.Workspace = workspaceFor(ctx, wargear.Workspace)
+	now := time.Now()
+	wargear.CreatedAt = now
+	wargear.UpdatedAt = now
 	id, err := r.Create(ctx, wargear)
 	if err != nil {
 		return "", err
@@ -43,6 +60,13 @@ func (r *WarGearRepository) GetWarGearByID(ctx context.Context, id string) (*mod
 	return &wargear, nil
 }
 
+func (r *WarGearRepository) GetWarGearByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.WarGear, error) {
+	var wargear []models.WarGear
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+	err := r.GetAll(ctx, filter, &wargear, 0, 0)
+	return wargear, err
+}
+
 func (r *WarGearRepository) GetAllWarGear(ctx context.Context, limit, skip int64) ([]models.WarGear, error) {
 	wargear := make([]models.WarGear, 0)
 	err := r.GetAll(ctx, bson.M{}, &wargear, limit, skip)
@@ -67,13 +91,26 @@ func (r *WarGearRepository) SearchWarGearByName(ctx context.Context, name string
 	return wargear, nil
 }
 
+// ListWarGear returns one page of wargear, optionally only those whose name contains name,
+// and the cursor for the next page
+func (r *WarGearRepository) ListWarGear(ctx context.Context, name string, opts ListOptions) ([]models.WarGear, string, error) {
+	var wargear []models.WarGear
+	nextCursor, err := r.List(ctx, nameFilter(name), &wargear, opts)
+	return wargear, nextCursor, err
+}
+
 func (r *WarGearRepository) UpdateWarGear(ctx context.Context, id string, wargear *models.WarGear) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
 	wargear.ID = objectID
-	return r.Update(ctx, objectID, wargear)
+	version, err := r.UpdateVersioned(ctx, objectID, wargear.Version, wargear)
+	if err != nil {
+		return err
+	}
+	wargear.Version = version
+	return nil
 }
 
 func (r *WarGearRepository) DeleteWarGear(ctx context.Context, id string) error {
@@ -91,14 +128,17 @@ func (r *WarGearRepository) BulkImportWarGear(ctx context.Context, wargearList [
 
 	// Convert to interface{} slice for bulk insert
 	documents := make([]interface{}, len(wargearList))
+	now := time.Now()
 	for i, wargear := range wargearList {
+		wargear.Version = 1
+		wargear.Workspace = workspaceFor(ctx, wargear.Workspace)
+		wargear.CreatedAt = now
+		wargear.UpdatedAt = now
 		documents[i] = wargear
 	}
 
+	// On failure the documents inserted before it are still reported
 	insertedIDs, err := r.BulkInsert(ctx, documents)
-	if err != nil {
-		return nil, err
-	}
 
 	// Convert ObjectIDs to hex strings
 	hexIDs := make([]string, len(insertedIDs))
@@ -106,7 +146,7 @@ func (r *WarGearRepository) BulkImportWarGear(ctx context.Context, wargearList [
 		hexIDs[i] = id.Hex()
 	}
 
-	return hexIDs, nil
+	return hexIDs, err
 }
 
 // Unit Repository
@@ -114,13 +154,51 @@ type UnitRepository struct {
 	*BaseRepository
 }
 
-func NewUnitRepository(collection *mongo.Collection) *UnitRepository {
+func NewUnitRepository(collection Collection) *UnitRepository {
+	base := NewBaseRepository(collection).
+		withSortField(SortByPoints, "points").
+		withFilterFields(map[string]FilterField{
+			"type":                textField("type"),
+			"melee":               numberField("melee"),
+			"ranged":              numberField("ranged"),
+			"morale":              numberField("morale"),
+			"defense":             numberField("defense"),
+			"points":              numberField("points"),
+			"amount":              numberField("amount"),
+			"max":                 numberField("max"),
+			"rules.ruleId":        referenceField("rules.ruleId"),
+			"availableWeaponIds":  referenceField("availableWeaponIds"),
+			"weapons.weaponId":    referenceField("weapons.weaponId"),
+			"availableWarGearIds": referenceField("availableWarGearIds"),
+			"warGearIds":          referenceField("warGearIds"),
+		})
 	return &UnitRepository{
-		BaseRepository: NewBaseRepository(collection),
+		BaseRepository: base,
+	}
+}
+
+// Indexes declares the indexes units rely on, including the reference
+// fields searched when a rule, weapon or wargear is deleted
+func (r *UnitRepository) Indexes() []IndexSpec {
+	return []IndexSpec{
+		uniqueNameIndex(),
+		fieldIndex("rules.ruleId"),
+		fieldIndex("availableWeaponIds"),
+		fieldIndex("weapons.weaponId"),
+		fieldIndex("availableWarGearIds"),
+		fieldIndex("warGearIds"),
+		fieldIndex("assetIds"),
+		fieldIndex("updatedAt"),
+		textSearchIndex("name"),
 	}
 }
 
 func (r *UnitRepository) CreateUnit(ctx context.Context, unit *models.Unit) (string, error) {
+	unit.Version = 1
+	unit.Workspace = workspaceFor(ctx, unit.Workspace)
+	now := time.Now()
+	unit.CreatedAt = now
+	unit.UpdatedAt = now
 	id, err := r.Create(ctx, unit)
 	if err != nil {
 		return "", err
@@ -143,6 +221,13 @@ func (r *UnitRepository) GetUnitByID(ctx context.Context, id string) (*models.Un
 	return &unit, nil
 }
 
+func (r *UnitRepository) GetUnitsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Unit, error) {
+	var units []models.Unit
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+	err := r.GetAll(ctx, filter, &units, 0, 0)
+	return units, err
+}
+
 func (r *UnitRepository) GetAllUnits(ctx context.Context, limit, skip int64) ([]models.Unit, error) {
 	units := make([]models.Unit, 0)
 	err := r.GetAll(ctx, bson.M{}, &units, limit, skip)
@@ -167,13 +252,26 @@ func (r *UnitRepository) SearchUnitsByName(ctx context.Context, name string, lim
 	return units, nil
 }
 
+// ListUnits returns one page of units, optionally only those whose name contains name,
+// and the cursor for the next page
+func (r *UnitRepository) ListUnits(ctx context.Context, name string, opts ListOptions) ([]models.Unit, string, error) {
+	var units []models.Unit
+	nextCursor, err := r.List(ctx, nameFilter(name), &units, opts)
+	return units, nextCursor, err
+}
+
 func (r *UnitRepository) UpdateUnit(ctx context.Context, id string, unit *models.Unit) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
 	unit.ID = objectID
-	return r.Update(ctx, objectID, unit)
+	version, err := r.UpdateVersioned(ctx, objectID, unit.Version, unit)
+	if err != nil {
+		return err
+	}
+	unit.Version = version
+	return nil
 }
 
 func (r *UnitRepository) DeleteUnit(ctx context.Context, id string) error {
@@ -190,21 +288,24 @@ func (r *UnitRepository) BulkImportUnits(ctx context.Context, unitsList []models
 	}
 
 	documents := make([]interface{}, len(unitsList))
+	now := time.Now()
 	for i, unit := range unitsList {
+		unit.Version = 1
+		unit.Workspace = workspaceFor(ctx, unit.Workspace)
+		unit.CreatedAt = now
+		unit.UpdatedAt = now
 		documents[i] = unit
 	}
 
+	// On failure the documents inserted before it are still reported
 	insertedIDs, err := r.BulkInsert(ctx, documents)
-	if err != nil {
-		return nil, err
-	}
 
 	hexIDs := make([]string, len(insertedIDs))
 	for i, id := range insertedIDs {
 		hexIDs[i] = id.Hex()
 	}
 
-	return hexIDs, nil
+	return hexIDs, err
 }
 
 // ArmyBook Repository
@@ -212,13 +313,36 @@ type ArmyBookRepository struct {
 	*BaseRepository
 }
 
-func NewArmyBookRepository(collection *mongo.Collection) *ArmyBookRepository {
+func NewArmyBookRepository(collection Collection) *ArmyBookRepository {
+	base := NewBaseRepository(collection).
+		withFilterFields(map[string]FilterField{
+			"factionId":    idField("factionId"),
+			"unitIds":      referenceField("unitIds"),
+			"rules.ruleId": referenceField("rules.ruleId"),
+		})
 	return &ArmyBookRepository{
-		BaseRepository: NewBaseRepository(collection),
+		BaseRepository: base,
+	}
+}
+
+// Indexes declares the indexes army books rely on
+func (r *ArmyBookRepository) Indexes() []IndexSpec {
+	return []IndexSpec{
+		uniqueNameIndex(),
+		fieldIndex("factionId"),
+		fieldIndex("unitIds"),
+		fieldIndex("rules.ruleId"),
+		fieldIndex("updatedAt"),
+		textSearchIndex("name", "description"),
 	}
 }
 
 func (r *ArmyBookRepository) CreateArmyBook(ctx context.Context, armyBook *models.ArmyBook) (string, error) {
+	armyBook.Version = 1
+	armyBook.Workspace = workspaceFor(ctx, armyBook.Workspace)
+	now := time.Now()
+	armyBook.CreatedAt = now
+	armyBook.UpdatedAt = now
 	id, err := r.Create(ctx, armyBook)
 	if err != nil {
 		return "", err
@@ -265,13 +389,26 @@ func (r *ArmyBookRepository) SearchArmyBooksByName(ctx context.Context, name str
 	return armyBooks, nil
 }
 
+// ListArmyBooks returns one page of army books, optionally only those whose name contains name,
+// and the cursor for the next page
+func (r *ArmyBookRepository) ListArmyBooks(ctx context.Context, name string, opts ListOptions) ([]models.ArmyBook, string, error) {
+	var armyBooks []models.ArmyBook
+	nextCursor, err := r.List(ctx, nameFilter(name), &armyBooks, opts)
+	return armyBooks, nextCursor, err
+}
+
 func (r *ArmyBookRepository) UpdateArmyBook(ctx context.Context, id string, armyBook *models.ArmyBook) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
 	armyBook.ID = objectID
-	return r.Update(ctx, objectID, armyBook)
+	version, err := r.UpdateVersioned(ctx, objectID, armyBook.Version, armyBook)
+	if err != nil {
+		return err
+	}
+	armyBook.Version = version
+	return nil
 }
 
 func (r *ArmyBookRepository) DeleteArmyBook(ctx context.Context, id string) error {
@@ -288,21 +425,24 @@ func (r *ArmyBookRepository) BulkImportArmyBooks(ctx context.Context, armyBooksL
 	}
 
 	documents := make([]interface{}, len(armyBooksList))
+	now := time.Now()
 	for i, armyBook := range armyBooksList {
+		armyBook.Version = 1
+		armyBook.Workspace = workspaceFor(ctx, armyBook.Workspace)
+		armyBook.CreatedAt = now
+		armyBook.UpdatedAt = now
 		documents[i] = armyBook
 	}
 
+	// On failure the documents inserted before it are still reported
 	insertedIDs, err := r.BulkInsert(ctx, documents)
-	if err != nil {
-		return nil, err
-	}
 
 	hexIDs := make([]string, len(insertedIDs))
 	for i, id := range insertedIDs {
 		hexIDs[i] = id.Hex()
 	}
 
-	return hexIDs, nil
+	return hexIDs, err
 }
 
 // ArmyList Repository
@@ -310,13 +450,42 @@ type ArmyListRepository struct {
 	*BaseRepository
 }
 
-func NewArmyListRepository(collection *mongo.Collection) *ArmyListRepository {
+func NewArmyListRepository(collection Collection) *ArmyListRepository {
+	base := NewBaseRepository(collection).
+		withSortField(SortByPoints, "points").
+		withFilterFields(map[string]FilterField{
+			"player":    textField("player"),
+			"factionId": idField("factionId"),
+			"points":    numberField("points"),
+			"unitIds":   referenceField("unitIds"),
+		})
 	return &ArmyListRepository{
-		BaseRepository: NewBaseRepository(collection),
+		BaseRepository: base,
+	}
+}
+
+// Indexes declares the indexes army lists rely on. List names only need to
+// be unique per player within a workspace.
+func (r *ArmyListRepository) Indexes() []IndexSpec {
+	return []IndexSpec{
+		{
+			Name:            "player_name_ci_unique",
+			Keys:            bson.D{{Key: WorkspaceField, Value: 1}, {Key: "player", Value: 1}, {Key: "name", Value: 1}},
+			Unique:          true,
+			CaseInsensitive: true,
+		},
+		fieldIndex("factionId"),
+		fieldIndex("unitIds"),
+		fieldIndex("updatedAt"),
 	}
 }
 
 func (r *ArmyListRepository) CreateArmyList(ctx context.Context, armyList *models.ArmyList) (string, error) {
+	armyList.Version = 1
+	armyList.Workspace = workspaceFor(ctx, armyList.Workspace)
+	now := time.Now()
+	armyList.CreatedAt = now
+	armyList.UpdatedAt = now
 	id, err := r.Create(ctx, armyList)
 	if err != nil {
 		return "", err
@@ -363,13 +532,26 @@ func (r *ArmyListRepository) SearchArmyListsByName(ctx context.Context, name str
 	return armyLists, nil
 }
 
+// ListArmyLists returns one page of army lists, optionally only those whose name contains name,
+// and the cursor for the next page
+func (r *ArmyListRepository) ListArmyLists(ctx context.Context, name string, opts ListOptions) ([]models.ArmyList, string, error) {
+	var armyLists []models.ArmyList
+	nextCursor, err := r.List(ctx, nameFilter(name), &armyLists, opts)
+	return armyLists, nextCursor, err
+}
+
 func (r *ArmyListRepository) UpdateArmyList(ctx context.Context, id string, armyList *models.ArmyList) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
 	armyList.ID = objectID
-	return r.Update(ctx, objectID, armyList)
+	version, err := r.UpdateVersioned(ctx, objectID, armyList.Version, armyList)
+	if err != nil {
+		return err
+	}
+	armyList.Version = version
+	return nil
 }
 
 func (r *ArmyListRepository) DeleteArmyList(ctx context.Context, id string) error {
@@ -386,19 +568,22 @@ func (r *ArmyListRepository) BulkImportArmyLists(ctx context.Context, armyListsL
 	}
 
 	documents := make([]interface{}, len(armyListsList))
+	now := time.Now()
 	for i, armyList := range armyListsList {
+		armyList.Version = 1
+		armyList.Workspace = workspaceFor(ctx, armyList.Workspace)
+		armyList.CreatedAt = now
+		armyList.UpdatedAt = now
 		documents[i] = armyList
 	}
 
+	// On failure the documents inserted before it are still reported
 	insertedIDs, err := r.BulkInsert(ctx, documents)
-	if err != nil {
-		return nil, err
-	}
 
 	hexIDs := make([]string, len(insertedIDs))
 	for i, id := range insertedIDs {
 		hexIDs[i] = id.Hex()
 	}
 
-	return hexIDs, nil
+	return hexIDs, err
 }
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"grimdank-database/models"
+	"grimdank-database/utils"
+)
+
+// AssetRepository stores the metadata of uploaded assets. The bytes are in a
+// BlobStore under the asset's ID.
+type AssetRepository struct {
+	*BaseRepository
+}
+
+func NewAssetRepository(collection Collection) *AssetRepository {
+	return &AssetRepository{
+		BaseRepository: NewBaseRepository(collection),
+	}
+}
+
+// Indexes declares the indexes assets rely on
+func (r *AssetRepository) Indexes() []IndexSpec {
+	return []IndexSpec{fieldIndex("createdAt")}
+}
+
+// CreateAsset stores an asset's metadata. The ID is set by the caller, since
+// the blobs are written under it first.
+func (r *AssetRepository) CreateAsset(ctx context.Context, asset *models.Asset) error {
+	asset.Workspace = workspaceFor(ctx, asset.Workspace)
+	asset.CreatedAt = time.Now()
+	_, err := r.Create(ctx, asset)
+	return err
+}
+
+func (r *AssetRepository) GetAssetByID(ctx context.Context, id string) (*models.Asset, error) {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var asset models.Asset
+	if err := r.GetByID(ctx, objectID, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// DeleteAsset removes an asset's metadata for good; assets have no trash
+func (r *AssetRepository) DeleteAsset(ctx context.Context, id primitive.ObjectID) error {
+	deleted, err := r.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return utils.NewNotFoundError("document not found")
+	}
+	return nil
+}
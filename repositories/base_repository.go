@@ -2,7 +2,12 @@ package repositories
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"grimdank-database/database"
+	"grimdank-database/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -10,30 +15,49 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// notDeleted matches documents that have not been moved to the trash
+var notDeleted = bson.M{"$exists": false}
+
 type BaseRepository struct {
-	Collection *mongo.Collection
+	Collection   Collection
+	sortFields   map[string]string // sort keys accepted by List, mapped to document paths
+	defaultSort  string
+	filterFields map[string]FilterField // fields accepted by Where
 }
 
-func NewBaseRepository(collection *mongo.Collection) *BaseRepository {
+func NewBaseRepository(collection Collection) *BaseRepository {
 	return &BaseRepository{
 		Collection: collection,
+		sortFields: map[string]string{
+			SortByName: "name",
+			// ObjectIDs start with their creation time, so _id orders by creation
+			SortByCreatedAt: "_id",
+		},
+		defaultSort: SortByCreatedAt,
+		filterFields: map[string]FilterField{
+			"name": textField("name"),
+		},
 	}
 }
 
 func (r *BaseRepository) Create(ctx context.Context, document interface{}) (primitive.ObjectID, error) {
-	result, err := r.Collection.InsertOne(ctx, document)
+	id, err := r.Collection.InsertOne(ctx, document)
 	if err != nil {
-		return primitive.NilObjectID, err
+		return id, translateWriteError(err)
 	}
-	return result.InsertedID.(primitive.ObjectID), nil
+	database.OnRollback(ctx, func(ctx context.Context) error {
+		_, err := r.Collection.DeleteOne(ctx, bson.M{"_id": id})
+		return err
+	})
+	return id, nil
 }
 
 func (r *BaseRepository) GetByID(ctx context.Context, id primitive.ObjectID, result interface{}) error {
-	filter := bson.M{"_id": id}
-	err := r.Collection.FindOne(ctx, filter).Decode(result)
+	filter := bson.M{"_id": id, "deletedAt": notDeleted}
+	err := r.Collection.FindOne(ctx, filter, result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return errors.New("document not found")
+			return utils.NewNotFoundError("document not found")
 		}
 		return err
 	}
@@ -49,59 +73,587 @@ func (r *BaseRepository) GetAll(ctx context.Context, filter bson.M, results inte
 		opts.SetSkip(skip)
 	}
 
-	cursor, err := r.Collection.Find(ctx, filter, opts)
+	err := r.Collection.Find(ctx, withoutDeleted(filter), results, opts)
 	if err != nil {
 		return err
 	}
-	defer cursor.Close(ctx)
 
-	err = cursor.All(ctx, results)
+	// Ensure we return an empty slice instead of nil when no documents are found
+	// This prevents frontend crashes when trying to map over the results
+	return nil
+}
+
+// FindMatching lists up to limit live documents that match conditions, oldest first
+func (r *BaseRepository) FindMatching(ctx context.Context, conditions Filter, limit int64, results interface{}) error {
+	filter, err := r.Where(bson.M{}, conditions)
 	if err != nil {
 		return err
 	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	return r.Collection.Find(ctx, withoutDeleted(filter), results, opts)
+}
 
-	// Ensure we return an empty slice instead of nil when no documents are found
-	// This prevents frontend crashes when trying to map over the results
+func (r *BaseRepository) Update(ctx context.Context, id primitive.ObjectID, update interface{}) error {
+	_, err := r.UpdateVersioned(ctx, id, 0, update)
+	return err
+}
+
+// UpdateVersioned applies update only if the stored document is still at
+// expectedVersion and bumps the version by one. An expectedVersion of 0 skips
+// the check, which keeps clients that never read a version working.
+// It returns the new version of the document.
+func (r *BaseRepository) UpdateVersioned(ctx context.Context, id primitive.ObjectID, expectedVersion int, update interface{}) (int, error) {
+	fields, err := toDocument(update)
+	if err != nil {
+		return 0, err
+	}
+	// Version, timestamps and deletion state are owned by the repository, and
+	// _id and the workspace can never change
+	delete(fields, "version")
+	delete(fields, "deletedAt")
+	delete(fields, "createdAt")
+	delete(fields, "_id")
+	delete(fields, WorkspaceField)
+	fields["updatedAt"] = time.Now()
+
+	filter := bson.M{"_id": id, "deletedAt": notDeleted}
+	if expectedVersion > 0 {
+		filter["version"] = expectedVersion
+	}
+
+	updateDoc := bson.M{
+		"$set": fields,
+		"$inc": bson.M{"version": 1},
+	}
+
+	registerUndo, err := r.snapshotForUndo(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	// The version is read from the updated document itself, so a concurrent
+	// write can't slip in between and hand the caller someone else's version
+	var updated struct {
+		Version int `bson:"version"`
+	}
+	err = r.Collection.FindOneAndUpdate(ctx, filter, updateDoc, &updated)
+	if err == mongo.ErrNoDocuments {
+		current, err := r.currentVersion(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		return 0, utils.NewVersionConflictError(id.Hex(), expectedVersion, current)
+	}
+	if err != nil {
+		return 0, translateWriteError(err)
+	}
+	registerUndo()
+	return updated.Version, nil
+}
+
+// currentVersion reads the stored version of a document
+func (r *BaseRepository) currentVersion(ctx context.Context, id primitive.ObjectID) (int, error) {
+	var stored struct {
+		Version int `bson:"version"`
+	}
+	if err := r.GetByID(ctx, id, &stored); err != nil {
+		return 0, err
+	}
+	return stored.Version, nil
+}
+
+// Delete moves a document to the trash. It stays in the collection with a
+// deletedAt timestamp until it is restored or purged.
+func (r *BaseRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	now := time.Now()
+	filter := bson.M{"_id": id, "deletedAt": notDeleted}
+	update := bson.M{
+		"$set": bson.M{"deletedAt": now, "updatedAt": now},
+		"$inc": bson.M{"version": 1},
+	}
+	matched, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return utils.NewNotFoundError("document not found")
+	}
+	database.OnRollback(ctx, func(ctx context.Context) error {
+		_, err := r.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+			"$unset": bson.M{"deletedAt": ""},
+			"$inc":   bson.M{"version": 1},
+		})
+		return err
+	})
 	return nil
 }
 
-func (r *BaseRepository) Update(ctx context.Context, id primitive.ObjectID, update interface{}) error {
-	filter := bson.M{"_id": id}
-	updateDoc := bson.M{"$set": update}
-	result, err := r.Collection.UpdateOne(ctx, filter, updateDoc)
+// GetDeleted lists documents in the trash, most recently deleted first
+func (r *BaseRepository) GetDeleted(ctx context.Context, results interface{}, limit, skip int64) error {
+	opts := options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	if skip > 0 {
+		opts.SetSkip(skip)
+	}
+
+	return r.Collection.Find(ctx, bson.M{"deletedAt": bson.M{"$exists": true}}, results, opts)
+}
+
+// ChangedSince finds the live documents written at or after since, oldest
+// change first. Those created at or after since go into created and those
+// created before it go into updated.
+func (r *BaseRepository) ChangedSince(ctx context.Context, since time.Time, created, updated interface{}) error {
+	opts := options.Find().SetSort(bson.D{{Key: "updatedAt", Value: 1}})
+
+	filter := bson.M{"createdAt": bson.M{"$gte": since}, "deletedAt": notDeleted}
+	if err := r.Collection.Find(ctx, filter, created, opts); err != nil {
+		return err
+	}
+
+	filter = bson.M{
+		"createdAt": bson.M{"$lt": since},
+		"updatedAt": bson.M{"$gte": since},
+		"deletedAt": notDeleted,
+	}
+	return r.Collection.Find(ctx, filter, updated, opts)
+}
+
+// DeletedSince lists the documents moved to the trash at or after since
+func (r *BaseRepository) DeletedSince(ctx context.Context, since time.Time) ([]primitive.ObjectID, error) {
+	var documents []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	filter := bson.M{"deletedAt": bson.M{"$exists": true}, "updatedAt": bson.M{"$gte": since}}
+	opts := options.Find().SetSort(bson.D{{Key: "updatedAt", Value: 1}})
+	if err := r.Collection.Find(ctx, filter, &documents, opts); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(documents))
+	for i, document := range documents {
+		ids[i] = document.ID
+	}
+	return ids, nil
+}
+
+// CountDeleted counts the documents in the trash
+func (r *BaseRepository) CountDeleted(ctx context.Context) (int64, error) {
+	return r.Collection.CountDocuments(ctx, bson.M{"deletedAt": bson.M{"$exists": true}})
+}
+
+// Restore takes a document out of the trash
+func (r *BaseRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	filter := bson.M{"_id": id, "deletedAt": bson.M{"$exists": true}}
+	update := bson.M{
+		"$set":   bson.M{"updatedAt": time.Now()},
+		"$unset": bson.M{"deletedAt": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+	registerUndo, err := r.snapshotForUndo(ctx, id)
 	if err != nil {
 		return err
 	}
-	if result.MatchedCount == 0 {
-		return errors.New("document not found")
+	matched, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
 	}
+	if matched == 0 {
+		return utils.NewNotFoundError("document not found in trash")
+	}
+	registerUndo()
 	return nil
 }
 
-func (r *BaseRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
-	filter := bson.M{"_id": id}
-	result, err := r.Collection.DeleteOne(ctx, filter)
+// Purge permanently removes a document that is in the trash
+func (r *BaseRepository) Purge(ctx context.Context, id primitive.ObjectID) error {
+	filter := bson.M{"_id": id, "deletedAt": bson.M{"$exists": true}}
+	deleted, err := r.Collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return utils.NewNotFoundError("document not found in trash")
+	}
+	return nil
+}
+
+// PurgeDeletedBefore permanently removes every document deleted before cutoff
+func (r *BaseRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.Collection.DeleteMany(ctx, bson.M{"deletedAt": bson.M{"$lt": cutoff}})
+}
+
+// Snapshot reads a document exactly as it is stored, including documents in the trash
+func (r *BaseRepository) Snapshot(ctx context.Context, id primitive.ObjectID) (bson.M, error) {
+	var document bson.M
+	if err := r.Collection.FindOne(ctx, bson.M{"_id": id}, &document); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, utils.NewNotFoundError("document not found")
+		}
+		return nil, err
+	}
+	return document, nil
+}
+
+// SnapshotAll reads every document exactly as it is stored, including the
+// ones in the trash, oldest first
+func (r *BaseRepository) SnapshotAll(ctx context.Context) ([]bson.M, error) {
+	documents := []bson.M{}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if err := r.Collection.Find(ctx, bson.M{}, &documents, opts); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// Stored reports whether a document exists, in the trash or not
+func (r *BaseRepository) Stored(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	count, err := r.Collection.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CountStored counts every document, including the ones in the trash
+func (r *BaseRepository) CountStored(ctx context.Context) (int64, error) {
+	return r.Collection.CountDocuments(ctx, bson.M{})
+}
+
+// ReplaceAll removes every document, trash included, and inserts documents in
+// their place. It returns how many documents were removed. In a sequential
+// unit of work the removed documents are put back if the unit fails.
+func (r *BaseRepository) ReplaceAll(ctx context.Context, documents []interface{}) (int64, error) {
+	var previous []bson.M
+	if database.NeedsUndo(ctx) {
+		var err error
+		if previous, err = r.SnapshotAll(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	removed, err := r.Collection.DeleteMany(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	if len(previous) > 0 {
+		database.OnRollback(ctx, func(ctx context.Context) error {
+			restored := make([]interface{}, len(previous))
+			for i, document := range previous {
+				restored[i] = document
+			}
+			_, err := r.Collection.InsertMany(ctx, restored)
+			return err
+		})
+	}
+
+	if _, err := r.BulkInsert(ctx, documents); err != nil {
+		return removed, err
+	}
+	return removed, nil
+}
+
+// FindDuplicate finds the stored document that document collides with on a
+// unique index, including documents in the trash. It returns the nil ID when
+// there is none.
+func (r *BaseRepository) FindDuplicate(ctx context.Context, index IndexSpec, document bson.M) (primitive.ObjectID, error) {
+	filter := bson.M{}
+	for _, key := range index.Keys {
+		value := document[key.Key]
+		if text, ok := value.(string); ok && index.CaseInsensitive {
+			value = bson.M{"$regex": "^" + regexp.QuoteMeta(text) + "$", "$options": "i"}
+		}
+		filter[key.Key] = value
+	}
+
+	var existing struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := r.Collection.FindOne(ctx, filter, &existing); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return primitive.NilObjectID, nil
+		}
+		return primitive.NilObjectID, err
+	}
+	return existing.ID, nil
+}
+
+// Revert replaces a document with an earlier copy of itself, taking it out of
+// the trash if it is there. The version and updatedAt keep counting up from
+// the current ones rather than going back. It returns the new version of the
+// document.
+func (r *BaseRepository) Revert(ctx context.Context, id primitive.ObjectID, document bson.M) (int, error) {
+	current, err := r.Snapshot(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	version := DocumentVersion(current)
+
+	replacement := bson.M{}
+	for key, value := range document {
+		replacement[key] = value
+	}
+	delete(replacement, "deletedAt")
+	replacement["_id"] = id
+	replacement["version"] = version + 1
+	replacement["updatedAt"] = time.Now()
+	// Revisions recorded before documents had timestamps carry no createdAt
+	if createdAt, ok := current["createdAt"]; ok {
+		replacement["createdAt"] = createdAt
+	}
+
+	registerUndo, err := r.snapshotForUndo(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	matched, err := r.Collection.ReplaceOne(ctx, bson.M{"_id": id, "version": current["version"]}, replacement)
+	if err != nil {
+		return 0, translateWriteError(err)
+	}
+	if matched == 0 {
+		latest, err := r.Snapshot(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		return 0, utils.NewVersionConflictError(id.Hex(), version, DocumentVersion(latest))
+	}
+	registerUndo()
+	return version + 1, nil
+}
+
+// DocumentVersion reads the version of a raw document, whichever integer type it was stored as
+func DocumentVersion(document bson.M) int {
+	switch version := document["version"].(type) {
+	case int32:
+		return int(version)
+	case int64:
+		return int(version)
+	case int:
+		return version
+	case float64:
+		return int(version)
+	}
+	return 0
+}
+
+// DocumentSummary identifies a document when reporting it to a caller
+type DocumentSummary struct {
+	ID   primitive.ObjectID `bson:"_id" json:"id"`
+	Name string             `bson:"name" json:"name"`
+}
+
+// FindReferencing lists the live documents whose field at path holds id.
+// path may point into an array, e.g. "rules.ruleId" or "unitIds".
+func (r *BaseRepository) FindReferencing(ctx context.Context, path string, id primitive.ObjectID) ([]DocumentSummary, error) {
+	var documents []DocumentSummary
+	if err := r.GetAll(ctx, bson.M{path: id}, &documents, 0, 0); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// ReferenceField is an array field that holds references to other documents.
+// Element names the field of the array's sub-documents that holds the ID, or
+// is empty when the array holds plain IDs.
+type ReferenceField struct {
+	Array   string
+	Element string
+}
+
+// The reference fields that are attached and detached one element at a time
+var (
+	RuleReferences             = ReferenceField{Array: "rules", Element: "ruleId"}
+	AvailableWeaponReferences  = ReferenceField{Array: "availableWeaponIds"}
+	EquippedWeaponReferences   = ReferenceField{Array: "weapons", Element: "weaponId"}
+	AvailableWarGearReferences = ReferenceField{Array: "availableWarGearIds"}
+	EquippedWarGearReferences  = ReferenceField{Array: "warGearIds"}
+)
+
+// path is the query path of the referenced IDs
+func (f ReferenceField) path() string {
+	if f.Element == "" {
+		return f.Array
+	}
+	return f.Array + "." + f.Element
+}
+
+// PullReferences removes every reference to id from the given array fields
+// of one document in a single update
+func (r *BaseRepository) PullReferences(ctx context.Context, documentID, id primitive.ObjectID, fields []ReferenceField) error {
+	pulls := bson.M{}
+	for _, field := range fields {
+		if field.Element == "" {
+			pulls[field.Array] = id
+		} else {
+			pulls[field.Array] = bson.M{field.Element: id}
+		}
+	}
+
+	filter := bson.M{"_id": documentID, "deletedAt": notDeleted}
+	registerUndo, err := r.snapshotForUndo(ctx, documentID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$pull": pulls,
+		"$set":  bson.M{"updatedAt": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	matched, err := r.Collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
-	if result.DeletedCount == 0 {
-		return errors.New("document not found")
+	if matched == 0 {
+		return utils.NewNotFoundError("document not found")
+	}
+	registerUndo()
+	return nil
+}
+
+// snapshotForUndo reads the document as it is now, for writes that cannot be
+// reversed by a simple inverse update, and returns a function that registers
+// an undo putting it back. Call it once the write has succeeded. The write
+// must bump the version by one, and the undo only applies while the document
+// is still at that version, so it never reverts a later write of someone
+// else. Outside a sequential unit of work the function does nothing.
+func (r *BaseRepository) snapshotForUndo(ctx context.Context, id primitive.ObjectID) (func(), error) {
+	if !database.NeedsUndo(ctx) {
+		return func() {}, nil
+	}
+	var snapshot bson.M
+	if err := r.Collection.FindOne(ctx, bson.M{"_id": id}, &snapshot); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return func() {}, nil
+		}
+		return nil, err
+	}
+	written := DocumentVersion(snapshot) + 1
+	return func() {
+		database.OnRollback(ctx, func(ctx context.Context) error {
+			_, err := r.Collection.ReplaceOne(ctx, bson.M{"_id": id, "version": written}, snapshot)
+			return err
+		})
+	}, nil
+}
+
+// AttachReference appends element, which references id, to the array field of
+// one live document. The duplicate check and the push are a single update, so
+// concurrent attaches can neither lose each other's elements nor both add the
+// same reference. Returns a utils.AlreadyAttachedError if id is already there.
+func (r *BaseRepository) AttachReference(ctx context.Context, documentID, id primitive.ObjectID, field ReferenceField, element interface{}) error {
+	registerUndo, err := r.snapshotForUndo(ctx, documentID)
+	if err != nil {
+		return err
+	}
+
+	// $push fails on a null array, which is how documents created without any references store it
+	emptyArray := bson.M{"_id": documentID, field.Array: nil}
+	if _, err := r.Collection.UpdateOne(ctx, emptyArray, bson.M{"$set": bson.M{field.Array: bson.A{}}}); err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": documentID, "deletedAt": notDeleted, field.path(): bson.M{"$ne": id}}
+	update := bson.M{
+		"$push": bson.M{field.Array: element},
+		"$set":  bson.M{"updatedAt": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	matched, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		if err := r.requireLive(ctx, documentID); err != nil {
+			return err
+		}
+		return utils.NewAlreadyAttachedError(field.Array, id.Hex())
+	}
+	registerUndo()
+	return nil
+}
+
+// DetachReference removes the references to id from the array fields of one
+// live document in a single update. The first field must hold id; the others
+// are cleaned up along with it, e.g. the equipped weapons when a weapon stops
+// being available.
+func (r *BaseRepository) DetachReference(ctx context.Context, documentID, id primitive.ObjectID, fields ...ReferenceField) error {
+	pulls := bson.M{}
+	for _, field := range fields {
+		if field.Element == "" {
+			pulls[field.Array] = id
+		} else {
+			pulls[field.Array] = bson.M{field.Element: id}
+		}
+	}
+
+	filter := bson.M{"_id": documentID, "deletedAt": notDeleted, fields[0].path(): id}
+	registerUndo, err := r.snapshotForUndo(ctx, documentID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$pull": pulls,
+		"$set":  bson.M{"updatedAt": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	matched, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		if err := r.requireLive(ctx, documentID); err != nil {
+			return err
+		}
+		return fmt.Errorf("%s reference %s not found", fields[0].Array, id.Hex())
+	}
+	registerUndo()
+	return nil
+}
+
+// requireLive returns an error if the document does not exist or is in the trash
+func (r *BaseRepository) requireLive(ctx context.Context, id primitive.ObjectID) error {
+	count, err := r.Collection.CountDocuments(ctx, bson.M{"_id": id, "deletedAt": notDeleted})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return utils.NewNotFoundError("document not found")
 	}
 	return nil
 }
 
 func (r *BaseRepository) SearchByName(ctx context.Context, name string, results interface{}, limit, skip int64) error {
-	filter := bson.M{
+	return r.GetAll(ctx, nameFilter(name), results, limit, skip)
+}
+
+// TextSearch ranks the documents outside the trash against a text query using
+// the collection's text index, best match first
+func (r *BaseRepository) TextSearch(ctx context.Context, text string, limit int64) ([]TextMatch, error) {
+	return r.Collection.TextSearch(ctx, text, withoutDeleted(bson.M{}), limit)
+}
+
+// nameFilter matches names containing name regardless of case; an empty name
+// matches everything. name is matched literally, so "+1 Strength" finds itself
+// rather than failing to compile as a pattern.
+func nameFilter(name string) bson.M {
+	if name == "" {
+		return bson.M{}
+	}
+	return bson.M{
 		"name": bson.M{
-			"$regex":   name,
+			"$regex":   regexp.QuoteMeta(name),
 			"$options": "i",
 		},
 	}
-	return r.GetAll(ctx, filter, results, limit, skip)
 }
 
 func (r *BaseRepository) Count(ctx context.Context, filter bson.M) (int64, error) {
-	count, err := r.Collection.CountDocuments(ctx, filter)
+	count, err := r.Collection.CountDocuments(ctx, withoutDeleted(filter))
 	if err != nil {
 		return 0, err
 	}
@@ -113,15 +665,27 @@ func (r *BaseRepository) BulkInsert(ctx context.Context, documents []interface{}
 		return []primitive.ObjectID{}, nil
 	}
 
-	result, err := r.Collection.InsertMany(ctx, documents)
-	if err != nil {
-		return nil, err
+	ids, err := r.Collection.InsertMany(ctx, documents)
+	if len(ids) > 0 {
+		inserted := ids
+		database.OnRollback(ctx, func(ctx context.Context) error {
+			_, err := r.Collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": inserted}})
+			return err
+		})
 	}
+	return ids, translateWriteError(err)
+}
 
-	insertedIDs := make([]primitive.ObjectID, len(result.InsertedIDs))
-	for i, id := range result.InsertedIDs {
-		insertedIDs[i] = id.(primitive.ObjectID)
+// withoutDeleted adds the trash exclusion to a filter unless it already
+// says something about deletedAt
+func withoutDeleted(filter bson.M) bson.M {
+	if _, ok := filter["deletedAt"]; ok {
+		return filter
 	}
 
-	return insertedIDs, nil
+	scoped := bson.M{"deletedAt": notDeleted}
+	for key, value := range filter {
+		scoped[key] = value
+	}
+	return scoped
 }
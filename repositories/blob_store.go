@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrBlobNotFound is returned when a blob does not exist
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps the bytes of uploaded files by ID. Blobs are not scoped to
+// workspaces; callers look them up through metadata that is.
+type BlobStore interface {
+	Put(ctx context.Context, id primitive.ObjectID, filename string, content io.Reader) error
+	Open(ctx context.Context, id primitive.ObjectID) (io.ReadCloser, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// GridFSBlobStore keeps blobs in a MongoDB GridFS bucket
+type GridFSBlobStore struct {
+	database *mongo.Database
+	bucket   string
+}
+
+// NewGridFSBlobStore creates a blob store in the named bucket of the database
+func NewGridFSBlobStore(database *mongo.Database, bucket string) *GridFSBlobStore {
+	return &GridFSBlobStore{
+		database: database,
+		bucket:   bucket,
+	}
+}
+
+// open returns a bucket bounded by ctx's deadline. Buckets keep their
+// deadlines as state, so each call gets its own.
+func (s *GridFSBlobStore) open(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(s.database, options.GridFSBucket().SetName(s.bucket))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		bucket.SetReadDeadline(deadline)
+		bucket.SetWriteDeadline(deadline)
+	}
+	return bucket, nil
+}
+
+func (s *GridFSBlobStore) Put(ctx context.Context, id primitive.ObjectID, filename string, content io.Reader) error {
+	bucket, err := s.open(ctx)
+	if err != nil {
+		return err
+	}
+	return bucket.UploadFromStreamWithID(id, filename, content)
+}
+
+func (s *GridFSBlobStore) Open(ctx context.Context, id primitive.ObjectID) (io.ReadCloser, error) {
+	bucket, err := s.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := bucket.OpenDownloadStream(id)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrBlobNotFound
+	}
+	return stream, err
+}
+
+func (s *GridFSBlobStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	bucket, err := s.open(ctx)
+	if err != nil {
+		return err
+	}
+	err = bucket.DeleteContext(ctx, id)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return ErrBlobNotFound
+	}
+	return err
+}
+
+// FileBlobStore keeps blobs as files in a directory, for development
+type FileBlobStore struct {
+	dir string
+}
+
+// NewFileBlobStore creates a blob store in dir, creating the directory if needed
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create asset directory: %w", err)
+	}
+	return &FileBlobStore{dir: dir}, nil
+}
+
+func (s *FileBlobStore) path(id primitive.ObjectID) string {
+	return filepath.Join(s.dir, id.Hex())
+}
+
+// Put writes the blob to a temporary file first, so a failed write never
+// leaves a partial blob behind
+func (s *FileBlobStore) Put(ctx context.Context, id primitive.ObjectID, filename string, content io.Reader) error {
+	file, err := os.CreateTemp(s.dir, id.Hex()+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), s.path(id))
+}
+
+func (s *FileBlobStore) Open(ctx context.Context, id primitive.ObjectID) (io.ReadCloser, error) {
+	file, err := os.Open(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return file, err
+}
+
+func (s *FileBlobStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrBlobNotFound
+	}
+	return err
+}
@@ -0,0 +1,289 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection is the storage interface the repositories are built on.
+// It mirrors the subset of *mongo.Collection the repositories use so that
+// the MongoDB driver and the in-memory backend are interchangeable.
+type Collection interface {
+	InsertOne(ctx context.Context, document interface{}) (primitive.ObjectID, error)
+	// InsertMany inserts documents in order and stops at the first failure.
+	// It returns the IDs of the documents inserted, also when it fails.
+	InsertMany(ctx context.Context, documents []interface{}) ([]primitive.ObjectID, error)
+	FindOne(ctx context.Context, filter bson.M, result interface{}) error
+	Find(ctx context.Context, filter bson.M, results interface{}, opts *options.FindOptions) error
+	UpdateOne(ctx context.Context, filter bson.M, update bson.M) (int64, error)
+	// FindOneAndUpdate applies update to the first document matching filter
+	// and decodes the document as it is after the update into result. It
+	// returns mongo.ErrNoDocuments when nothing matches.
+	FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, result interface{}) error
+	ReplaceOne(ctx context.Context, filter bson.M, replacement interface{}) (int64, error)
+	DeleteOne(ctx context.Context, filter bson.M) (int64, error)
+	DeleteMany(ctx context.Context, filter bson.M) (int64, error)
+	CountDocuments(ctx context.Context, filter bson.M) (int64, error)
+	Drop(ctx context.Context) error
+
+	// Index management; the implicit _id index is never listed
+	ListIndexes(ctx context.Context) ([]IndexSpec, error)
+	CreateIndex(ctx context.Context, index IndexSpec) error
+	DropIndex(ctx context.Context, name string) error
+
+	// TextSearch finds up to limit documents matching filter whose text index
+	// matches text, best match first. It fails when there is no text index.
+	TextSearch(ctx context.Context, text string, filter bson.M, limit int64) ([]TextMatch, error)
+}
+
+// TextMatch is a document found by a text search and how well it matched
+type TextMatch struct {
+	Document bson.M
+	Score    float64
+}
+
+// Store hands out named collections from a single storage backend
+type Store interface {
+	Collection(name string) Collection
+}
+
+// MongoStore is a Store backed by a MongoDB database
+type MongoStore struct {
+	database *mongo.Database
+}
+
+// NewMongoStore creates a store that reads and writes the given database
+func NewMongoStore(database *mongo.Database) *MongoStore {
+	return &MongoStore{
+		database: database,
+	}
+}
+
+// Collection returns the named MongoDB collection wrapped as a Collection
+func (s *MongoStore) Collection(name string) Collection {
+	return NewMongoCollection(s.database.Collection(name))
+}
+
+// MongoCollection adapts a *mongo.Collection to the Collection interface
+type MongoCollection struct {
+	collection *mongo.Collection
+}
+
+// NewMongoCollection wraps a MongoDB collection
+func NewMongoCollection(collection *mongo.Collection) *MongoCollection {
+	return &MongoCollection{
+		collection: collection,
+	}
+}
+
+func (c *MongoCollection) InsertOne(ctx context.Context, document interface{}) (primitive.ObjectID, error) {
+	result, err := c.collection.InsertOne(ctx, document)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+func (c *MongoCollection) InsertMany(ctx context.Context, documents []interface{}) ([]primitive.ObjectID, error) {
+	result, err := c.collection.InsertMany(ctx, documents)
+	if result == nil {
+		return nil, err
+	}
+
+	// The result lists every document sent; an ordered insert stops at the first write error
+	inserted := len(result.InsertedIDs)
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0 {
+		inserted = bulkErr.WriteErrors[0].Index
+	} else if err != nil {
+		inserted = 0
+	}
+
+	insertedIDs := make([]primitive.ObjectID, inserted)
+	for i, id := range result.InsertedIDs[:inserted] {
+		insertedIDs[i] = id.(primitive.ObjectID)
+	}
+	return insertedIDs, err
+}
+
+func (c *MongoCollection) FindOne(ctx context.Context, filter bson.M, result interface{}) error {
+	return c.collection.FindOne(ctx, filter).Decode(result)
+}
+
+func (c *MongoCollection) Find(ctx context.Context, filter bson.M, results interface{}, opts *options.FindOptions) error {
+	if opts == nil {
+		opts = options.Find()
+	}
+
+	cursor, err := c.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	return cursor.All(ctx, results)
+}
+
+func (c *MongoCollection) UpdateOne(ctx context.Context, filter bson.M, update bson.M) (int64, error) {
+	result, err := c.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+func (c *MongoCollection) FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, result interface{}) error {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	return c.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(result)
+}
+
+func (c *MongoCollection) ReplaceOne(ctx context.Context, filter bson.M, replacement interface{}) (int64, error) {
+	result, err := c.collection.ReplaceOne(ctx, filter, replacement)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+func (c *MongoCollection) DeleteOne(ctx context.Context, filter bson.M) (int64, error) {
+	result, err := c.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (c *MongoCollection) DeleteMany(ctx context.Context, filter bson.M) (int64, error) {
+	result, err := c.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (c *MongoCollection) CountDocuments(ctx context.Context, filter bson.M) (int64, error) {
+	return c.collection.CountDocuments(ctx, filter)
+}
+
+func (c *MongoCollection) Drop(ctx context.Context) error {
+	return c.collection.Drop(ctx)
+}
+
+func (c *MongoCollection) ListIndexes(ctx context.Context) ([]IndexSpec, error) {
+	cursor, err := c.collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var raw []struct {
+		Name      string `bson:"name"`
+		Key       bson.D `bson:"key"`
+		Unique    bool   `bson:"unique"`
+		Weights   bson.M `bson:"weights"`
+		Collation *struct {
+			Locale   string `bson:"locale"`
+			Strength int    `bson:"strength"`
+		} `bson:"collation"`
+	}
+	if err := cursor.All(ctx, &raw); err != nil {
+		return nil, err
+	}
+
+	indexes := make([]IndexSpec, 0, len(raw))
+	for _, index := range raw {
+		if index.Name == "_id_" {
+			continue
+		}
+		spec := IndexSpec{
+			Name:            index.Name,
+			Keys:            index.Key,
+			Unique:          index.Unique,
+			CaseInsensitive: index.Collation != nil && index.Collation.Locale != "simple" && index.Collation.Strength <= 2,
+		}
+		if index.Weights != nil {
+			// Text indexes are listed under the internal keys _fts and _ftsx;
+			// the indexed fields only show up in the weights
+			fields := make([]string, 0, len(index.Weights))
+			for field := range index.Weights {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+
+			spec.Keys = bson.D{}
+			spec.Weights = make(map[string]int, len(fields))
+			for _, field := range fields {
+				weight, _ := toFloat(index.Weights[field])
+				spec.Keys = append(spec.Keys, bson.E{Key: field, Value: textKey})
+				spec.Weights[field] = int(weight)
+			}
+		}
+		indexes = append(indexes, spec)
+	}
+	return indexes, nil
+}
+
+func (c *MongoCollection) CreateIndex(ctx context.Context, index IndexSpec) error {
+	opts := options.Index().SetName(index.Name)
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+	if index.CaseInsensitive {
+		opts.SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	}
+	if len(index.Weights) > 0 {
+		weights := bson.M{}
+		for field, weight := range index.Weights {
+			weights[field] = weight
+		}
+		opts.SetWeights(weights)
+	}
+
+	_, err := c.collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: index.Keys, Options: opts})
+	return err
+}
+
+func (c *MongoCollection) DropIndex(ctx context.Context, name string) error {
+	_, err := c.collection.Indexes().DropOne(ctx, name)
+	return err
+}
+
+func (c *MongoCollection) TextSearch(ctx context.Context, text string, filter bson.M, limit int64) ([]TextMatch, error) {
+	query := bson.M{"$text": bson.M{"$search": text}}
+	for key, value := range filter {
+		query[key] = value
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"_score": score}).
+		SetSort(bson.D{{Key: "_score", Value: score}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cursor, err := c.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	matches := make([]TextMatch, len(docs))
+	for i, doc := range docs {
+		matches[i].Score, _ = toFloat(doc["_score"])
+		delete(doc, "_score")
+		matches[i].Document = doc
+	}
+	return matches, nil
+}
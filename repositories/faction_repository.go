@@ -7,33 +7,45 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"grimdank-database/models"
 )
 
 type FactionRepository struct {
-	collection *mongo.Collection
+	*BaseRepository
 }
 
-func NewFactionRepository(collection *mongo.Collection) *FactionRepository {
+func NewFactionRepository(collection Collection) *FactionRepository {
+	base := NewBaseRepository(collection).
+		withFilterFields(map[string]FilterField{
+			"type": textField("type"),
+		})
+	base.defaultSort = SortByName
 	return &FactionRepository{
-		collection: collection,
+		BaseRepository: base,
 	}
 }
 
+// Indexes declares the indexes factions rely on
+func (r *FactionRepository) Indexes() []IndexSpec {
+	return []IndexSpec{uniqueNameIndex(), fieldIndex("assetIds"), fieldIndex("updatedAt"), textSearchIndex("name", "description")}
+}
+
 func (r *FactionRepository) CreateFaction(ctx context.Context, faction *models.Faction) error {
-	faction.CreatedAt = time.Now()
-	faction.UpdatedAt = time.Now()
+	now := time.Now()
+	faction.Version = 1
+	faction.Workspace = workspaceFor(ctx, faction.Workspace)
+	faction.CreatedAt = now
+	faction.UpdatedAt = now
 
-	result, err := r.collection.InsertOne(ctx, faction)
+	id, err := r.Create(ctx, faction)
 	if err != nil {
 		return err
 	}
 
 	// Set the ID from the inserted document
-	faction.ID = result.InsertedID.(primitive.ObjectID)
+	faction.ID = id
 	return nil
 }
 
@@ -44,7 +56,7 @@ func (r *FactionRepository) GetFactionByID(ctx context.Context, id string) (*mod
 	}
 
 	var faction models.Faction
-	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&faction)
+	err = r.Collection.FindOne(ctx, bson.M{"_id": objectID, "deletedAt": notDeleted}, &faction)
 	if err != nil {
 		return nil, err
 	}
@@ -62,14 +74,8 @@ func (r *FactionRepository) GetAllFactions(ctx context.Context, limit, skip int)
 	}
 	opts.SetSort(bson.D{{Key: "name", Value: 1}})
 
-	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
 	var factions []models.Faction
-	if err = cursor.All(ctx, &factions); err != nil {
+	if err := r.Collection.Find(ctx, withoutDeleted(bson.M{}), &factions, opts); err != nil {
 		return nil, err
 	}
 
@@ -86,43 +92,38 @@ func (r *FactionRepository) SearchFactionsByName(ctx context.Context, name strin
 	}
 	opts.SetSort(bson.D{{Key: "name", Value: 1}})
 
-	filter := bson.M{
-		"name": bson.M{
-			"$regex":   name,
-			"$options": "i",
-		},
-	}
-
-	cursor, err := r.collection.Find(ctx, filter, opts)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
 	var factions []models.Faction
-	if err = cursor.All(ctx, &factions); err != nil {
+	if err := r.Collection.Find(ctx, withoutDeleted(nameFilter(name)), &factions, opts); err != nil {
 		return nil, err
 	}
 
 	return factions, nil
 }
 
+// ListFactions returns one page of factions, optionally only those whose name contains name,
+// and the cursor for the next page
+func (r *FactionRepository) ListFactions(ctx context.Context, name string, opts ListOptions) ([]models.Faction, string, error) {
+	var factions []models.Faction
+	nextCursor, err := r.List(ctx, nameFilter(name), &factions, opts)
+	return factions, nextCursor, err
+}
+
 func (r *FactionRepository) UpdateFaction(ctx context.Context, id string, faction *models.Faction) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
 
-	faction.UpdatedAt = time.Now()
-	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": objectID}, faction)
+	faction.ID = objectID
+	version, err := r.UpdateVersioned(ctx, objectID, faction.Version, faction)
 	if err != nil {
+		if err.Error() == "document not found" {
+			return fmt.Errorf("faction not found")
+		}
 		return err
 	}
 
-	if result.MatchedCount == 0 {
-		return fmt.Errorf("faction not found")
-	}
-
+	faction.Version = version
 	return nil
 }
 
@@ -132,15 +133,13 @@ func (r *FactionRepository) DeleteFaction(ctx context.Context, id string) error
 		return err
 	}
 
-	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
-	if err != nil {
+	if err := r.Delete(ctx, objectID); err != nil {
+		if err.Error() == "document not found" {
+			return fmt.Errorf("faction not found")
+		}
 		return err
 	}
 
-	if result.DeletedCount == 0 {
-		return fmt.Errorf("faction not found")
-	}
-
 	return nil
 }
 
@@ -154,21 +153,12 @@ func (r *FactionRepository) BulkImportFactions(ctx context.Context, factions []m
 	now := time.Now()
 
 	for i, faction := range factions {
+		faction.Version = 1
+		faction.Workspace = workspaceFor(ctx, faction.Workspace)
 		faction.CreatedAt = now
 		faction.UpdatedAt = now
 		docs[i] = faction
 	}
 
-	result, err := r.collection.InsertMany(ctx, docs)
-	if err != nil {
-		return nil, err
-	}
-
-	// Extract ObjectIDs from the result
-	objectIDs := make([]primitive.ObjectID, len(result.InsertedIDs))
-	for i, id := range result.InsertedIDs {
-		objectIDs[i] = id.(primitive.ObjectID)
-	}
-
-	return objectIDs, nil
+	return r.BulkInsert(ctx, docs)
 }
@@ -0,0 +1,219 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FilterOperator is a comparison allowed in a filter condition
+type FilterOperator string
+
+// Filter operators
+const (
+	OpEq  FilterOperator = "eq"
+	OpNe  FilterOperator = "ne"
+	OpGt  FilterOperator = "gt"
+	OpGte FilterOperator = "gte"
+	OpLt  FilterOperator = "lt"
+	OpLte FilterOperator = "lte"
+	OpIn  FilterOperator = "in"  // one of a comma-separated list; on arrays, contains any of them
+	OpNin FilterOperator = "nin" // none of a comma-separated list
+	OpHas FilterOperator = "has" // an array contains the value
+)
+
+// mongoOperators maps filter operators to the query operators they compile to.
+// has compiles to $eq because an equality match on an array means "contains".
+var mongoOperators = map[FilterOperator]string{
+	OpEq:  "$eq",
+	OpNe:  "$ne",
+	OpGt:  "$gt",
+	OpGte: "$gte",
+	OpLt:  "$lt",
+	OpLte: "$lte",
+	OpIn:  "$in",
+	OpNin: "$nin",
+	OpHas: "$eq",
+}
+
+// FieldKind decides how condition values are parsed
+type FieldKind int
+
+const (
+	FieldString FieldKind = iota
+	FieldInt
+	FieldObjectID
+)
+
+// FilterField is a field a collection can be filtered on and the operators it allows
+type FilterField struct {
+	Path      string
+	Kind      FieldKind
+	Operators []FilterOperator
+}
+
+func (f FilterField) allows(op FilterOperator) bool {
+	for _, allowed := range f.Operators {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// textField allows exact matches and membership on a string
+func textField(path string) FilterField {
+	return FilterField{Path: path, Kind: FieldString, Operators: []FilterOperator{OpEq, OpNe, OpIn, OpNin}}
+}
+
+// numberField allows exact matches, ranges and membership on a number or, for
+// arrays, on any of its elements
+func numberField(path string) FilterField {
+	return FilterField{Path: path, Kind: FieldInt, Operators: []FilterOperator{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpIn, OpNin}}
+}
+
+// idField allows exact matches and membership on a single reference
+func idField(path string) FilterField {
+	return FilterField{Path: path, Kind: FieldObjectID, Operators: []FilterOperator{OpEq, OpNe, OpIn, OpNin}}
+}
+
+// referenceField allows containment checks on an array of references
+func referenceField(path string) FilterField {
+	return FilterField{Path: path, Kind: FieldObjectID, Operators: []FilterOperator{OpHas, OpIn, OpNin}}
+}
+
+// Condition is one parsed filter expression, such as points:lt:15
+type Condition struct {
+	Field    string
+	Operator FilterOperator
+	Value    string
+}
+
+// Filter is a set of conditions that must all hold
+type Filter []Condition
+
+// ParseFilter parses expressions of the form field:operator:value. Only the
+// syntax is checked here; fields and values are checked against the
+// collection when the filter is compiled.
+func ParseFilter(expressions []string) (Filter, error) {
+	filter := make(Filter, 0, len(expressions))
+	for _, expression := range expressions {
+		parts := strings.SplitN(expression, ":", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, utils.NewValidationError("filter", fmt.Sprintf("%q is not of the form field:operator:value", expression))
+		}
+
+		op := FilterOperator(parts[1])
+		if _, ok := mongoOperators[op]; !ok {
+			return nil, utils.NewValidationError("filter", fmt.Sprintf("unknown operator %q in %q", parts[1], expression))
+		}
+		filter = append(filter, Condition{Field: parts[0], Operator: op, Value: parts[2]})
+	}
+	return filter, nil
+}
+
+// withFilterFields makes fields filterable under their API names
+func (r *BaseRepository) withFilterFields(fields map[string]FilterField) *BaseRepository {
+	for name, field := range fields {
+		r.filterFields[name] = field
+	}
+	return r
+}
+
+// FilterFields returns the names of the fields the collection can be filtered on, in name order
+func (r *BaseRepository) FilterFields() []string {
+	names := make([]string, 0, len(r.filterFields))
+	for name := range r.filterFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Where adds the compiled conditions to filter. Each condition becomes its own
+// clause of an $and, so repeating a field narrows the match further.
+func (r *BaseRepository) Where(filter bson.M, conditions Filter) (bson.M, error) {
+	if len(conditions) == 0 {
+		return filter, nil
+	}
+
+	clauses := make(bson.A, 0, len(conditions))
+	for _, condition := range conditions {
+		clause, err := r.compileCondition(condition)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, clause)
+	}
+
+	combined := bson.M{}
+	for key, value := range filter {
+		combined[key] = value
+	}
+	if existing, ok := combined["$and"].(bson.A); ok {
+		clauses = append(existing, clauses...)
+	}
+	combined["$and"] = clauses
+	return combined, nil
+}
+
+func (r *BaseRepository) compileCondition(condition Condition) (bson.M, error) {
+	field, ok := r.filterFields[condition.Field]
+	if !ok {
+		return nil, utils.NewValidationError("filter", fmt.Sprintf("cannot filter on %q, use one of %s", condition.Field, strings.Join(r.FilterFields(), ", ")))
+	}
+	if !field.allows(condition.Operator) {
+		allowed := make([]string, len(field.Operators))
+		for i, op := range field.Operators {
+			allowed[i] = string(op)
+		}
+		return nil, utils.NewValidationError("filter", fmt.Sprintf("%q does not support %s, use one of %s", condition.Field, condition.Operator, strings.Join(allowed, ", ")))
+	}
+
+	var operand interface{}
+	if condition.Operator == OpIn || condition.Operator == OpNin {
+		values := bson.A{}
+		for _, raw := range strings.Split(condition.Value, ",") {
+			value, err := parseFilterValue(condition.Field, field.Kind, raw)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		operand = values
+	} else {
+		value, err := parseFilterValue(condition.Field, field.Kind, condition.Value)
+		if err != nil {
+			return nil, err
+		}
+		operand = value
+	}
+
+	return bson.M{field.Path: bson.M{mongoOperators[condition.Operator]: operand}}, nil
+}
+
+func parseFilterValue(name string, kind FieldKind, raw string) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+	switch kind {
+	case FieldInt:
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, utils.NewValidationError("filter", fmt.Sprintf("%q needs a whole number, got %q", name, raw))
+		}
+		return value, nil
+	case FieldObjectID:
+		value, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			return nil, utils.NewValidationError("filter", fmt.Sprintf("%q needs an ID, got %q", name, raw))
+		}
+		return value, nil
+	default:
+		return raw, nil
+	}
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"grimdank-database/models"
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter([]string{"points:lt:15", "name:eq:Bolt: Heavy"})
+	if err != nil {
+		t.Fatalf("Failed to parse filter: %v", err)
+	}
+	if len(filter) != 2 || filter[1].Value != "Bolt: Heavy" {
+		t.Errorf("Expected values to keep their colons, got %+v", filter)
+	}
+
+	for _, expression := range []string{"points", "points:lt", ":eq:5", "points:like:5"} {
+		if _, err := ParseFilter([]string{expression}); !utils.IsValidationError(err) {
+			t.Errorf("Expected validation error for %q, got %v", expression, err)
+		}
+	}
+}
+
+func TestWeaponFilters(t *testing.T) {
+	ctx := context.Background()
+	repo := NewWeaponRepository(NewMemoryCollection())
+
+	rending := primitive.NewObjectID()
+	weapons := []*models.Weapon{
+		{Name: "Power Sword", Type: "Melee", AP: "2", Points: 10, Rules: []models.RuleReference{{RuleID: rending, Tier: 1}}},
+		{Name: "Power Fist", Type: "Melee", AP: "3", Points: 20, Rules: []models.RuleReference{{RuleID: rending, Tier: 1}}},
+		{Name: "Chainsword", Type: "Melee", AP: "1", Points: 5},
+		{Name: "Lightning Claw", Type: "Melee", AP: "2", Points: 12},
+		{Name: "Plasma Gun", Type: "Ranged", AP: "3", Points: 14, Rules: []models.RuleReference{{RuleID: rending, Tier: 2}}},
+	}
+	for _, weapon := range weapons {
+		if _, err := repo.CreateWeapon(ctx, weapon); err != nil {
+			t.Fatalf("Failed to create weapon: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter []string
+		want   int
+	}{
+		{"Melee With AP 2 Under 15 Points Carrying Rule", []string{"type:eq:Melee", "ap:gte:2", "points:lt:15", "rules.ruleId:has:" + rending.Hex()}, 1},
+		{"Membership", []string{"type:in:Melee,Ranged", "points:nin:5,10"}, 3},
+		{"Range On Both Sides", []string{"points:gte:10", "points:lte:14"}, 3},
+		{"Not Equal", []string{"type:ne:Melee"}, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := ParseFilter(tc.filter)
+			if err != nil {
+				t.Fatalf("Failed to parse filter: %v", err)
+			}
+
+			found, _, err := repo.ListWeapons(ctx, "", ListOptions{Filter: filter})
+			if err != nil {
+				t.Fatalf("Failed to list weapons: %v", err)
+			}
+			if len(found) != tc.want {
+				t.Errorf("Expected %d weapons, got %d: %v", tc.want, len(found), found)
+			}
+
+			count, err := repo.CountWeaponsMatching(ctx, "", filter)
+			if err != nil || count != int64(tc.want) {
+				t.Errorf("Expected count %d, got %d (%v)", tc.want, count, err)
+			}
+		})
+	}
+
+	t.Run("Combined With Name", func(t *testing.T) {
+		filter, _ := ParseFilter([]string{"type:eq:Melee"})
+		count, err := repo.CountWeaponsMatching(ctx, "power", filter)
+		if err != nil || count != 2 {
+			t.Errorf("Expected 2 Melee power weapons, got %d (%v)", count, err)
+		}
+	})
+
+	t.Run("Whitelist", func(t *testing.T) {
+		rejected := []string{
+			"description:eq:x",     // not filterable
+			"type:gt:Melee",        // text fields only match exactly
+			"rules.ruleId:has:xyz", // not an ID
+			"points:lt:cheap",      // not a number
+			"deletedAt:ne:x",       // internal fields stay hidden
+		}
+		for _, expression := range rejected {
+			filter, err := ParseFilter([]string{expression})
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", expression, err)
+			}
+			if _, _, err := repo.ListWeapons(ctx, "", ListOptions{Filter: filter}); !utils.IsValidationError(err) {
+				t.Errorf("Expected validation error for %q, got %v", expression, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// IndexSpec declares an index a repository relies on
+type IndexSpec struct {
+	Name            string
+	Keys            bson.D
+	Unique          bool
+	CaseInsensitive bool           // compare strings with a case-insensitive collation
+	Weights         map[string]int // text indexes only: how much a match in each field counts; unlisted fields count 1
+}
+
+// textKey is the key value that puts a field in a text index
+const textKey = "text"
+
+// IsText reports whether the index is a text index
+func (s IndexSpec) IsText() bool {
+	for _, key := range s.Keys {
+		if key.Value == textKey {
+			return true
+		}
+	}
+	return false
+}
+
+// weight is how much a match in field counts towards a text score
+func (s IndexSpec) weight(field string) int {
+	if weight, ok := s.Weights[field]; ok {
+		return weight
+	}
+	return 1
+}
+
+// sameDefinition reports whether two indexes index the same keys in the same way
+func (s IndexSpec) sameDefinition(other IndexSpec) bool {
+	if s.Unique != other.Unique || s.CaseInsensitive != other.CaseInsensitive || len(s.Keys) != len(other.Keys) {
+		return false
+	}
+	if s.IsText() || other.IsText() {
+		return s.sameTextDefinition(other)
+	}
+	for i, key := range s.Keys {
+		if key.Key != other.Keys[i].Key {
+			return false
+		}
+		left, _ := toFloat(key.Value)
+		right, _ := toFloat(other.Keys[i].Value)
+		if left != right {
+			return false
+		}
+	}
+	return true
+}
+
+// sameTextDefinition compares text indexes, whose field order does not matter
+// and which MongoDB reports back in its own order
+func (s IndexSpec) sameTextDefinition(other IndexSpec) bool {
+	if !s.IsText() || !other.IsText() {
+		return false
+	}
+	fields := make(map[string]bool, len(s.Keys))
+	for _, key := range s.Keys {
+		fields[key.Key] = true
+	}
+	for _, key := range other.Keys {
+		if !fields[key.Key] || key.Value != textKey || s.weight(key.Key) != other.weight(key.Key) {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueNameIndex is the case-insensitive unique name most collections
+// declare. Names only need to be unique within a workspace.
+func uniqueNameIndex() IndexSpec {
+	return IndexSpec{
+		Name:            "name_ci_unique",
+		Keys:            bson.D{{Key: WorkspaceField, Value: 1}, {Key: "name", Value: 1}},
+		Unique:          true,
+		CaseInsensitive: true,
+	}
+}
+
+// fieldIndex is a plain ascending index on one field, named the way MongoDB names it
+func fieldIndex(field string) IndexSpec {
+	return IndexSpec{
+		Name: field + "_1",
+		Keys: bson.D{{Key: field, Value: 1}},
+	}
+}
+
+// textSearchIndex is the text index the search endpoint uses. Name matches
+// count ten times as much as matches in the other fields.
+func textSearchIndex(fields ...string) IndexSpec {
+	index := IndexSpec{Name: "text_search", Weights: map[string]int{}}
+	for _, field := range fields {
+		index.Keys = append(index.Keys, bson.E{Key: field, Value: textKey})
+		index.Weights[field] = 1
+		if field == "name" {
+			index.Weights[field] = 10
+		}
+	}
+	return index
+}
+
+// IndexReport describes how a collection's indexes compare to the declared ones
+type IndexReport struct {
+	Collection string   `json:"collection"`
+	Missing    []string `json:"missing,omitempty"`   // declared but not in the collection
+	Changed    []string `json:"changed,omitempty"`   // in the collection under the same name with another definition
+	Unmanaged  []string `json:"unmanaged,omitempty"` // in the collection but not declared; never dropped automatically
+	Created    []string `json:"created,omitempty"`   // missing or changed indexes that were (re)built
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// HasDrift reports whether the collection differed from its declared indexes
+func (r *IndexReport) HasDrift() bool {
+	return len(r.Missing) > 0 || len(r.Changed) > 0 || len(r.Unmanaged) > 0
+}
+
+// IndexedRepository is a repository that declares the indexes it relies on
+type IndexedRepository interface {
+	Indexes() []IndexSpec
+	SyncIndexes(ctx context.Context, declared []IndexSpec, apply bool) (*IndexReport, error)
+}
+
+// pendingIndexSuffix names the copy of a changed index built before the old
+// index is dropped
+const pendingIndexSuffix = "_pending"
+
+// SyncIndexes compares the declared indexes with the collection's. When apply
+// is set, missing indexes are created and changed ones are rebuilt; failures
+// such as existing duplicates are recorded in the report rather than returned.
+// A changed index is first built under a temporary name, so the old one is
+// only dropped once the documents are known to fit the new definition.
+func (r *BaseRepository) SyncIndexes(ctx context.Context, declared []IndexSpec, apply bool) (*IndexReport, error) {
+	existing, err := r.Collection.ListIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]IndexSpec, len(existing))
+	for _, index := range existing {
+		current[index.Name] = index
+	}
+
+	report := &IndexReport{}
+	declaredNames := make(map[string]bool, len(declared))
+	for _, index := range declared {
+		declaredNames[index.Name] = true
+
+		found, exists := current[index.Name]
+		switch {
+		case !exists:
+			report.Missing = append(report.Missing, index.Name)
+		case !found.sameDefinition(index):
+			report.Changed = append(report.Changed, index.Name)
+		default:
+			continue
+		}
+
+		if !apply {
+			continue
+		}
+		if exists {
+			if err := r.replaceIndex(ctx, index); err != nil {
+				report.Errors = append(report.Errors, err.Error())
+				continue
+			}
+			report.Created = append(report.Created, index.Name)
+			continue
+		}
+		if err := r.Collection.CreateIndex(ctx, index); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("create %s: %v", index.Name, err))
+			continue
+		}
+		report.Created = append(report.Created, index.Name)
+	}
+
+	for _, index := range existing {
+		if !declaredNames[index.Name] {
+			report.Unmanaged = append(report.Unmanaged, index.Name)
+		}
+	}
+	sort.Strings(report.Unmanaged)
+
+	return report, nil
+}
+
+// replaceIndex rebuilds an index whose definition changed. The new definition
+// is built under a temporary name first; if that fails, e.g. on duplicates a
+// new unique index won't allow, the old index is kept. Once the old index is
+// dropped the temporary one makes way for the index under its own name, as
+// MongoDB won't keep the same index twice, and is built again should that fail.
+func (r *BaseRepository) replaceIndex(ctx context.Context, index IndexSpec) error {
+	pending := index
+	pending.Name = index.Name + pendingIndexSuffix
+	if err := r.Collection.CreateIndex(ctx, pending); err != nil {
+		return fmt.Errorf("create %s: kept the old index: %v", index.Name, err)
+	}
+	if err := r.Collection.DropIndex(ctx, index.Name); err != nil {
+		if dropErr := r.Collection.DropIndex(ctx, pending.Name); dropErr != nil {
+			return fmt.Errorf("drop %s: %v; drop %s: %v", index.Name, err, pending.Name, dropErr)
+		}
+		return fmt.Errorf("drop %s: %v", index.Name, err)
+	}
+	if err := r.Collection.DropIndex(ctx, pending.Name); err != nil {
+		return fmt.Errorf("drop %s: %v", pending.Name, err)
+	}
+	if err := r.Collection.CreateIndex(ctx, index); err != nil {
+		if pendingErr := r.Collection.CreateIndex(ctx, pending); pendingErr != nil {
+			return fmt.Errorf("create %s: %v; create %s: %v", index.Name, err, pending.Name, pendingErr)
+		}
+		return fmt.Errorf("create %s: kept the new definition as %s: %v", index.Name, pending.Name, err)
+	}
+	return nil
+}
+
+// ReconcileIndexes syncs the declared indexes of every repository, keyed by
+// collection name, and returns one report per collection in name order
+func ReconcileIndexes(ctx context.Context, repos map[string]IndexedRepository, apply bool) ([]IndexReport, error) {
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reports := make([]IndexReport, 0, len(names))
+	for _, name := range names {
+		repo := repos[name]
+		report, err := repo.SyncIndexes(ctx, repo.Indexes(), apply)
+		if err != nil {
+			return reports, fmt.Errorf("failed to sync indexes for %s: %w", name, err)
+		}
+		report.Collection = name
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+var duplicateIndexPattern = regexp.MustCompile(`index: (\S+)`)
+
+// translateWriteError turns duplicate key errors from either backend into a utils.DuplicateError
+func translateWriteError(err error) error {
+	if err == nil || !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	index := ""
+	if match := duplicateIndexPattern.FindStringSubmatch(err.Error()); match != nil {
+		index = match[1]
+	}
+	return utils.NewDuplicateError(index, err)
+}
@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"grimdank-database/models"
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSyncIndexes(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRuleRepository(NewMemoryCollection())
+
+	t.Run("Check Reports Missing", func(t *testing.T) {
+		report, err := repo.SyncIndexes(ctx, repo.Indexes(), false)
+		if err != nil {
+			t.Fatalf("Failed to sync indexes: %v", err)
+		}
+		if len(report.Missing) != len(repo.Indexes()) || len(report.Created) != 0 {
+			t.Errorf("Expected every index missing and none created, got %+v", report)
+		}
+	})
+
+	t.Run("Apply Creates Missing", func(t *testing.T) {
+		report, err := repo.SyncIndexes(ctx, repo.Indexes(), true)
+		if err != nil {
+			t.Fatalf("Failed to sync indexes: %v", err)
+		}
+		if len(report.Created) != len(repo.Indexes()) {
+			t.Errorf("Expected the missing indexes to be created, got %+v", report)
+		}
+
+		report, err = repo.SyncIndexes(ctx, repo.Indexes(), false)
+		if err != nil {
+			t.Fatalf("Failed to sync indexes: %v", err)
+		}
+		if report.HasDrift() {
+			t.Errorf("Expected no drift after applying, got %+v", report)
+		}
+	})
+
+	t.Run("Changed And Unmanaged", func(t *testing.T) {
+		if err := repo.Collection.CreateIndex(ctx, fieldIndex("type")); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+		changed := uniqueNameIndex()
+		changed.CaseInsensitive = false
+
+		report, err := repo.SyncIndexes(ctx, []IndexSpec{changed}, false)
+		if err != nil {
+			t.Fatalf("Failed to sync indexes: %v", err)
+		}
+		// Only the name index is passed in, so the other declared ones count as unmanaged too
+		if len(report.Changed) != 1 || len(report.Unmanaged) != len(repo.Indexes()) || report.Unmanaged[1] != "type_1" {
+			t.Errorf("Expected one changed index and type_1 among the unmanaged ones, got %+v", report)
+		}
+	})
+}
+
+func TestSyncIndexesRebuildsChanged(t *testing.T) {
+	ctx := context.Background()
+	collection := NewMemoryCollection()
+	repo := NewBaseRepository(collection)
+	old := fieldIndex("name")
+	if err := collection.CreateIndex(ctx, old); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	for _, name := range []string{"Bolter", "Bolter", "Flamer"} {
+		if _, err := collection.InsertOne(ctx, bson.M{"name": name}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	unique := old
+	unique.Unique = true
+
+	t.Run("Duplicates Keep The Old Index", func(t *testing.T) {
+		report, err := repo.SyncIndexes(ctx, []IndexSpec{unique}, true)
+		if err != nil {
+			t.Fatalf("Failed to sync indexes: %v", err)
+		}
+		if len(report.Created) != 0 || len(report.Errors) != 1 || !strings.Contains(report.Errors[0], "Bolter") {
+			t.Errorf("Expected the rebuild to fail on the duplicate Bolter, got %+v", report)
+		}
+		indexes, _ := collection.ListIndexes(ctx)
+		if len(indexes) != 1 || !indexes[0].sameDefinition(old) {
+			t.Errorf("Expected only the old index to be left, got %+v", indexes)
+		}
+	})
+
+	t.Run("Rebuilt Once Clean", func(t *testing.T) {
+		if _, err := collection.DeleteOne(ctx, bson.M{"name": "Bolter"}); err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+		report, err := repo.SyncIndexes(ctx, []IndexSpec{unique}, true)
+		if err != nil {
+			t.Fatalf("Failed to sync indexes: %v", err)
+		}
+		if len(report.Created) != 1 || len(report.Errors) != 0 {
+			t.Errorf("Expected the index to be rebuilt, got %+v", report)
+		}
+		indexes, _ := collection.ListIndexes(ctx)
+		if len(indexes) != 1 || indexes[0].Name != unique.Name || !indexes[0].sameDefinition(unique) {
+			t.Errorf("Expected only the new index under its own name, got %+v", indexes)
+		}
+	})
+}
+
+func TestTextIndexDefinition(t *testing.T) {
+	declared := textSearchIndex("name", "description")
+
+	// MongoDB lists text index fields in its own order
+	listed := IndexSpec{
+		Name:    "text_search",
+		Keys:    bson.D{{Key: "description", Value: "text"}, {Key: "name", Value: "text"}},
+		Weights: map[string]int{"description": 1, "name": 10},
+	}
+	if !declared.sameDefinition(listed) {
+		t.Error("Expected field order not to matter for text indexes")
+	}
+
+	listed.Weights["name"] = 5
+	if declared.sameDefinition(listed) {
+		t.Error("Expected a weight change to count as a different definition")
+	}
+
+	if textSearchIndex("name").sameDefinition(fieldIndex("name")) {
+		t.Error("Expected a text index to differ from a plain index on the same field")
+	}
+}
+
+func TestUniqueNameIndex(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRuleRepository(NewMemoryCollection())
+
+	if _, err := repo.CreateRule(ctx, &models.Rule{Name: "Rending"}); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	if _, err := repo.CreateRule(ctx, &models.Rule{Name: "rending"}); err != nil {
+		t.Fatalf("Expected duplicates to be allowed before the index exists: %v", err)
+	}
+
+	t.Run("Existing Duplicates Are Reported", func(t *testing.T) {
+		report, err := repo.SyncIndexes(ctx, repo.Indexes(), true)
+		if err != nil {
+			t.Fatalf("Failed to sync indexes: %v", err)
+		}
+		if len(report.Errors) != 1 || len(report.Created) != len(repo.Indexes())-1 {
+			t.Errorf("Expected index creation to fail on existing duplicates, got %+v", report)
+		}
+		for _, created := range report.Created {
+			if created == "name_ci_unique" {
+				t.Errorf("Expected the unique name index not to be created, got %+v", report)
+			}
+		}
+	})
+
+	t.Run("Create Rejects Duplicate Name", func(t *testing.T) {
+		repo := NewRuleRepository(NewMemoryCollection())
+		if _, err := repo.SyncIndexes(ctx, repo.Indexes(), true); err != nil {
+			t.Fatalf("Failed to sync indexes: %v", err)
+		}
+		if _, err := repo.CreateRule(ctx, &models.Rule{Name: "Rending"}); err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+
+		_, err := repo.CreateRule(ctx, &models.Rule{Name: "RENDING"})
+		if !utils.IsDuplicateError(err) {
+			t.Fatalf("Expected duplicate error, got %v", err)
+		}
+		if err.(utils.DuplicateError).Index != "name_ci_unique" {
+			t.Errorf("Expected the name index to be reported, got %v", err)
+		}
+	})
+
+	t.Run("Update Rejects Duplicate Name", func(t *testing.T) {
+		repo := NewRuleRepository(NewMemoryCollection())
+		if _, err := repo.SyncIndexes(ctx, repo.Indexes(), true); err != nil {
+			t.Fatalf("Failed to sync indexes: %v", err)
+		}
+		repo.CreateRule(ctx, &models.Rule{Name: "Rending"})
+		other := &models.Rule{Name: "Fleet"}
+		id, err := repo.CreateRule(ctx, other)
+		if err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+
+		other.Name = "rending"
+		if err := repo.UpdateRule(ctx, id, other); !utils.IsDuplicateError(err) {
+			t.Fatalf("Expected duplicate error, got %v", err)
+		}
+
+		other.Name = "Fleet"
+		other.Description = "Renamed back"
+		if err := repo.UpdateRule(ctx, id, other); err != nil {
+			t.Errorf("Expected update keeping its own name to succeed: %v", err)
+		}
+	})
+}
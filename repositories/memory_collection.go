@@ -0,0 +1,509 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MemoryStore is a Store that keeps every collection in process memory.
+// It is meant for local demos and tests that should run without MongoDB.
+type MemoryStore struct {
+	mu          sync.Mutex
+	collections map[string]*MemoryCollection
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		collections: make(map[string]*MemoryCollection),
+	}
+}
+
+// Collection returns the named collection, creating it on first use
+func (s *MemoryStore) Collection(name string) Collection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collection, exists := s.collections[name]
+	if !exists {
+		collection = NewMemoryCollection()
+		s.collections[name] = collection
+	}
+	return collection
+}
+
+// MemoryCollection is an in-memory Collection. Documents are stored in their
+// BSON form so filters, updates and decoding behave like they do in MongoDB.
+type MemoryCollection struct {
+	mu        sync.RWMutex
+	documents []bson.M
+	indexes   []IndexSpec
+}
+
+// NewMemoryCollection creates an empty in-memory collection
+func NewMemoryCollection() *MemoryCollection {
+	return &MemoryCollection{
+		documents: make([]bson.M, 0),
+	}
+}
+
+func (c *MemoryCollection) InsertOne(ctx context.Context, document interface{}) (primitive.ObjectID, error) {
+	doc, id, err := prepareInsert(document)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.indexOfID(id) >= 0 {
+		return primitive.NilObjectID, duplicateKeyError(id)
+	}
+	if err := c.checkUnique(doc, -1); err != nil {
+		return primitive.NilObjectID, err
+	}
+	c.documents = append(c.documents, doc)
+	return id, nil
+}
+
+// InsertMany inserts documents in order and stops at the first failure,
+// matching the behaviour of an ordered MongoDB InsertMany.
+func (c *MemoryCollection) InsertMany(ctx context.Context, documents []interface{}) ([]primitive.ObjectID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	insertedIDs := make([]primitive.ObjectID, 0, len(documents))
+	for _, document := range documents {
+		doc, id, err := prepareInsert(document)
+		if err != nil {
+			return insertedIDs, err
+		}
+		if c.indexOfID(id) >= 0 {
+			return insertedIDs, duplicateKeyError(id)
+		}
+		if err := c.checkUnique(doc, -1); err != nil {
+			return insertedIDs, err
+		}
+		c.documents = append(c.documents, doc)
+		insertedIDs = append(insertedIDs, id)
+	}
+	return insertedIDs, nil
+}
+
+func (c *MemoryCollection) FindOne(ctx context.Context, filter bson.M, result interface{}) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	index, err := c.firstMatch(filter)
+	if err != nil {
+		return err
+	}
+	if index < 0 {
+		return mongo.ErrNoDocuments
+	}
+	return decodeDocument(c.documents[index], result)
+}
+
+func (c *MemoryCollection) Find(ctx context.Context, filter bson.M, results interface{}, opts *options.FindOptions) error {
+	resultsVal := reflect.ValueOf(results)
+	if resultsVal.Kind() != reflect.Ptr || resultsVal.Elem().Kind() != reflect.Slice {
+		return errors.New("results argument must be a pointer to a slice")
+	}
+
+	c.mu.RLock()
+	matched, err := c.matching(filter)
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if opts != nil && opts.Sort != nil {
+		keys, err := parseSortKeys(opts.Sort)
+		if err != nil {
+			return err
+		}
+		sortDocuments(matched, keys)
+	}
+
+	if opts != nil && opts.Skip != nil && *opts.Skip > 0 {
+		skip := *opts.Skip
+		if skip > int64(len(matched)) {
+			skip = int64(len(matched))
+		}
+		matched = matched[skip:]
+	}
+
+	if opts != nil && opts.Limit != nil && *opts.Limit != 0 {
+		limit := *opts.Limit
+		if limit < 0 {
+			limit = -limit
+		}
+		if limit < int64(len(matched)) {
+			matched = matched[:limit]
+		}
+	}
+
+	sliceVal := resultsVal.Elem().Slice(0, 0)
+	elementType := sliceVal.Type().Elem()
+	for _, doc := range matched {
+		element := reflect.New(elementType)
+		if err := decodeDocument(doc, element.Interface()); err != nil {
+			return err
+		}
+		sliceVal = reflect.Append(sliceVal, element.Elem())
+	}
+	resultsVal.Elem().Set(sliceVal)
+	return nil
+}
+
+func (c *MemoryCollection) UpdateOne(ctx context.Context, filter bson.M, update bson.M) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	updated, err := c.updateFirst(filter, update)
+	if err != nil || updated == nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (c *MemoryCollection) FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	updated, err := c.updateFirst(filter, update)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return mongo.ErrNoDocuments
+	}
+	return decodeDocument(updated, result)
+}
+
+// updateFirst applies update to the first document matching filter and
+// returns it as updated, or nil if nothing matches. The caller holds the lock.
+func (c *MemoryCollection) updateFirst(filter bson.M, update bson.M) (bson.M, error) {
+	index, err := c.firstMatch(filter)
+	if err != nil || index < 0 {
+		return nil, err
+	}
+
+	// Apply the update to a copy so a failing operator leaves the stored document untouched
+	updated, err := copyDocument(c.documents[index])
+	if err != nil {
+		return nil, err
+	}
+	if err := applyUpdate(updated, update); err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(updated["_id"], c.documents[index]["_id"]) {
+		return nil, errors.New("performing an update on the path '_id' would modify the immutable field '_id'")
+	}
+	if err := c.checkUnique(updated, index); err != nil {
+		return nil, err
+	}
+
+	c.documents[index] = updated
+	return updated, nil
+}
+
+func (c *MemoryCollection) ReplaceOne(ctx context.Context, filter bson.M, replacement interface{}) (int64, error) {
+	doc, err := toDocument(replacement)
+	if err != nil {
+		return 0, err
+	}
+	for key := range doc {
+		if len(key) > 0 && key[0] == '$' {
+			return 0, errors.New("replacement document cannot contain keys beginning with '$'")
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index, err := c.firstMatch(filter)
+	if err != nil || index < 0 {
+		return 0, err
+	}
+
+	existingID := c.documents[index]["_id"]
+	if id, exists := doc["_id"]; exists && id != primitive.NilObjectID && !reflect.DeepEqual(id, existingID) {
+		return 0, errors.New("the _id field cannot be changed by a replacement")
+	}
+	doc["_id"] = existingID
+	if err := c.checkUnique(doc, index); err != nil {
+		return 0, err
+	}
+
+	c.documents[index] = doc
+	return 1, nil
+}
+
+func (c *MemoryCollection) DeleteOne(ctx context.Context, filter bson.M) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index, err := c.firstMatch(filter)
+	if err != nil || index < 0 {
+		return 0, err
+	}
+
+	c.documents = append(c.documents[:index], c.documents[index+1:]...)
+	return 1, nil
+}
+
+func (c *MemoryCollection) DeleteMany(ctx context.Context, filter bson.M) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	normalized, err := normalizeFilter(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	kept := make([]bson.M, 0, len(c.documents))
+	var deleted int64
+	for _, doc := range c.documents {
+		matched, err := matchDocument(doc, normalized)
+		if err != nil {
+			return 0, err
+		}
+		if matched {
+			deleted++
+		} else {
+			kept = append(kept, doc)
+		}
+	}
+
+	c.documents = kept
+	return deleted, nil
+}
+
+func (c *MemoryCollection) CountDocuments(ctx context.Context, filter bson.M) (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	matched, err := c.matching(filter)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(matched)), nil
+}
+
+func (c *MemoryCollection) Drop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.documents = make([]bson.M, 0)
+	c.indexes = nil
+	return nil
+}
+
+// matching returns the stored documents that satisfy the filter, in insertion order.
+// Callers must hold at least a read lock.
+func (c *MemoryCollection) matching(filter bson.M) ([]bson.M, error) {
+	normalized, err := normalizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]bson.M, 0)
+	for _, doc := range c.documents {
+		ok, err := matchDocument(doc, normalized)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, doc)
+		}
+	}
+	return matched, nil
+}
+
+// firstMatch returns the index of the first document satisfying the filter, or -1.
+// Callers must hold at least a read lock.
+func (c *MemoryCollection) firstMatch(filter bson.M) (int, error) {
+	normalized, err := normalizeFilter(filter)
+	if err != nil {
+		return -1, err
+	}
+
+	for i, doc := range c.documents {
+		ok, err := matchDocument(doc, normalized)
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// indexOfID returns the index of the document with the given _id, or -1.
+// Callers must hold at least a read lock.
+func (c *MemoryCollection) indexOfID(id primitive.ObjectID) int {
+	for i, doc := range c.documents {
+		if docID, ok := doc["_id"].(primitive.ObjectID); ok && docID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// prepareInsert converts a document to its stored form and assigns an _id when missing
+func prepareInsert(document interface{}) (bson.M, primitive.ObjectID, error) {
+	doc, err := toDocument(document)
+	if err != nil {
+		return nil, primitive.NilObjectID, err
+	}
+
+	rawID, exists := doc["_id"]
+	if !exists || rawID == nil || rawID == primitive.NilObjectID {
+		id := primitive.NewObjectID()
+		doc["_id"] = id
+		return doc, id, nil
+	}
+
+	id, ok := rawID.(primitive.ObjectID)
+	if !ok {
+		return nil, primitive.NilObjectID, fmt.Errorf("unsupported _id type %T", rawID)
+	}
+	return doc, id, nil
+}
+
+// duplicateKeyError builds the same error MongoDB reports so mongo.IsDuplicateKeyError works for both backends
+func duplicateKeyError(id primitive.ObjectID) error {
+	return mongo.WriteException{
+		WriteErrors: []mongo.WriteError{{
+			Code:    11000,
+			Message: fmt.Sprintf("E11000 duplicate key error dup key: { _id: ObjectId('%s') }", id.Hex()),
+		}},
+	}
+}
+
+// toDocument converts a struct, map or bson.D into its BSON document form
+func toDocument(value interface{}) (bson.M, error) {
+	raw, err := bson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// copyDocument returns a deep copy of a stored document
+func copyDocument(doc bson.M) (bson.M, error) {
+	return toDocument(doc)
+}
+
+// decodeDocument decodes a stored document into the caller's result value
+func decodeDocument(doc bson.M, result interface{}) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, result)
+}
+
+// normalizeValue converts a Go value into the representation it has once stored,
+// e.g. time.Time becomes primitive.DateTime and []primitive.ObjectID becomes primitive.A
+func normalizeValue(value interface{}) (interface{}, error) {
+	doc, err := toDocument(bson.M{"v": value})
+	if err != nil {
+		return nil, err
+	}
+	return doc["v"], nil
+}
+
+// normalizeFilter converts a filter into stored representation so it can be compared directly
+func normalizeFilter(filter bson.M) (bson.M, error) {
+	if len(filter) == 0 {
+		return bson.M{}, nil
+	}
+	return toDocument(filter)
+}
+
+type sortKey struct {
+	field     string
+	direction int
+}
+
+// parseSortKeys reads a sort specification given as bson.D or bson.M
+func parseSortKeys(spec interface{}) ([]sortKey, error) {
+	var keys []sortKey
+
+	switch s := spec.(type) {
+	case bson.D:
+		for _, elem := range s {
+			direction, err := sortDirection(elem.Value)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, sortKey{field: elem.Key, direction: direction})
+		}
+	case bson.M:
+		fields := make([]string, 0, len(s))
+		for field := range s {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			direction, err := sortDirection(s[field])
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, sortKey{field: field, direction: direction})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sort specification %T", spec)
+	}
+
+	return keys, nil
+}
+
+func sortDirection(value interface{}) (int, error) {
+	number, ok := toFloat(value)
+	if !ok || (number != 1 && number != -1) {
+		return 0, fmt.Errorf("invalid sort direction %v", value)
+	}
+	return int(number), nil
+}
+
+// sortDocuments sorts documents in place by the given keys, keeping insertion order for ties
+func sortDocuments(documents []bson.M, keys []sortKey) {
+	sort.SliceStable(documents, func(i, j int) bool {
+		for _, key := range keys {
+			left := firstValue(documents[i], key.field)
+			right := firstValue(documents[j], key.field)
+			cmp := sortCompare(left, right)
+			if cmp != 0 {
+				return cmp*key.direction < 0
+			}
+		}
+		return false
+	})
+}
+
+// firstValue returns the first value found at a dotted path, or nil
+func firstValue(doc bson.M, path string) interface{} {
+	values, found := lookupPath(doc, path)
+	if !found || len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
@@ -0,0 +1,256 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"grimdank-database/models"
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMemoryCollectionCRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRuleRepository(NewMemoryCollection())
+
+	rule := &models.Rule{Name: "Rending", Description: "Wounds on 10 ignore defense", Points: []int{3, 5, 8}}
+	id, err := repo.CreateRule(ctx, rule)
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	t.Run("Get By ID", func(t *testing.T) {
+		found, err := repo.GetRuleByID(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to get rule: %v", err)
+		}
+		if found.Name != "Rending" || len(found.Points) != 3 || found.Points[2] != 8 {
+			t.Errorf("Unexpected rule returned: %+v", found)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		rule.Description = "Updated"
+		if err := repo.UpdateRule(ctx, id, rule); err != nil {
+			t.Fatalf("Failed to update rule: %v", err)
+		}
+		found, _ := repo.GetRuleByID(ctx, id)
+		if found.Description != "Updated" {
+			t.Errorf("Expected updated description, got %s", found.Description)
+		}
+	})
+
+	t.Run("Update Stale Version", func(t *testing.T) {
+		if rule.Version != 2 {
+			t.Fatalf("Expected version 2 after one update, got %d", rule.Version)
+		}
+		stale := *rule
+		stale.Version = 1
+		err := repo.UpdateRule(ctx, id, &stale)
+		if !utils.IsVersionConflictError(err) {
+			t.Errorf("Expected version conflict, got %v", err)
+		}
+	})
+
+	t.Run("Update Non-Existent", func(t *testing.T) {
+		err := repo.UpdateRule(ctx, primitive.NewObjectID().Hex(), rule)
+		if err == nil || err.Error() != "document not found" {
+			t.Errorf("Expected document not found, got %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := repo.DeleteRule(ctx, id); err != nil {
+			t.Fatalf("Failed to delete rule: %v", err)
+		}
+		if _, err := repo.GetRuleByID(ctx, id); err == nil {
+			t.Error("Expected error getting deleted rule")
+		}
+		if err := repo.DeleteRule(ctx, id); err == nil {
+			t.Error("Expected error deleting rule twice")
+		}
+	})
+}
+
+func TestMemoryCollectionSearchPagingAndCount(t *testing.T) {
+	ctx := context.Background()
+	repo := NewWeaponRepository(NewMemoryCollection())
+
+	names := []string{"Bolt Pistol", "Bolter", "Chainsword", "Heavy Bolter", "Power Fist"}
+	for _, name := range names {
+		if _, err := repo.CreateWeapon(ctx, &models.Weapon{Name: name, Type: "ranged"}); err != nil {
+			t.Fatalf("Failed to create weapon: %v", err)
+		}
+	}
+
+	matches, err := repo.SearchWeaponsByName(ctx, "BOLT", 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to search weapons: %v", err)
+	}
+	if len(matches) != 3 {
+		t.Errorf("Expected 3 case-insensitive matches, got %d", len(matches))
+	}
+
+	page, err := repo.GetAllWeapons(ctx, 2, 1)
+	if err != nil {
+		t.Fatalf("Failed to page weapons: %v", err)
+	}
+	if len(page) != 2 || page[0].Name != "Bolter" || page[1].Name != "Chainsword" {
+		t.Errorf("Expected insertion-ordered page [Bolter Chainsword], got %v", page)
+	}
+
+	count, err := repo.CountWeaponsByName(ctx, "bolt")
+	if err != nil || count != 3 {
+		t.Errorf("Expected count 3, got %d (%v)", count, err)
+	}
+
+	total, err := repo.CountWeapons(ctx)
+	if err != nil || total != int64(len(names)) {
+		t.Errorf("Expected total %d, got %d (%v)", len(names), total, err)
+	}
+}
+
+func TestMemoryCollectionBulkInsertAndGetRulesByIDs(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRuleRepository(NewMemoryCollection())
+
+	ids, err := repo.BulkImportRules(ctx, []models.Rule{{Name: "A"}, {Name: "B"}, {Name: "C"}})
+	if err != nil {
+		t.Fatalf("Failed to bulk import: %v", err)
+	}
+	if len(ids) != 3 {
+		t.Fatalf("Expected 3 ids, got %d", len(ids))
+	}
+
+	wanted := []primitive.ObjectID{
+		primitive.NewObjectID(), // unknown IDs are ignored
+	}
+	for _, hex := range ids[1:] {
+		id, _ := primitive.ObjectIDFromHex(hex)
+		wanted = append(wanted, id)
+	}
+
+	rules, err := repo.GetRulesByIDs(ctx, wanted)
+	if err != nil {
+		t.Fatalf("Failed to get rules by IDs: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Name != "B" || rules[1].Name != "C" {
+		t.Errorf("Expected rules B and C, got %v", rules)
+	}
+
+	// Re-inserting an existing ID must fail like a MongoDB duplicate key error
+	existing, _ := primitive.ObjectIDFromHex(ids[0])
+	_, err = repo.BulkInsert(ctx, []interface{}{models.Rule{ID: existing, Name: "Dup"}})
+	if !mongo.IsDuplicateKeyError(err) {
+		t.Errorf("Expected duplicate key error, got %v", err)
+	}
+}
+
+func TestMemoryCollectionFilters(t *testing.T) {
+	ctx := context.Background()
+	collection := NewMemoryCollection()
+	ruleID := primitive.NewObjectID()
+
+	weapons := []models.Weapon{
+		{Name: "Lascannon", Type: "ranged", Range: 48, Points: 20, Rules: []models.RuleReference{{RuleID: ruleID, Tier: 2}}},
+		{Name: "Lasgun", Type: "ranged", Range: 24, Points: 5},
+		{Name: "Power Sword", Type: "melee", Range: 0, Points: 12},
+	}
+	for _, weapon := range weapons {
+		if _, err := collection.InsertOne(ctx, weapon); err != nil {
+			t.Fatalf("Failed to insert weapon: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		filter bson.M
+		want   int64
+	}{
+		{"Equality", bson.M{"type": "melee"}, 1},
+		{"Range", bson.M{"range": bson.M{"$gte": 24, "$lt": 48}}, 1},
+		{"Membership", bson.M{"name": bson.M{"$in": []string{"Lasgun", "Power Sword"}}}, 2},
+		{"Nested Array Path", bson.M{"rules.ruleId": ruleID}, 1},
+		{"Elem Match", bson.M{"rules": bson.M{"$elemMatch": bson.M{"ruleId": ruleID, "tier": 3}}}, 0},
+		{"Or", bson.M{"$or": []bson.M{{"points": bson.M{"$gt": 15}}, {"type": "melee"}}}, 2},
+		{"Exists", bson.M{"missing": bson.M{"$exists": false}}, 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, err := collection.CountDocuments(ctx, tc.filter)
+			if err != nil {
+				t.Fatalf("Count failed: %v", err)
+			}
+			if count != tc.want {
+				t.Errorf("Expected %d matches, got %d", tc.want, count)
+			}
+		})
+	}
+
+	t.Run("Sort", func(t *testing.T) {
+		var sorted []models.Weapon
+		opts := options.Find().SetSort(bson.D{{Key: "points", Value: -1}})
+		if err := collection.Find(ctx, bson.M{}, &sorted, opts); err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		if len(sorted) != 3 || sorted[0].Name != "Lascannon" || sorted[2].Name != "Lasgun" {
+			t.Errorf("Unexpected sort order: %v", sorted)
+		}
+	})
+
+	t.Run("Unsupported Operator", func(t *testing.T) {
+		if _, err := collection.CountDocuments(ctx, bson.M{"name": bson.M{"$where": "true"}}); err == nil {
+			t.Error("Expected error for unsupported operator")
+		}
+	})
+}
+
+func TestMemoryCollectionUpdateOperators(t *testing.T) {
+	ctx := context.Background()
+	collection := NewMemoryCollection()
+
+	id, err := collection.InsertOne(ctx, models.Weapon{Name: "Bolter", Points: 5})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	ruleRef := models.RuleReference{RuleID: primitive.NewObjectID(), Tier: 1}
+	update := bson.M{
+		"$inc":      bson.M{"points": 3},
+		"$addToSet": bson.M{"rules": ruleRef},
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	var weapon models.Weapon
+	if err := collection.FindOne(ctx, bson.M{"_id": id}, &weapon); err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	if weapon.Points != 11 {
+		t.Errorf("Expected points 11, got %d", weapon.Points)
+	}
+	if len(weapon.Rules) != 1 {
+		t.Errorf("Expected $addToSet to keep one rule, got %d", len(weapon.Rules))
+	}
+
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$pull": bson.M{"rules": bson.M{"ruleId": ruleRef.RuleID}}}); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	weapon = models.Weapon{}
+	collection.FindOne(ctx, bson.M{"_id": id}, &weapon)
+	if len(weapon.Rules) != 0 {
+		t.Errorf("Expected rule to be pulled, got %v", weapon.Rules)
+	}
+
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"name": "No operator"}); err == nil {
+		t.Error("Expected error for update without operators")
+	}
+}
@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (c *MemoryCollection) ListIndexes(ctx context.Context) ([]IndexSpec, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	indexes := make([]IndexSpec, len(c.indexes))
+	copy(indexes, c.indexes)
+	return indexes, nil
+}
+
+// CreateIndex adds an index. Like MongoDB, creating a unique index fails when
+// the collection already holds duplicates, and re-creating an identical index is a no-op.
+func (c *MemoryCollection) CreateIndex(ctx context.Context, index IndexSpec) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, existing := range c.indexes {
+		if existing.Name == index.Name {
+			if existing.sameDefinition(index) {
+				return nil
+			}
+			return fmt.Errorf("an index named %s already exists with a different definition", index.Name)
+		}
+		if existing.IsText() && index.IsText() {
+			return fmt.Errorf("a collection has at most one text index, found %s", existing.Name)
+		}
+	}
+
+	if index.Unique {
+		for i, doc := range c.documents {
+			for j := i + 1; j < len(c.documents); j++ {
+				if sameIndexKey(index, doc, c.documents[j]) {
+					return uniqueIndexError(index, doc)
+				}
+			}
+		}
+	}
+
+	c.indexes = append(c.indexes, index)
+	return nil
+}
+
+func (c *MemoryCollection) DropIndex(ctx context.Context, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, index := range c.indexes {
+		if index.Name == name {
+			c.indexes = append(c.indexes[:i], c.indexes[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("index not found with name [%s]", name)
+}
+
+// checkUnique verifies that doc does not collide with any stored document on a
+// unique index. skip is the position of the document being replaced, or -1.
+// Callers must hold the write lock.
+func (c *MemoryCollection) checkUnique(doc bson.M, skip int) error {
+	for _, index := range c.indexes {
+		if !index.Unique {
+			continue
+		}
+		for i, other := range c.documents {
+			if i != skip && sameIndexKey(index, doc, other) {
+				return uniqueIndexError(index, doc)
+			}
+		}
+	}
+	return nil
+}
+
+// sameIndexKey reports whether two documents have the same key in an index.
+// Missing fields compare as null, as they do in MongoDB.
+func sameIndexKey(index IndexSpec, a, b bson.M) bool {
+	for _, key := range index.Keys {
+		left, right := firstValue(a, key.Key), firstValue(b, key.Key)
+
+		leftString, leftIsString := left.(string)
+		rightString, rightIsString := right.(string)
+		if index.CaseInsensitive && leftIsString && rightIsString {
+			if !strings.EqualFold(leftString, rightString) {
+				return false
+			}
+			continue
+		}
+
+		if !valuesEqual(left, right) {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueIndexError builds the error MongoDB reports for a unique index violation
+func uniqueIndexError(index IndexSpec, doc bson.M) error {
+	fields := make([]string, len(index.Keys))
+	for i, key := range index.Keys {
+		fields[i] = fmt.Sprintf("%s: %q", key.Key, fmt.Sprint(firstValue(doc, key.Key)))
+	}
+
+	return mongo.WriteException{
+		WriteErrors: []mongo.WriteError{{
+			Code:    11000,
+			Message: fmt.Sprintf("E11000 duplicate key error index: %s dup key: { %s }", index.Name, strings.Join(fields, ", ")),
+		}},
+	}
+}
+
+// TextSearch approximates a MongoDB $text query: words are matched by stem,
+// ignoring case, and each matching field adds its weight to the score, more
+// so the larger the share of the field the term makes up. Phrases and negated
+// words are treated as plain words.
+func (c *MemoryCollection) TextSearch(ctx context.Context, text string, filter bson.M, limit int64) ([]TextMatch, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var index *IndexSpec
+	for i := range c.indexes {
+		if c.indexes[i].IsText() {
+			index = &c.indexes[i]
+			break
+		}
+	}
+	if index == nil {
+		return nil, errors.New("text index required for $text query")
+	}
+
+	terms := utils.SearchTerms(text)
+	if len(terms) == 0 {
+		return []TextMatch{}, nil
+	}
+
+	candidates, err := c.matching(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]TextMatch, 0)
+	for _, doc := range candidates {
+		score := textScore(*index, doc, terms)
+		if score == 0 {
+			continue
+		}
+		copied, err := copyDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, TextMatch{Document: copied, Score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if limit > 0 && int64(len(matches)) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func textScore(index IndexSpec, doc bson.M, terms []string) float64 {
+	score := 0.0
+	for _, key := range index.Keys {
+		value, _ := firstValue(doc, key.Key).(string)
+		words := utils.Words(value)
+		if len(words) == 0 {
+			continue
+		}
+
+		stems := make(map[string]int, len(words))
+		for _, word := range words {
+			stems[utils.Stem(word.Text)]++
+		}
+		for _, term := range terms {
+			if count := stems[term]; count > 0 {
+				share := float64(count) / float64(len(words))
+				score += float64(index.weight(key.Key)) * (0.5 + 0.5*share)
+			}
+		}
+	}
+	return score
+}
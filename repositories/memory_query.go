@@ -0,0 +1,714 @@
+package repositories
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// This file implements the subset of the MongoDB query and update language
+// used by the repositories, for the in-memory backend. Filters and updates
+// are expected to be normalized (see normalizeFilter) before evaluation.
+
+// matchDocument reports whether a stored document satisfies a normalized filter
+func matchDocument(doc bson.M, filter bson.M) (bool, error) {
+	for key, condition := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			clauses, ok := condition.(primitive.A)
+			if !ok || len(clauses) == 0 {
+				return false, fmt.Errorf("%s must be a non-empty array", key)
+			}
+
+			matchedAny := false
+			matchedAll := true
+			for _, clause := range clauses {
+				clauseFilter, ok := clause.(bson.M)
+				if !ok {
+					return false, fmt.Errorf("%s entries must be documents", key)
+				}
+				matched, err := matchDocument(doc, clauseFilter)
+				if err != nil {
+					return false, err
+				}
+				matchedAny = matchedAny || matched
+				matchedAll = matchedAll && matched
+			}
+
+			if (key == "$and" && !matchedAll) || (key == "$or" && !matchedAny) || (key == "$nor" && matchedAny) {
+				return false, nil
+			}
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, fmt.Errorf("unsupported query operator %s", key)
+			}
+			matched, err := matchField(doc, key, condition)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// matchField evaluates the condition for a single (possibly dotted) field path
+func matchField(doc bson.M, path string, condition interface{}) (bool, error) {
+	values, found := lookupPath(doc, path)
+
+	if operators, ok := condition.(bson.M); ok && isOperatorDocument(operators) {
+		return matchOperators(values, found, operators)
+	}
+	if regex, ok := condition.(primitive.Regex); ok {
+		return matchRegex(expandArrays(values), regex.Pattern, regex.Options)
+	}
+	return containsEqual(expandArrays(values), condition), nil
+}
+
+// matchOperators evaluates an operator document such as {"$gte": 2, "$lt": 5}
+func matchOperators(values []interface{}, found bool, operators bson.M) (bool, error) {
+	candidates := expandArrays(values)
+
+	for operator, operand := range operators {
+		var matched bool
+		var err error
+
+		switch operator {
+		case "$eq":
+			matched = containsEqual(candidates, operand)
+		case "$ne":
+			matched = !containsEqual(candidates, operand)
+		case "$gt", "$gte", "$lt", "$lte":
+			matched = matchComparison(candidates, operator, operand)
+		case "$in", "$nin":
+			list, ok := operand.(primitive.A)
+			if !ok {
+				return false, fmt.Errorf("%s needs an array", operator)
+			}
+			matched = false
+			for _, item := range list {
+				if regex, ok := item.(primitive.Regex); ok {
+					matched, err = matchRegex(candidates, regex.Pattern, regex.Options)
+					if err != nil {
+						return false, err
+					}
+				} else {
+					matched = containsEqual(candidates, item)
+				}
+				if matched {
+					break
+				}
+			}
+			if operator == "$nin" {
+				matched = !matched
+			}
+		case "$exists":
+			matched = found == truthy(operand)
+		case "$regex":
+			pattern, options, err := regexOperand(operand, operators["$options"])
+			if err != nil {
+				return false, err
+			}
+			matched, err = matchRegex(candidates, pattern, options)
+			if err != nil {
+				return false, err
+			}
+		case "$options":
+			// Consumed together with $regex
+			matched = true
+		case "$size":
+			size, ok := toFloat(operand)
+			if !ok {
+				return false, fmt.Errorf("$size needs a number")
+			}
+			matched = false
+			for _, value := range values {
+				if array, ok := value.(primitive.A); ok && float64(len(array)) == size {
+					matched = true
+					break
+				}
+			}
+		case "$all":
+			list, ok := operand.(primitive.A)
+			if !ok {
+				return false, fmt.Errorf("$all needs an array")
+			}
+			matched = len(list) > 0
+			for _, item := range list {
+				if !containsEqual(candidates, item) {
+					matched = false
+					break
+				}
+			}
+		case "$elemMatch":
+			criteria, ok := operand.(bson.M)
+			if !ok {
+				return false, fmt.Errorf("$elemMatch needs a document")
+			}
+			matched, err = matchElement(values, criteria)
+			if err != nil {
+				return false, err
+			}
+		case "$not":
+			inner, ok := operand.(bson.M)
+			if !ok {
+				if regex, isRegex := operand.(primitive.Regex); isRegex {
+					matched, err = matchRegex(candidates, regex.Pattern, regex.Options)
+					if err != nil {
+						return false, err
+					}
+					matched = !matched
+					break
+				}
+				return false, fmt.Errorf("$not needs a document or regex")
+			}
+			matched, err = matchOperators(values, found, inner)
+			if err != nil {
+				return false, err
+			}
+			matched = !matched
+		default:
+			return false, fmt.Errorf("unsupported query operator %s", operator)
+		}
+
+		if !matched {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// matchElement implements $elemMatch against every array found at the path
+func matchElement(values []interface{}, criteria bson.M) (bool, error) {
+	for _, value := range values {
+		array, ok := value.(primitive.A)
+		if !ok {
+			continue
+		}
+		for _, element := range array {
+			var matched bool
+			var err error
+			if isOperatorDocument(criteria) {
+				matched, err = matchOperators([]interface{}{element}, true, criteria)
+			} else if elementDoc, isDoc := element.(bson.M); isDoc {
+				matched, err = matchDocument(elementDoc, criteria)
+			}
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func matchComparison(candidates []interface{}, operator string, operand interface{}) bool {
+	for _, candidate := range candidates {
+		cmp, ok := compareValues(candidate, operand)
+		if !ok {
+			continue
+		}
+		switch operator {
+		case "$gt":
+			if cmp > 0 {
+				return true
+			}
+		case "$gte":
+			if cmp >= 0 {
+				return true
+			}
+		case "$lt":
+			if cmp < 0 {
+				return true
+			}
+		case "$lte":
+			if cmp <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func regexOperand(operand interface{}, rawOptions interface{}) (string, string, error) {
+	options, _ := rawOptions.(string)
+	switch pattern := operand.(type) {
+	case string:
+		return pattern, options, nil
+	case primitive.Regex:
+		if options == "" {
+			options = pattern.Options
+		}
+		return pattern.Pattern, options, nil
+	default:
+		return "", "", fmt.Errorf("$regex needs a string")
+	}
+}
+
+func matchRegex(candidates []interface{}, pattern, options string) (bool, error) {
+	flags := ""
+	for _, option := range options {
+		switch option {
+		case 'i', 'm', 's':
+			flags += string(option)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("invalid regular expression: %w", err)
+	}
+
+	for _, candidate := range candidates {
+		if text, ok := candidate.(string); ok && compiled.MatchString(text) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// lookupPath resolves a dotted path, descending into arrays of sub-documents
+// the same way MongoDB does. It reports whether the path exists at all.
+func lookupPath(doc bson.M, path string) ([]interface{}, bool) {
+	return lookupParts(doc, strings.Split(path, "."))
+}
+
+func lookupParts(value interface{}, parts []string) ([]interface{}, bool) {
+	if len(parts) == 0 {
+		return []interface{}{value}, true
+	}
+
+	switch v := value.(type) {
+	case bson.M:
+		child, exists := v[parts[0]]
+		if !exists {
+			return nil, false
+		}
+		return lookupParts(child, parts[1:])
+	case primitive.A:
+		if index, err := strconv.Atoi(parts[0]); err == nil {
+			if index < 0 || index >= len(v) {
+				return nil, false
+			}
+			return lookupParts(v[index], parts[1:])
+		}
+
+		var results []interface{}
+		found := false
+		for _, element := range v {
+			if _, isDoc := element.(bson.M); !isDoc {
+				continue
+			}
+			elementValues, elementFound := lookupParts(element, parts)
+			if elementFound {
+				found = true
+				results = append(results, elementValues...)
+			}
+		}
+		return results, found
+	default:
+		return nil, false
+	}
+}
+
+// expandArrays returns the values plus the elements of any arrays among them,
+// which is how MongoDB matches scalar conditions against array fields
+func expandArrays(values []interface{}) []interface{} {
+	expanded := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		expanded = append(expanded, value)
+		if array, ok := value.(primitive.A); ok {
+			expanded = append(expanded, array...)
+		}
+	}
+	return expanded
+}
+
+func isOperatorDocument(doc bson.M) bool {
+	if len(doc) == 0 {
+		return false
+	}
+	for key := range doc {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+func containsEqual(candidates []interface{}, target interface{}) bool {
+	if target == nil && len(candidates) == 0 {
+		// {field: null} also matches documents where the field is missing
+		return true
+	}
+	for _, candidate := range candidates {
+		if valuesEqual(candidate, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// valuesEqual compares two normalized values, treating all numeric types alike
+func valuesEqual(a, b interface{}) bool {
+	if left, ok := toFloat(a); ok {
+		right, ok := toFloat(b)
+		return ok && left == right
+	}
+
+	switch left := a.(type) {
+	case primitive.A:
+		right, ok := b.(primitive.A)
+		if !ok || len(left) != len(right) {
+			return false
+		}
+		for i := range left {
+			if !valuesEqual(left[i], right[i]) {
+				return false
+			}
+		}
+		return true
+	case bson.M:
+		right, ok := b.(bson.M)
+		if !ok || len(left) != len(right) {
+			return false
+		}
+		for key, value := range left {
+			other, exists := right[key]
+			if !exists || !valuesEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues orders two values of the same kind; ok is false when they are not comparable
+func compareValues(a, b interface{}) (int, bool) {
+	if left, ok := toFloat(a); ok {
+		right, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		return compareFloats(left, right), true
+	}
+
+	switch left := a.(type) {
+	case string:
+		right, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(left, right), true
+	case primitive.DateTime:
+		right, ok := b.(primitive.DateTime)
+		if !ok {
+			return 0, false
+		}
+		return compareFloats(float64(left), float64(right)), true
+	case primitive.ObjectID:
+		right, ok := b.(primitive.ObjectID)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(left[:], right[:]), true
+	case bool:
+		right, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case left == right:
+			return 0, true
+		case !left:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// sortCompare orders any two values using MongoDB's cross-type ordering
+func sortCompare(a, b interface{}) int {
+	rankA, rankB := typeRank(a), typeRank(b)
+	if rankA != rankB {
+		return compareFloats(float64(rankA), float64(rankB))
+	}
+	if cmp, ok := compareValues(a, b); ok {
+		return cmp
+	}
+	return 0
+}
+
+func typeRank(value interface{}) int {
+	if _, ok := toFloat(value); ok {
+		return 1
+	}
+	switch value.(type) {
+	case nil:
+		return 0
+	case string:
+		return 2
+	case bson.M:
+		return 3
+	case primitive.A:
+		return 4
+	case primitive.ObjectID:
+		return 5
+	case bool:
+		return 6
+	case primitive.DateTime:
+		return 7
+	default:
+		return 8
+	}
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func truthy(value interface{}) bool {
+	if number, ok := toFloat(value); ok {
+		return number != 0
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return value != nil
+}
+
+// applyUpdate applies a normalized update document ($set, $inc, $push, ...) to doc in place
+func applyUpdate(doc bson.M, update bson.M) error {
+	normalized, err := toDocument(update)
+	if err != nil {
+		return err
+	}
+	if len(normalized) == 0 || !isOperatorDocument(normalized) {
+		return fmt.Errorf("update document must contain only update operators")
+	}
+
+	for operator, rawFields := range normalized {
+		fields, ok := rawFields.(bson.M)
+		if !ok {
+			return fmt.Errorf("%s needs a document", operator)
+		}
+
+		for path, value := range fields {
+			var err error
+			switch operator {
+			case "$set":
+				err = setPath(doc, path, value)
+			case "$setOnInsert":
+				// Upserts are not supported, so there is never an insert
+			case "$unset":
+				unsetPath(doc, path)
+			case "$inc":
+				err = incrementPath(doc, path, value)
+			case "$push":
+				err = pushPath(doc, path, value, false)
+			case "$addToSet":
+				err = pushPath(doc, path, value, true)
+			case "$pull":
+				err = pullPath(doc, path, value)
+			default:
+				err = fmt.Errorf("unsupported update operator %s", operator)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parentFor walks to the document that holds the last element of the path, creating
+// intermediate documents as needed
+func parentFor(doc bson.M, path string, create bool) (bson.M, string, error) {
+	parts := strings.Split(path, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		child, exists := current[part]
+		if !exists || child == nil {
+			if !create {
+				return nil, "", nil
+			}
+			next := bson.M{}
+			current[part] = next
+			current = next
+			continue
+		}
+		next, ok := child.(bson.M)
+		if !ok {
+			return nil, "", fmt.Errorf("cannot traverse field %s in path %s", part, path)
+		}
+		current = next
+	}
+	return current, parts[len(parts)-1], nil
+}
+
+func setPath(doc bson.M, path string, value interface{}) error {
+	parent, key, err := parentFor(doc, path, true)
+	if err != nil {
+		return err
+	}
+	parent[key] = value
+	return nil
+}
+
+func unsetPath(doc bson.M, path string) {
+	parent, key, err := parentFor(doc, path, false)
+	if err == nil && parent != nil {
+		delete(parent, key)
+	}
+}
+
+func incrementPath(doc bson.M, path string, value interface{}) error {
+	parent, key, err := parentFor(doc, path, true)
+	if err != nil {
+		return err
+	}
+
+	delta, ok := toFloat(value)
+	if !ok {
+		return fmt.Errorf("cannot increment with non-numeric value")
+	}
+
+	current, exists := parent[key]
+	if !exists || current == nil {
+		parent[key] = value
+		return nil
+	}
+
+	currentNumber, ok := toFloat(current)
+	if !ok {
+		return fmt.Errorf("cannot apply $inc to non-numeric field %s", path)
+	}
+
+	_, currentIsFloat := current.(float64)
+	_, deltaIsFloat := value.(float64)
+	if currentIsFloat || deltaIsFloat {
+		parent[key] = currentNumber + delta
+		return nil
+	}
+
+	sum := int64(currentNumber) + int64(delta)
+	if sum >= -1<<31 && sum < 1<<31 {
+		parent[key] = int32(sum)
+	} else {
+		parent[key] = sum
+	}
+	return nil
+}
+
+func pushPath(doc bson.M, path string, value interface{}, unique bool) error {
+	parent, key, err := parentFor(doc, path, true)
+	if err != nil {
+		return err
+	}
+
+	var array primitive.A
+	switch current := parent[key].(type) {
+	case nil:
+		array = primitive.A{}
+	case primitive.A:
+		array = current
+	default:
+		return fmt.Errorf("cannot push to non-array field %s", path)
+	}
+
+	items := primitive.A{value}
+	if modifiers, ok := value.(bson.M); ok {
+		if each, hasEach := modifiers["$each"]; hasEach {
+			list, ok := each.(primitive.A)
+			if !ok {
+				return fmt.Errorf("$each needs an array")
+			}
+			items = list
+		}
+	}
+
+	for _, item := range items {
+		if unique && containsEqual(array, item) {
+			continue
+		}
+		array = append(array, item)
+	}
+	parent[key] = array
+	return nil
+}
+
+func pullPath(doc bson.M, path string, condition interface{}) error {
+	parent, key, err := parentFor(doc, path, false)
+	if err != nil || parent == nil {
+		return err
+	}
+
+	array, ok := parent[key].(primitive.A)
+	if !ok {
+		return nil
+	}
+
+	kept := primitive.A{}
+	for _, element := range array {
+		var remove bool
+		criteria, isDoc := condition.(bson.M)
+		switch {
+		case isDoc && isOperatorDocument(criteria):
+			remove, err = matchOperators([]interface{}{element}, true, criteria)
+		case isDoc:
+			if elementDoc, ok := element.(bson.M); ok {
+				remove, err = matchDocument(elementDoc, criteria)
+			}
+		default:
+			remove = valuesEqual(element, condition)
+		}
+		if err != nil {
+			return err
+		}
+		if !remove {
+			kept = append(kept, element)
+		}
+	}
+	parent[key] = kept
+	return nil
+}
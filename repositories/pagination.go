@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Sort keys accepted by List
+const (
+	SortByName      = "name"
+	SortByPoints    = "points"
+	SortByCreatedAt = "createdAt"
+)
+
+// ListOptions controls the order and paging of a list query
+type ListOptions struct {
+	Limit      int64
+	Skip       int64  // ignored when Cursor is set
+	Sort       string // one of the Sort* keys; empty uses the repository default
+	Descending bool
+	Cursor     string // the NextCursor of the previous page
+	Filter     Filter // conditions the documents must also match
+}
+
+// listCursor is the position after the last document of a page. It is
+// BSON-encoded so the sort value keeps its type across requests.
+type listCursor struct {
+	Sort       string             `bson:"s"`
+	Descending bool               `bson:"d"`
+	Value      interface{}        `bson:"v"`
+	ID         primitive.ObjectID `bson:"i"`
+}
+
+func encodeCursor(c listCursor) (string, error) {
+	raw, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(encoded string) (listCursor, error) {
+	var c listCursor
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || bson.Unmarshal(raw, &c) != nil || c.ID.IsZero() {
+		return c, utils.NewValidationError("cursor", "invalid cursor")
+	}
+	return c, nil
+}
+
+// withSortField makes another field sortable under the given key
+func (r *BaseRepository) withSortField(key, path string) *BaseRepository {
+	r.sortFields[key] = path
+	return r
+}
+
+// SortKeys returns the sort keys List accepts, in name order
+func (r *BaseRepository) SortKeys() []string {
+	keys := make([]string, 0, len(r.sortFields))
+	for key := range r.sortFields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// List finds one page of documents matching filter into results, which must
+// point to a slice. Documents are ordered by the sort field with _id breaking
+// ties, which makes the order stable enough to page with a cursor. When there
+// are more documents after the page, the cursor for the next page is returned.
+func (r *BaseRepository) List(ctx context.Context, filter bson.M, results interface{}, opts ListOptions) (string, error) {
+	sortKey := opts.Sort
+	if sortKey == "" {
+		sortKey = r.defaultSort
+	}
+	path, ok := r.sortFields[sortKey]
+	if !ok {
+		return "", utils.NewValidationError("sort", fmt.Sprintf("cannot sort by %q, use one of %s", sortKey, strings.Join(r.SortKeys(), ", ")))
+	}
+
+	direction := 1
+	if opts.Descending {
+		direction = -1
+	}
+
+	filter, err := r.Where(filter, opts.Filter)
+	if err != nil {
+		return "", err
+	}
+
+	query := withoutDeleted(filter)
+	findOpts := options.Find()
+	if path == "_id" {
+		findOpts.SetSort(bson.D{{Key: "_id", Value: direction}})
+	} else {
+		findOpts.SetSort(bson.D{{Key: path, Value: direction}, {Key: "_id", Value: direction}})
+	}
+
+	if opts.Cursor != "" {
+		after, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return "", err
+		}
+		if after.Sort != sortKey || after.Descending != opts.Descending {
+			return "", utils.NewValidationError("cursor", "cursor was issued for a different sort")
+		}
+		query = bson.M{"$and": bson.A{query, afterCursor(path, direction, after)}}
+	} else if opts.Skip > 0 {
+		findOpts.SetSkip(opts.Skip)
+	}
+
+	// Fetch one extra document to learn whether there is a next page
+	if opts.Limit > 0 {
+		findOpts.SetLimit(opts.Limit + 1)
+	}
+
+	if err := r.Collection.Find(ctx, query, results, findOpts); err != nil {
+		return "", err
+	}
+
+	page := reflect.ValueOf(results).Elem()
+	if opts.Limit <= 0 || int64(page.Len()) <= opts.Limit {
+		return "", nil
+	}
+	page.Set(page.Slice(0, int(opts.Limit)))
+
+	last, err := toDocument(page.Index(page.Len() - 1).Interface())
+	if err != nil {
+		return "", err
+	}
+	id, _ := last["_id"].(primitive.ObjectID)
+	return encodeCursor(listCursor{
+		Sort:       sortKey,
+		Descending: opts.Descending,
+		Value:      firstValue(last, path),
+		ID:         id,
+	})
+}
+
+// afterCursor matches the documents that come after the cursor in the list
+// order. Comparison operators never match null, so documents without a sort
+// value, which sort first ascending and last descending, need their own branch.
+func afterCursor(path string, direction int, after listCursor) bson.M {
+	beyond, idBeyond := "$gt", bson.M{"$gt": after.ID}
+	if direction < 0 {
+		beyond, idBeyond = "$lt", bson.M{"$lt": after.ID}
+	}
+
+	if path == "_id" {
+		return bson.M{"_id": idBeyond}
+	}
+
+	if after.Value == nil {
+		if direction > 0 {
+			return bson.M{"$or": bson.A{
+				bson.M{path: bson.M{"$ne": nil}},
+				bson.M{path: nil, "_id": idBeyond},
+			}}
+		}
+		return bson.M{path: nil, "_id": idBeyond}
+	}
+
+	branches := bson.A{
+		bson.M{path: bson.M{beyond: after.Value}},
+		bson.M{path: after.Value, "_id": idBeyond},
+	}
+	if direction < 0 {
+		branches = append(branches, bson.M{path: nil})
+	}
+	return bson.M{"$or": branches}
+}
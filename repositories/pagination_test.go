@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"grimdank-database/models"
+	"grimdank-database/utils"
+)
+
+// pageThrough follows cursors until the last page and returns the names in order
+func pageThrough(t *testing.T, repo *RuleRepository, opts ListOptions) []string {
+	t.Helper()
+	ctx := context.Background()
+
+	var names []string
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("Cursor paging did not terminate")
+		}
+		rules, nextCursor, err := repo.ListRules(ctx, "", opts)
+		if err != nil {
+			t.Fatalf("Failed to list rules: %v", err)
+		}
+		for _, rule := range rules {
+			names = append(names, rule.Name)
+		}
+		if nextCursor == "" {
+			return names
+		}
+		opts.Cursor = nextCursor
+	}
+}
+
+func TestListCursorPaging(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRuleRepository(NewMemoryCollection())
+
+	// Two rules share a cost and one has no points, so ties and nulls are both paged over
+	rules := []*models.Rule{
+		{Name: "Fleet", Points: []int{5}},
+		{Name: "Rending", Points: []int{3, 5}},
+		{Name: "Stealth"},
+		{Name: "Armourbane", Points: []int{8}},
+		{Name: "Fear", Points: []int{5}},
+	}
+	for _, rule := range rules {
+		if _, err := repo.CreateRule(ctx, rule); err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{"Created Order", ListOptions{Limit: 2}, []string{"Fleet", "Rending", "Stealth", "Armourbane", "Fear"}},
+		{"Name Descending", ListOptions{Limit: 2, Sort: SortByName, Descending: true}, []string{"Stealth", "Rending", "Fleet", "Fear", "Armourbane"}},
+		{"Points Ascending", ListOptions{Limit: 2, Sort: SortByPoints}, []string{"Stealth", "Rending", "Fleet", "Fear", "Armourbane"}},
+		{"Points Descending", ListOptions{Limit: 2, Sort: SortByPoints, Descending: true}, []string{"Armourbane", "Fear", "Fleet", "Rending", "Stealth"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := pageThrough(t, repo, tc.opts)
+			if len(got) != len(tc.want) {
+				t.Fatalf("Expected %v, got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("Expected %v, got %v", tc.want, got)
+				}
+			}
+		})
+	}
+
+	t.Run("Insert While Paging", func(t *testing.T) {
+		repo := NewRuleRepository(NewMemoryCollection())
+		for _, name := range []string{"B", "D"} {
+			repo.CreateRule(ctx, &models.Rule{Name: name})
+		}
+
+		first, nextCursor, err := repo.ListRules(ctx, "", ListOptions{Limit: 1, Sort: SortByName})
+		if err != nil || len(first) != 1 || first[0].Name != "B" {
+			t.Fatalf("Unexpected first page %v (%v)", first, err)
+		}
+
+		// A document inserted before the cursor must not shift the next page
+		repo.CreateRule(ctx, &models.Rule{Name: "A"})
+		second, _, err := repo.ListRules(ctx, "", ListOptions{Limit: 1, Sort: SortByName, Cursor: nextCursor})
+		if err != nil || len(second) != 1 || second[0].Name != "D" {
+			t.Errorf("Expected D on the second page, got %v (%v)", second, err)
+		}
+	})
+
+	t.Run("Invalid Sort And Cursor", func(t *testing.T) {
+		if _, _, err := repo.ListRules(ctx, "", ListOptions{Sort: "weight"}); !utils.IsValidationError(err) {
+			t.Errorf("Expected validation error for unknown sort, got %v", err)
+		}
+		if _, _, err := repo.ListRules(ctx, "", ListOptions{Cursor: "not-a-cursor"}); !utils.IsValidationError(err) {
+			t.Errorf("Expected validation error for bad cursor, got %v", err)
+		}
+
+		_, nextCursor, _ := repo.ListRules(ctx, "", ListOptions{Limit: 1, Sort: SortByName})
+		if _, _, err := repo.ListRules(ctx, "", ListOptions{Limit: 1, Sort: SortByPoints, Cursor: nextCursor}); !utils.IsValidationError(err) {
+			t.Errorf("Expected validation error for cursor from another sort, got %v", err)
+		}
+	})
+
+	t.Run("Army Books Cannot Sort By Points", func(t *testing.T) {
+		books := NewArmyBookRepository(NewMemoryCollection())
+		if _, _, err := books.ListArmyBooks(ctx, "", ListOptions{Sort: SortByPoints}); !utils.IsValidationError(err) {
+			t.Errorf("Expected validation error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"grimdank-database/models"
+)
+
+// PointsSeasonRepository stores the points seasons. They apply to every
+// workspace, so its collection must not be scoped to workspaces.
+type PointsSeasonRepository struct {
+	*BaseRepository
+}
+
+func NewPointsSeasonRepository(collection Collection) *PointsSeasonRepository {
+	return &PointsSeasonRepository{
+		BaseRepository: NewBaseRepository(collection),
+	}
+}
+
+// Indexes declares the indexes points seasons rely on
+func (r *PointsSeasonRepository) Indexes() []IndexSpec {
+	return []IndexSpec{
+		{
+			Name:   "name_unique",
+			Keys:   bson.D{{Key: "name", Value: 1}},
+			Unique: true,
+		},
+	}
+}
+
+func (r *PointsSeasonRepository) CreateSeason(ctx context.Context, season *models.PointsSeason) error {
+	season.Version = 1
+	now := time.Now()
+	season.CreatedAt = now
+	season.UpdatedAt = now
+
+	id, err := r.Create(ctx, season)
+	if err != nil {
+		return err
+	}
+	season.ID = id
+	return nil
+}
+
+func (r *PointsSeasonRepository) GetSeasonByName(ctx context.Context, name string) (*models.PointsSeason, error) {
+	var season models.PointsSeason
+	if err := r.Collection.FindOne(ctx, bson.M{"name": name}, &season); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("points season not found")
+		}
+		return nil, err
+	}
+	return &season, nil
+}
+
+// ListSeasons returns every stored season by name
+func (r *PointsSeasonRepository) ListSeasons(ctx context.Context) ([]models.PointsSeason, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	seasons := []models.PointsSeason{}
+	if err := r.Collection.Find(ctx, bson.M{}, &seasons, opts); err != nil {
+		return nil, err
+	}
+	return seasons, nil
+}
+
+// UpdateSeason replaces the settings of a season, which must still be at
+// season.Version unless that is 0. Whether it is current is left alone.
+func (r *PointsSeasonRepository) UpdateSeason(ctx context.Context, season *models.PointsSeason) error {
+	version, err := r.UpdateVersioned(ctx, season.ID, season.Version, bson.M{
+		"name":        season.Name,
+		"description": season.Description,
+		"rules":       season.Rules,
+		"weapons":     season.Weapons,
+		"units":       season.Units,
+	})
+	if err != nil {
+		return err
+	}
+	season.Version = version
+	return nil
+}
+
+// SetCurrent makes the named season the current one and every season
+// activated before it historical, and returns it. The season is marked
+// current before the others are cleared, so there is always a current season;
+// when activations overlap, both may be left marked and the later one counts.
+func (r *PointsSeasonRepository) SetCurrent(ctx context.Context, name string) (*models.PointsSeason, error) {
+	now := time.Now().Truncate(time.Millisecond)
+	var season models.PointsSeason
+	err := r.Collection.FindOneAndUpdate(ctx, bson.M{"name": name}, bson.M{
+		"$set": bson.M{"current": true, "activatedAt": now, "updatedAt": now},
+		"$inc": bson.M{"version": 1},
+	}, &season)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("points season not found")
+		}
+		return nil, err
+	}
+	if err := r.clearCurrent(ctx, season.ID, now); err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+// ClearCurrent makes every season activated before now historical, leaving
+// the built-in season current
+func (r *PointsSeasonRepository) ClearCurrent(ctx context.Context) error {
+	return r.clearCurrent(ctx, primitive.NilObjectID, time.Now().Truncate(time.Millisecond))
+}
+
+// clearCurrent makes the current seasons other than except that were
+// activated before cutoff historical, each with a conditional update, so a
+// season activated meanwhile stays current
+func (r *PointsSeasonRepository) clearCurrent(ctx context.Context, except primitive.ObjectID, cutoff time.Time) error {
+	var current []models.PointsSeason
+	if err := r.Collection.Find(ctx, bson.M{"current": true, "_id": bson.M{"$ne": except}}, &current, nil); err != nil {
+		return err
+	}
+	for _, season := range current {
+		if _, err := r.Collection.UpdateOne(ctx, bson.M{
+			"_id":     season.ID,
+			"current": true,
+			"$or": bson.A{
+				bson.M{"activatedAt": bson.M{"$lt": cutoff}},
+				bson.M{"activatedAt": bson.M{"$exists": false}},
+			},
+		}, bson.M{
+			"$set": bson.M{"current": false, "updatedAt": time.Now()},
+			"$inc": bson.M{"version": 1},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteSeason removes a season for good
+func (r *PointsSeasonRepository) DeleteSeason(ctx context.Context, name string) error {
+	deleted, err := r.Collection.DeleteOne(ctx, bson.M{"name": name})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("points season not found")
+	}
+	return nil
+}
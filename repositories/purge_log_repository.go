@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PurgeRecord notes that documents of a collection were removed for good
+// before the trash retention ran out, by hand or by a snapshot restore
+type PurgeRecord struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EntityType string             `bson:"entityType" json:"entityType"`
+	PurgedAt   time.Time          `bson:"purgedAt" json:"purgedAt"`
+}
+
+// PurgeLogRepository records early purges, which sync can't report as
+// deletions. It is shared by every workspace.
+type PurgeLogRepository struct {
+	*BaseRepository
+}
+
+func NewPurgeLogRepository(collection Collection) *PurgeLogRepository {
+	return &PurgeLogRepository{
+		BaseRepository: NewBaseRepository(collection),
+	}
+}
+
+// Indexes declares the index finding the latest purge relies on
+func (r *PurgeLogRepository) Indexes() []IndexSpec {
+	return []IndexSpec{
+		{Name: "purged_at", Keys: bson.D{{Key: "purgedAt", Value: -1}}},
+	}
+}
+
+// RecordPurge notes that documents of entityType were purged at
+func (r *PurgeLogRepository) RecordPurge(ctx context.Context, entityType string, at time.Time) error {
+	_, err := r.Create(ctx, &PurgeRecord{EntityType: entityType, PurgedAt: at})
+	return err
+}
+
+// LastPurge returns when documents were last purged early, or the zero time
+// if they never were
+func (r *PurgeLogRepository) LastPurge(ctx context.Context) (time.Time, error) {
+	var records []PurgeRecord
+	opts := options.Find().SetSort(bson.D{{Key: "purgedAt", Value: -1}}).SetLimit(1)
+	if err := r.Collection.Find(ctx, bson.M{}, &records, opts); err != nil {
+		return time.Time{}, err
+	}
+	if len(records) == 0 {
+		return time.Time{}, nil
+	}
+	return records[0].PurgedAt, nil
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"grimdank-database/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RevisionRepository stores the revision history of the other collections.
+// Revisions are only ever inserted; nothing here updates or removes them.
+type RevisionRepository struct {
+	*BaseRepository
+}
+
+func NewRevisionRepository(collection Collection) *RevisionRepository {
+	return &RevisionRepository{
+		BaseRepository: NewBaseRepository(collection),
+	}
+}
+
+// Indexes declares the indexes revisions rely on. An entity has one revision
+// per version, which also serves listing an entity's history in order.
+func (r *RevisionRepository) Indexes() []IndexSpec {
+	return []IndexSpec{
+		{
+			Name: "entity_version_unique",
+			Keys: bson.D{
+				{Key: "entityType", Value: 1},
+				{Key: "entityId", Value: 1},
+				{Key: "version", Value: 1},
+			},
+			Unique: true,
+		},
+	}
+}
+
+func (r *RevisionRepository) CreateRevision(ctx context.Context, revision *models.Revision) error {
+	id, err := r.Create(ctx, revision)
+	if err != nil {
+		return err
+	}
+	revision.ID = id
+	return nil
+}
+
+// ListRevisions returns the revisions of one entity, newest first
+func (r *RevisionRepository) ListRevisions(ctx context.Context, entityType string, entityID primitive.ObjectID, limit, skip int64) ([]models.Revision, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	if skip > 0 {
+		opts.SetSkip(skip)
+	}
+
+	revisions := []models.Revision{}
+	filter := bson.M{"entityType": entityType, "entityId": entityID}
+	if err := r.Collection.Find(ctx, filter, &revisions, opts); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetRevision returns the revision of one entity at the given version
+func (r *RevisionRepository) GetRevision(ctx context.Context, entityType string, entityID primitive.ObjectID, version int) (*models.Revision, error) {
+	var revision models.Revision
+	filter := bson.M{"entityType": entityType, "entityId": entityID, "version": version}
+	if err := r.Collection.FindOne(ctx, filter, &revision); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("revision not found")
+		}
+		return nil, err
+	}
+	return &revision, nil
+}
@@ -2,25 +2,42 @@ package repositories
 
 import (
 	"context"
+	"time"
+
 	"grimdank-database/models"
 	"grimdank-database/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type RuleRepository struct {
 	*BaseRepository
 }
 
-func NewRuleRepository(collection *mongo.Collection) *RuleRepository {
+func NewRuleRepository(collection Collection) *RuleRepository {
+	// Rules are sorted by the cost of their first tier but match a points filter on any tier
+	base := NewBaseRepository(collection).
+		withSortField(SortByPoints, "points.0").
+		withFilterFields(map[string]FilterField{
+			"points": numberField("points"),
+		})
 	return &RuleRepository{
-		BaseRepository: NewBaseRepository(collection),
+		BaseRepository: base,
 	}
 }
 
+// Indexes declares the indexes rules rely on
+func (r *RuleRepository) Indexes() []IndexSpec {
+	return []IndexSpec{uniqueNameIndex(), fieldIndex("updatedAt"), textSearchIndex("name", "description")}
+}
+
 func (r *RuleRepository) CreateRule(ctx context.Context, rule *models.Rule) (string, error) {
+	rule.Version = 1
+	rule.Workspace = workspaceFor(ctx, rule.Workspace)
+	now := time.Now()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
 	id, err := r.Create(ctx, rule)
 	if err != nil {
 		return "", err
@@ -55,13 +72,26 @@ func (r *RuleRepository) SearchRulesByName(ctx context.Context, name string, lim
 	return rules, err
 }
 
+// ListRules returns one page of rules, optionally only those whose name contains name,
+// and the cursor for the next page
+func (r *RuleRepository) ListRules(ctx context.Context, name string, opts ListOptions) ([]models.Rule, string, error) {
+	var rules []models.Rule
+	nextCursor, err := r.List(ctx, nameFilter(name), &rules, opts)
+	return rules, nextCursor, err
+}
+
 func (r *RuleRepository) UpdateRule(ctx context.Context, id string, rule *models.Rule) error {
 	objectID, err := utils.ParseObjectID(id)
 	if err != nil {
 		return err
 	}
 	rule.ID = objectID
-	return r.Update(ctx, objectID, rule)
+	version, err := r.UpdateVersioned(ctx, objectID, rule.Version, rule)
+	if err != nil {
+		return err
+	}
+	rule.Version = version
+	return nil
 }
 
 func (r *RuleRepository) DeleteRule(ctx context.Context, id string) error {
@@ -73,17 +103,20 @@ func (r *RuleRepository) DeleteRule(ctx context.Context, id string) error {
 }
 
 func (r *RuleRepository) CountRules(ctx context.Context) (int64, error) {
-	return r.Count(ctx, bson.M{})
+	return r.CountRulesMatching(ctx, "", nil)
 }
 
 func (r *RuleRepository) CountRulesByName(ctx context.Context, name string) (int64, error) {
-	filter := bson.M{
-		"name": bson.M{
-			"$regex":   name,
-			"$options": "i",
-		},
+	return r.CountRulesMatching(ctx, name, nil)
+}
+
+// CountRulesMatching counts the rules ListRules would return across all pages
+func (r *RuleRepository) CountRulesMatching(ctx context.Context, name string, filter Filter) (int64, error) {
+	query, err := r.Where(nameFilter(name), filter)
+	if err != nil {
+		return 0, err
 	}
-	return r.Count(ctx, filter)
+	return r.Count(ctx, query)
 }
 
 func (r *RuleRepository) BulkImportRules(ctx context.Context, rulesList []models.Rule) ([]string, error) {
@@ -92,21 +125,24 @@ func (r *RuleRepository) BulkImportRules(ctx context.Context, rulesList []models
 	}
 
 	documents := make([]interface{}, len(rulesList))
+	now := time.Now()
 	for i, rule := range rulesList {
+		rule.Version = 1
+		rule.Workspace = workspaceFor(ctx, rule.Workspace)
+		rule.CreatedAt = now
+		rule.UpdatedAt = now
 		documents[i] = rule
 	}
 
+	// On failure the documents inserted before it are still reported
 	insertedIDs, err := r.BulkInsert(ctx, documents)
-	if err != nil {
-		return nil, err
-	}
 
 	hexIDs := make([]string, len(insertedIDs))
 	for i, id := range insertedIDs {
 		hexIDs[i] = id.Hex()
 	}
 
-	return hexIDs, nil
+	return hexIDs, err
 }
 
 func (r *RuleRepository) GetRulesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Rule, error) {
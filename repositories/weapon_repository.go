@@ -2,24 +2,47 @@ package repositories
 
 import (
 	"context"
+	"time"
+
 	"grimdank-database/models"
 	"grimdank-database/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type WeaponRepository struct {
 	*BaseRepository
 }
 
-func NewWeaponRepository(collection *mongo.Collection) *WeaponRepository {
+func NewWeaponRepository(collection Collection) *WeaponRepository {
+	base := NewBaseRepository(collection).
+		withSortField(SortByPoints, "points").
+		withFilterFields(map[string]FilterField{
+			"type":    textField("type"),
+			"range":   numberField("range"),
+			"attacks": numberField("attacks"),
+			"points":  numberField("points"),
+			// AP is stored as a single digit string, so ranges compare correctly as text
+			"ap":           {Path: "ap", Kind: FieldString, Operators: numberField("ap").Operators},
+			"rules.ruleId": referenceField("rules.ruleId"),
+		})
 	return &WeaponRepository{
-		BaseRepository: NewBaseRepository(collection),
+		BaseRepository: base,
 	}
 }
 
+// Indexes declares the indexes weapons rely on
+func (r *WeaponRepository) Indexes() []IndexSpec {
+	return []IndexSpec{uniqueNameIndex(), fieldIndex("rules.ruleId"), fieldIndex("updatedAt"), textSearchIndex("name")}
+}
+
 func (r *WeaponRepository) CreateWeapon(ctx context.Context, weapon *models.Weapon) (string, error) {
+	weapon.Version = 1
+	weapon.Workspace = workspaceFor(ctx, weapon.Workspace)
+	now := time.Now()
+	weapon.CreatedAt = now
+	weapon.UpdatedAt = now
 	id, err := r.Create(ctx, weapon)
 	if err != nil {
 		return "", err
@@ -42,6 +65,13 @@ func (r *WeaponRepository) GetWeaponByID(ctx context.Context, id string) (*model
 	return &weapon, nil
 }
 
+func (r *WeaponRepository) GetWeaponsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Weapon, error) {
+	var weapons []models.Weapon
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+	err := r.GetAll(ctx, filter, &weapons, 0, 0)
+	return weapons, err
+}
+
 func (r *WeaponRepository) GetAllWeapons(ctx context.Context, limit, skip int64) ([]models.Weapon, error) {
 	var weapons []models.Weapon
 	err := r.GetAll(ctx, bson.M{}, &weapons, limit, skip)
@@ -54,13 +84,26 @@ func (r *WeaponRepository) SearchWeaponsByName(ctx context.Context, name string,
 	return weapons, err
 }
 
+// ListWeapons returns one page of weapons, optionally only those whose name contains name,
+// and the cursor for the next page
+func (r *WeaponRepository) ListWeapons(ctx context.Context, name string, opts ListOptions) ([]models.Weapon, string, error) {
+	var weapons []models.Weapon
+	nextCursor, err := r.List(ctx, nameFilter(name), &weapons, opts)
+	return weapons, nextCursor, err
+}
+
 func (r *WeaponRepository) UpdateWeapon(ctx context.Context, id string, weapon *models.Weapon) error {
 	objectID, err := utils.ParseObjectID(id)
 	if err != nil {
 		return err
 	}
 	weapon.ID = objectID
-	return r.Update(ctx, objectID, weapon)
+	version, err := r.UpdateVersioned(ctx, objectID, weapon.Version, weapon)
+	if err != nil {
+		return err
+	}
+	weapon.Version = version
+	return nil
 }
 
 func (r *WeaponRepository) DeleteWeapon(ctx context.Context, id string) error {
@@ -72,17 +115,20 @@ func (r *WeaponRepository) DeleteWeapon(ctx context.Context, id string) error {
 }
 
 func (r *WeaponRepository) CountWeapons(ctx context.Context) (int64, error) {
-	return r.Count(ctx, bson.M{})
+	return r.CountWeaponsMatching(ctx, "", nil)
 }
 
 func (r *WeaponRepository) CountWeaponsByName(ctx context.Context, name string) (int64, error) {
-	filter := bson.M{
-		"name": bson.M{
-			"$regex":   name,
-			"$options": "i",
-		},
+	return r.CountWeaponsMatching(ctx, name, nil)
+}
+
+// CountWeaponsMatching counts the weapons ListWeapons would return across all pages
+func (r *WeaponRepository) CountWeaponsMatching(ctx context.Context, name string, filter Filter) (int64, error) {
+	query, err := r.Where(nameFilter(name), filter)
+	if err != nil {
+		return 0, err
 	}
-	return r.Count(ctx, filter)
+	return r.Count(ctx, query)
 }
 
 func (r *WeaponRepository) BulkImportWeapons(ctx context.Context, weaponsList []models.Weapon) ([]string, error) {
@@ -91,19 +137,22 @@ func (r *WeaponRepository) BulkImportWeapons(ctx context.Context, weaponsList []
 	}
 
 	documents := make([]interface{}, len(weaponsList))
+	now := time.Now()
 	for i, weapon := range weaponsList {
+		weapon.Version = 1
+		weapon.Workspace = workspaceFor(ctx, weapon.Workspace)
+		weapon.CreatedAt = now
+		weapon.UpdatedAt = now
 		documents[i] = weapon
 	}
 
+	// On failure the documents inserted before it are still reported
 	insertedIDs, err := r.BulkInsert(ctx, documents)
-	if err != nil {
-		return nil, err
-	}
 
 	hexIDs := make([]string, len(insertedIDs))
 	for i, id := range insertedIDs {
 		hexIDs[i] = id.Hex()
 	}
 
-	return hexIDs, nil
+	return hexIDs, err
 }
@@ -0,0 +1,293 @@
+package repositories
+
+import (
+	"context"
+
+	"grimdank-database/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultWorkspace holds the data of requests that name no workspace, and
+// everything written before workspaces existed. It is open to everyone.
+const DefaultWorkspace = "default"
+
+// WorkspaceField is the document field holding the workspace a document belongs to
+const WorkspaceField = "workspace"
+
+// sharedDocuments lists, per collection, the documents every workspace can
+// read. Only the workspace they belong to can change them.
+var sharedDocuments = map[string]bson.M{
+	"factions": {"type": models.FactionTypeOfficial},
+}
+
+type workspaceKey struct{}
+
+// allWorkspaces marks a context whose storage calls are not scoped
+const allWorkspaces = "\x00all"
+
+// WithWorkspace returns a context whose storage calls only see and write the
+// documents of workspace
+func WithWorkspace(ctx context.Context, workspace string) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, workspace)
+}
+
+// AllWorkspaces returns a context whose storage calls see every workspace and
+// write documents as they are given. It is meant for maintenance such as
+// snapshots and purging the trash.
+func AllWorkspaces(ctx context.Context) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, allWorkspaces)
+}
+
+// WorkspaceFrom returns the workspace ctx is scoped to, the default one if it
+// names none. It returns "" for a context of AllWorkspaces.
+func WorkspaceFrom(ctx context.Context) string {
+	workspace, _ := ctx.Value(workspaceKey{}).(string)
+	switch workspace {
+	case "":
+		return DefaultWorkspace
+	case allWorkspaces:
+		return ""
+	}
+	return workspace
+}
+
+// WorkspaceOf returns the workspace a document belongs to given its stored
+// workspace field. Documents without one belong to the default workspace.
+func WorkspaceOf(stored string) string {
+	if stored == "" {
+		return DefaultWorkspace
+	}
+	return stored
+}
+
+// workspaceFor returns the workspace to store on a new document: the
+// caller's, or under AllWorkspaces the one the document names, the default
+// workspace if it names none
+func workspaceFor(ctx context.Context, stored string) string {
+	if workspace := WorkspaceFrom(ctx); workspace != "" {
+		return workspace
+	}
+	return WorkspaceOf(stored)
+}
+
+// WorkspaceStore is a Store whose collections are scoped to the workspace of
+// the context each call is made with
+type WorkspaceStore struct {
+	store Store
+}
+
+// NewWorkspaceStore scopes the collections of store to workspaces
+func NewWorkspaceStore(store Store) *WorkspaceStore {
+	return &WorkspaceStore{
+		store: store,
+	}
+}
+
+// Collection returns the named collection scoped to workspaces
+func (s *WorkspaceStore) Collection(name string) Collection {
+	return &workspaceCollection{
+		Collection: s.store.Collection(name),
+		shared:     sharedDocuments[name],
+	}
+}
+
+// workspaceCollection adds the caller's workspace to every filter and stamps
+// it on every document written. Index management is not scoped.
+type workspaceCollection struct {
+	Collection
+	shared bson.M
+}
+
+// OwnedBy matches the documents belonging to workspace. Documents written
+// before workspaces existed have no workspace and belong to the default one,
+// whether or not the migration stamping them has run.
+func OwnedBy(workspace string) bson.M {
+	if workspace == DefaultWorkspace {
+		return bson.M{"$or": bson.A{
+			bson.M{WorkspaceField: workspace},
+			bson.M{WorkspaceField: bson.M{"$exists": false}},
+		}}
+	}
+	return bson.M{WorkspaceField: workspace}
+}
+
+// readScope matches the documents a workspace can read
+func (c *workspaceCollection) readScope(workspace string) bson.M {
+	if c.shared == nil {
+		return OwnedBy(workspace)
+	}
+	return bson.M{"$or": bson.A{OwnedBy(workspace), c.shared}}
+}
+
+// scoped combines filter with scope
+func scoped(filter, scope bson.M) bson.M {
+	if len(filter) == 0 {
+		return scope
+	}
+
+	combined := make(bson.M, len(filter)+len(scope))
+	for key, value := range filter {
+		combined[key] = value
+	}
+	for key, value := range scope {
+		if _, clash := combined[key]; clash {
+			return bson.M{"$and": bson.A{filter, scope}}
+		}
+		combined[key] = value
+	}
+	return combined
+}
+
+// stamped returns document with its workspace set
+func stamped(document interface{}, workspace string) (bson.D, error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	for i, element := range doc {
+		if element.Key == WorkspaceField {
+			doc[i].Value = workspace
+			return doc, nil
+		}
+	}
+	return append(doc, bson.E{Key: WorkspaceField, Value: workspace}), nil
+}
+
+func (c *workspaceCollection) InsertOne(ctx context.Context, document interface{}) (primitive.ObjectID, error) {
+	workspace := WorkspaceFrom(ctx)
+	if workspace == "" {
+		return c.Collection.InsertOne(ctx, document)
+	}
+	doc, err := stamped(document, workspace)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return c.Collection.InsertOne(ctx, doc)
+}
+
+func (c *workspaceCollection) InsertMany(ctx context.Context, documents []interface{}) ([]primitive.ObjectID, error) {
+	workspace := WorkspaceFrom(ctx)
+	if workspace == "" {
+		return c.Collection.InsertMany(ctx, documents)
+	}
+	docs := make([]interface{}, len(documents))
+	for i, document := range documents {
+		doc, err := stamped(document, workspace)
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = doc
+	}
+	return c.Collection.InsertMany(ctx, docs)
+}
+
+func (c *workspaceCollection) FindOne(ctx context.Context, filter bson.M, result interface{}) error {
+	if workspace := WorkspaceFrom(ctx); workspace != "" {
+		filter = scoped(filter, c.readScope(workspace))
+	}
+	return c.Collection.FindOne(ctx, filter, result)
+}
+
+func (c *workspaceCollection) Find(ctx context.Context, filter bson.M, results interface{}, opts *options.FindOptions) error {
+	if workspace := WorkspaceFrom(ctx); workspace != "" {
+		filter = scoped(filter, c.readScope(workspace))
+	}
+	return c.Collection.Find(ctx, filter, results, opts)
+}
+
+func (c *workspaceCollection) CountDocuments(ctx context.Context, filter bson.M) (int64, error) {
+	if workspace := WorkspaceFrom(ctx); workspace != "" {
+		filter = scoped(filter, c.readScope(workspace))
+	}
+	return c.Collection.CountDocuments(ctx, filter)
+}
+
+func (c *workspaceCollection) TextSearch(ctx context.Context, text string, filter bson.M, limit int64) ([]TextMatch, error) {
+	if workspace := WorkspaceFrom(ctx); workspace != "" {
+		filter = scoped(filter, c.readScope(workspace))
+	}
+	return c.Collection.TextSearch(ctx, text, filter, limit)
+}
+
+// UpdateOne only changes documents of the caller's workspace and never moves
+// them to another one
+func (c *workspaceCollection) UpdateOne(ctx context.Context, filter bson.M, update bson.M) (int64, error) {
+	workspace := WorkspaceFrom(ctx)
+	if workspace == "" {
+		return c.Collection.UpdateOne(ctx, filter, update)
+	}
+	return c.Collection.UpdateOne(ctx, scoped(filter, OwnedBy(workspace)), guarded(update))
+}
+
+// FindOneAndUpdate is scoped like UpdateOne
+func (c *workspaceCollection) FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, result interface{}) error {
+	workspace := WorkspaceFrom(ctx)
+	if workspace == "" {
+		return c.Collection.FindOneAndUpdate(ctx, filter, update, result)
+	}
+	return c.Collection.FindOneAndUpdate(ctx, scoped(filter, OwnedBy(workspace)), guarded(update), result)
+}
+
+// guarded returns update without any change to the workspace field
+func guarded(update bson.M) bson.M {
+	kept := make(bson.M, len(update))
+	for operator, value := range update {
+		if fields, ok := value.(bson.M); ok {
+			if _, touches := fields[WorkspaceField]; touches {
+				withoutWorkspace := make(bson.M, len(fields))
+				for field, fieldValue := range fields {
+					if field != WorkspaceField {
+						withoutWorkspace[field] = fieldValue
+					}
+				}
+				value = withoutWorkspace
+			}
+		}
+		kept[operator] = value
+	}
+	return kept
+}
+
+func (c *workspaceCollection) ReplaceOne(ctx context.Context, filter bson.M, replacement interface{}) (int64, error) {
+	workspace := WorkspaceFrom(ctx)
+	if workspace == "" {
+		return c.Collection.ReplaceOne(ctx, filter, replacement)
+	}
+	doc, err := stamped(replacement, workspace)
+	if err != nil {
+		return 0, err
+	}
+	return c.Collection.ReplaceOne(ctx, scoped(filter, OwnedBy(workspace)), doc)
+}
+
+func (c *workspaceCollection) DeleteOne(ctx context.Context, filter bson.M) (int64, error) {
+	if workspace := WorkspaceFrom(ctx); workspace != "" {
+		filter = scoped(filter, OwnedBy(workspace))
+	}
+	return c.Collection.DeleteOne(ctx, filter)
+}
+
+func (c *workspaceCollection) DeleteMany(ctx context.Context, filter bson.M) (int64, error) {
+	if workspace := WorkspaceFrom(ctx); workspace != "" {
+		filter = scoped(filter, OwnedBy(workspace))
+	}
+	return c.Collection.DeleteMany(ctx, filter)
+}
+
+// Drop only removes the caller's documents unless ctx covers all workspaces
+func (c *workspaceCollection) Drop(ctx context.Context) error {
+	workspace := WorkspaceFrom(ctx)
+	if workspace == "" {
+		return c.Collection.Drop(ctx)
+	}
+	_, err := c.Collection.DeleteMany(ctx, OwnedBy(workspace))
+	return err
+}
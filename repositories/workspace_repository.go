@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"grimdank-database/models"
+)
+
+// WorkspaceRepository stores the workspaces themselves. Its collection must
+// not be scoped to workspaces.
+type WorkspaceRepository struct {
+	*BaseRepository
+}
+
+func NewWorkspaceRepository(collection Collection) *WorkspaceRepository {
+	return &WorkspaceRepository{
+		BaseRepository: NewBaseRepository(collection),
+	}
+}
+
+// Indexes declares the indexes workspaces rely on
+func (r *WorkspaceRepository) Indexes() []IndexSpec {
+	return []IndexSpec{
+		{
+			Name:   "slug_unique",
+			Keys:   bson.D{{Key: "slug", Value: 1}},
+			Unique: true,
+		},
+		fieldIndex("members.actor"),
+	}
+}
+
+func (r *WorkspaceRepository) CreateWorkspace(ctx context.Context, workspace *models.Workspace) error {
+	now := time.Now()
+	workspace.CreatedAt = now
+	workspace.UpdatedAt = now
+
+	id, err := r.Create(ctx, workspace)
+	if err != nil {
+		return err
+	}
+	workspace.ID = id
+	return nil
+}
+
+func (r *WorkspaceRepository) GetWorkspaceBySlug(ctx context.Context, slug string) (*models.Workspace, error) {
+	var workspace models.Workspace
+	if err := r.Collection.FindOne(ctx, bson.M{"slug": slug}, &workspace); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("workspace not found")
+		}
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+// ListWorkspacesOf returns the workspaces actor is a member of, by slug
+func (r *WorkspaceRepository) ListWorkspacesOf(ctx context.Context, actor string) ([]models.Workspace, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "slug", Value: 1}})
+
+	workspaces := []models.Workspace{}
+	if err := r.Collection.Find(ctx, bson.M{"members.actor": actor}, &workspaces, opts); err != nil {
+		return nil, err
+	}
+	return workspaces, nil
+}
+
+// SetMembers replaces the members of a workspace
+func (r *WorkspaceRepository) SetMembers(ctx context.Context, slug string, members []models.WorkspaceMember) error {
+	matched, err := r.Collection.UpdateOne(ctx, bson.M{"slug": slug}, bson.M{
+		"$set": bson.M{"members": members, "updatedAt": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return fmt.Errorf("workspace not found")
+	}
+	return nil
+}
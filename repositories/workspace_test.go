@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestWorkspaceCollection(t *testing.T) {
+	store := NewMemoryStore()
+	factions := NewWorkspaceStore(store).Collection("factions")
+	alpha := WithWorkspace(context.Background(), "alpha")
+	beta := WithWorkspace(context.Background(), "beta")
+
+	factions.InsertOne(alpha, bson.M{"name": "Orks", "type": "Custom", "workspace": "beta"})
+	factions.InsertOne(beta, bson.M{"name": "Eldar", "type": "Custom"})
+	factions.InsertOne(context.Background(), bson.M{"name": "Marines", "type": "Official"})
+
+	names := func(ctx context.Context, filter bson.M) []string {
+		var docs []bson.M
+		if err := factions.Find(ctx, filter, &docs, options.Find().SetSort(bson.D{{Key: "name", Value: 1}})); err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		var names []string
+		for _, doc := range docs {
+			names = append(names, doc["name"].(string))
+		}
+		return names
+	}
+
+	if got := names(alpha, nil); len(got) != 2 || got[0] != "Marines" || got[1] != "Orks" {
+		t.Errorf("Expected alpha's faction and the official one, got %v", got)
+	}
+	// A filter on the workspace cannot widen the scope
+	if got := names(alpha, bson.M{"workspace": "beta"}); len(got) != 0 {
+		t.Errorf("Expected nothing of beta from alpha, got %v", got)
+	}
+	if got := names(AllWorkspaces(context.Background()), nil); len(got) != 3 {
+		t.Errorf("Expected every faction across workspaces, got %v", got)
+	}
+
+	if matched, _ := factions.UpdateOne(alpha, bson.M{"name": "Marines"}, bson.M{"$set": bson.M{"name": "Traitors"}}); matched != 0 {
+		t.Error("Expected the shared faction to be read-only")
+	}
+	if matched, _ := factions.UpdateOne(alpha, bson.M{"name": "Orks"}, bson.M{"$set": bson.M{"workspace": "beta", "description": "Waaagh"}}); matched != 1 {
+		t.Error("Expected alpha's faction to be updated")
+	}
+	if got := names(alpha, bson.M{"description": "Waaagh"}); len(got) != 1 {
+		t.Errorf("Expected the faction to stay in alpha, got %v", got)
+	}
+
+	if err := factions.Drop(alpha); err != nil {
+		t.Fatalf("Drop failed: %v", err)
+	}
+	if count, _ := store.Collection("factions").CountDocuments(context.Background(), bson.M{}); count != 2 {
+		t.Errorf("Expected dropping alpha to leave the other workspaces, got %d documents", count)
+	}
+}
+
+func TestWorkspaceCollectionUnmigratedDocuments(t *testing.T) {
+	store := NewMemoryStore()
+	rules := NewWorkspaceStore(store).Collection("rules")
+	defaultCtx := context.Background()
+	alpha := WithWorkspace(context.Background(), "alpha")
+
+	// Written before workspaces existed, so it has no workspace field
+	store.Collection("rules").InsertOne(defaultCtx, bson.M{"name": "Legacy"})
+
+	var found bson.M
+	if err := rules.FindOne(defaultCtx, bson.M{"name": "Legacy"}, &found); err != nil {
+		t.Fatalf("Expected the default workspace to read unmigrated documents: %v", err)
+	}
+	if err := rules.FindOne(alpha, bson.M{"name": "Legacy"}, &found); err == nil {
+		t.Error("Expected unmigrated documents to stay out of other workspaces")
+	}
+	if matched, _ := rules.UpdateOne(alpha, bson.M{"name": "Legacy"}, bson.M{"$set": bson.M{"points": 1}}); matched != 0 {
+		t.Error("Expected other workspaces not to change unmigrated documents")
+	}
+	if matched, _ := rules.UpdateOne(defaultCtx, bson.M{"name": "Legacy"}, bson.M{"$set": bson.M{"points": 1}}); matched != 1 {
+		t.Error("Expected the default workspace to change unmigrated documents")
+	}
+	if count, _ := rules.CountDocuments(defaultCtx, bson.M{"$or": bson.A{bson.M{"points": 1}, bson.M{"points": 2}}}); count != 1 {
+		t.Errorf("Expected a filter of its own $or to combine with the scope, got %d", count)
+	}
+	if deleted, _ := rules.DeleteOne(defaultCtx, bson.M{"name": "Legacy"}); deleted != 1 {
+		t.Error("Expected the default workspace to delete unmigrated documents")
+	}
+}
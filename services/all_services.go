@@ -12,37 +12,64 @@ import (
 
 // Weapon Service
 type WeaponService struct {
-	repo *repositories.WeaponRepository
+	repo       *repositories.WeaponRepository
+	references *ReferenceService
+	revisions  *RevisionService
+	cache      *Cache[models.Weapon]
 }
 
-func NewWeaponService(repo *repositories.WeaponRepository) *WeaponService {
+func NewWeaponService(repo *repositories.WeaponRepository, references *ReferenceService, revisions *RevisionService) *WeaponService {
 	return &WeaponService{
-		repo: repo,
+		repo:       repo,
+		references: references,
+		revisions:  revisions,
 	}
 }
 
-func (s *WeaponService) CreateWeapon(ctx context.Context, weapon *models.Weapon) (*models.Weapon, error) {
+// validateWeapon checks a weapon before it is written and normalizes its type
+func validateWeapon(weapon *models.Weapon) error {
 	if err := utils.ValidateName(weapon.Name); err != nil {
-		return nil, err
+		return err
 	}
 
 	// Validate weapon type (only "melee" or "ranged" allowed)
 	weaponType, err := utils.ValidateWeaponType(weapon.Type)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	weapon.Type = weaponType // Normalize to lowercase
+	return nil
+}
+
+func (s *WeaponService) CreateWeapon(ctx context.Context, weapon *models.Weapon) (*models.Weapon, error) {
+	if err := validateWeapon(weapon); err != nil {
+		return nil, err
+	}
 
-	id, err := s.repo.CreateWeapon(ctx, weapon)
+	err := s.revisions.TrackCreate(ctx, "weapons", func(ctx context.Context) (primitive.ObjectID, error) {
+		id, err := s.repo.CreateWeapon(ctx, weapon)
+		weapon.ID, _ = primitive.ObjectIDFromHex(id)
+		return weapon.ID, err
+	})
 	if err != nil {
 		return nil, err
 	}
-	weapon.ID, _ = primitive.ObjectIDFromHex(id)
 	return weapon, nil
 }
 
+// UseCache puts cache in front of GetWeaponByID
+func (s *WeaponService) UseCache(cache *Cache[models.Weapon]) {
+	s.cache = cache
+}
+
 func (s *WeaponService) GetWeaponByID(ctx context.Context, id string) (*models.Weapon, error) {
-	return s.repo.GetWeaponByID(ctx, id)
+	return s.cache.Get(ctx, id, func(ctx context.Context) (*models.Weapon, error) {
+		return s.repo.GetWeaponByID(ctx, id)
+	})
+}
+
+func (s *WeaponService) GetWeaponsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Weapon, error) {
+	return s.repo.GetWeaponsByIDs(ctx, ids)
 }
 
 func (s *WeaponService) GetAllWeapons(ctx context.Context, limit, skip int64) ([]models.Weapon, error) {
@@ -53,23 +80,51 @@ func (s *WeaponService) SearchWeaponsByName(ctx context.Context, name string, li
 	return s.repo.SearchWeaponsByName(ctx, name, limit, skip)
 }
 
+func (s *WeaponService) ListWeapons(ctx context.Context, name string, opts ListOptions) ([]models.Weapon, string, error) {
+	return s.repo.ListWeapons(ctx, name, opts)
+}
+
 func (s *WeaponService) UpdateWeapon(ctx context.Context, id string, weapon *models.Weapon) error {
-	if err := utils.ValidateName(weapon.Name); err != nil {
+	if err := validateWeapon(weapon); err != nil {
 		return err
 	}
 
-	// Validate weapon type (only "melee" or "ranged" allowed)
-	weaponType, err := utils.ValidateWeaponType(weapon.Type)
+	return s.revisions.Track(ctx, "weapons", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.UpdateWeapon(ctx, id, weapon)
+	})
+}
+
+// DeleteWeapon deletes a weapon, refusing if anything still references it
+func (s *WeaponService) DeleteWeapon(ctx context.Context, id string) error {
+	_, err := s.DeleteWeaponWithPolicy(ctx, id, DeletePolicyReject)
+	return err
+}
+
+// DeleteWeaponWithPolicy deletes a weapon and applies policy to anything that references it
+func (s *WeaponService) DeleteWeaponWithPolicy(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error) {
+	return s.references.Delete(ctx, "weapons", id, policy)
+}
+
+// AttachRule adds a rule reference to a weapon, refusing a rule it already has
+func (s *WeaponService) AttachRule(ctx context.Context, id string, ref models.RuleReference) error {
+	objectID, err := utils.ParseObjectID(id)
 	if err != nil {
 		return err
 	}
-	weapon.Type = weaponType // Normalize to lowercase
-
-	return s.repo.UpdateWeapon(ctx, id, weapon)
+	return s.revisions.Track(ctx, "weapons", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.AttachReference(ctx, objectID, ref.RuleID, repositories.RuleReferences, ref)
+	})
 }
 
-func (s *WeaponService) DeleteWeapon(ctx context.Context, id string) error {
-	return s.repo.DeleteWeapon(ctx, id)
+// DetachRule removes a rule reference from a weapon
+func (s *WeaponService) DetachRule(ctx context.Context, id string, ruleID primitive.ObjectID) error {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+	return s.revisions.Track(ctx, "weapons", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.DetachReference(ctx, objectID, ruleID, repositories.RuleReferences)
+	})
 }
 
 func (s *WeaponService) CountWeapons(ctx context.Context) (int64, error) {
@@ -80,6 +135,10 @@ func (s *WeaponService) CountWeaponsByName(ctx context.Context, name string) (in
 	return s.repo.CountWeaponsByName(ctx, name)
 }
 
+func (s *WeaponService) CountWeaponsMatching(ctx context.Context, name string, filter Filter) (int64, error) {
+	return s.repo.CountWeaponsMatching(ctx, name, filter)
+}
+
 func (s *WeaponService) BulkImportWeapons(ctx context.Context, weapons []models.Weapon) ([]string, error) {
 	// Validate all weapons before importing
 	for i, weapon := range weapons {
@@ -94,35 +153,63 @@ func (s *WeaponService) BulkImportWeapons(ctx context.Context, weapons []models.
 		weapons[i].Type = weaponType // Normalize to lowercase
 	}
 
-	return s.repo.BulkImportWeapons(ctx, weapons)
+	importedIDs, err := s.repo.BulkImportWeapons(ctx, weapons)
+	if recordErr := s.revisions.RecordImported(ctx, "weapons", importedIDs); recordErr != nil && err == nil {
+		return nil, recordErr
+	}
+	return importedIDs, err
 }
 
 // WarGear Service
 type WarGearService struct {
-	repo *repositories.WarGearRepository
+	repo       *repositories.WarGearRepository
+	references *ReferenceService
+	revisions  *RevisionService
+	cache      *Cache[models.WarGear]
 }
 
-func NewWarGearService(repo *repositories.WarGearRepository) *WarGearService {
+func NewWarGearService(repo *repositories.WarGearRepository, references *ReferenceService, revisions *RevisionService) *WarGearService {
 	return &WarGearService{
-		repo: repo,
+		repo:       repo,
+		references: references,
+		revisions:  revisions,
 	}
 }
 
+// validateWarGear checks a wargear item before it is written
+func validateWarGear(wargear *models.WarGear) error {
+	return utils.ValidateName(wargear.Name)
+}
+
 func (s *WarGearService) CreateWarGear(ctx context.Context, wargear *models.WarGear) (*models.WarGear, error) {
-	if err := utils.ValidateName(wargear.Name); err != nil {
+	if err := validateWarGear(wargear); err != nil {
 		return nil, err
 	}
 
-	id, err := s.repo.CreateWarGear(ctx, wargear)
+	err := s.revisions.TrackCreate(ctx, "wargear", func(ctx context.Context) (primitive.ObjectID, error) {
+		id, err := s.repo.CreateWarGear(ctx, wargear)
+		wargear.ID, _ = primitive.ObjectIDFromHex(id)
+		return wargear.ID, err
+	})
 	if err != nil {
 		return nil, err
 	}
-	wargear.ID, _ = primitive.ObjectIDFromHex(id)
 	return wargear, nil
 }
 
+// UseCache puts cache in front of GetWarGearByID
+func (s *WarGearService) UseCache(cache *Cache[models.WarGear]) {
+	s.cache = cache
+}
+
 func (s *WarGearService) GetWarGearByID(ctx context.Context, id string) (*models.WarGear, error) {
-	return s.repo.GetWarGearByID(ctx, id)
+	return s.cache.Get(ctx, id, func(ctx context.Context) (*models.WarGear, error) {
+		return s.repo.GetWarGearByID(ctx, id)
+	})
+}
+
+func (s *WarGearService) GetWarGearByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.WarGear, error) {
+	return s.repo.GetWarGearByIDs(ctx, ids)
 }
 
 func (s *WarGearService) GetAllWarGear(ctx context.Context, limit, skip int64) ([]models.WarGear, error) {
@@ -133,16 +220,51 @@ func (s *WarGearService) SearchWarGearByName(ctx context.Context, name string, l
 	return s.repo.SearchWarGearByName(ctx, name, limit, skip)
 }
 
+func (s *WarGearService) ListWarGear(ctx context.Context, name string, opts ListOptions) ([]models.WarGear, string, error) {
+	return s.repo.ListWarGear(ctx, name, opts)
+}
+
 func (s *WarGearService) UpdateWarGear(ctx context.Context, id string, wargear *models.WarGear) error {
-	if err := utils.ValidateName(wargear.Name); err != nil {
+	if err := validateWarGear(wargear); err != nil {
 		return err
 	}
 
-	return s.repo.UpdateWarGear(ctx, id, wargear)
+	return s.revisions.Track(ctx, "wargear", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.UpdateWarGear(ctx, id, wargear)
+	})
 }
 
+// DeleteWarGear deletes a wargear item, refusing if anything still references it
 func (s *WarGearService) DeleteWarGear(ctx context.Context, id string) error {
-	return s.repo.DeleteWarGear(ctx, id)
+	_, err := s.DeleteWarGearWithPolicy(ctx, id, DeletePolicyReject)
+	return err
+}
+
+// DeleteWarGearWithPolicy deletes a wargear item and applies policy to anything that references it
+func (s *WarGearService) DeleteWarGearWithPolicy(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error) {
+	return s.references.Delete(ctx, "wargear", id, policy)
+}
+
+// AttachRule adds a rule reference to a wargear item, refusing a rule it already has
+func (s *WarGearService) AttachRule(ctx context.Context, id string, ref models.RuleReference) error {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+	return s.revisions.Track(ctx, "wargear", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.AttachReference(ctx, objectID, ref.RuleID, repositories.RuleReferences, ref)
+	})
+}
+
+// DetachRule removes a rule reference from a wargear item
+func (s *WarGearService) DetachRule(ctx context.Context, id string, ruleID primitive.ObjectID) error {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+	return s.revisions.Track(ctx, "wargear", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.DetachReference(ctx, objectID, ruleID, repositories.RuleReferences)
+	})
 }
 
 func (s *WarGearService) BulkImportWarGear(ctx context.Context, wargear []models.WarGear) ([]string, error) {
@@ -153,30 +275,46 @@ func (s *WarGearService) BulkImportWarGear(ctx context.Context, wargear []models
 		}
 	}
 
-	return s.repo.BulkImportWarGear(ctx, wargear)
+	importedIDs, err := s.repo.BulkImportWarGear(ctx, wargear)
+	if recordErr := s.revisions.RecordImported(ctx, "wargear", importedIDs); recordErr != nil && err == nil {
+		return nil, recordErr
+	}
+	return importedIDs, err
 }
 
 // Unit Service
 type UnitService struct {
-	repo *repositories.UnitRepository
+	repo       *repositories.UnitRepository
+	references *ReferenceService
+	revisions  *RevisionService
 }
 
-func NewUnitService(repo *repositories.UnitRepository) *UnitService {
+func NewUnitService(repo *repositories.UnitRepository, references *ReferenceService, revisions *RevisionService) *UnitService {
 	return &UnitService{
-		repo: repo,
+		repo:       repo,
+		references: references,
+		revisions:  revisions,
 	}
 }
 
+// validateUnit checks a unit before it is written
+func validateUnit(unit *models.Unit) error {
+	return utils.ValidateName(unit.Name)
+}
+
 func (s *UnitService) CreateUnit(ctx context.Context, unit *models.Unit) (*models.Unit, error) {
-	if err := utils.ValidateName(unit.Name); err != nil {
+	if err := validateUnit(unit); err != nil {
 		return nil, err
 	}
 
-	id, err := s.repo.CreateUnit(ctx, unit)
+	err := s.revisions.TrackCreate(ctx, "units", func(ctx context.Context) (primitive.ObjectID, error) {
+		id, err := s.repo.CreateUnit(ctx, unit)
+		unit.ID, _ = primitive.ObjectIDFromHex(id)
+		return unit.ID, err
+	})
 	if err != nil {
 		return nil, err
 	}
-	unit.ID, _ = primitive.ObjectIDFromHex(id)
 	return unit, nil
 }
 
@@ -184,6 +322,10 @@ func (s *UnitService) GetUnitByID(ctx context.Context, id string) (*models.Unit,
 	return s.repo.GetUnitByID(ctx, id)
 }
 
+func (s *UnitService) GetUnitsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Unit, error) {
+	return s.repo.GetUnitsByIDs(ctx, ids)
+}
+
 func (s *UnitService) GetAllUnits(ctx context.Context, limit, skip int64) ([]models.Unit, error) {
 	return s.repo.GetAllUnits(ctx, limit, skip)
 }
@@ -192,16 +334,95 @@ func (s *UnitService) SearchUnitsByName(ctx context.Context, name string, limit,
 	return s.repo.SearchUnitsByName(ctx, name, limit, skip)
 }
 
+func (s *UnitService) ListUnits(ctx context.Context, name string, opts ListOptions) ([]models.Unit, string, error) {
+	return s.repo.ListUnits(ctx, name, opts)
+}
+
 func (s *UnitService) UpdateUnit(ctx context.Context, id string, unit *models.Unit) error {
-	if err := utils.ValidateName(unit.Name); err != nil {
+	if err := validateUnit(unit); err != nil {
 		return err
 	}
 
-	return s.repo.UpdateUnit(ctx, id, unit)
+	return s.revisions.Track(ctx, "units", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.UpdateUnit(ctx, id, unit)
+	})
 }
 
+// DeleteUnit deletes a unit, refusing if anything still references it
 func (s *UnitService) DeleteUnit(ctx context.Context, id string) error {
-	return s.repo.DeleteUnit(ctx, id)
+	_, err := s.DeleteUnitWithPolicy(ctx, id, DeletePolicyReject)
+	return err
+}
+
+// DeleteUnitWithPolicy deletes a unit and applies policy to anything that references it
+func (s *UnitService) DeleteUnitWithPolicy(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error) {
+	return s.references.Delete(ctx, "units", id, policy)
+}
+
+// AttachRule adds a rule reference to a unit, refusing a rule it already has
+func (s *UnitService) AttachRule(ctx context.Context, id string, ref models.RuleReference) error {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+	return s.revisions.Track(ctx, "units", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.AttachReference(ctx, objectID, ref.RuleID, repositories.RuleReferences, ref)
+	})
+}
+
+// DetachRule removes a rule reference from a unit
+func (s *UnitService) DetachRule(ctx context.Context, id string, ruleID primitive.ObjectID) error {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+	return s.revisions.Track(ctx, "units", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.DetachReference(ctx, objectID, ruleID, repositories.RuleReferences)
+	})
+}
+
+// AttachWeapon makes a weapon available to a unit
+func (s *UnitService) AttachWeapon(ctx context.Context, id string, weaponID primitive.ObjectID) error {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+	return s.revisions.Track(ctx, "units", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.AttachReference(ctx, objectID, weaponID, repositories.AvailableWeaponReferences, weaponID)
+	})
+}
+
+// DetachWeapon makes a weapon unavailable to a unit and unequips it
+func (s *UnitService) DetachWeapon(ctx context.Context, id string, weaponID primitive.ObjectID) error {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+	return s.revisions.Track(ctx, "units", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.DetachReference(ctx, objectID, weaponID, repositories.AvailableWeaponReferences, repositories.EquippedWeaponReferences)
+	})
+}
+
+// AttachWarGear makes a wargear item available to a unit
+func (s *UnitService) AttachWarGear(ctx context.Context, id string, wargearID primitive.ObjectID) error {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+	return s.revisions.Track(ctx, "units", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.AttachReference(ctx, objectID, wargearID, repositories.AvailableWarGearReferences, wargearID)
+	})
+}
+
+// DetachWarGear makes a wargear item unavailable to a unit and unequips it
+func (s *UnitService) DetachWarGear(ctx context.Context, id string, wargearID primitive.ObjectID) error {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+	return s.revisions.Track(ctx, "units", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.DetachReference(ctx, objectID, wargearID, repositories.AvailableWarGearReferences, repositories.EquippedWarGearReferences)
+	})
 }
 
 func (s *UnitService) BulkImportUnits(ctx context.Context, units []models.Unit) ([]string, error) {
@@ -212,30 +433,44 @@ func (s *UnitService) BulkImportUnits(ctx context.Context, units []models.Unit)
 		}
 	}
 
-	return s.repo.BulkImportUnits(ctx, units)
+	importedIDs, err := s.repo.BulkImportUnits(ctx, units)
+	if recordErr := s.revisions.RecordImported(ctx, "units", importedIDs); recordErr != nil && err == nil {
+		return nil, recordErr
+	}
+	return importedIDs, err
 }
 
 // ArmyBook Service
 type ArmyBookService struct {
-	repo *repositories.ArmyBookRepository
+	repo      *repositories.ArmyBookRepository
+	revisions *RevisionService
 }
 
-func NewArmyBookService(repo *repositories.ArmyBookRepository) *ArmyBookService {
+func NewArmyBookService(repo *repositories.ArmyBookRepository, revisions *RevisionService) *ArmyBookService {
 	return &ArmyBookService{
-		repo: repo,
+		repo:      repo,
+		revisions: revisions,
 	}
 }
 
+// validateArmyBook checks an army book before it is written
+func validateArmyBook(armyBook *models.ArmyBook) error {
+	return utils.ValidateName(armyBook.Name)
+}
+
 func (s *ArmyBookService) CreateArmyBook(ctx context.Context, armyBook *models.ArmyBook) (*models.ArmyBook, error) {
-	if err := utils.ValidateName(armyBook.Name); err != nil {
+	if err := validateArmyBook(armyBook); err != nil {
 		return nil, err
 	}
 
-	id, err := s.repo.CreateArmyBook(ctx, armyBook)
+	err := s.revisions.TrackCreate(ctx, "armybooks", func(ctx context.Context) (primitive.ObjectID, error) {
+		id, err := s.repo.CreateArmyBook(ctx, armyBook)
+		armyBook.ID, _ = primitive.ObjectIDFromHex(id)
+		return armyBook.ID, err
+	})
 	if err != nil {
 		return nil, err
 	}
-	armyBook.ID, _ = primitive.ObjectIDFromHex(id)
 	return armyBook, nil
 }
 
@@ -251,16 +486,24 @@ func (s *ArmyBookService) SearchArmyBooksByName(ctx context.Context, name string
 	return s.repo.SearchArmyBooksByName(ctx, name, limit, skip)
 }
 
+func (s *ArmyBookService) ListArmyBooks(ctx context.Context, name string, opts ListOptions) ([]models.ArmyBook, string, error) {
+	return s.repo.ListArmyBooks(ctx, name, opts)
+}
+
 func (s *ArmyBookService) UpdateArmyBook(ctx context.Context, id string, armyBook *models.ArmyBook) error {
-	if err := utils.ValidateName(armyBook.Name); err != nil {
+	if err := validateArmyBook(armyBook); err != nil {
 		return err
 	}
 
-	return s.repo.UpdateArmyBook(ctx, id, armyBook)
+	return s.revisions.Track(ctx, "armybooks", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.UpdateArmyBook(ctx, id, armyBook)
+	})
 }
 
 func (s *ArmyBookService) DeleteArmyBook(ctx context.Context, id string) error {
-	return s.repo.DeleteArmyBook(ctx, id)
+	return s.revisions.Track(ctx, "armybooks", id, RevisionDeleted, func(ctx context.Context) error {
+		return s.repo.DeleteArmyBook(ctx, id)
+	})
 }
 
 func (s *ArmyBookService) BulkImportArmyBooks(ctx context.Context, armyBooks []models.ArmyBook) ([]string, error) {
@@ -271,30 +514,47 @@ func (s *ArmyBookService) BulkImportArmyBooks(ctx context.Context, armyBooks []m
 		}
 	}
 
-	return s.repo.BulkImportArmyBooks(ctx, armyBooks)
+	importedIDs, err := s.repo.BulkImportArmyBooks(ctx, armyBooks)
+	if recordErr := s.revisions.RecordImported(ctx, "armybooks", importedIDs); recordErr != nil && err == nil {
+		return nil, recordErr
+	}
+	return importedIDs, err
 }
 
 // ArmyList Service
 type ArmyListService struct {
-	repo *repositories.ArmyListRepository
+	repo      *repositories.ArmyListRepository
+	revisions *RevisionService
 }
 
-func NewArmyListService(repo *repositories.ArmyListRepository) *ArmyListService {
+func NewArmyListService(repo *repositories.ArmyListRepository, revisions *RevisionService) *ArmyListService {
 	return &ArmyListService{
-		repo: repo,
+		repo:      repo,
+		revisions: revisions,
 	}
 }
 
+// validateArmyList checks an army list before it is written
+func validateArmyList(armyList *models.ArmyList) error {
+	if armyList.PointsLimit < 0 {
+		return utils.NewValidationError("pointsLimit", "points limit can't be negative")
+	}
+	return utils.ValidateName(armyList.Name)
+}
+
 func (s *ArmyListService) CreateArmyList(ctx context.Context, armyList *models.ArmyList) (*models.ArmyList, error) {
-	if err := utils.ValidateName(armyList.Name); err != nil {
+	if err := validateArmyList(armyList); err != nil {
 		return nil, err
 	}
 
-	id, err := s.repo.CreateArmyList(ctx, armyList)
+	err := s.revisions.TrackCreate(ctx, "armylists", func(ctx context.Context) (primitive.ObjectID, error) {
+		id, err := s.repo.CreateArmyList(ctx, armyList)
+		armyList.ID, _ = primitive.ObjectIDFromHex(id)
+		return armyList.ID, err
+	})
 	if err != nil {
 		return nil, err
 	}
-	armyList.ID, _ = primitive.ObjectIDFromHex(id)
 	return armyList, nil
 }
 
@@ -310,16 +570,24 @@ func (s *ArmyListService) SearchArmyListsByName(ctx context.Context, name string
 	return s.repo.SearchArmyListsByName(ctx, name, limit, skip)
 }
 
+func (s *ArmyListService) ListArmyLists(ctx context.Context, name string, opts ListOptions) ([]models.ArmyList, string, error) {
+	return s.repo.ListArmyLists(ctx, name, opts)
+}
+
 func (s *ArmyListService) UpdateArmyList(ctx context.Context, id string, armyList *models.ArmyList) error {
-	if err := utils.ValidateName(armyList.Name); err != nil {
+	if err := validateArmyList(armyList); err != nil {
 		return err
 	}
 
-	return s.repo.UpdateArmyList(ctx, id, armyList)
+	return s.revisions.Track(ctx, "armylists", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.UpdateArmyList(ctx, id, armyList)
+	})
 }
 
 func (s *ArmyListService) DeleteArmyList(ctx context.Context, id string) error {
-	return s.repo.DeleteArmyList(ctx, id)
+	return s.revisions.Track(ctx, "armylists", id, RevisionDeleted, func(ctx context.Context) error {
+		return s.repo.DeleteArmyList(ctx, id)
+	})
 }
 
 func (s *ArmyListService) BulkImportArmyLists(ctx context.Context, armyLists []models.ArmyList) ([]string, error) {
@@ -330,5 +598,9 @@ func (s *ArmyListService) BulkImportArmyLists(ctx context.Context, armyLists []m
 		}
 	}
 
-	return s.repo.BulkImportArmyLists(ctx, armyLists)
+	importedIDs, err := s.repo.BulkImportArmyLists(ctx, armyLists)
+	if recordErr := s.revisions.RecordImported(ctx, "armylists", importedIDs); recordErr != nil && err == nil {
+		return nil, recordErr
+	}
+	return importedIDs, err
 }
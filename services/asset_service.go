@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // registers GIF with image.DecodeConfig
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+)
+
+// ErrAssetTooLarge is returned for uploads over the size limit
+var ErrAssetTooLarge = errors.New("asset is too large")
+
+// assetContentTypes are the content types accepted for upload. Thumbnails are
+// only made for the ones marked true.
+var assetContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  false,
+}
+
+// assetReferences are the collections whose documents list asset IDs in assetIds
+var assetReferences = []string{"units", "wargear", "factions"}
+
+// AssetService stores uploaded images and their thumbnails
+type AssetService struct {
+	repo    *repositories.AssetRepository
+	blobs   repositories.BlobStore
+	maxSize int64
+	// referencing holds the repositories of assetReferences by collection
+	referencing map[string]*repositories.BaseRepository
+}
+
+func NewAssetService(
+	repo *repositories.AssetRepository,
+	blobs repositories.BlobStore,
+	unitRepo *repositories.UnitRepository,
+	wargearRepo *repositories.WarGearRepository,
+	factionRepo *repositories.FactionRepository,
+	maxSize int64,
+) *AssetService {
+	return &AssetService{
+		repo:    repo,
+		blobs:   blobs,
+		maxSize: maxSize,
+		referencing: map[string]*repositories.BaseRepository{
+			"units":    unitRepo.BaseRepository,
+			"wargear":  wargearRepo.BaseRepository,
+			"factions": factionRepo.BaseRepository,
+		},
+	}
+}
+
+// MaxSize is the largest upload accepted, in bytes
+func (s *AssetService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// UploadAsset validates and stores an image. The content type is taken from
+// the bytes themselves; a declared type that disagrees with them is refused.
+// PNG and JPEG images get a thumbnail.
+func (s *AssetService) UploadAsset(ctx context.Context, filename, declaredType string, content io.Reader) (*models.Asset, error) {
+	data, err := io.ReadAll(io.LimitReader(content, s.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	if int64(len(data)) > s.maxSize {
+		return nil, fmt.Errorf("%w: the limit is %d bytes", ErrAssetTooLarge, s.maxSize)
+	}
+	if len(data) == 0 {
+		return nil, utils.NewValidationError("file", "file is empty")
+	}
+
+	contentType := http.DetectContentType(data)
+	thumbnailable, ok := assetContentTypes[contentType]
+	if !ok {
+		return nil, utils.NewValidationError("file", fmt.Sprintf("unsupported content type %s; upload a PNG, JPEG or GIF image", contentType))
+	}
+	if declared := mediaType(declaredType); declared != "" && declared != "application/octet-stream" && declared != contentType {
+		return nil, utils.NewValidationError("file", fmt.Sprintf("declared content type %s does not match the file, which is %s", declared, contentType))
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, utils.NewValidationError("file", fmt.Sprintf("not a valid image: %v", err))
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, utils.NewValidationError("file", fmt.Sprintf("image is %dx%d; at most %d pixels are accepted", config.Width, config.Height, maxImagePixels))
+	}
+
+	asset := &models.Asset{
+		ID:          primitive.NewObjectID(),
+		Filename:    filepath.Base(filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       config.Width,
+		Height:      config.Height,
+	}
+
+	// Write the blobs before the metadata, so a listed asset always has them
+	if err := s.blobs.Put(ctx, asset.ID, asset.Filename, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to store asset: %w", err)
+	}
+	if thumbnailable {
+		thumbnail, size, err := makeThumbnail(data, contentType)
+		if err != nil {
+			s.deleteBlobs(ctx, asset)
+			return nil, utils.NewValidationError("file", fmt.Sprintf("not a valid image: %v", err))
+		}
+		thumbnailID := primitive.NewObjectID()
+		if err := s.blobs.Put(ctx, thumbnailID, "thumbnail-"+asset.Filename, bytes.NewReader(thumbnail)); err != nil {
+			s.deleteBlobs(ctx, asset)
+			return nil, fmt.Errorf("failed to store thumbnail: %w", err)
+		}
+		asset.Thumbnail = &models.Thumbnail{ID: thumbnailID, Size: int64(len(thumbnail)), Width: size.X, Height: size.Y}
+	}
+
+	if err := s.repo.CreateAsset(ctx, asset); err != nil {
+		s.deleteBlobs(ctx, asset)
+		return nil, fmt.Errorf("failed to create asset: %w", err)
+	}
+	return asset, nil
+}
+
+// mediaType strips parameters such as charset from a content type
+func mediaType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+func (s *AssetService) GetAsset(ctx context.Context, id string) (*models.Asset, error) {
+	asset, err := s.repo.GetAssetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("asset not found: %w", err)
+	}
+	return asset, nil
+}
+
+// ReadAsset returns an asset and its bytes, or those of its thumbnail
+func (s *AssetService) ReadAsset(ctx context.Context, id string, thumbnail bool) (*models.Asset, []byte, error) {
+	asset, err := s.GetAsset(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blobID := asset.ID
+	if thumbnail {
+		if asset.Thumbnail == nil {
+			return nil, nil, fmt.Errorf("thumbnail not found: only PNG and JPEG assets have one")
+		}
+		blobID = asset.Thumbnail.ID
+	}
+
+	blob, err := s.blobs.Open(ctx, blobID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open asset: %w", err)
+	}
+	defer blob.Close()
+
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read asset: %w", err)
+	}
+	return asset, data, nil
+}
+
+// DeleteAsset deletes an asset and its thumbnail, refusing while units,
+// wargear or factions still use it
+func (s *AssetService) DeleteAsset(ctx context.Context, id string) error {
+	asset, err := s.GetAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	references := []Reference{}
+	for _, collection := range assetReferences {
+		documents, err := s.referencing[collection].FindReferencing(ctx, "assetIds", asset.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check %s for references: %w", collection, err)
+		}
+		for _, document := range documents {
+			references = append(references, Reference{Collection: collection, ID: document.ID.Hex(), Name: document.Name, Field: "assetIds"})
+		}
+	}
+	if len(references) > 0 {
+		return ReferencedError{Collection: "assets", ID: asset.ID.Hex(), References: references}
+	}
+
+	// Remove the metadata first; a blob left behind by a failure is unreachable
+	if err := s.repo.DeleteAsset(ctx, asset.ID); err != nil {
+		return fmt.Errorf("failed to delete asset: %w", err)
+	}
+	s.deleteBlobs(ctx, asset)
+	return nil
+}
+
+// deleteBlobs removes an asset's bytes and thumbnail. Failures only leave
+// unreachable blobs behind, so they are logged rather than returned.
+func (s *AssetService) deleteBlobs(ctx context.Context, asset *models.Asset) {
+	ids := []primitive.ObjectID{asset.ID}
+	if asset.Thumbnail != nil {
+		ids = append(ids, asset.Thumbnail.ID)
+	}
+	for _, id := range ids {
+		if err := s.blobs.Delete(ctx, id); err != nil && !errors.Is(err, repositories.ErrBlobNotFound) {
+			log.Printf("Error deleting blob %s of asset %s: %v", id.Hex(), asset.ID.Hex(), err)
+		}
+	}
+}
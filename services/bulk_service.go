@@ -0,0 +1,483 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MaxBulkDocuments is the most documents one bulk operation may touch
+const MaxBulkDocuments = 500
+
+// ErrBulkPlanChanged is returned when a bulk operation is confirmed but the
+// documents its filter matches have changed since the dry run
+var ErrBulkPlanChanged = errors.New("the documents matched by the filter have changed since the dry run; run it again")
+
+// bulkProtectedFields are owned by the repositories and cannot be set in bulk
+var bulkProtectedFields = map[string]bool{
+	"id":        true,
+	"version":   true,
+	"createdAt": true,
+	"updatedAt": true,
+	"deletedAt": true,
+	"workspace": true,
+}
+
+// BulkStatus is what happened, or would happen on a dry run, to one document
+type BulkStatus string
+
+const (
+	BulkChanged   BulkStatus = "changed"   // updated or deleted
+	BulkUnchanged BulkStatus = "unchanged" // already looks like the update
+	BulkFailed    BulkStatus = "failed"    // rejected by validation, a reference check or a concurrent edit
+)
+
+// BulkResult reports one matched document
+type BulkResult struct {
+	ID         string        `json:"id"`
+	Name       string        `json:"name"`
+	Version    int           `json:"version"` // after the operation, or the current one on a dry run
+	Status     BulkStatus    `json:"status"`
+	Changes    []FieldChange `json:"changes,omitempty"`
+	Delete     *DeleteReport `json:"delete,omitempty"`
+	References []Reference   `json:"references,omitempty"` // what blocked a rejected delete
+	Error      string        `json:"error,omitempty"`
+}
+
+// BulkReport is the outcome of a bulk update or delete. A dry run carries a
+// confirmation that applies exactly what it reported when sent back.
+type BulkReport struct {
+	EntityType   string       `json:"entityType"`
+	DryRun       bool         `json:"dryRun"`
+	Confirmation string       `json:"confirmation,omitempty"`
+	Matched      int          `json:"matched"`
+	Changed      int          `json:"changed"`
+	Unchanged    int          `json:"unchanged"`
+	Failed       int          `json:"failed"`
+	Results      []BulkResult `json:"results"`
+}
+
+func (r *BulkReport) add(result BulkResult) {
+	switch result.Status {
+	case BulkChanged:
+		r.Changed++
+	case BulkUnchanged:
+		r.Unchanged++
+	case BulkFailed:
+		r.Failed++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// bulkDocument is a matched document in its JSON form, the form clients send updates in
+type bulkDocument struct {
+	id      string
+	name    string
+	version int
+	fields  map[string]interface{}
+}
+
+// bulkCollection is what the bulk operations need from one entity type
+type bulkCollection interface {
+	match(ctx context.Context, filter Filter) ([]bulkDocument, error)
+	checkUpdate(set map[string]interface{}) error
+	update(ctx context.Context, doc bulkDocument, set map[string]interface{}, apply bool) (BulkResult, error)
+	delete(ctx context.Context, doc bulkDocument, policy DeletePolicy, apply bool) (BulkResult, error)
+}
+
+// bulkEntity implements bulkCollection on top of an entity's repository and
+// service, so bulk writes get the same validation, revisions and events as
+// single ones
+type bulkEntity[T any] struct {
+	repo          *repositories.BaseRepository
+	validate      func(entity *T) error
+	save          func(ctx context.Context, id string, entity *T) error
+	remove        func(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error)
+	previewRemove func(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error)
+}
+
+func (e bulkEntity[T]) match(ctx context.Context, filter Filter) ([]bulkDocument, error) {
+	var entities []T
+	if err := e.repo.FindMatching(ctx, filter, MaxBulkDocuments+1, &entities); err != nil {
+		return nil, err
+	}
+	if len(entities) > MaxBulkDocuments {
+		return nil, utils.NewValidationError("filter", fmt.Sprintf("matches more than %d documents; narrow it down", MaxBulkDocuments))
+	}
+
+	docs := make([]bulkDocument, len(entities))
+	for i := range entities {
+		fields, err := jsonFields(&entities[i])
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = newBulkDocument(fields)
+	}
+	return docs, nil
+}
+
+// checkUpdate rejects updates that set fields the entity does not have, fields
+// the repository owns or values of the wrong type, before anything is matched
+func (e bulkEntity[T]) checkUpdate(set map[string]interface{}) error {
+	if len(set) == 0 {
+		return utils.NewValidationError("set", "no fields to update")
+	}
+
+	known, err := jsonFields(new(T))
+	if err != nil {
+		return err
+	}
+	for field := range set {
+		if bulkProtectedFields[field] {
+			return utils.NewValidationError("set", fmt.Sprintf("%q is managed by the server and cannot be set", field))
+		}
+		if _, ok := known[field]; !ok {
+			return utils.NewValidationError("set", fmt.Sprintf("unknown field %q", field))
+		}
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, new(T)); err != nil {
+		return utils.NewValidationError("set", err.Error())
+	}
+	return nil
+}
+
+func (e bulkEntity[T]) update(ctx context.Context, doc bulkDocument, set map[string]interface{}, apply bool) (BulkResult, error) {
+	result := doc.result()
+
+	merged := make(map[string]interface{}, len(doc.fields)+len(set))
+	for field, value := range doc.fields {
+		merged[field] = value
+	}
+	for field, value := range set {
+		merged[field] = value
+	}
+
+	var entity T
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(data, &entity); err != nil {
+		return result, err
+	}
+	if err := e.validate(&entity); err != nil {
+		return result.failed(err), nil
+	}
+
+	// Compare after validation, which may normalize values
+	after, err := jsonFields(&entity)
+	if err != nil {
+		return result, err
+	}
+	result.Changes = diffDocuments(bson.M(doc.fields), bson.M(after))
+	if len(result.Changes) == 0 {
+		result.Status = BulkUnchanged
+		return result, nil
+	}
+	if !apply {
+		return result, nil
+	}
+
+	// The entity still carries the version it was matched at, so a concurrent edit is a conflict
+	if err := e.save(ctx, doc.id, &entity); err != nil {
+		return result.failed(err), nil
+	}
+	saved, err := jsonFields(&entity)
+	if err != nil {
+		return result, err
+	}
+	result.Version = newBulkDocument(saved).version
+	return result, nil
+}
+
+func (e bulkEntity[T]) delete(ctx context.Context, doc bulkDocument, policy DeletePolicy, apply bool) (BulkResult, error) {
+	result := doc.result()
+
+	remove := e.previewRemove
+	if apply {
+		remove = e.remove
+	}
+	report, err := remove(ctx, doc.id, policy)
+	if err != nil {
+		var referenced ReferencedError
+		if errors.As(err, &referenced) {
+			result.References = referenced.References
+		}
+		return result.failed(err), nil
+	}
+	result.Delete = report
+	if apply {
+		result.Version++
+	}
+	return result, nil
+}
+
+// unreferencedDelete adapts the delete of an entity type nothing references
+// to the policy-aware signature; the policy has nothing to act on
+func unreferencedDelete(remove func(ctx context.Context, id string) error) (
+	func(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error),
+	func(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error),
+) {
+	apply := func(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error) {
+		if err := remove(ctx, id); err != nil {
+			return nil, err
+		}
+		return &DeleteReport{Policy: policy}, nil
+	}
+	preview := func(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error) {
+		return &DeleteReport{Policy: policy}, nil
+	}
+	return apply, preview
+}
+
+func newBulkDocument(fields map[string]interface{}) bulkDocument {
+	doc := bulkDocument{fields: fields}
+	doc.id, _ = fields["id"].(string)
+	doc.name, _ = fields["name"].(string)
+	if version, ok := fields["version"].(float64); ok {
+		doc.version = int(version)
+	}
+	return doc
+}
+
+// result starts the report of a document as a change to it
+func (d bulkDocument) result() BulkResult {
+	return BulkResult{ID: d.id, Name: d.name, Version: d.version, Status: BulkChanged}
+}
+
+func (r BulkResult) failed(err error) BulkResult {
+	r.Status = BulkFailed
+	r.Error = err.Error()
+	return r
+}
+
+// jsonFields converts an entity to the field map its JSON encoding has
+func jsonFields(entity interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// BulkService updates and deletes every entity of a type that matches a
+// filter. Each operation is a dry run unless it is confirmed with the
+// confirmation of a dry run whose matched documents have not changed since.
+type BulkService struct {
+	collections map[string]bulkCollection
+}
+
+func NewBulkService(
+	ruleService *RuleService,
+	weaponService *WeaponService,
+	wargearService *WarGearService,
+	unitService *UnitService,
+	armyBookService *ArmyBookService,
+	armyListService *ArmyListService,
+	factionService *FactionService,
+	references *ReferenceService,
+) *BulkService {
+	previewRemove := func(collection string) func(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error) {
+		return func(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error) {
+			return references.PreviewDelete(ctx, collection, id, policy)
+		}
+	}
+	removeArmyBook, previewArmyBook := unreferencedDelete(armyBookService.DeleteArmyBook)
+	removeArmyList, previewArmyList := unreferencedDelete(armyListService.DeleteArmyList)
+	removeFaction, previewFaction := unreferencedDelete(factionService.DeleteFaction)
+
+	return &BulkService{
+		collections: map[string]bulkCollection{
+			"rules": bulkEntity[models.Rule]{
+				repo:          ruleService.repo.BaseRepository,
+				validate:      validateRule,
+				save:          ruleService.UpdateRule,
+				remove:        ruleService.DeleteRuleWithPolicy,
+				previewRemove: previewRemove("rules"),
+			},
+			"weapons": bulkEntity[models.Weapon]{
+				repo:          weaponService.repo.BaseRepository,
+				validate:      validateWeapon,
+				save:          weaponService.UpdateWeapon,
+				remove:        weaponService.DeleteWeaponWithPolicy,
+				previewRemove: previewRemove("weapons"),
+			},
+			"wargear": bulkEntity[models.WarGear]{
+				repo:          wargearService.repo.BaseRepository,
+				validate:      validateWarGear,
+				save:          wargearService.UpdateWarGear,
+				remove:        wargearService.DeleteWarGearWithPolicy,
+				previewRemove: previewRemove("wargear"),
+			},
+			"units": bulkEntity[models.Unit]{
+				repo:          unitService.repo.BaseRepository,
+				validate:      validateUnit,
+				save:          unitService.UpdateUnit,
+				remove:        unitService.DeleteUnitWithPolicy,
+				previewRemove: previewRemove("units"),
+			},
+			"armybooks": bulkEntity[models.ArmyBook]{
+				repo:          armyBookService.repo.BaseRepository,
+				validate:      validateArmyBook,
+				save:          armyBookService.UpdateArmyBook,
+				remove:        removeArmyBook,
+				previewRemove: previewArmyBook,
+			},
+			"armylists": bulkEntity[models.ArmyList]{
+				repo:          armyListService.repo.BaseRepository,
+				validate:      validateArmyList,
+				save:          armyListService.UpdateArmyList,
+				remove:        removeArmyList,
+				previewRemove: previewArmyList,
+			},
+			"factions": bulkEntity[models.Faction]{
+				repo:          factionService.repo.BaseRepository,
+				validate:      validateFaction,
+				save:          factionService.UpdateFaction,
+				remove:        removeFaction,
+				previewRemove: previewFaction,
+			},
+		},
+	}
+}
+
+// EntityTypes returns the entity types that support bulk operations, in alphabetical order
+func (s *BulkService) EntityTypes() []string {
+	types := make([]string, 0, len(s.collections))
+	for entityType := range s.collections {
+		types = append(types, entityType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Update sets the given fields on every entity that matches filter. set uses
+// the entities' JSON field names. Without confirm it is a dry run that reports
+// the changes each entity would get.
+func (s *BulkService) Update(ctx context.Context, entityType string, filter Filter, set map[string]interface{}, confirm string) (*BulkReport, error) {
+	collection, err := s.collectionFor(entityType, filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := collection.checkUpdate(set); err != nil {
+		return nil, err
+	}
+
+	return s.run(ctx, entityType, collection, filter, confirm, set, func(ctx context.Context, doc bulkDocument, apply bool) (BulkResult, error) {
+		return collection.update(ctx, doc, set, apply)
+	})
+}
+
+// Delete moves every entity that matches filter to the trash, applying policy
+// to whatever references them. Without confirm it is a dry run that reports
+// what each delete would do.
+func (s *BulkService) Delete(ctx context.Context, entityType string, filter Filter, policy DeletePolicy, confirm string) (*BulkReport, error) {
+	collection, err := s.collectionFor(entityType, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.run(ctx, entityType, collection, filter, confirm, policy, func(ctx context.Context, doc bulkDocument, apply bool) (BulkResult, error) {
+		return collection.delete(ctx, doc, policy, apply)
+	})
+}
+
+// run matches the documents and either plans the operation on each of them
+// or, when confirm matches the plan, applies it to each of them in turn. A
+// document that fails is reported and does not stop the others.
+func (s *BulkService) run(
+	ctx context.Context,
+	entityType string,
+	collection bulkCollection,
+	filter Filter,
+	confirm string,
+	operation interface{},
+	each func(ctx context.Context, doc bulkDocument, apply bool) (BulkResult, error),
+) (*BulkReport, error) {
+	docs, err := collection.match(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmation, err := bulkConfirmation(entityType, filter, operation, docs)
+	if err != nil {
+		return nil, err
+	}
+	apply := confirm != ""
+	if apply && confirm != confirmation {
+		return nil, ErrBulkPlanChanged
+	}
+
+	if apply {
+		change := changeFrom(ctx)
+		if change.Reason == "" {
+			ctx = WithChange(ctx, change.Actor, "bulk change of "+entityType)
+		}
+	}
+
+	report := &BulkReport{EntityType: entityType, DryRun: !apply, Matched: len(docs), Results: []BulkResult{}}
+	if !apply {
+		report.Confirmation = confirmation
+	}
+	for _, doc := range docs {
+		result, err := each(ctx, doc, apply)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", entityType, doc.id, err)
+		}
+		report.add(result)
+	}
+	return report, nil
+}
+
+func (s *BulkService) collectionFor(entityType string, filter Filter) (bulkCollection, error) {
+	collection, ok := s.collections[entityType]
+	if !ok {
+		return nil, utils.NewValidationError("type", fmt.Sprintf("unknown entity type %q, use one of %s", entityType, strings.Join(s.EntityTypes(), ", ")))
+	}
+	// An empty filter matches everything, which is never what a bulk change means to do
+	if len(filter) == 0 {
+		return nil, utils.NewValidationError("filter", "a bulk operation needs at least one filter condition")
+	}
+	return collection, nil
+}
+
+// bulkConfirmation fingerprints an operation together with the version of
+// every document it matched, so confirming it applies exactly what was
+// reported
+func bulkConfirmation(entityType string, filter Filter, operation interface{}, docs []bulkDocument) (string, error) {
+	matched := make([]string, len(docs))
+	for i, doc := range docs {
+		matched[i] = fmt.Sprintf("%s@%d", doc.id, doc.version)
+	}
+	data, err := json.Marshal(struct {
+		EntityType string
+		Filter     Filter
+		Operation  interface{}
+		Matched    []string
+	}{entityType, filter, operation, matched})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"grimdank-database/events"
+	"grimdank-database/models"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheStats counts how a cache has been used since it was created
+type CacheStats struct {
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	Evictions     int64   `json:"evictions"`     // entries dropped to stay within the size limit
+	Invalidations int64   `json:"invalidations"` // entries dropped because the entity changed
+	Entries       int     `json:"entries"`
+	HitRate       float64 `json:"hitRate"`
+}
+
+// cacheEntry is one cached entity and the workspace it was read in
+type cacheEntry[T any] struct {
+	id        string
+	workspace string
+	value     T
+	expires   time.Time
+}
+
+// Cache is a read-through cache of entities by ID. Entries expire after a
+// TTL, and the least recently used ones are dropped beyond a size limit.
+// Concurrent misses for the same entity share a single load. A nil Cache, or
+// one with a TTL of zero, loads every time.
+type Cache[T any] struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	// generation is bumped by every invalidation, so a load that started
+	// before it does not put the old entity back
+	generation uint64
+	stats      CacheStats
+
+	loads singleflight.Group
+}
+
+func NewCache[T any](ttl time.Duration, size int) *Cache[T] {
+	return &Cache[T]{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns the entity with the given ID, calling load on a miss. Errors
+// are not cached. Each caller gets its own copy of the entity, but slices in
+// it are shared with the cache and must not be modified.
+func (c *Cache[T]) Get(ctx context.Context, id string, load func(ctx context.Context) (*T, error)) (*T, error) {
+	if c == nil || c.ttl <= 0 {
+		return load(ctx)
+	}
+
+	workspace := WorkspaceFrom(ctx)
+	value, generation, hit := c.lookup(id, workspace)
+	if hit {
+		return &value, nil
+	}
+
+	// Callers that miss together wait for the first one's load, which must
+	// not fail them all if the first caller goes away. It keeps the values
+	// of ctx, the workspace among them.
+	result, err, _ := c.loads.Do(workspace+"/"+id, func() (interface{}, error) {
+		loaded, err := load(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		c.store(id, workspace, *loaded, generation)
+		return loaded, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	value = *result.(*T)
+	return &value, nil
+}
+
+// lookup returns the cached entity, or the generation a load must start from
+func (c *Cache[T]) lookup(id, workspace string) (T, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[id]; ok {
+		entry := element.Value.(*cacheEntry[T])
+		switch {
+		case time.Now().After(entry.expires):
+			c.remove(element)
+		case workspace == "" || entry.workspace == workspace:
+			c.order.MoveToFront(element)
+			c.stats.Hits++
+			return entry.value, c.generation, true
+		}
+	}
+
+	c.stats.Misses++
+	var zero T
+	return zero, c.generation, false
+}
+
+// store caches an entity loaded since generation, unless it was invalidated meanwhile
+func (c *Cache[T]) store(id, workspace string, value T, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if element, ok := c.entries[id]; ok {
+		c.remove(element)
+	}
+
+	c.entries[id] = c.order.PushFront(&cacheEntry[T]{
+		id:        id,
+		workspace: workspace,
+		value:     value,
+		expires:   time.Now().Add(c.ttl),
+	})
+	for c.size > 0 && c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops an entry; the caller holds the lock
+func (c *Cache[T]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry[T]).id)
+}
+
+// Invalidate drops the entity with the given ID in every workspace
+func (c *Cache[T]) Invalidate(id string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if element, ok := c.entries[id]; ok {
+		c.remove(element)
+		c.stats.Invalidations++
+	}
+}
+
+// Flush drops every entry
+func (c *Cache[T]) Flush() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.stats.Invalidations += int64(c.order.Len())
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Stats returns the cache's counters
+func (c *Cache[T]) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// Caches are the caches in front of the rule, weapon and wargear lookups,
+// which points calculations make over and over for data that rarely changes
+type Caches struct {
+	Rules   *Cache[models.Rule]
+	Weapons *Cache[models.Weapon]
+	WarGear *Cache[models.WarGear]
+}
+
+// NewCaches creates the caches with the same TTL and size limit per entity
+// type. A TTL of zero turns caching off.
+func NewCaches(ttl time.Duration, size int) *Caches {
+	return &Caches{
+		Rules:   NewCache[models.Rule](ttl, size),
+		Weapons: NewCache[models.Weapon](ttl, size),
+		WarGear: NewCache[models.WarGear](ttl, size),
+	}
+}
+
+// Subscribe invalidates cached entities when the services change them. The
+// subscribers run before the write returns, so a client never reads back
+// what it has just changed from the cache.
+func (c *Caches) Subscribe(bus *events.Bus) {
+	bus.SubscribeAll("entity-cache", func(ctx context.Context, event events.Event) error {
+		change := event.Change()
+		c.Invalidate(change.EntityType, change.EntityID.Hex())
+		return nil
+	})
+}
+
+// Invalidate drops an entity of the given type, for changes that are not
+// announced on the bus such as purges from the trash
+func (c *Caches) Invalidate(entityType, id string) {
+	if c == nil {
+		return
+	}
+	switch entityType {
+	case "rules":
+		c.Rules.Invalidate(id)
+	case "weapons":
+		c.Weapons.Invalidate(id)
+	case "wargear":
+		c.WarGear.Invalidate(id)
+	}
+}
+
+// Flush empties every cache, for changes made without the services such as
+// snapshot restores and schema migrations
+func (c *Caches) Flush() {
+	if c == nil {
+		return
+	}
+	c.Rules.Flush()
+	c.Weapons.Flush()
+	c.WarGear.Flush()
+}
+
+// Stats returns the counters of each cache by entity type
+func (c *Caches) Stats() map[string]CacheStats {
+	return map[string]CacheStats{
+		"rules":   c.Rules.Stats(),
+		"weapons": c.Weapons.Stats(),
+		"wargear": c.WarGear.Stats(),
+	}
+}
@@ -8,24 +8,68 @@ import (
 
 	"grimdank-database/models"
 	"grimdank-database/repositories"
+	"grimdank-database/utils"
 )
 
 type FactionService struct {
-	repo *repositories.FactionRepository
+	repo      *repositories.FactionRepository
+	revisions *RevisionService
 }
 
-func NewFactionService(repo *repositories.FactionRepository) *FactionService {
+func NewFactionService(repo *repositories.FactionRepository, revisions *RevisionService) *FactionService {
 	return &FactionService{
-		repo: repo,
+		repo:      repo,
+		revisions: revisions,
 	}
 }
 
-func (s *FactionService) CreateFaction(ctx context.Context, faction *models.Faction) (*models.Faction, error) {
+// validateFaction checks a faction before it is written
+func validateFaction(faction *models.Faction) error {
 	if faction.Name == "" {
-		return nil, fmt.Errorf("faction name is required")
+		return fmt.Errorf("faction name is required")
+	}
+	return nil
+}
+
+// checkPublishable refuses official factions outside the default workspace.
+// Official factions are shared with every workspace, so only the default
+// workspace may publish them.
+func checkPublishable(ctx context.Context, faction *models.Faction) error {
+	workspace := WorkspaceFrom(ctx)
+	if faction.Type == models.FactionTypeOfficial && workspace != "" && workspace != DefaultWorkspace {
+		return utils.NewForbiddenError("only the default workspace can publish official factions")
+	}
+	return nil
+}
+
+// checkOwned refuses changes to a faction of another workspace, which the
+// caller can only see because it is official
+func (s *FactionService) checkOwned(ctx context.Context, id string) error {
+	workspace := WorkspaceFrom(ctx)
+	existing, err := s.repo.GetFactionByID(ctx, id)
+	if err != nil || workspace == "" {
+		// Missing factions are reported by the write itself
+		return nil
+	}
+	owner := repositories.WorkspaceOf(existing.Workspace)
+	if owner == workspace {
+		return nil
+	}
+	return utils.NewForbiddenError(fmt.Sprintf("official faction %s is read-only outside the %s workspace", existing.Name, owner))
+}
+
+func (s *FactionService) CreateFaction(ctx context.Context, faction *models.Faction) (*models.Faction, error) {
+	if err := validateFaction(faction); err != nil {
+		return nil, err
+	}
+	if err := checkPublishable(ctx, faction); err != nil {
+		return nil, err
 	}
 
-	err := s.repo.CreateFaction(ctx, faction)
+	err := s.revisions.TrackCreate(ctx, "factions", func(ctx context.Context) (primitive.ObjectID, error) {
+		err := s.repo.CreateFaction(ctx, faction)
+		return faction.ID, err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create faction: %w", err)
 	}
@@ -60,12 +104,29 @@ func (s *FactionService) SearchFactionsByName(ctx context.Context, name string,
 	return factions, nil
 }
 
+func (s *FactionService) ListFactions(ctx context.Context, name string, opts ListOptions) ([]models.Faction, string, error) {
+	factions, nextCursor, err := s.repo.ListFactions(ctx, name, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list factions: %w", err)
+	}
+
+	return factions, nextCursor, nil
+}
+
 func (s *FactionService) UpdateFaction(ctx context.Context, id string, faction *models.Faction) error {
-	if faction.Name == "" {
-		return fmt.Errorf("faction name is required")
+	if err := validateFaction(faction); err != nil {
+		return err
+	}
+	if err := checkPublishable(ctx, faction); err != nil {
+		return err
+	}
+	if err := s.checkOwned(ctx, id); err != nil {
+		return err
 	}
 
-	err := s.repo.UpdateFaction(ctx, id, faction)
+	err := s.revisions.Track(ctx, "factions", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.UpdateFaction(ctx, id, faction)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update faction: %w", err)
 	}
@@ -74,7 +135,13 @@ func (s *FactionService) UpdateFaction(ctx context.Context, id string, faction *
 }
 
 func (s *FactionService) DeleteFaction(ctx context.Context, id string) error {
-	err := s.repo.DeleteFaction(ctx, id)
+	if err := s.checkOwned(ctx, id); err != nil {
+		return err
+	}
+
+	err := s.revisions.Track(ctx, "factions", id, RevisionDeleted, func(ctx context.Context) error {
+		return s.repo.DeleteFaction(ctx, id)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to delete faction: %w", err)
 	}
@@ -83,7 +150,20 @@ func (s *FactionService) DeleteFaction(ctx context.Context, id string) error {
 }
 
 func (s *FactionService) BulkImportFactions(ctx context.Context, factions []models.Faction) ([]primitive.ObjectID, error) {
+	for i := range factions {
+		if err := checkPublishable(ctx, &factions[i]); err != nil {
+			return nil, err
+		}
+	}
+
 	importedIDs, err := s.repo.BulkImportFactions(ctx, factions)
+	hexIDs := make([]string, len(importedIDs))
+	for i, id := range importedIDs {
+		hexIDs[i] = id.Hex()
+	}
+	if recordErr := s.revisions.RecordImported(ctx, "factions", hexIDs); recordErr != nil && err == nil {
+		err = recordErr
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to import factions: %w", err)
 	}
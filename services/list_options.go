@@ -0,0 +1,27 @@
+package services
+
+import (
+	"strings"
+
+	"grimdank-database/repositories"
+)
+
+// ListOptions controls the order, paging and filtering of list queries
+type ListOptions = repositories.ListOptions
+
+// Filter is a set of field:operator:value conditions a list must match
+type Filter = repositories.Filter
+
+// ParseSort reads a sort parameter such as "points" or "-points", where a
+// leading minus sorts in descending order
+func ParseSort(sort string) (string, bool) {
+	if strings.HasPrefix(sort, "-") {
+		return strings.TrimPrefix(sort, "-"), true
+	}
+	return sort, false
+}
+
+// ParseFilter reads filter parameters such as "points:lt:15"
+func ParseFilter(expressions []string) (Filter, error) {
+	return repositories.ParseFilter(expressions)
+}
@@ -286,73 +286,31 @@ func (pc *PointsCalculator) analyzeBaseEffectiveness(text string) string {
 	// Convert to lowercase for analysis
 	text = strings.ToLower(text)
 
-	// Overpowered indicators - game-breaking rules
-	overpoweredKeywords := []string{
-		"immune to all", "ignore all", "unlimited", "automatic", "always pass",
-		"cannot be", "immune to", "invulnerable to", "eternal", "immortal",
-		"unbreakable", "unstoppable", "overpowered", "broken", "overpowered",
-		"win the game", "instant win", "guaranteed", "certain", "absolute",
-		"eternal warrior", "immortal", "unbreakable", "unstoppable",
-	}
-
-	// Strong indicators - powerful rules
-	strongKeywords := []string{
-		"invulnerable save", "feel no pain", "eternal warrior", "fearless",
-		"preferred enemy", "hate", "rage", "furious charge", "counter-attack",
-		"stubborn", "unbreakable", "stealth", "concealed", "hidden",
-		"regeneration", "tough", "hardy", "resilient", "durable", "sturdy",
-		"ward save", "shield", "protection", "armour", "cover", "concealment",
-		"preferred enemy", "hate", "rage", "furious charge", "counter-attack",
-		"psychic", "magic", "warp", "soul", "spirit", "ethereal",
-		"phase", "teleport", "deep strike", "outflank", "infiltrate",
-	}
-
-	// Moderate indicators - decent rules
-	moderateKeywords := []string{
-		"all friendly", "all units", "within", "range", "distance", "inches",
-		"leadership", "morale", "fear", "terror", "awe", "inspiring",
-		"command", "officer", "sergeant", "leader", "commander", "captain",
-		"lieutenant", "major", "colonel", "general", "marshal", "lord",
-		"reroll", "rerolls", "bonus", "penalty", "modifier", "adjustment",
-		"difficult", "dangerous", "hazardous", "perilous", "challenging",
-		"fearless", "stubborn", "unbreakable", "stealth", "concealed",
-	}
-
-	// Minimal indicators - weak rules
-	minimalKeywords := []string{
-		"+1", "+2", "+3", "-1", "-2", "-3", "bonus", "penalty", "modifier",
-		"reroll", "rerolls", "dice", "roll", "rolls", "d6", "d3", "2d6", "3d6",
-		"hit", "wound", "save", "armour", "cover", "concealment", "stealth",
-		"move", "movement", "advance", "charge", "assault", "close combat",
-		"melee", "shooting", "ranged", "fire", "shoot", "gun", "weapon",
-		"if", "when", "unless", "but", "however", "except", "provided",
-	}
-
 	// Count keyword matches
 	overpoweredCount := 0
 	strongCount := 0
 	moderateCount := 0
 	minimalCount := 0
 
-	for _, keyword := range overpoweredKeywords {
+	for _, keyword := range pc.config.OverpoweredKeywords {
 		if strings.Contains(text, keyword) {
 			overpoweredCount++
 		}
 	}
 
-	for _, keyword := range strongKeywords {
+	for _, keyword := range pc.config.StrongKeywords {
 		if strings.Contains(text, keyword) {
 			strongCount++
 		}
 	}
 
-	for _, keyword := range moderateKeywords {
+	for _, keyword := range pc.config.ModerateKeywords {
 		if strings.Contains(text, keyword) {
 			moderateCount++
 		}
 	}
 
-	for _, keyword := range minimalKeywords {
+	for _, keyword := range pc.config.MinimalKeywords {
 		if strings.Contains(text, keyword) {
 			minimalCount++
 		}
@@ -2,46 +2,13 @@ package services
 
 import (
 	"time"
-)
-
-// PointsCalculatorConfig holds configuration for the points calculator
-type PointsCalculatorConfig struct {
-	// Keyword analysis thresholds
-	HighImpactThreshold int
-	StrongThreshold     int
-	ModerateThreshold   int
-
-	// Complexity analysis thresholds
-	HighComplexityThreshold   int
-	MediumComplexityThreshold int
-
-	// Numerical value thresholds
-	OverpoweredThreshold       int
-	StrongNumericalThreshold   int
-	ModerateNumericalThreshold int
-
-	// Multiplier bounds
-	MaxMultiplier float64
-	MinMultiplier float64
-
-	// Complexity bounds
-	MaxComplexity int
-	MinComplexity int
-
-	// Frequency analysis keywords
-	PassiveKeywords  []string
-	LimitedKeywords  []string
-	FrequentKeywords []string
-
-	// High impact keywords
-	HighImpactKeywords []string
 
-	// Complexity keywords
-	ComplexityKeywords []string
+	"grimdank-database/models"
+)
 
-	// Base effectiveness keywords
-	BaseEffectivenessKeywords map[string]string
-}
+// PointsCalculatorConfig holds configuration for the points calculator. It
+// is stored as part of each points season.
+type PointsCalculatorConfig = models.RulePointsConfig
 
 // DefaultPointsCalculatorConfig returns default configuration
 func DefaultPointsCalculatorConfig() *PointsCalculatorConfig {
@@ -126,6 +93,48 @@ func DefaultPointsCalculatorConfig() *PointsCalculatorConfig {
 			"special", "unique", "rare", "legendary", "epic",
 		},
 
+		// Overpowered indicators - game-breaking rules
+		OverpoweredKeywords: []string{
+			"immune to all", "ignore all", "unlimited", "automatic", "always pass",
+			"cannot be", "immune to", "invulnerable to", "eternal", "immortal",
+			"unbreakable", "unstoppable", "overpowered", "broken", "overpowered",
+			"win the game", "instant win", "guaranteed", "certain", "absolute",
+			"eternal warrior", "immortal", "unbreakable", "unstoppable",
+		},
+
+		// Strong indicators - powerful rules
+		StrongKeywords: []string{
+			"invulnerable save", "feel no pain", "eternal warrior", "fearless",
+			"preferred enemy", "hate", "rage", "furious charge", "counter-attack",
+			"stubborn", "unbreakable", "stealth", "concealed", "hidden",
+			"regeneration", "tough", "hardy", "resilient", "durable", "sturdy",
+			"ward save", "shield", "protection", "armour", "cover", "concealment",
+			"preferred enemy", "hate", "rage", "furious charge", "counter-attack",
+			"psychic", "magic", "warp", "soul", "spirit", "ethereal",
+			"phase", "teleport", "deep strike", "outflank", "infiltrate",
+		},
+
+		// Moderate indicators - decent rules
+		ModerateKeywords: []string{
+			"all friendly", "all units", "within", "range", "distance", "inches",
+			"leadership", "morale", "fear", "terror", "awe", "inspiring",
+			"command", "officer", "sergeant", "leader", "commander", "captain",
+			"lieutenant", "major", "colonel", "general", "marshal", "lord",
+			"reroll", "rerolls", "bonus", "penalty", "modifier", "adjustment",
+			"difficult", "dangerous", "hazardous", "perilous", "challenging",
+			"fearless", "stubborn", "unbreakable", "stealth", "concealed",
+		},
+
+		// Minimal indicators - weak rules
+		MinimalKeywords: []string{
+			"+1", "+2", "+3", "-1", "-2", "-3", "bonus", "penalty", "modifier",
+			"reroll", "rerolls", "dice", "roll", "rolls", "d6", "d3", "2d6", "3d6",
+			"hit", "wound", "save", "armour", "cover", "concealment", "stealth",
+			"move", "movement", "advance", "charge", "assault", "close combat",
+			"melee", "shooting", "ranged", "fire", "shoot", "gun", "weapon",
+			"if", "when", "unless", "but", "however", "except", "provided",
+		},
+
 		// Base effectiveness keywords
 		BaseEffectivenessKeywords: map[string]string{
 			"invulnerable": "overpowered",
@@ -140,18 +149,69 @@ func DefaultPointsCalculatorConfig() *PointsCalculatorConfig {
 			"hardy":        "moderate",
 			"resilient":    "moderate",
 		},
+
+		// Rule effects - points per point of change, so a +1 to Defense
+		// rolls costs 4 points and 6" of range costs 1.5
+		EffectWeights: map[string]float64{
+			"defense": 4, "ranged": 3, "melee": 3, "morale": 2,
+			"speed": 0.5, "charge": 0.5,
+			"ap": 3, "attacks": 3, "range": 0.25,
+		},
+		TriggerMultipliers: map[string]float64{
+			"once_per_game": 0.4,
+			"once_per_turn": 0.7,
+			"conditional":   0.7,
+		},
+		AuraMultiplier: 2,
 	}
 }
 
 // GetPointsCalculatorConfig returns configuration for the points calculator
-// Can be overridden by environment variables or config files
+// of the built-in default season. The seasons themselves are managed by
+// PointsSeasonService.
 func GetPointsCalculatorConfig() *PointsCalculatorConfig {
-	config := DefaultPointsCalculatorConfig()
+	return DefaultPointsCalculatorConfig()
+}
 
-	// TODO: Load from environment variables or config file
-	// This allows for runtime configuration changes without code changes
+// DefaultWeaponPointsConfig returns the weapon weights of the built-in default season
+func DefaultWeaponPointsConfig() *models.WeaponPointsConfig {
+	return &models.WeaponPointsConfig{
+		// Range matters most for ranged weapons, attacks for melee weapons
+		RangedWeights: models.StatWeights{Range: 0.4, Attacks: 0.4, AP: 0.2},
+		MeleeWeights:  models.StatWeights{Range: 0.1, Attacks: 0.6, AP: 0.3},
+		MaxRange:      48,
+		ScaleBase:     1.6,
+		MinPoints:     1,
+		MaxPoints:     50,
+		Pricing:       WeaponPricingLegacy,
+		Statistical:   *DefaultStatisticalWeaponPricing(),
+	}
+}
 
-	return config
+// DefaultStatisticalWeaponPricing returns the built-in settings of
+// statistical weapon pricing: a wielder hitting on 5+ against targets with
+// Defense 4+ to 7+, the middle ones most common
+func DefaultStatisticalWeaponPricing() *models.StatisticalWeaponPricing {
+	return &models.StatisticalWeaponPricing{
+		Skill: 5,
+		Defenses: []models.DefenseProfile{
+			{Defense: 4, Weight: 1},
+			{Defense: 5, Weight: 2},
+			{Defense: 6, Weight: 2},
+			{Defense: 7, Weight: 1},
+		},
+		PointsPerWound: 5,
+		RangeBonus:     0.1,
+	}
+}
+
+// DefaultUnitPointsConfig returns the unit base cost formula of the built-in default season
+func DefaultUnitPointsConfig() *models.UnitPointsConfig {
+	return &models.UnitPointsConfig{
+		StatMultiplier: 2,
+		BaseCost:       10,
+		MinCost:        5,
+	}
 }
 
 // ValidationConfig holds validation rules for points calculation
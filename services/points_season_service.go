@@ -0,0 +1,422 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"grimdank-database/events"
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+)
+
+// DefaultSeasonName names the built-in season, which has the calculators'
+// original weights. It is current until a stored season is made current.
+const DefaultSeasonName = "default"
+
+// seasonName is what a season name looks like, so it can be part of a URL
+var seasonName = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,62}[a-z0-9])?$`)
+
+// effectivenessLevels are the levels base effectiveness keywords map to
+var effectivenessLevels = map[string]bool{"minimal": true, "moderate": true, "strong": true, "overpowered": true}
+
+// DefaultPointsSeason returns the built-in season
+func DefaultPointsSeason() *models.PointsSeason {
+	return &models.PointsSeason{
+		Name:        DefaultSeasonName,
+		Description: "Built-in weights",
+		Rules:       *DefaultPointsCalculatorConfig(),
+		Weapons:     *DefaultWeaponPointsConfig(),
+		Units:       *DefaultUnitPointsConfig(),
+	}
+}
+
+// PointsSeasonService keeps the points seasons in memory for the calculators
+// and reloads them from the store when they change, so that edits take effect
+// without a restart. Seasons handed out are shared and must not be modified.
+// A nil PointsSeasonService only knows the built-in season.
+type PointsSeasonService struct {
+	repo *repositories.PointsSeasonRepository
+	bus  *events.Bus
+
+	mu      sync.RWMutex
+	seasons map[string]*models.PointsSeason
+	current *models.PointsSeason
+}
+
+func NewPointsSeasonService(repo *repositories.PointsSeasonRepository, bus *events.Bus) *PointsSeasonService {
+	builtIn := DefaultPointsSeason()
+	builtIn.Current = true
+	return &PointsSeasonService{
+		repo:    repo,
+		bus:     bus,
+		seasons: map[string]*models.PointsSeason{DefaultSeasonName: builtIn},
+		current: builtIn,
+	}
+}
+
+// Current returns the season costs are calculated in
+func (s *PointsSeasonService) Current() *models.PointsSeason {
+	if s == nil {
+		return DefaultPointsSeason()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Season returns the named season, or the current one for an empty name
+func (s *PointsSeasonService) Season(name string) (*models.PointsSeason, error) {
+	if name == "" {
+		return s.Current(), nil
+	}
+	if s == nil {
+		if name == DefaultSeasonName {
+			return DefaultPointsSeason(), nil
+		}
+		return nil, fmt.Errorf("points season not found")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	season, ok := s.seasons[name]
+	if !ok {
+		return nil, fmt.Errorf("points season not found")
+	}
+	return season, nil
+}
+
+// ListSeasons returns every season by name, including the built-in one
+// unless a stored season has taken its name
+func (s *PointsSeasonService) ListSeasons() []models.PointsSeason {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seasons := make([]models.PointsSeason, 0, len(s.seasons))
+	for _, season := range s.seasons {
+		seasons = append(seasons, *season)
+	}
+	sort.Slice(seasons, func(i, j int) bool { return seasons[i].Name < seasons[j].Name })
+	return seasons
+}
+
+// Reload reads the seasons from the store. A stored season that doesn't
+// validate, e.g. after a hand edit, is logged and its last good settings are
+// kept. Of the stored seasons marked current, the one activated last is; the
+// others are shown as historical. The built-in season is current when no
+// stored season is.
+func (s *PointsSeasonService) Reload(ctx context.Context) error {
+	stored, err := s.repo.ListSeasons(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seasons := map[string]*models.PointsSeason{DefaultSeasonName: DefaultPointsSeason()}
+	var current *models.PointsSeason
+	for i := range stored {
+		season := &stored[i]
+		if err := ValidatePointsSeason(season); err != nil {
+			log.Printf("Ignoring invalid points season %q: %v", season.Name, err)
+			previous, ok := s.seasons[season.Name]
+			if !ok || previous.ID != season.ID {
+				continue
+			}
+			// Keep the last good settings, but follow the switch of current season
+			kept := *previous
+			kept.Current = season.Current
+			season = &kept
+		}
+		seasons[season.Name] = season
+		if season.Current && (current == nil || activatedLater(season, current)) {
+			current = season
+		}
+	}
+	for _, season := range seasons {
+		if season.Current && season != current {
+			historical := *season
+			historical.Current = false
+			seasons[season.Name] = &historical
+		}
+	}
+	if current == nil {
+		current = seasons[DefaultSeasonName]
+		current.Current = true
+	}
+
+	s.seasons = seasons
+	s.current = current
+	return nil
+}
+
+// activatedLater reports whether season was activated after other, breaking
+// ties by ID
+func activatedLater(season, other *models.PointsSeason) bool {
+	if !season.ActivatedAt.Equal(other.ActivatedAt) {
+		return season.ActivatedAt.After(other.ActivatedAt)
+	}
+	return season.ID.Hex() > other.ID.Hex()
+}
+
+// RunReloadLoop reloads the seasons every interval until ctx is cancelled, so
+// edits made by other servers or directly in the database are picked up
+func (s *PointsSeasonService) RunReloadLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				log.Printf("Failed to reload points seasons: %v", err)
+			}
+		}
+	}
+}
+
+// CreateSeason stores a new season. It becomes current if it says so, which
+// is announced on the event bus.
+func (s *PointsSeasonService) CreateSeason(ctx context.Context, season *models.PointsSeason) (*models.PointsSeason, error) {
+	if err := ValidatePointsSeason(season); err != nil {
+		return nil, err
+	}
+
+	current := season.Current
+	season.Current = false
+	if err := s.repo.CreateSeason(ctx, season); err != nil {
+		return nil, err
+	}
+	if !current {
+		return s.reloaded(ctx, season.Name)
+	}
+	if _, err := s.repo.SetCurrent(ctx, season.Name); err != nil {
+		return nil, err
+	}
+	return s.announced(ctx, season.Name)
+}
+
+// UpdateSeason replaces the settings of a stored season. Changes to the
+// current season apply to the next calculation and are announced on the
+// event bus.
+func (s *PointsSeasonService) UpdateSeason(ctx context.Context, name string, season *models.PointsSeason) (*models.PointsSeason, error) {
+	existing, err := s.repo.GetSeasonByName(ctx, name)
+	if err != nil {
+		if name == DefaultSeasonName {
+			return nil, utils.NewValidationError("name", "the built-in season can't be changed; create a season instead")
+		}
+		return nil, err
+	}
+	if season.Name == "" {
+		season.Name = name
+	}
+	if err := ValidatePointsSeason(season); err != nil {
+		return nil, err
+	}
+
+	season.ID = existing.ID
+	if err := s.repo.UpdateSeason(ctx, season); err != nil {
+		return nil, err
+	}
+	if !existing.Current {
+		return s.reloaded(ctx, season.Name)
+	}
+	return s.announced(ctx, season.Name)
+}
+
+// ActivateSeason makes the named season the current one and announces it on
+// the event bus. Activating the built-in season, unless a stored season has
+// its name, makes every stored season historical.
+func (s *PointsSeasonService) ActivateSeason(ctx context.Context, name string) (*models.PointsSeason, error) {
+	_, err := s.repo.SetCurrent(ctx, name)
+	if err != nil && name == DefaultSeasonName && strings.Contains(err.Error(), "not found") {
+		err = s.repo.ClearCurrent(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.announced(ctx, name)
+}
+
+// DeleteSeason removes a stored season. The current season can't be deleted.
+func (s *PointsSeasonService) DeleteSeason(ctx context.Context, name string) error {
+	season, err := s.repo.GetSeasonByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if season.Current {
+		return utils.NewValidationError("name", "the current season can't be deleted")
+	}
+	if err := s.repo.DeleteSeason(ctx, name); err != nil {
+		return err
+	}
+	return s.Reload(ctx)
+}
+
+// reloaded reloads the seasons after a write and returns the named one
+func (s *PointsSeasonService) reloaded(ctx context.Context, name string) (*models.PointsSeason, error) {
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return s.Season(name)
+}
+
+// announced reloads the seasons after a write that changed the current season
+// or its settings, publishes SeasonChanged with the season now current and
+// returns the named one
+func (s *PointsSeasonService) announced(ctx context.Context, name string) (*models.PointsSeason, error) {
+	season, err := s.reloaded(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	current := s.Current()
+	change := changeFrom(ctx)
+	s.bus.Publish(ctx, events.SeasonChanged{
+		Meta: events.Meta{
+			EntityType: "pointsseasons",
+			EntityID:   current.ID,
+			Version:    current.Version,
+			Actor:      change.Actor,
+			Reason:     change.Reason,
+			At:         time.Now(),
+		},
+		Season: *current,
+	})
+	return season, nil
+}
+
+// ValidatePointsSeason checks that a season's settings make sense together
+func ValidatePointsSeason(season *models.PointsSeason) error {
+	if !seasonName.MatchString(season.Name) {
+		return utils.NewValidationError("name", "season names are lowercase letters, digits, dots, dashes and underscores")
+	}
+	if err := validateRulePointsConfig(&season.Rules); err != nil {
+		return err
+	}
+	if err := validateWeaponPointsConfig(&season.Weapons); err != nil {
+		return err
+	}
+	return validateUnitPointsConfig(&season.Units)
+}
+
+func validateRulePointsConfig(config *models.RulePointsConfig) error {
+	if config.ModerateThreshold < 1 || config.StrongThreshold < config.ModerateThreshold || config.HighImpactThreshold < config.StrongThreshold {
+		return utils.NewValidationError("rules", "keyword thresholds must be at least 1 and rise from moderate to strong to high impact")
+	}
+	if config.MediumComplexityThreshold < 1 || config.HighComplexityThreshold < config.MediumComplexityThreshold {
+		return utils.NewValidationError("rules", "complexity thresholds must be at least 1 and rise from medium to high")
+	}
+	if config.ModerateNumericalThreshold < 1 || config.StrongNumericalThreshold < config.ModerateNumericalThreshold || config.OverpoweredThreshold < config.StrongNumericalThreshold {
+		return utils.NewValidationError("rules", "numerical thresholds must be at least 1 and rise from moderate to strong to overpowered")
+	}
+	if config.MinMultiplier <= 0 || config.MaxMultiplier < config.MinMultiplier {
+		return utils.NewValidationError("rules", "the multiplier bounds must be positive with the minimum below the maximum")
+	}
+	if config.MinComplexity < 1 || config.MaxComplexity < config.MinComplexity {
+		return utils.NewValidationError("rules", "the complexity bounds must be at least 1 with the minimum below the maximum")
+	}
+
+	keywordLists := map[string][]string{
+		"passiveKeywords":     config.PassiveKeywords,
+		"limitedKeywords":     config.LimitedKeywords,
+		"frequentKeywords":    config.FrequentKeywords,
+		"highImpactKeywords":  config.HighImpactKeywords,
+		"complexityKeywords":  config.ComplexityKeywords,
+		"overpoweredKeywords": config.OverpoweredKeywords,
+		"strongKeywords":      config.StrongKeywords,
+		"moderateKeywords":    config.ModerateKeywords,
+		"minimalKeywords":     config.MinimalKeywords,
+	}
+	for field, keywords := range keywordLists {
+		for _, keyword := range keywords {
+			if keyword == "" {
+				return utils.NewValidationError("rules."+field, "keywords can't be empty")
+			}
+		}
+	}
+	for keyword, level := range config.BaseEffectivenessKeywords {
+		if keyword == "" || !effectivenessLevels[level] {
+			return utils.NewValidationError("rules.baseEffectivenessKeywords", fmt.Sprintf("%q must map to minimal, moderate, strong or overpowered", keyword))
+		}
+	}
+
+	for stat, weight := range config.EffectWeights {
+		if !ruleEffectStats[stat] || weight < 0 {
+			return utils.NewValidationError("rules.effectWeights", fmt.Sprintf("%q must be a lowercase stat rule effects change, with a weight that isn't negative", stat))
+		}
+	}
+	for trigger, multiplier := range config.TriggerMultipliers {
+		if !triggerName.MatchString(trigger) || multiplier < 0 {
+			return utils.NewValidationError("rules.triggerMultipliers", fmt.Sprintf("%q must be a trigger name with a multiplier that isn't negative", trigger))
+		}
+	}
+	if config.AuraMultiplier < 0 {
+		return utils.NewValidationError("rules.auraMultiplier", "the aura multiplier can't be negative")
+	}
+	return nil
+}
+
+func validateWeaponPointsConfig(config *models.WeaponPointsConfig) error {
+	for field, weights := range map[string]models.StatWeights{"rangedWeights": config.RangedWeights, "meleeWeights": config.MeleeWeights} {
+		if weights.Range < 0 || weights.Attacks < 0 || weights.AP < 0 || weights.Range+weights.Attacks+weights.AP == 0 {
+			return utils.NewValidationError("weapons."+field, "weights can't be negative and must not all be zero")
+		}
+	}
+	if config.MaxRange < 1 {
+		return utils.NewValidationError("weapons.maxRange", "the maximum range must be at least 1")
+	}
+	if config.ScaleBase <= 1 {
+		return utils.NewValidationError("weapons.scaleBase", "the scale base must be greater than 1")
+	}
+	if config.MinPoints < 0 || config.MaxPoints < config.MinPoints {
+		return utils.NewValidationError("weapons", "the points bounds can't be negative and the minimum must not exceed the maximum")
+	}
+	switch config.Pricing {
+	case "", WeaponPricingLegacy, WeaponPricingStatistical:
+	default:
+		return utils.NewValidationError("weapons.pricing", "pricing must be legacy or statistical")
+	}
+	return validateStatisticalWeaponPricing(&config.Statistical)
+}
+
+// validateStatisticalWeaponPricing checks the statistical settings, unless
+// they are left out for the built-in ones
+func validateStatisticalWeaponPricing(config *models.StatisticalWeaponPricing) error {
+	if len(config.Defenses) == 0 {
+		return nil
+	}
+	if config.Skill < 1 || config.Skill > 10 {
+		return utils.NewValidationError("weapons.statistical.skill", "the skill is a D10 target from 1 to 10")
+	}
+	totalWeight := 0.0
+	for _, profile := range config.Defenses {
+		if profile.Defense < 1 || profile.Defense > 10 || profile.Weight < 0 {
+			return utils.NewValidationError("weapons.statistical.defenses", "defenses are D10 targets from 1 to 10 with weights that aren't negative")
+		}
+		totalWeight += profile.Weight
+	}
+	if totalWeight == 0 {
+		return utils.NewValidationError("weapons.statistical.defenses", "the weights must not all be zero")
+	}
+	if config.PointsPerWound <= 0 || config.RangeBonus < 0 {
+		return utils.NewValidationError("weapons.statistical", "points per wound must be positive and the range bonus can't be negative")
+	}
+	return nil
+}
+
+func validateUnitPointsConfig(config *models.UnitPointsConfig) error {
+	if config.StatMultiplier < 0 || config.BaseCost < 0 || config.MinCost < 0 {
+		return utils.NewValidationError("units", "the base cost formula can't have negative terms")
+	}
+	return nil
+}
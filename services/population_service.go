@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+
 	"grimdank-database/models"
+	"grimdank-database/utils"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -25,125 +29,128 @@ func NewPopulationService(ruleService *RuleService, weaponService *WeaponService
 	}
 }
 
-// PopulateWeaponRules populates rule references with full rule data
+// PopulateWeaponRules populates rule references with full rule data,
+// reporting references to rules that are gone in MissingReferences
 func (ps *PopulationService) PopulateWeaponRules(ctx context.Context, weapon *models.Weapon) (*models.PopulatedWeapon, error) {
 	populatedWeapon := &models.PopulatedWeapon{
 		Weapon: *weapon,
 	}
 
-	// Collect all rule IDs for batch query
-	ruleIDs := make([]primitive.ObjectID, len(weapon.Rules))
-	for i, ruleRef := range weapon.Rules {
-		ruleIDs[i] = ruleRef.RuleID
-	}
-
-	// Single batch query instead of N individual queries
-	rules, err := ps.ruleService.GetRulesByIDs(ctx, ruleIDs)
+	rules, err := ps.rulesOf(ctx, weapon.Rules)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create lookup map for O(1) access
-	rulesMap := make(map[primitive.ObjectID]*models.Rule)
-	for i := range rules {
-		rulesMap[rules[i].ID] = &rules[i]
-	}
-
-	// Populate rules using map lookup
 	for _, ruleRef := range weapon.Rules {
-		if rule, exists := rulesMap[ruleRef.RuleID]; exists {
-			ruleWithTier := models.RuleWithTier{
-				Rule: *rule,
-				Tier: ruleRef.Tier,
-			}
-			populatedWeapon.PopulatedRules = append(populatedWeapon.PopulatedRules, ruleWithTier)
+		if rule, ok := rules[ruleRef.RuleID]; ok {
+			populatedWeapon.PopulatedRules = append(populatedWeapon.PopulatedRules, models.RuleWithTier{Rule: rule, Tier: ruleRef.Tier})
 		} else {
-			// Return error if rule doesn't exist
-			return nil, fmt.Errorf("rule with ID %s not found", ruleRef.RuleID.Hex())
+			populatedWeapon.MissingReferences = append(populatedWeapon.MissingReferences, models.MissingReference{Field: "rules", ID: ruleRef.RuleID})
 		}
 	}
 
 	return populatedWeapon, nil
 }
 
-// PopulateWarGearRules populates rule references with full rule data
+// PopulateWarGearRules populates rule references with full rule data,
+// reporting references to rules that are gone in MissingReferences
 func (ps *PopulationService) PopulateWarGearRules(ctx context.Context, wargear *models.WarGear) (*models.PopulatedWarGear, error) {
 	populatedWarGear := &models.PopulatedWarGear{
 		WarGear: *wargear,
 	}
 
-	// Collect all rule IDs for batch query
-	ruleIDs := make([]primitive.ObjectID, len(wargear.Rules))
-	for i, ruleRef := range wargear.Rules {
-		ruleIDs[i] = ruleRef.RuleID
-	}
-
-	// Single batch query instead of N individual queries
-	rules, err := ps.ruleService.GetRulesByIDs(ctx, ruleIDs)
+	rules, err := ps.rulesOf(ctx, wargear.Rules)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create lookup map for O(1) access
-	rulesMap := make(map[primitive.ObjectID]*models.Rule)
-	for i := range rules {
-		rulesMap[rules[i].ID] = &rules[i]
-	}
-
-	// Populate rules using map lookup
 	for _, ruleRef := range wargear.Rules {
-		if rule, exists := rulesMap[ruleRef.RuleID]; exists {
-			populatedWarGear.PopulatedRules = append(populatedWarGear.PopulatedRules, *rule)
+		if rule, ok := rules[ruleRef.RuleID]; ok {
+			populatedWarGear.PopulatedRules = append(populatedWarGear.PopulatedRules, rule)
 		} else {
-			// Return error if rule doesn't exist
-			return nil, fmt.Errorf("rule with ID %s not found", ruleRef.RuleID.Hex())
+			populatedWarGear.MissingReferences = append(populatedWarGear.MissingReferences, models.MissingReference{Field: "rules", ID: ruleRef.RuleID})
 		}
 	}
 
 	return populatedWarGear, nil
 }
 
-// PopulateUnitWithReferences populates all references in a unit
+// rulesOf looks up the referenced rules with a single query and returns what
+// it finds by ID
+func (ps *PopulationService) rulesOf(ctx context.Context, refs []models.RuleReference) (map[primitive.ObjectID]models.Rule, error) {
+	ruleIDs := make([]primitive.ObjectID, 0, len(refs))
+	for _, ruleRef := range refs {
+		ruleIDs = append(ruleIDs, ruleRef.RuleID)
+	}
+	return fetchByIDs(ctx, ruleIDs, ps.ruleService.GetRulesByIDs, func(rule *models.Rule) primitive.ObjectID { return rule.ID })
+}
+
+// PopulateUnitWithReferences populates all references in a unit with one
+// query per referenced collection. References to entities that are gone are
+// listed in MissingReferences instead of failing the population.
 func (ps *PopulationService) PopulateUnitWithReferences(ctx context.Context, unit *models.Unit) (*models.PopulatedUnit, error) {
 	populatedUnit := &models.PopulatedUnit{
 		Unit: *unit,
 	}
+	missing := func(field string, id primitive.ObjectID) {
+		populatedUnit.MissingReferences = append(populatedUnit.MissingReferences, models.MissingReference{Field: field, ID: id})
+	}
+
+	weaponIDs := append([]primitive.ObjectID{}, unit.AvailableWeapons...)
+	for _, weaponRef := range unit.Weapons {
+		weaponIDs = append(weaponIDs, weaponRef.WeaponID)
+	}
+	wargearIDs := append(append([]primitive.ObjectID{}, unit.AvailableWarGear...), unit.WarGear...)
+
+	rules, err := ps.rulesOf(ctx, unit.Rules)
+	if err != nil {
+		return nil, err
+	}
+	weapons, err := fetchByIDs(ctx, weaponIDs, ps.weaponService.GetWeaponsByIDs, func(weapon *models.Weapon) primitive.ObjectID { return weapon.ID })
+	if err != nil {
+		return nil, err
+	}
+	wargear, err := fetchByIDs(ctx, wargearIDs, ps.wargearService.GetWarGearByIDs, func(wargear *models.WarGear) primitive.ObjectID { return wargear.ID })
+	if err != nil {
+		return nil, err
+	}
 
 	// Populate rules
 	for _, ruleRef := range unit.Rules {
-		rule, err := ps.ruleService.GetRuleByID(ctx, ruleRef.RuleID.Hex())
-		if err != nil {
-			return nil, err
+		if rule, ok := rules[ruleRef.RuleID]; ok {
+			populatedUnit.PopulatedRules = append(populatedUnit.PopulatedRules, rule)
+		} else {
+			missing("rules", ruleRef.RuleID)
 		}
-		populatedUnit.PopulatedRules = append(populatedUnit.PopulatedRules, *rule)
 	}
 
 	// Populate available weapons
 	for _, weaponID := range unit.AvailableWeapons {
-		weapon, err := ps.weaponService.GetWeaponByID(ctx, weaponID.Hex())
-		if err != nil {
-			return nil, err
+		if weapon, ok := weapons[weaponID]; ok {
+			populatedUnit.PopulatedAvailableWeapons = append(populatedUnit.PopulatedAvailableWeapons, weapon)
+		} else {
+			missing("availableWeaponIds", weaponID)
 		}
-		populatedUnit.PopulatedAvailableWeapons = append(populatedUnit.PopulatedAvailableWeapons, *weapon)
 	}
 
 	// Populate available wargear
 	for _, wargearID := range unit.AvailableWarGear {
-		wargear, err := ps.wargearService.GetWarGearByID(ctx, wargearID.Hex())
-		if err != nil {
-			return nil, err
+		if item, ok := wargear[wargearID]; ok {
+			populatedUnit.PopulatedAvailableWarGear = append(populatedUnit.PopulatedAvailableWarGear, item)
+		} else {
+			missing("availableWarGearIds", wargearID)
 		}
-		populatedUnit.PopulatedAvailableWarGear = append(populatedUnit.PopulatedAvailableWarGear, *wargear)
 	}
 
 	// Populate equipped weapons with quantity and type info
 	for _, weaponRef := range unit.Weapons {
-		weapon, err := ps.weaponService.GetWeaponByID(ctx, weaponRef.WeaponID.Hex())
-		if err != nil {
-			return nil, err
+		weapon, ok := weapons[weaponRef.WeaponID]
+		if !ok {
+			missing("weapons", weaponRef.WeaponID)
+			continue
 		}
 		popWeaponRef := models.PopulatedWeaponReference{
-			Weapon:   *weapon,
+			Weapon:   weapon,
 			Quantity: weaponRef.Quantity,
 			Type:     weaponRef.Type,
 		}
@@ -152,16 +159,101 @@ func (ps *PopulationService) PopulateUnitWithReferences(ctx context.Context, uni
 
 	// Populate equipped wargear
 	for _, wargearID := range unit.WarGear {
-		wargear, err := ps.wargearService.GetWarGearByID(ctx, wargearID.Hex())
-		if err != nil {
-			return nil, err
+		if item, ok := wargear[wargearID]; ok {
+			populatedUnit.PopulatedWarGear = append(populatedUnit.PopulatedWarGear, item)
+		} else {
+			missing("warGearIds", wargearID)
 		}
-		populatedUnit.PopulatedWarGear = append(populatedUnit.PopulatedWarGear, *wargear)
 	}
 
 	return populatedUnit, nil
 }
 
+// PopulateArmyBook populates the units and rules of an army book, reporting
+// references to entities that are gone in MissingReferences
+func (ps *PopulationService) PopulateArmyBook(ctx context.Context, armyBook *models.ArmyBook) (*models.PopulatedArmyBook, error) {
+	populatedArmyBook := &models.PopulatedArmyBook{
+		ArmyBook: *armyBook,
+	}
+
+	units, err := fetchByIDs(ctx, armyBook.Units, ps.unitService.GetUnitsByIDs, func(unit *models.Unit) primitive.ObjectID { return unit.ID })
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ps.rulesOf(ctx, armyBook.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, unitID := range armyBook.Units {
+		if unit, ok := units[unitID]; ok {
+			populatedArmyBook.PopulatedUnits = append(populatedArmyBook.PopulatedUnits, unit)
+		} else {
+			populatedArmyBook.MissingReferences = append(populatedArmyBook.MissingReferences, models.MissingReference{Field: "unitIds", ID: unitID})
+		}
+	}
+	for _, ruleRef := range armyBook.Rules {
+		if rule, ok := rules[ruleRef.RuleID]; ok {
+			populatedArmyBook.PopulatedRules = append(populatedArmyBook.PopulatedRules, rule)
+		} else {
+			populatedArmyBook.MissingReferences = append(populatedArmyBook.MissingReferences, models.MissingReference{Field: "rules", ID: ruleRef.RuleID})
+		}
+	}
+
+	return populatedArmyBook, nil
+}
+
+// PopulateArmyList populates the units of an army list, reporting references
+// to units that are gone in MissingReferences
+func (ps *PopulationService) PopulateArmyList(ctx context.Context, armyList *models.ArmyList) (*models.PopulatedArmyList, error) {
+	populatedArmyList := &models.PopulatedArmyList{
+		ArmyList: *armyList,
+	}
+
+	units, err := fetchByIDs(ctx, armyList.Units, ps.unitService.GetUnitsByIDs, func(unit *models.Unit) primitive.ObjectID { return unit.ID })
+	if err != nil {
+		return nil, err
+	}
+
+	// A list can field the same unit more than once
+	for _, unitID := range armyList.Units {
+		if unit, ok := units[unitID]; ok {
+			populatedArmyList.PopulatedUnits = append(populatedArmyList.PopulatedUnits, unit)
+		} else {
+			populatedArmyList.MissingReferences = append(populatedArmyList.MissingReferences, models.MissingReference{Field: "unitIds", ID: unitID})
+		}
+	}
+
+	return populatedArmyList, nil
+}
+
+// fetchByIDs looks the distinct ids up with a single query and returns what
+// it finds by ID
+func fetchByIDs[T any](ctx context.Context, ids []primitive.ObjectID, fetch func(ctx context.Context, ids []primitive.ObjectID) ([]T, error), idOf func(*T) primitive.ObjectID) (map[primitive.ObjectID]T, error) {
+	found := make(map[primitive.ObjectID]T)
+
+	seen := make(map[primitive.ObjectID]bool, len(ids))
+	distinct := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			distinct = append(distinct, id)
+		}
+	}
+	if len(distinct) == 0 {
+		return found, nil
+	}
+
+	items, err := fetch(ctx, distinct)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		found[idOf(&items[i])] = items[i]
+	}
+	return found, nil
+}
+
 // CalculateTotalPoints calculates total points including rule costs
 func (ps *PopulationService) CalculateTotalPoints(ctx context.Context, weapon *models.Weapon) (int, error) {
 	basePoints := weapon.Points
@@ -187,99 +279,178 @@ func (ps *PopulationService) CalculateTotalPoints(ctx context.Context, weapon *m
 	return basePoints + rulePoints, nil
 }
 
-// AddRuleToWeapon adds a rule reference to a weapon
-func (ps *PopulationService) AddRuleToWeapon(ctx context.Context, weaponID string, ruleID string, tier int) error {
+// ruleReference checks that a rule can be attached at the given tier: the
+// rule must exist and have points for the tier, so a rule without tier
+// points can't be attached
+func (ps *PopulationService) ruleReference(ctx context.Context, ruleID string, tier int) (models.RuleReference, error) {
 	ruleObjID, err := primitive.ObjectIDFromHex(ruleID)
 	if err != nil {
-		return err
+		return models.RuleReference{}, utils.NewValidationError("ruleId", "invalid rule ID")
 	}
-
-	ruleRef := models.RuleReference{
-		RuleID: ruleObjID,
-		Tier:   tier,
+	if tier < 1 || tier > 3 {
+		return models.RuleReference{}, utils.NewValidationError("tier", "tier must be 1, 2, or 3")
 	}
 
-	// Get current weapon
-	weapon, err := ps.weaponService.GetWeaponByID(ctx, weaponID)
+	rule, err := ps.ruleService.GetRuleByID(ctx, ruleID)
 	if err != nil {
-		return err
+		if strings.Contains(err.Error(), "not found") {
+			return models.RuleReference{}, utils.NewValidationError("ruleId", fmt.Sprintf("rule %s not found", ruleID))
+		}
+		return models.RuleReference{}, err
+	}
+	if len(rule.Points) == 0 {
+		return models.RuleReference{}, utils.NewValidationError("tier", fmt.Sprintf("rule %s has no tier points", rule.Name))
+	}
+	if tier > len(rule.Points) {
+		return models.RuleReference{}, utils.NewValidationError("tier", fmt.Sprintf("rule %s only has %d tier(s)", rule.Name, len(rule.Points)))
 	}
 
-	// Add rule reference
-	weapon.Rules = append(weapon.Rules, ruleRef)
+	return models.RuleReference{RuleID: ruleObjID, Tier: tier}, nil
+}
 
-	// Update weapon
-	return ps.weaponService.UpdateWeapon(ctx, weaponID, weapon)
+// AddRuleToWeapon attaches a rule to a weapon at the given tier and returns the updated weapon
+func (ps *PopulationService) AddRuleToWeapon(ctx context.Context, weaponID string, ruleID string, tier int) (*models.PopulatedWeapon, error) {
+	ruleRef, err := ps.ruleReference(ctx, ruleID, tier)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.weaponService.AttachRule(ctx, weaponID, ruleRef); err != nil {
+		return nil, err
+	}
+	return ps.populatedWeapon(ctx, weaponID)
 }
 
-// RemoveRuleFromWeapon removes a rule reference from a weapon
-func (ps *PopulationService) RemoveRuleFromWeapon(ctx context.Context, weaponID string, ruleID string) error {
+// RemoveRuleFromWeapon detaches a rule from a weapon and returns the updated weapon
+func (ps *PopulationService) RemoveRuleFromWeapon(ctx context.Context, weaponID string, ruleID string) (*models.PopulatedWeapon, error) {
 	ruleObjID, err := primitive.ObjectIDFromHex(ruleID)
 	if err != nil {
-		return err
+		return nil, utils.NewValidationError("ruleId", "invalid rule ID")
+	}
+	if err := ps.weaponService.DetachRule(ctx, weaponID, ruleObjID); err != nil {
+		return nil, err
 	}
+	return ps.populatedWeapon(ctx, weaponID)
+}
 
-	// Get current weapon
+func (ps *PopulationService) populatedWeapon(ctx context.Context, weaponID string) (*models.PopulatedWeapon, error) {
 	weapon, err := ps.weaponService.GetWeaponByID(ctx, weaponID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return ps.PopulateWeaponRules(ctx, weapon)
+}
 
-	// Remove rule reference
-	var newRules []models.RuleReference
-	for _, ruleRef := range weapon.Rules {
-		if ruleRef.RuleID != ruleObjID {
-			newRules = append(newRules, ruleRef)
-		}
+// AddRuleToWarGear attaches a rule to a wargear item at the given tier and returns the updated item
+func (ps *PopulationService) AddRuleToWarGear(ctx context.Context, wargearID string, ruleID string, tier int) (*models.PopulatedWarGear, error) {
+	ruleRef, err := ps.ruleReference(ctx, ruleID, tier)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.wargearService.AttachRule(ctx, wargearID, ruleRef); err != nil {
+		return nil, err
 	}
-	weapon.Rules = newRules
+	return ps.populatedWarGear(ctx, wargearID)
+}
 
-	// Update weapon
-	return ps.weaponService.UpdateWeapon(ctx, weaponID, weapon)
+// RemoveRuleFromWarGear detaches a rule from a wargear item and returns the updated item
+func (ps *PopulationService) RemoveRuleFromWarGear(ctx context.Context, wargearID string, ruleID primitive.ObjectID) (*models.PopulatedWarGear, error) {
+	if err := ps.wargearService.DetachRule(ctx, wargearID, ruleID); err != nil {
+		return nil, err
+	}
+	return ps.populatedWarGear(ctx, wargearID)
 }
 
-// AddRuleToWarGear adds a rule reference to a wargear item
-func (ps *PopulationService) AddRuleToWarGear(ctx context.Context, wargearID string, ruleID string, tier int) error {
-	ruleObjID, err := primitive.ObjectIDFromHex(ruleID)
+func (ps *PopulationService) populatedWarGear(ctx context.Context, wargearID string) (*models.PopulatedWarGear, error) {
+	wargear, err := ps.wargearService.GetWarGearByID(ctx, wargearID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return ps.PopulateWarGearRules(ctx, wargear)
+}
 
-	ruleRef := models.RuleReference{
-		RuleID: ruleObjID,
-		Tier:   tier,
+// AddRuleToUnit attaches a rule to a unit at the given tier and returns the updated unit
+func (ps *PopulationService) AddRuleToUnit(ctx context.Context, unitID string, ruleID string, tier int) (*models.PopulatedUnit, error) {
+	ruleRef, err := ps.ruleReference(ctx, ruleID, tier)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.unitService.AttachRule(ctx, unitID, ruleRef); err != nil {
+		return nil, err
 	}
+	return ps.GetPopulatedUnit(ctx, unitID)
+}
 
-	// Get current wargear
-	wargear, err := ps.wargearService.GetWarGearByID(ctx, wargearID)
+// RemoveRuleFromUnit detaches a rule from a unit and returns the updated unit
+func (ps *PopulationService) RemoveRuleFromUnit(ctx context.Context, unitID string, ruleID string) (*models.PopulatedUnit, error) {
+	ruleObjID, err := primitive.ObjectIDFromHex(ruleID)
 	if err != nil {
-		return err
+		return nil, utils.NewValidationError("ruleId", "invalid rule ID")
+	}
+	if err := ps.unitService.DetachRule(ctx, unitID, ruleObjID); err != nil {
+		return nil, err
 	}
+	return ps.GetPopulatedUnit(ctx, unitID)
+}
 
-	// Add rule reference
-	wargear.Rules = append(wargear.Rules, ruleRef)
+// AddWeaponToUnit makes a weapon available to a unit and returns the updated unit
+func (ps *PopulationService) AddWeaponToUnit(ctx context.Context, unitID string, weaponID string) (*models.PopulatedUnit, error) {
+	weapon, err := ps.weaponService.GetWeaponByID(ctx, weaponID)
+	if err != nil {
+		if errors.Is(err, primitive.ErrInvalidHex) || strings.Contains(err.Error(), "not found") {
+			return nil, utils.NewValidationError("weaponId", fmt.Sprintf("weapon %s not found", weaponID))
+		}
+		return nil, err
+	}
+	if err := ps.unitService.AttachWeapon(ctx, unitID, weapon.ID); err != nil {
+		return nil, err
+	}
+	return ps.GetPopulatedUnit(ctx, unitID)
+}
 
-	// Update wargear
-	return ps.wargearService.UpdateWarGear(ctx, wargearID, wargear)
+// RemoveWeaponFromUnit makes a weapon unavailable to a unit, unequipping it, and returns the updated unit
+func (ps *PopulationService) RemoveWeaponFromUnit(ctx context.Context, unitID string, weaponID string) (*models.PopulatedUnit, error) {
+	weaponObjID, err := primitive.ObjectIDFromHex(weaponID)
+	if err != nil {
+		return nil, utils.NewValidationError("weaponId", "invalid weapon ID")
+	}
+	if err := ps.unitService.DetachWeapon(ctx, unitID, weaponObjID); err != nil {
+		return nil, err
+	}
+	return ps.GetPopulatedUnit(ctx, unitID)
 }
 
-// RemoveRuleFromWarGear removes a rule reference from a wargear item
-func (ps *PopulationService) RemoveRuleFromWarGear(ctx context.Context, wargearID string, ruleID primitive.ObjectID) error {
-	// Get current wargear
+// AddWarGearToUnit makes a wargear item available to a unit and returns the updated unit
+func (ps *PopulationService) AddWarGearToUnit(ctx context.Context, unitID string, wargearID string) (*models.PopulatedUnit, error) {
 	wargear, err := ps.wargearService.GetWarGearByID(ctx, wargearID)
 	if err != nil {
-		return err
+		if errors.Is(err, primitive.ErrInvalidHex) || strings.Contains(err.Error(), "not found") {
+			return nil, utils.NewValidationError("wargearId", fmt.Sprintf("wargear %s not found", wargearID))
+		}
+		return nil, err
 	}
+	if err := ps.unitService.AttachWarGear(ctx, unitID, wargear.ID); err != nil {
+		return nil, err
+	}
+	return ps.GetPopulatedUnit(ctx, unitID)
+}
 
-	// Remove rule reference
-	var newRules []models.RuleReference
-	for _, ruleRef := range wargear.Rules {
-		if ruleRef.RuleID != ruleID {
-			newRules = append(newRules, ruleRef)
-		}
+// RemoveWarGearFromUnit makes a wargear item unavailable to a unit, unequipping it, and returns the updated unit
+func (ps *PopulationService) RemoveWarGearFromUnit(ctx context.Context, unitID string, wargearID string) (*models.PopulatedUnit, error) {
+	wargearObjID, err := primitive.ObjectIDFromHex(wargearID)
+	if err != nil {
+		return nil, utils.NewValidationError("wargearId", "invalid wargear ID")
+	}
+	if err := ps.unitService.DetachWarGear(ctx, unitID, wargearObjID); err != nil {
+		return nil, err
 	}
-	wargear.Rules = newRules
+	return ps.GetPopulatedUnit(ctx, unitID)
+}
 
-	// Update wargear
-	return ps.wargearService.UpdateWarGear(ctx, wargearID, wargear)
+// GetPopulatedUnit returns a unit with all its references populated
+func (ps *PopulationService) GetPopulatedUnit(ctx context.Context, unitID string) (*models.PopulatedUnit, error) {
+	unit, err := ps.unitService.GetUnitByID(ctx, unitID)
+	if err != nil {
+		return nil, err
+	}
+	return ps.PopulateUnitWithReferences(ctx, unit)
 }
@@ -0,0 +1,554 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"grimdank-database/events"
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+)
+
+// RecalculationReason is recorded on the revisions of points the
+// recalculation engine writes
+const RecalculationReason = "points recalculation"
+
+// pointsDependents lists, per collection, the fields in other collections
+// whose stored points depend on it. Units only pay for equipped weapons, so
+// weapons that are merely available don't count.
+var pointsDependents = map[string][]referenceField{
+	"rules": {
+		{collection: "weapons", array: "rules", element: "ruleId"},
+		{collection: "wargear", array: "rules", element: "ruleId"},
+		{collection: "units", array: "rules", element: "ruleId"},
+	},
+	"weapons": {
+		{collection: "units", array: "weapons", element: "weaponId"},
+	},
+	"wargear": {
+		{collection: "units", array: "warGearIds"},
+	},
+	"units": {
+		{collection: "armylists", array: "unitIds"},
+	},
+}
+
+// maxRecalculationAttempts bounds how often the points of one entity are
+// recalculated when it keeps changing under the engine
+const maxRecalculationAttempts = 3
+
+// maxWalkAttempts bounds how often a pending walk is tried as a whole. After
+// that it is walked entity by entity, and the entities that still fail are
+// logged and dropped.
+const maxWalkAttempts = 3
+
+// recalculationKey marks a context whose writes are the engine's own
+type recalculationKey struct{}
+
+// recalculationOrder lists the collections with stored points, each after
+// the ones its points are calculated from
+var recalculationOrder = []string{"weapons", "wargear", "units", "armylists"}
+
+// PointsChange is an entity whose stored points the recalculation changed
+type PointsChange struct {
+	EntityType string `json:"entityType"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	From       int    `json:"from"`
+	To         int    `json:"to"`
+}
+
+// RecalculationReport says how many entities a recalculation checked and
+// which of them it changed
+type RecalculationReport struct {
+	Checked int            `json:"checked"`
+	Changed []PointsChange `json:"changed"`
+}
+
+// RecalculationService keeps the stored points of weapons, wargear, units and
+// army lists in line with what they are calculated from:
+//   - a weapon costs what the weapon calculator makes of its stats in the current season
+//   - a wargear item costs its rules at their tiers, per model
+//   - a unit costs what UnitPointsService calculates
+//   - an army list costs the sum of its units, counting repeats
+type RecalculationService struct {
+	weaponRepo   *repositories.WeaponRepository
+	wargearRepo  *repositories.WarGearRepository
+	unitRepo     *repositories.UnitRepository
+	armyListRepo *repositories.ArmyListRepository
+	repos        map[string]*repositories.BaseRepository
+	unitPoints   *UnitPointsService
+	revisions    *RevisionService
+
+	// walking lets one walk of the graph run at a time, so a slow walk can't
+	// overwrite the points a newer one wrote
+	walking sync.Mutex
+
+	// The changes subscribers have seen but not walked yet, by workspace
+	mu       sync.Mutex
+	pending  map[string]*pendingWalk
+	draining bool
+}
+
+// pendingWalk is a set of changed entities of one workspace still to be
+// walked, with the context of the first change to them. everything means
+// the season changed, so every entity with points is walked. attempts
+// counts the times the walk has failed.
+type pendingWalk struct {
+	ctx        context.Context
+	nodes      map[graphNode]bool
+	everything bool
+	attempts   int
+}
+
+func NewRecalculationService(
+	ruleRepo *repositories.RuleRepository,
+	weaponRepo *repositories.WeaponRepository,
+	wargearRepo *repositories.WarGearRepository,
+	unitRepo *repositories.UnitRepository,
+	armyListRepo *repositories.ArmyListRepository,
+	unitPoints *UnitPointsService,
+	revisions *RevisionService,
+) *RecalculationService {
+	return &RecalculationService{
+		weaponRepo:   weaponRepo,
+		wargearRepo:  wargearRepo,
+		unitRepo:     unitRepo,
+		armyListRepo: armyListRepo,
+		repos: map[string]*repositories.BaseRepository{
+			"rules":     ruleRepo.BaseRepository,
+			"weapons":   weaponRepo.BaseRepository,
+			"wargear":   wargearRepo.BaseRepository,
+			"units":     unitRepo.BaseRepository,
+			"armylists": armyListRepo.BaseRepository,
+		},
+		unitPoints: unitPoints,
+		revisions:  revisions,
+		pending:    map[string]*pendingWalk{},
+	}
+}
+
+// graphNode is one entity in the points dependency graph
+type graphNode struct {
+	collection string
+	id         primitive.ObjectID
+}
+
+// Recalculate recalculates the points of an entity and of everything whose
+// points depend on it, directly or through other entities. An entity in the
+// trash is not recalculated itself, but what still references it is.
+func (s *RecalculationService) Recalculate(ctx context.Context, entityType, id string) (*RecalculationReport, error) {
+	repo, ok := s.repos[entityType]
+	if !ok {
+		return nil, utils.NewValidationError("entityType", fmt.Sprintf("unknown entity type %q (expected rules, weapons, wargear, units or armylists)", entityType))
+	}
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return nil, err
+	}
+	if stored, err := repo.Stored(ctx, objectID); err != nil {
+		return nil, err
+	} else if !stored {
+		return nil, utils.NewNotFoundError(fmt.Sprintf("%s %s not found", entityType, id))
+	}
+
+	affected, err := pointsDependentsOf(ctx, s.repos, []graphNode{{entityType, objectID}})
+	if err != nil {
+		return nil, err
+	}
+	return s.recalculateAll(ctx, affected)
+}
+
+// RecalculateEverything recalculates the points of every weapon, wargear
+// item, unit and army list
+func (s *RecalculationService) RecalculateEverything(ctx context.Context) (*RecalculationReport, error) {
+	affected, err := everythingWithPoints(ctx, s.repos)
+	if err != nil {
+		return nil, err
+	}
+	return s.recalculateAll(ctx, affected)
+}
+
+// pointsDependentsOf walks the points dependency graph breadth first from the
+// given entities and returns them and everything that depends on them,
+// directly or through other entities, by collection
+func pointsDependentsOf(ctx context.Context, repos map[string]*repositories.BaseRepository, from []graphNode) (map[string][]primitive.ObjectID, error) {
+	affected := map[string][]primitive.ObjectID{}
+	visited := map[graphNode]bool{}
+	queue := append([]graphNode(nil), from...)
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if visited[node] {
+			continue
+		}
+		visited[node] = true
+		affected[node.collection] = append(affected[node.collection], node.id)
+
+		for _, field := range pointsDependents[node.collection] {
+			documents, err := repos[field.collection].FindReferencing(ctx, field.path(), node.id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find %s depending on %s %s: %w", field.collection, node.collection, node.id.Hex(), err)
+			}
+			for _, document := range documents {
+				queue = append(queue, graphNode{field.collection, document.ID})
+			}
+		}
+	}
+	return affected, nil
+}
+
+// everythingWithPoints returns every weapon, wargear item, unit and army list
+// by collection
+func everythingWithPoints(ctx context.Context, repos map[string]*repositories.BaseRepository) (map[string][]primitive.ObjectID, error) {
+	affected := map[string][]primitive.ObjectID{}
+	for _, collection := range recalculationOrder {
+		var documents []repositories.DocumentSummary
+		if err := repos[collection].GetAll(ctx, bson.M{}, &documents, 0, 0); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", collection, err)
+		}
+		for _, document := range documents {
+			affected[collection] = append(affected[collection], document.ID)
+		}
+	}
+	return affected, nil
+}
+
+// recalculateAll recalculates the given entities in recalculationOrder, so
+// each is calculated from points that are already up to date. It stops at
+// the first entity that fails.
+func (s *RecalculationService) recalculateAll(ctx context.Context, affected map[string][]primitive.ObjectID) (*RecalculationReport, error) {
+	return s.recalculateEach(ctx, affected, func(node graphNode, err error) error {
+		return fmt.Errorf("failed to recalculate %s %s: %w", node.collection, node.id.Hex(), err)
+	})
+}
+
+// recalculateEach recalculates the given entities in recalculationOrder and
+// hands the error of each one that fails to failed, stopping if it returns
+// one
+func (s *RecalculationService) recalculateEach(ctx context.Context, affected map[string][]primitive.ObjectID, failed func(graphNode, error) error) (*RecalculationReport, error) {
+	s.walking.Lock()
+	defer s.walking.Unlock()
+
+	ctx = context.WithValue(WithChange(ctx, changeFrom(ctx).Actor, RecalculationReason), recalculationKey{}, true)
+	report := &RecalculationReport{Changed: []PointsChange{}}
+	for _, collection := range recalculationOrder {
+		for _, id := range affected[collection] {
+			if err := s.recalculate(ctx, collection, id, report); err != nil {
+				if err := failed(graphNode{collection, id}, err); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// recalculate stores the calculated points of one entity if they differ
+// from its stored points. Entities in the trash are skipped. The points are
+// written only if the entity is still at the version they were calculated
+// from; otherwise they are calculated again.
+func (s *RecalculationService) recalculate(ctx context.Context, collection string, id primitive.ObjectID, report *RecalculationReport) error {
+	for attempt := 1; ; attempt++ {
+		current, err := s.points(ctx, collection, id)
+		if err != nil {
+			if utils.IsNotFoundError(err) {
+				return nil
+			}
+			return err
+		}
+
+		if attempt == 1 {
+			report.Checked++
+		}
+		if current.calculated == current.stored {
+			return nil
+		}
+
+		err = s.revisions.Track(ctx, collection, id.Hex(), RevisionUpdated, func(ctx context.Context) error {
+			_, err := s.repos[collection].UpdateVersioned(ctx, id, current.version, bson.M{"points": current.calculated})
+			return err
+		})
+		if utils.IsVersionConflictError(err) && attempt < maxRecalculationAttempts {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		report.Changed = append(report.Changed, PointsChange{EntityType: collection, ID: id.Hex(), Name: current.name, From: current.stored, To: current.calculated})
+		return nil
+	}
+}
+
+// entityPoints is an entity's stored points, at the version they were read
+// at, and its calculated points
+type entityPoints struct {
+	name       string
+	version    int
+	stored     int
+	calculated int
+}
+
+// points returns an entity's stored and calculated points
+func (s *RecalculationService) points(ctx context.Context, collection string, id primitive.ObjectID) (entityPoints, error) {
+	switch collection {
+	case "weapons":
+		weapon, err := s.weaponRepo.GetWeaponByID(ctx, id.Hex())
+		if err != nil {
+			return entityPoints{}, err
+		}
+		calculated := seasonWeaponPoints(s.unitPoints.season())(ctx, s.unitPoints, weapon, 0)
+		return entityPoints{weapon.Name, weapon.Version, weapon.Points, calculated}, nil
+
+	case "wargear":
+		wargear, err := s.wargearRepo.GetWarGearByID(ctx, id.Hex())
+		if err != nil {
+			return entityPoints{}, err
+		}
+		return entityPoints{wargear.Name, wargear.Version, wargear.Points, wargearPoints(ctx, s.unitPoints, wargear)}, nil
+
+	case "units":
+		unit, err := s.unitRepo.GetUnitByID(ctx, id.Hex())
+		if err != nil {
+			return entityPoints{}, err
+		}
+		breakdown, err := s.unitPoints.CalculateUnitPoints(ctx, unit)
+		if err != nil {
+			return entityPoints{}, err
+		}
+		return entityPoints{unit.Name, unit.Version, unit.Points, breakdown.TotalPoints}, nil
+
+	case "armylists":
+		armyList, err := s.armyListRepo.GetArmyListByID(ctx, id.Hex())
+		if err != nil {
+			return entityPoints{}, err
+		}
+		units, err := s.unitRepo.GetUnitsByIDs(ctx, armyList.Units)
+		if err != nil {
+			return entityPoints{}, err
+		}
+		unitPoints := make(map[primitive.ObjectID]int, len(units))
+		for _, unit := range units {
+			unitPoints[unit.ID] = unit.Points
+		}
+		// A list pays for a unit each time it fields it
+		calculated := 0
+		for _, unitID := range armyList.Units {
+			calculated += unitPoints[unitID]
+		}
+		return entityPoints{armyList.Name, armyList.Version, armyList.Points, calculated}, nil
+	}
+	return entityPoints{}, fmt.Errorf("%s have no points", collection)
+}
+
+// wargearPoints returns what a wargear item costs: its rules at their tiers.
+// Missing rules cost nothing, as they do for units.
+func wargearPoints(ctx context.Context, sources pointsSources, wargear *models.WarGear) int {
+	points := 0
+	for _, ruleRef := range wargear.Rules {
+		rule, err := sources.rule(ctx, ruleRef.RuleID)
+		if err != nil {
+			continue
+		}
+		points += tierPoints(rule, ruleRef.Tier)
+	}
+	return points
+}
+
+// Subscribe recalculates what depends on an entity whenever the services
+// change it, and everything in every workspace when the current points
+// season changes. Recalculations run in the background, so writes don't wait
+// for them, and the changes they make themselves are not recalculated again.
+// Changes that arrive while a walk is running are merged into the next one,
+// so a burst of edits costs a few walks rather than one each.
+func (s *RecalculationService) Subscribe(bus *events.Bus) {
+	events.Subscribe(bus, "season-recalculation", func(ctx context.Context, event events.SeasonChanged) error {
+		workspaces, err := s.workspacesWithPoints(ctx)
+		if err != nil {
+			return err
+		}
+		drain := false
+		for _, workspace := range workspaces {
+			if s.queue(repositories.WithWorkspace(ctx, workspace), true) {
+				drain = true
+			}
+		}
+		if !drain {
+			return nil
+		}
+		return s.drain()
+	}, events.Async(), events.WithRetry(3, time.Second))
+
+	bus.SubscribeAll("points-recalculation", func(ctx context.Context, event events.Event) error {
+		if ctx.Value(recalculationKey{}) != nil {
+			return nil
+		}
+		change := event.Change()
+		if _, ok := s.repos[change.EntityType]; !ok {
+			return nil
+		}
+		if !s.queue(ctx, false, graphNode{change.EntityType, change.EntityID}) {
+			return nil
+		}
+		return s.drain()
+	}, events.Async(), events.WithRetry(3, time.Second))
+}
+
+// workspacesWithPoints returns the workspaces that have anything with points
+func (s *RecalculationService) workspacesWithPoints(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	var workspaces []string
+	for _, collection := range recalculationOrder {
+		var documents []struct {
+			Workspace string `bson:"workspace"`
+		}
+		if err := s.repos[collection].GetAll(repositories.AllWorkspaces(ctx), bson.M{}, &documents, 0, 0); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", collection, err)
+		}
+		for _, document := range documents {
+			workspace := repositories.WorkspaceOf(document.Workspace)
+			if !seen[workspace] {
+				seen[workspace] = true
+				workspaces = append(workspaces, workspace)
+			}
+		}
+	}
+	return workspaces, nil
+}
+
+// queue adds changed entities to the pending walk of ctx's workspace, or
+// every entity with points if everything is set. It reports whether the
+// caller should drain the pending walks, which it should unless another
+// subscriber is already doing so.
+func (s *RecalculationService) queue(ctx context.Context, everything bool, nodes ...graphNode) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	workspace := repositories.WorkspaceFrom(ctx)
+	walk, ok := s.pending[workspace]
+	if !ok {
+		walk = &pendingWalk{ctx: ctx, nodes: map[graphNode]bool{}}
+		s.pending[workspace] = walk
+	}
+	walk.everything = walk.everything || everything
+	for _, node := range nodes {
+		walk.nodes[node] = true
+	}
+
+	if s.draining {
+		return false
+	}
+	s.draining = true
+	return true
+}
+
+// drain walks the pending changes until none are left. A walk that fails is
+// put back, to be tried again by the retry of the event that failed or with
+// the next change. After maxWalkAttempts it is isolated instead, so entities
+// that can't be recalculated don't hold back the changes merged with them.
+func (s *RecalculationService) drain() error {
+	for {
+		s.mu.Lock()
+		var walk *pendingWalk
+		for workspace, pending := range s.pending {
+			walk = pending
+			delete(s.pending, workspace)
+			break
+		}
+		if walk == nil {
+			s.draining = false
+		}
+		s.mu.Unlock()
+		if walk == nil {
+			return nil
+		}
+
+		nodes := make([]graphNode, 0, len(walk.nodes))
+		for node := range walk.nodes {
+			nodes = append(nodes, node)
+		}
+		var affected map[string][]primitive.ObjectID
+		var err error
+		if walk.everything {
+			affected, err = everythingWithPoints(walk.ctx, s.repos)
+		} else {
+			affected, err = pointsDependentsOf(walk.ctx, s.repos, nodes)
+		}
+		if err == nil {
+			_, err = s.recalculateAll(walk.ctx, affected)
+		}
+		if err == nil {
+			continue
+		}
+		walk.attempts++
+		if walk.attempts < maxWalkAttempts {
+			s.requeue(walk)
+			return err
+		}
+		s.isolate(walk, nodes)
+	}
+}
+
+// requeue puts a failed walk back, merging the changes queued since into it
+func (s *RecalculationService) requeue(walk *pendingWalk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	workspace := repositories.WorkspaceFrom(walk.ctx)
+	if pending, ok := s.pending[workspace]; ok {
+		walk.everything = walk.everything || pending.everything
+		for node := range pending.nodes {
+			walk.nodes[node] = true
+		}
+	}
+	s.pending[workspace] = walk
+	s.draining = false
+}
+
+// isolate walks a walk that keeps failing one changed entity and one
+// dependent at a time, logging and dropping the ones that still fail so the
+// others are recalculated
+func (s *RecalculationService) isolate(walk *pendingWalk, nodes []graphNode) {
+	workspace := repositories.WorkspaceFrom(walk.ctx)
+	drop := func(what string, err error) {
+		log.Printf("⚠️ Dropping points recalculation of %s in workspace %s after %d attempts: %v", what, workspace, walk.attempts, err)
+	}
+
+	affected := map[string][]primitive.ObjectID{}
+	if walk.everything {
+		var err error
+		if affected, err = everythingWithPoints(walk.ctx, s.repos); err != nil {
+			drop("everything", err)
+			return
+		}
+	} else {
+		seen := map[graphNode]bool{}
+		for _, node := range nodes {
+			dependents, err := pointsDependentsOf(walk.ctx, s.repos, []graphNode{node})
+			if err != nil {
+				drop(fmt.Sprintf("what depends on %s %s", node.collection, node.id.Hex()), err)
+				continue
+			}
+			for collection, ids := range dependents {
+				for _, id := range ids {
+					if !seen[graphNode{collection, id}] {
+						seen[graphNode{collection, id}] = true
+						affected[collection] = append(affected[collection], id)
+					}
+				}
+			}
+		}
+	}
+
+	s.recalculateEach(walk.ctx, affected, func(node graphNode, err error) error {
+		drop(fmt.Sprintf("%s %s", node.collection, node.id.Hex()), err)
+		return nil
+	})
+}
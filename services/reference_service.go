@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DeletePolicy decides what happens to documents that still reference one being deleted
+type DeletePolicy string
+
+const (
+	// DeletePolicyReject refuses the delete and reports the referencing documents
+	DeletePolicyReject DeletePolicy = "reject"
+	// DeletePolicyCascade deletes the referencing documents as well
+	DeletePolicyCascade DeletePolicy = "cascade"
+	// DeletePolicyDetach removes the references and keeps the referencing documents
+	DeletePolicyDetach DeletePolicy = "detach"
+)
+
+// ParseDeletePolicy reads a delete policy, defaulting to reject when empty
+func ParseDeletePolicy(value string) (DeletePolicy, error) {
+	switch policy := DeletePolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case "":
+		return DeletePolicyReject, nil
+	case DeletePolicyReject, DeletePolicyCascade, DeletePolicyDetach:
+		return policy, nil
+	default:
+		return "", utils.NewValidationError("policy", fmt.Sprintf("unknown delete policy %q (expected reject, cascade or detach)", value))
+	}
+}
+
+// Reference is a document that points at another document through one of its fields
+type Reference struct {
+	Collection string `json:"collection"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Field      string `json:"field"`
+}
+
+// ReferencedError is returned when a delete is rejected because other documents still reference the target
+type ReferencedError struct {
+	Collection string
+	ID         string
+	References []Reference
+}
+
+func (e ReferencedError) Error() string {
+	return fmt.Sprintf("%s %s is still referenced by %d document(s)", e.Collection, e.ID, len(e.References))
+}
+
+// IsReferencedError checks if an error is a rejected delete of a referenced document
+func IsReferencedError(err error) bool {
+	var referencedErr ReferencedError
+	return errors.As(err, &referencedErr)
+}
+
+// DeleteReport describes what a delete did to the documents that referenced its target
+type DeleteReport struct {
+	Policy   DeletePolicy `json:"policy"`
+	Detached []Reference  `json:"detached,omitempty"`
+	Cascaded []Reference  `json:"cascaded,omitempty"`
+}
+
+// referenceField is an array field in one collection that holds IDs of another
+type referenceField struct {
+	collection string // collection holding the references
+	array      string // array field holding the references
+	element    string // field of the array's sub-documents holding the ID, empty for plain ID arrays
+}
+
+func (f referenceField) path() string {
+	if f.element == "" {
+		return f.array
+	}
+	return f.array + "." + f.element
+}
+
+// inboundReferences lists, per collection, the fields in other collections that point at it
+var inboundReferences = map[string][]referenceField{
+	"rules": {
+		{collection: "weapons", array: "rules", element: "ruleId"},
+		{collection: "wargear", array: "rules", element: "ruleId"},
+		{collection: "units", array: "rules", element: "ruleId"},
+		{collection: "armybooks", array: "rules", element: "ruleId"},
+	},
+	"weapons": {
+		{collection: "units", array: "availableWeaponIds"},
+		{collection: "units", array: "weapons", element: "weaponId"},
+	},
+	"wargear": {
+		{collection: "units", array: "availableWarGearIds"},
+		{collection: "units", array: "warGearIds"},
+	},
+	"units": {
+		{collection: "armybooks", array: "unitIds"},
+		{collection: "armylists", array: "unitIds"},
+	},
+}
+
+// ReferenceService keeps references between collections intact when documents are deleted
+type ReferenceService struct {
+	repos      map[string]*repositories.BaseRepository
+	revisions  *RevisionService
+	unitOfWork UnitOfWork
+}
+
+func NewReferenceService(
+	ruleRepo *repositories.RuleRepository,
+	weaponRepo *repositories.WeaponRepository,
+	wargearRepo *repositories.WarGearRepository,
+	unitRepo *repositories.UnitRepository,
+	armyBookRepo *repositories.ArmyBookRepository,
+	armyListRepo *repositories.ArmyListRepository,
+	revisions *RevisionService,
+	unitOfWork UnitOfWork,
+) *ReferenceService {
+	return &ReferenceService{
+		revisions:  revisions,
+		unitOfWork: unitOfWork,
+		repos: map[string]*repositories.BaseRepository{
+			"rules":     ruleRepo.BaseRepository,
+			"weapons":   weaponRepo.BaseRepository,
+			"wargear":   wargearRepo.BaseRepository,
+			"units":     unitRepo.BaseRepository,
+			"armybooks": armyBookRepo.BaseRepository,
+			"armylists": armyListRepo.BaseRepository,
+		},
+	}
+}
+
+// FindReferences lists the live documents that reference the given document
+func (s *ReferenceService) FindReferences(ctx context.Context, collection string, id primitive.ObjectID) ([]Reference, error) {
+	references := []Reference{}
+	for _, field := range inboundReferences[collection] {
+		documents, err := s.repos[field.collection].FindReferencing(ctx, field.path(), id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s for references: %w", field.collection, err)
+		}
+		for _, document := range documents {
+			references = append(references, Reference{
+				Collection: field.collection,
+				ID:         document.ID.Hex(),
+				Name:       document.Name,
+				Field:      field.path(),
+			})
+		}
+	}
+	return references, nil
+}
+
+// Delete deletes a document, applying policy to any documents that still
+// reference it. The delete and everything it detaches or cascades to happen
+// in one unit of work, so a failure part way leaves every document as it was.
+func (s *ReferenceService) Delete(ctx context.Context, collection, id string, policy DeletePolicy) (*DeleteReport, error) {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var report *DeleteReport
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		// A transaction can be retried, so start each attempt with a fresh report
+		report = &DeleteReport{Policy: policy}
+		return s.delete(ctx, collection, objectID, policy, report, map[string]bool{}, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// PreviewDelete reports what Delete would do with the same policy without
+// writing anything. A delete that would be rejected returns the same
+// ReferencedError.
+func (s *ReferenceService) PreviewDelete(ctx context.Context, collection, id string, policy DeletePolicy) (*DeleteReport, error) {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeleteReport{Policy: policy}
+	if err := s.delete(ctx, collection, objectID, policy, report, map[string]bool{}, false); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// delete deletes a document and applies policy to its references. With write
+// unset it only works out the report.
+func (s *ReferenceService) delete(ctx context.Context, collection string, id primitive.ObjectID, policy DeletePolicy, report *DeleteReport, visited map[string]bool, write bool) error {
+	repo, ok := s.repos[collection]
+	if !ok {
+		return fmt.Errorf("unknown collection %q", collection)
+	}
+
+	key := collection + "/" + id.Hex()
+	if visited[key] {
+		return nil
+	}
+	visited[key] = true
+
+	var existing repositories.DocumentSummary
+	if err := repo.GetByID(ctx, id, &existing); err != nil {
+		return err
+	}
+
+	references, err := s.FindReferences(ctx, collection, id)
+	if err != nil {
+		return err
+	}
+
+	if len(references) > 0 {
+		switch policy {
+		case DeletePolicyReject:
+			return ReferencedError{Collection: collection, ID: id.Hex(), References: references}
+
+		case DeletePolicyDetach:
+			// A document can reference the target through several fields; update each document once
+			type document struct{ collection, id string }
+			var order []document
+			fields := map[document][]repositories.ReferenceField{}
+			for _, reference := range references {
+				doc := document{reference.Collection, reference.ID}
+				if _, seen := fields[doc]; !seen {
+					order = append(order, doc)
+				}
+				fields[doc] = append(fields[doc], referenceFieldFor(collection, reference))
+			}
+
+			if write {
+				for _, doc := range order {
+					documentID, _ := primitive.ObjectIDFromHex(doc.id)
+					before, err := s.repos[doc.collection].Snapshot(ctx, documentID)
+					if err != nil {
+						return err
+					}
+					if err := s.repos[doc.collection].PullReferences(ctx, documentID, id, fields[doc]); err != nil {
+						return fmt.Errorf("failed to detach %s from %s %s: %w", key, doc.collection, doc.id, err)
+					}
+					if err := s.revisions.Record(ctx, doc.collection, documentID, RevisionUpdated, before); err != nil {
+						return err
+					}
+				}
+			}
+			report.Detached = append(report.Detached, references...)
+
+		case DeletePolicyCascade:
+			for _, reference := range references {
+				referenceKey := reference.Collection + "/" + reference.ID
+				if visited[referenceKey] {
+					continue
+				}
+				documentID, _ := primitive.ObjectIDFromHex(reference.ID)
+				if err := s.delete(ctx, reference.Collection, documentID, policy, report, visited, write); err != nil {
+					return fmt.Errorf("failed to cascade delete to %s %s: %w", reference.Collection, reference.ID, err)
+				}
+				report.Cascaded = append(report.Cascaded, reference)
+			}
+
+		default:
+			return fmt.Errorf("unknown delete policy %q", policy)
+		}
+	}
+
+	if !write {
+		return nil
+	}
+	before, err := repo.Snapshot(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.revisions.Record(ctx, collection, id, RevisionDeleted, before)
+}
+
+// referenceFieldFor returns the array field a reference to the target collection was found in
+func referenceFieldFor(target string, reference Reference) repositories.ReferenceField {
+	for _, field := range inboundReferences[target] {
+		if field.collection == reference.Collection && field.path() == reference.Field {
+			return repositories.ReferenceField{Array: field.array, Element: field.element}
+		}
+	}
+	return repositories.ReferenceField{Array: reference.Field}
+}
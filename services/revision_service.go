@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"grimdank-database/database"
+	"grimdank-database/events"
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RevisionAction says what kind of change produced a revision
+type RevisionAction string
+
+const (
+	RevisionCreated  RevisionAction = "create"
+	RevisionUpdated  RevisionAction = "update"
+	RevisionDeleted  RevisionAction = "delete"
+	RevisionReverted RevisionAction = "revert"
+	RevisionRestored RevisionAction = "restore"
+)
+
+// eventAction is the kind of domain event a revision action announces
+func (a RevisionAction) eventAction() events.Action {
+	switch a {
+	case RevisionCreated:
+		return events.ActionCreated
+	case RevisionDeleted:
+		return events.ActionDeleted
+	}
+	return events.ActionUpdated
+}
+
+// AnonymousActor is recorded for changes made without naming who made them
+const AnonymousActor = "anonymous"
+
+type changeKey struct{}
+
+// Change describes who is making the changes of a request and why
+type Change struct {
+	Actor  string
+	Reason string
+}
+
+// WithChange returns a context whose writes are recorded as made by actor for reason
+func WithChange(ctx context.Context, actor, reason string) context.Context {
+	return context.WithValue(ctx, changeKey{}, Change{Actor: actor, Reason: reason})
+}
+
+// changeFrom returns the change ctx carries, with an anonymous actor if it carries none
+func changeFrom(ctx context.Context) Change {
+	change, _ := ctx.Value(changeKey{}).(Change)
+	if change.Actor == "" {
+		change.Actor = AnonymousActor
+	}
+	return change
+}
+
+// FieldChange is a field that differs between two revisions. Nested fields
+// are named by their dotted path; arrays are compared as a whole. From or To
+// is null when the field is missing from that revision.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// RevisionDiff lists the fields that changed between two revisions of an entity
+type RevisionDiff struct {
+	EntityType string        `json:"entityType"`
+	EntityID   string        `json:"entityId"`
+	From       int           `json:"from"`
+	To         int           `json:"to"`
+	Changes    []FieldChange `json:"changes"`
+}
+
+// RevisionService records the revision history of entities and reverts them
+// to earlier revisions. Every write the services make goes through it, so it
+// also announces each change on the event bus once the write has committed.
+type RevisionService struct {
+	repo       *repositories.RevisionRepository
+	entities   map[string]*repositories.BaseRepository
+	unitOfWork UnitOfWork
+	bus        *events.Bus
+}
+
+func NewRevisionService(
+	revisionRepo *repositories.RevisionRepository,
+	ruleRepo *repositories.RuleRepository,
+	weaponRepo *repositories.WeaponRepository,
+	wargearRepo *repositories.WarGearRepository,
+	unitRepo *repositories.UnitRepository,
+	armyBookRepo *repositories.ArmyBookRepository,
+	armyListRepo *repositories.ArmyListRepository,
+	factionRepo *repositories.FactionRepository,
+	unitOfWork UnitOfWork,
+	bus *events.Bus,
+) *RevisionService {
+	return &RevisionService{
+		repo: revisionRepo,
+		entities: map[string]*repositories.BaseRepository{
+			"rules":     ruleRepo.BaseRepository,
+			"weapons":   weaponRepo.BaseRepository,
+			"wargear":   wargearRepo.BaseRepository,
+			"units":     unitRepo.BaseRepository,
+			"armybooks": armyBookRepo.BaseRepository,
+			"armylists": armyListRepo.BaseRepository,
+			"factions":  factionRepo.BaseRepository,
+		},
+		unitOfWork: unitOfWork,
+		bus:        bus,
+	}
+}
+
+// EntityTypes returns the entity types that keep a history, in alphabetical order
+func (s *RevisionService) EntityTypes() []string {
+	types := make([]string, 0, len(s.entities))
+	for entityType := range s.entities {
+		types = append(types, entityType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Track runs write, which changes the entity with the given ID, and records
+// the revision it produces. Both happen in one unit of work, so a change is
+// never kept without its revision.
+func (s *RevisionService) Track(ctx context.Context, entityType, id string, action RevisionAction, write func(ctx context.Context) error) error {
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+	entities, err := s.entitiesFor(entityType)
+	if err != nil {
+		return err
+	}
+	return s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		before, err := entities.Snapshot(ctx, objectID)
+		if err != nil {
+			return err
+		}
+		if err := write(ctx); err != nil {
+			return err
+		}
+		return s.Record(ctx, entityType, objectID, action, before)
+	})
+}
+
+// TrackCreate runs create, which returns the ID of the entity it creates, and
+// records the first revision of the new entity in the same unit of work
+func (s *RevisionService) TrackCreate(ctx context.Context, entityType string, create func(ctx context.Context) (primitive.ObjectID, error)) error {
+	return s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		id, err := create(ctx)
+		if err != nil {
+			return err
+		}
+		return s.Record(ctx, entityType, id, RevisionCreated, nil)
+	})
+}
+
+// RecordImported records the first revision of imported entities. Imports
+// keep what they inserted before a failure, so this is called with those IDs
+// too and does not run in a unit of work of its own.
+func (s *RevisionService) RecordImported(ctx context.Context, entityType string, ids []string) error {
+	for _, id := range ids {
+		objectID, err := utils.ParseObjectID(id)
+		if err != nil {
+			return err
+		}
+		if err := s.Record(ctx, entityType, objectID, RevisionCreated, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record saves the entity as it is stored now as a new revision, attributed
+// to the actor and reason carried by ctx, and publishes the matching event
+// once the unit of work ctx belongs to commits. before is the entity as it
+// was stored before the change, or nil for a create.
+func (s *RevisionService) Record(ctx context.Context, entityType string, id primitive.ObjectID, action RevisionAction, before bson.M) error {
+	entities, err := s.entitiesFor(entityType)
+	if err != nil {
+		return err
+	}
+
+	document, err := entities.Snapshot(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to read %s %s for its revision: %w", entityType, id.Hex(), err)
+	}
+
+	change := changeFrom(ctx)
+	revision := &models.Revision{
+		EntityType: entityType,
+		EntityID:   id,
+		Version:    repositories.DocumentVersion(document),
+		Action:     string(action),
+		Actor:      change.Actor,
+		Reason:     change.Reason,
+		Timestamp:  time.Now().UTC(),
+		Document:   document,
+	}
+	if err := s.repo.CreateRevision(ctx, revision); err != nil {
+		return fmt.Errorf("failed to record revision of %s %s: %w", entityType, id.Hex(), err)
+	}
+
+	meta := events.Meta{
+		EntityType: entityType,
+		EntityID:   id,
+		Version:    revision.Version,
+		Actor:      revision.Actor,
+		Reason:     revision.Reason,
+		At:         revision.Timestamp,
+	}
+	event, err := events.FromDocuments(action.eventAction(), meta, before, document)
+	if err != nil {
+		return fmt.Errorf("failed to build event for %s %s: %w", entityType, id.Hex(), err)
+	}
+	database.AfterCommit(ctx, func(ctx context.Context) {
+		s.bus.Publish(ctx, event)
+	})
+	return nil
+}
+
+// ListRevisions returns the revisions of an entity, newest first
+func (s *RevisionService) ListRevisions(ctx context.Context, entityType, id string, limit, skip int64) ([]models.Revision, error) {
+	if _, err := s.entitiesFor(entityType); err != nil {
+		return nil, err
+	}
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListRevisions(ctx, entityType, objectID, limit, skip)
+}
+
+// GetRevision returns the revision of an entity at one version
+func (s *RevisionService) GetRevision(ctx context.Context, entityType, id string, version int) (*models.Revision, error) {
+	if _, err := s.entitiesFor(entityType); err != nil {
+		return nil, err
+	}
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevision(ctx, entityType, objectID, version)
+}
+
+// Diff compares two revisions of an entity field by field
+func (s *RevisionService) Diff(ctx context.Context, entityType, id string, from, to int) (*RevisionDiff, error) {
+	fromRevision, err := s.GetRevision(ctx, entityType, id, from)
+	if err != nil {
+		return nil, fmt.Errorf("version %d: %w", from, err)
+	}
+	toRevision, err := s.GetRevision(ctx, entityType, id, to)
+	if err != nil {
+		return nil, fmt.Errorf("version %d: %w", to, err)
+	}
+
+	return &RevisionDiff{
+		EntityType: entityType,
+		EntityID:   id,
+		From:       from,
+		To:         to,
+		Changes:    diffDocuments(fromRevision.Document, toRevision.Document),
+	}, nil
+}
+
+// Revert puts an entity back the way it was at an earlier version, restoring
+// it from the trash if it was deleted since. The revert is itself a new
+// revision, so it can be reverted in turn.
+func (s *RevisionService) Revert(ctx context.Context, entityType, id string, version int) (*models.Revision, error) {
+	target, err := s.GetRevision(ctx, entityType, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if target.Action == string(RevisionDeleted) {
+		return nil, utils.NewValidationError("version", fmt.Sprintf("version %d is a deletion; revert to a version before it", version))
+	}
+
+	entities := s.entities[entityType]
+	change := changeFrom(ctx)
+	if change.Reason == "" {
+		ctx = WithChange(ctx, change.Actor, fmt.Sprintf("revert to version %d", version))
+	}
+
+	var newVersion int
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		before, err := entities.Snapshot(ctx, target.EntityID)
+		if err != nil {
+			return err
+		}
+		newVersion, err = entities.Revert(ctx, target.EntityID, target.Document)
+		if err != nil {
+			return err
+		}
+		return s.Record(ctx, entityType, target.EntityID, RevisionReverted, before)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevision(ctx, entityType, target.EntityID, newVersion)
+}
+
+func (s *RevisionService) entitiesFor(entityType string) (*repositories.BaseRepository, error) {
+	entities, ok := s.entities[entityType]
+	if !ok {
+		return nil, utils.NewValidationError("type", fmt.Sprintf("unknown entity type %q, use one of %s", entityType, strings.Join(s.EntityTypes(), ", ")))
+	}
+	return entities, nil
+}
+
+// diffDocuments lists the fields that differ between two documents, in field
+// order. The version and updatedAt always differ and are left out.
+func diffDocuments(from, to bson.M) []FieldChange {
+	fromFields := flattenDocument("", from, map[string]interface{}{})
+	toFields := flattenDocument("", to, map[string]interface{}{})
+	for _, field := range []string{"version", "updatedAt"} {
+		delete(fromFields, field)
+		delete(toFields, field)
+	}
+
+	fields := make([]string, 0, len(fromFields)+len(toFields))
+	for field := range fromFields {
+		fields = append(fields, field)
+	}
+	for field := range toFields {
+		if _, seen := fromFields[field]; !seen {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []FieldChange{}
+	for _, field := range fields {
+		if !reflect.DeepEqual(fromFields[field], toFields[field]) {
+			changes = append(changes, FieldChange{Field: field, From: fromFields[field], To: toFields[field]})
+		}
+	}
+	return changes
+}
+
+// flattenDocument collects the leaf fields of a document under their dotted paths
+func flattenDocument(prefix string, document bson.M, fields map[string]interface{}) map[string]interface{} {
+	for key, value := range document {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		switch nested := value.(type) {
+		case bson.M:
+			flattenDocument(path, nested, fields)
+		case bson.D:
+			asMap := bson.M{}
+			for _, element := range nested {
+				asMap[element.Key] = element.Value
+			}
+			flattenDocument(path, asMap, fields)
+		default:
+			fields[path] = value
+		}
+	}
+	return fields
+}
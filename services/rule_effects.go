@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"grimdank-database/models"
+	"grimdank-database/utils"
+)
+
+// ruleEffectStats are the stats rule effects can change: the unit profile
+// stats, movement and charge distance, and the weapon profile stats
+var ruleEffectStats = map[string]bool{
+	"defense": true, "ranged": true, "melee": true, "morale": true,
+	"speed": true, "charge": true,
+	"ap": true, "attacks": true, "range": true,
+}
+
+// triggerName is what a trigger looks like, e.g. once_per_game
+var triggerName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ValidateRuleEffects checks that every effect changes a known stat by an
+// amount that can be worked out for each tier, or names a trigger
+func ValidateRuleEffects(effects []models.RuleEffect) error {
+	for i, effect := range effects {
+		field := fmt.Sprintf("effects[%d]", i)
+		if effect.Trigger != "" && !triggerName.MatchString(effect.Trigger) {
+			return utils.NewValidationError(field+".trigger", "triggers are lowercase words joined by underscores, e.g. once_per_game")
+		}
+		if effect.Stat == "" {
+			if effect.Trigger == "" || effect.Modifier != "" || effect.Add != "" || effect.Aura {
+				return utils.NewValidationError(field, "an effect changes a stat, or is only a trigger")
+			}
+			continue
+		}
+		if !ruleEffectStats[strings.ToLower(effect.Stat)] {
+			return utils.NewValidationError(field+".stat", fmt.Sprintf("unknown stat %q (expected Defense, Ranged, Melee, Morale, Speed, Charge, AP, Attacks or Range)", effect.Stat))
+		}
+		if (effect.Modifier == "") == (effect.Add == "") {
+			return utils.NewValidationError(field, "an effect on a stat has either a modifier or an add")
+		}
+		if _, err := effectAmount(effect, 1); err != nil {
+			return utils.NewValidationError(field, err.Error())
+		}
+	}
+	return nil
+}
+
+// effectAmount returns how much an effect changes its stat at a tier
+func effectAmount(effect models.RuleEffect, tier int) (float64, error) {
+	expression := effect.Modifier
+	if expression == "" {
+		expression = effect.Add
+	}
+	return evaluateTierExpression(expression, tier)
+}
+
+// evaluateTierExpression works out an amount such as "+tier", "-1" or
+// "6*tier" at a tier
+func evaluateTierExpression(expression string, tier int) (float64, error) {
+	text := strings.ReplaceAll(expression, " ", "")
+	sign := 1.0
+	if strings.HasPrefix(text, "+") {
+		text = text[1:]
+	} else if strings.HasPrefix(text, "-") {
+		sign = -1
+		text = text[1:]
+	}
+	if text == "" {
+		return 0, fmt.Errorf("%q is not an amount", expression)
+	}
+
+	value := sign
+	for _, factor := range strings.Split(text, "*") {
+		if strings.EqualFold(factor, "tier") {
+			value *= float64(tier)
+			continue
+		}
+		number, err := strconv.ParseFloat(factor, 64)
+		if err != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+			return 0, fmt.Errorf("%q is not an amount: use numbers and tier joined by *", expression)
+		}
+		value *= number
+	}
+	return value, nil
+}
+
+// EffectCost is what one effect of a rule costs at each tier, before the
+// rule's own triggers
+type EffectCost struct {
+	Effect models.RuleEffect `json:"effect"`
+	Points []float64         `json:"points"`
+}
+
+// CalculateRulePoints prices a rule from its effects when it has any, and
+// from its name and description otherwise
+func (pc *PointsCalculator) CalculateRulePoints(rule *models.Rule) []int {
+	if len(rule.Effects) == 0 {
+		return pc.CalculatePointsFromDescription(rule.Name, rule.Description)
+	}
+	costs, multiplier := pc.effectCosts(rule.Effects)
+	return effectTierPoints(costs, multiplier)
+}
+
+// effectCosts prices each effect that changes a stat at each tier and returns
+// them with the multiplier of the triggers that limit the whole rule. An
+// effect is priced by the size of the change, whichever way the rulebook words
+// it: a -1 to Morale rolls is as much of a benefit as a +1 to Defense rolls.
+func (pc *PointsCalculator) effectCosts(effects []models.RuleEffect) ([]EffectCost, float64) {
+	defaults := DefaultPointsCalculatorConfig()
+	multiplier := 1.0
+	var costs []EffectCost
+	for _, effect := range effects {
+		if effect.Stat == "" {
+			multiplier *= pc.triggerMultiplier(effect.Trigger, defaults)
+			continue
+		}
+
+		weight, ok := pc.config.EffectWeights[strings.ToLower(effect.Stat)]
+		if !ok {
+			weight = defaults.EffectWeights[strings.ToLower(effect.Stat)]
+		}
+		if effect.Aura {
+			aura := pc.config.AuraMultiplier
+			if aura == 0 {
+				aura = defaults.AuraMultiplier
+			}
+			weight *= aura
+		}
+		if effect.Trigger != "" {
+			weight *= pc.triggerMultiplier(effect.Trigger, defaults)
+		}
+
+		cost := EffectCost{Effect: effect, Points: make([]float64, 3)}
+		for tier := 1; tier <= 3; tier++ {
+			// Invalid amounts are rejected when rules are saved, so they cost nothing here
+			amount, _ := effectAmount(effect, tier)
+			cost.Points[tier-1] = math.Abs(amount) * weight
+		}
+		costs = append(costs, cost)
+	}
+	return costs, multiplier
+}
+
+// triggerMultiplier returns the share of the cost paid for an effect with a
+// trigger. Triggers the season doesn't know are priced as conditional.
+func (pc *PointsCalculator) triggerMultiplier(trigger string, defaults *PointsCalculatorConfig) float64 {
+	for _, multipliers := range []map[string]float64{pc.config.TriggerMultipliers, defaults.TriggerMultipliers} {
+		if multiplier, ok := multipliers[trigger]; ok {
+			return multiplier
+		}
+	}
+	return pc.getFrequencyMultiplier("conditional")
+}
+
+// effectTierPoints sums the effect costs per tier, applies the rule's
+// triggers and rounds, keeping each tier between 1 and 75 points as the text
+// calculation does
+func effectTierPoints(costs []EffectCost, multiplier float64) []int {
+	points := make([]int, 3)
+	for tier := range points {
+		total := 0.0
+		for _, cost := range costs {
+			total += cost.Points[tier]
+		}
+		points[tier] = int(math.Round(total * multiplier))
+		if points[tier] < 1 {
+			points[tier] = 1
+		}
+		if points[tier] > 75 {
+			points[tier] = 75
+		}
+	}
+	return points
+}
@@ -2,13 +2,14 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"grimdank-database/models"
 )
 
 // RulePointsService handles points calculation for rules
 type RulePointsService struct {
-	pointsCalculator *PointsCalculator
-	ruleService      *RuleService
+	ruleService *RuleService
+	seasons     *PointsSeasonService
 }
 
 // GetRuleService returns the rule service (for internal use)
@@ -19,20 +20,30 @@ func (rps *RulePointsService) GetRuleService() *RuleService {
 // NewRulePointsService creates a new rule points service
 func NewRulePointsService(ruleService *RuleService) *RulePointsService {
 	return &RulePointsService{
-		pointsCalculator: NewPointsCalculator(),
-		ruleService:      ruleService,
+		ruleService: ruleService,
 	}
 }
 
-// CalculateRulePoints calculates points for a rule based on its content
+// UseSeasons makes the service calculate in the current points season
+// rather than the built-in one
+func (rps *RulePointsService) UseSeasons(seasons *PointsSeasonService) {
+	rps.seasons = seasons
+}
+
+// pointsCalculator returns a calculator with the current season's keywords and thresholds
+func (rps *RulePointsService) pointsCalculator() *PointsCalculator {
+	return NewPointsCalculatorWithConfig(&rps.seasons.Current().Rules)
+}
+
+// CalculateRulePoints calculates points for a rule from its effects, or
+// from its text when it has none
 func (rps *RulePointsService) CalculateRulePoints(rule *models.Rule) []int {
-	effectiveness := rps.pointsCalculator.analyzeRuleText(rule.Name, rule.Description)
-	return rps.pointsCalculator.CalculatePoints(effectiveness)
+	return rps.pointsCalculator().CalculateRulePoints(rule)
 }
 
 // CalculateRulePointsWithCustom calculates points with custom effectiveness values
 func (rps *RulePointsService) CalculateRulePointsWithCustom(rule *models.Rule, effectiveness RuleEffectiveness) []int {
-	return rps.pointsCalculator.CalculatePoints(effectiveness)
+	return rps.pointsCalculator().CalculatePoints(effectiveness)
 }
 
 // UpdateRuleWithCalculatedPoints updates a rule with calculated points
@@ -65,13 +76,27 @@ func (rps *RulePointsService) BulkCalculatePoints(rules []models.Rule) []models.
 
 // GetPointsBreakdown returns detailed breakdown of points calculation
 func (rps *RulePointsService) GetPointsBreakdown(rule *models.Rule) map[string]interface{} {
-	effectiveness := rps.pointsCalculator.analyzeRuleText(rule.Name, rule.Description)
-	points := rps.pointsCalculator.CalculatePoints(effectiveness)
+	calculator := rps.pointsCalculator()
+	if len(rule.Effects) > 0 {
+		costs, multiplier := calculator.effectCosts(rule.Effects)
+		return map[string]interface{}{
+			"rule_id":            rule.ID.Hex(),
+			"rule_name":          rule.Name,
+			"calculated_points":  effectTierPoints(costs, multiplier),
+			"source":             "effects",
+			"effects":            costs,
+			"trigger_multiplier": multiplier,
+		}
+	}
+
+	effectiveness := calculator.analyzeRuleText(rule.Name, rule.Description)
+	points := calculator.CalculatePoints(effectiveness)
 
 	return map[string]interface{}{
 		"rule_id":           rule.ID.Hex(),
 		"rule_name":         rule.Name,
 		"calculated_points": points,
+		"source":            "text",
 		"effectiveness": map[string]interface{}{
 			"base_value": effectiveness.BaseValue,
 			"multiplier": effectiveness.Multiplier,
@@ -86,3 +111,18 @@ func (rps *RulePointsService) GetPointsBreakdown(rule *models.Rule) map[string]i
 		},
 	}
 }
+
+// GetPointsExplanation returns a human-readable summary of how a rule's points were derived
+func (rps *RulePointsService) GetPointsExplanation(rule *models.Rule) string {
+	calculator := rps.pointsCalculator()
+	if len(rule.Effects) > 0 {
+		points := calculator.CalculateRulePoints(rule)
+		return fmt.Sprintf("%s is priced from its %d effect(s), costing %d/%d/%d points for tiers 1-3",
+			rule.Name, len(rule.Effects), points[0], points[1], points[2])
+	}
+	effectiveness := calculator.analyzeRuleText(rule.Name, rule.Description)
+	points := calculator.CalculatePoints(effectiveness)
+
+	return fmt.Sprintf("%s is rated %s with %s frequency (multiplier %.2f), costing %d/%d/%d points for tiers 1-3",
+		rule.Name, effectiveness.BaseValue, effectiveness.Frequency, effectiveness.Multiplier, points[0], points[1], points[2])
+}
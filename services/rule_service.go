@@ -11,31 +11,54 @@ import (
 )
 
 type RuleService struct {
-	repo *repositories.RuleRepository
+	repo       *repositories.RuleRepository
+	references *ReferenceService
+	revisions  *RevisionService
+	cache      *Cache[models.Rule]
 }
 
-func NewRuleService(repo *repositories.RuleRepository) *RuleService {
+func NewRuleService(repo *repositories.RuleRepository, references *ReferenceService, revisions *RevisionService) *RuleService {
 	return &RuleService{
-		repo: repo,
+		repo:       repo,
+		references: references,
+		revisions:  revisions,
 	}
 }
 
+// validateRule checks a rule before it is written
+func validateRule(rule *models.Rule) error {
+	if err := utils.ValidateName(rule.Name); err != nil {
+		return err
+	}
+	return ValidateRuleEffects(rule.Effects)
+}
+
 func (s *RuleService) CreateRule(ctx context.Context, rule *models.Rule) (*models.Rule, error) {
 	// Validate required fields
-	if err := utils.ValidateName(rule.Name); err != nil {
+	if err := validateRule(rule); err != nil {
 		return nil, err
 	}
 
-	id, err := s.repo.CreateRule(ctx, rule)
+	err := s.revisions.TrackCreate(ctx, "rules", func(ctx context.Context) (primitive.ObjectID, error) {
+		id, err := s.repo.CreateRule(ctx, rule)
+		rule.ID, _ = primitive.ObjectIDFromHex(id)
+		return rule.ID, err
+	})
 	if err != nil {
 		return nil, err
 	}
-	rule.ID, _ = primitive.ObjectIDFromHex(id)
 	return rule, nil
 }
 
+// UseCache puts cache in front of GetRuleByID
+func (s *RuleService) UseCache(cache *Cache[models.Rule]) {
+	s.cache = cache
+}
+
 func (s *RuleService) GetRuleByID(ctx context.Context, id string) (*models.Rule, error) {
-	return s.repo.GetRuleByID(ctx, id)
+	return s.cache.Get(ctx, id, func(ctx context.Context) (*models.Rule, error) {
+		return s.repo.GetRuleByID(ctx, id)
+	})
 }
 
 func (s *RuleService) GetAllRules(ctx context.Context, limit, skip int64) ([]models.Rule, error) {
@@ -46,28 +69,45 @@ func (s *RuleService) SearchRulesByName(ctx context.Context, name string, limit,
 	return s.repo.SearchRulesByName(ctx, name, limit, skip)
 }
 
+func (s *RuleService) ListRules(ctx context.Context, name string, opts ListOptions) ([]models.Rule, string, error) {
+	return s.repo.ListRules(ctx, name, opts)
+}
+
 func (s *RuleService) UpdateRule(ctx context.Context, id string, rule *models.Rule) error {
 	// Validate required fields
-	if err := utils.ValidateName(rule.Name); err != nil {
+	if err := validateRule(rule); err != nil {
 		return err
 	}
 
-	return s.repo.UpdateRule(ctx, id, rule)
+	return s.revisions.Track(ctx, "rules", id, RevisionUpdated, func(ctx context.Context) error {
+		return s.repo.UpdateRule(ctx, id, rule)
+	})
 }
 
+// DeleteRule deletes a rule, refusing if anything still references it
 func (s *RuleService) DeleteRule(ctx context.Context, id string) error {
-	return s.repo.DeleteRule(ctx, id)
+	_, err := s.DeleteRuleWithPolicy(ctx, id, DeletePolicyReject)
+	return err
+}
+
+// DeleteRuleWithPolicy deletes a rule and applies policy to anything that references it
+func (s *RuleService) DeleteRuleWithPolicy(ctx context.Context, id string, policy DeletePolicy) (*DeleteReport, error) {
+	return s.references.Delete(ctx, "rules", id, policy)
 }
 
 func (s *RuleService) BulkImportRules(ctx context.Context, rules []models.Rule) ([]string, error) {
 	// Validate all rules before importing
-	for i, rule := range rules {
-		if err := utils.ValidateName(rule.Name); err != nil {
+	for i := range rules {
+		if err := validateRule(&rules[i]); err != nil {
 			return nil, fmt.Errorf("rule at index %d: %w", i, err)
 		}
 	}
 
-	return s.repo.BulkImportRules(ctx, rules)
+	importedIDs, err := s.repo.BulkImportRules(ctx, rules)
+	if recordErr := s.revisions.RecordImported(ctx, "rules", importedIDs); recordErr != nil && err == nil {
+		return nil, recordErr
+	}
+	return importedIDs, err
 }
 
 func (s *RuleService) GetRulesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Rule, error) {
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Search limits
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	maxQueryLength     = 200
+	snippetLength      = 160 // longest snippet of a long field, in bytes
+)
+
+// searchTarget ties an entity type to its repository and the fields its text index covers
+type searchTarget struct {
+	repo   *repositories.BaseRepository
+	fields []string
+}
+
+// SearchHit is one search result. Highlights holds a snippet of every field
+// that matched, HTML-escaped, with the matching words wrapped in <mark>.
+type SearchHit struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// SearchResults are the hits for a query across all searched types, best first
+type SearchResults struct {
+	Query string      `json:"query"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// SearchService runs full-text searches across the entity types
+type SearchService struct {
+	targets map[string]searchTarget
+}
+
+// NewSearchService creates a search service. Army lists are players' own
+// data and are not searched.
+func NewSearchService(
+	ruleRepo *repositories.RuleRepository,
+	weaponRepo *repositories.WeaponRepository,
+	wargearRepo *repositories.WarGearRepository,
+	unitRepo *repositories.UnitRepository,
+	armyBookRepo *repositories.ArmyBookRepository,
+	factionRepo *repositories.FactionRepository,
+) *SearchService {
+	return &SearchService{
+		targets: map[string]searchTarget{
+			"rules":     {ruleRepo.BaseRepository, []string{"name", "description"}},
+			"weapons":   {weaponRepo.BaseRepository, []string{"name"}},
+			"wargear":   {wargearRepo.BaseRepository, []string{"name", "description"}},
+			"units":     {unitRepo.BaseRepository, []string{"name"}},
+			"armybooks": {armyBookRepo.BaseRepository, []string{"name", "description"}},
+			"factions":  {factionRepo.BaseRepository, []string{"name", "description"}},
+		},
+	}
+}
+
+// EntityTypes returns the entity types that can be searched, in alphabetical order
+func (s *SearchService) EntityTypes() []string {
+	types := make([]string, 0, len(s.targets))
+	for entityType := range s.targets {
+		types = append(types, entityType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Search finds up to limit entities whose names or descriptions match query,
+// best match first. types narrows the search; empty searches every type.
+func (s *SearchService) Search(ctx context.Context, query string, types []string, limit int) (*SearchResults, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, utils.NewValidationError("q", "a search query is required")
+	}
+	if len(query) > maxQueryLength {
+		return nil, utils.NewValidationError("q", fmt.Sprintf("a search query can be at most %d characters", maxQueryLength))
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	if len(types) == 0 {
+		types = s.EntityTypes()
+	}
+	for _, entityType := range types {
+		if _, ok := s.targets[entityType]; !ok {
+			return nil, utils.NewValidationError("types", fmt.Sprintf("cannot search %q, use one of %s", entityType, strings.Join(s.EntityTypes(), ", ")))
+		}
+	}
+
+	terms := utils.SearchTerms(query)
+	hits := make([]SearchHit, 0)
+	for _, entityType := range types {
+		target := s.targets[entityType]
+		matches, err := target.repo.TextSearch(ctx, query, int64(limit))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %s: %w", entityType, err)
+		}
+		for _, match := range matches {
+			hits = append(hits, newSearchHit(entityType, target.fields, match, terms))
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return &SearchResults{Query: query, Hits: hits}, nil
+}
+
+func newSearchHit(entityType string, fields []string, match repositories.TextMatch, terms []string) SearchHit {
+	hit := SearchHit{
+		Type:       entityType,
+		Score:      match.Score,
+		Highlights: map[string]string{},
+	}
+	if id, ok := match.Document["_id"].(primitive.ObjectID); ok {
+		hit.ID = id.Hex()
+	}
+	hit.Name, _ = match.Document["name"].(string)
+
+	for _, field := range fields {
+		text, _ := match.Document[field].(string)
+		if snippet, ok := highlight(text, terms); ok {
+			hit.Highlights[field] = snippet
+		}
+	}
+	return hit
+}
+
+// highlight marks the words of text whose stems are among terms. Long texts
+// are cut down to a snippet around the first match. It reports false when no
+// word matched.
+func highlight(text string, terms []string) (string, bool) {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	var matched []utils.Word
+	for _, word := range utils.Words(text) {
+		if wanted[utils.Stem(word.Text)] {
+			matched = append(matched, word)
+		}
+	}
+	if len(matched) == 0 {
+		return "", false
+	}
+
+	start, end := 0, len(text)
+	if end > snippetLength {
+		start = matched[0].Start - snippetLength/4
+		if start < 0 {
+			start = 0
+		}
+		end = start + snippetLength
+		if end > len(text) {
+			end = len(text)
+		}
+		start, end = wordBoundaries(text, start, end)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	position := start
+	for _, word := range matched {
+		if word.Start < start || word.End > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[position:word.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(word.Text))
+		b.WriteString("</mark>")
+		position = word.End
+	}
+	b.WriteString(html.EscapeString(text[position:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
+
+// wordBoundaries narrows [start, end) so that it neither starts nor ends in the middle of a word
+func wordBoundaries(text string, start, end int) (int, int) {
+	for _, word := range utils.Words(text) {
+		if word.Start < start && word.End > start {
+			start = word.End
+		}
+		if word.Start < end && word.End > end {
+			end = word.Start
+		}
+	}
+	return start, end
+}
@@ -0,0 +1,543 @@
+package services
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Snapshot archives are gzipped tar files holding a manifest and one file per
+// collection with a document per line in canonical Extended JSON
+const (
+	SnapshotFormat        = "grimdank-snapshot"
+	SnapshotFormatVersion = 1
+	snapshotManifestFile  = "manifest.json"
+)
+
+// RestoreMode says what a restore does with the data already in the database
+type RestoreMode string
+
+const (
+	// RestoreReplace empties the collections and writes the archive as it is
+	RestoreReplace RestoreMode = "replace"
+	// RestoreMerge keeps the data in the database and adds what it lacks
+	RestoreMerge RestoreMode = "merge"
+)
+
+// ParseRestoreMode parses a restore mode, defaulting to merge, which never
+// removes anything
+func ParseRestoreMode(value string) (RestoreMode, error) {
+	switch RestoreMode(value) {
+	case "", RestoreMerge:
+		return RestoreMerge, nil
+	case RestoreReplace:
+		return RestoreReplace, nil
+	}
+	return "", utils.NewValidationError("mode", fmt.Sprintf("unknown restore mode %q; use replace or merge", value))
+}
+
+// SnapshotCollection describes one collection file in an archive
+type SnapshotCollection struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Count  int    `json:"count"`
+	SHA256 string `json:"sha256"`
+}
+
+// SnapshotManifest is the first file of an archive
+type SnapshotManifest struct {
+	Format        string `json:"format"`
+	FormatVersion int    `json:"formatVersion"`
+	// SchemaVersion is the latest migration the documents are shaped by
+	SchemaVersion int                  `json:"schemaVersion"`
+	CreatedAt     time.Time            `json:"createdAt"`
+	Collections   []SnapshotCollection `json:"collections"`
+}
+
+// RemappedID records an archived document that was matched to a different
+// document already in the database. References to From were rewritten to To.
+type RemappedID struct {
+	From primitive.ObjectID `json:"from"`
+	To   primitive.ObjectID `json:"to"`
+	Name string             `json:"name,omitempty"`
+}
+
+// CollectionRestore is what a restore did, or would do, to one collection
+type CollectionRestore struct {
+	Name     string `json:"name"`
+	Archived int    `json:"archived"`
+	Inserted int    `json:"inserted"`
+	// Removed counts documents a replace deleted
+	Removed int64 `json:"removed"`
+	// Existing counts documents a merge skipped because their ID was taken
+	Existing int          `json:"existing"`
+	Remapped []RemappedID `json:"remapped,omitempty"`
+}
+
+// RestoreReport is the outcome of a restore
+type RestoreReport struct {
+	Mode        RestoreMode         `json:"mode"`
+	DryRun      bool                `json:"dryRun"`
+	Manifest    SnapshotManifest    `json:"manifest"`
+	Collections []CollectionRestore `json:"collections"`
+}
+
+// snapshotSource ties a collection to its repository and the unique indexes
+// a merge matches documents on
+type snapshotSource struct {
+	name   string
+	repo   *repositories.BaseRepository
+	unique []repositories.IndexSpec
+}
+
+// snapshotArchive is a validated archive read into memory
+type snapshotArchive struct {
+	manifest  SnapshotManifest
+	documents map[string][]bson.M
+}
+
+// SnapshotService exports every entity collection to a single archive and
+// restores such archives. It works on documents as they are stored, trash
+// included, and bypasses the services' validation, revisions and events.
+type SnapshotService struct {
+	// sources are in dependency order: a document only references documents
+	// of the collections before it, so a merge learns of every remapped ID
+	// before it meets a reference to it
+	sources       []snapshotSource
+	purges        *repositories.PurgeLogRepository
+	unitOfWork    UnitOfWork
+	schemaVersion int
+}
+
+// NewSnapshotService creates a snapshot service. schemaVersion is the latest
+// migration; archives of any other version are refused.
+func NewSnapshotService(
+	workspaceRepo *repositories.WorkspaceRepository,
+	ruleRepo *repositories.RuleRepository,
+	weaponRepo *repositories.WeaponRepository,
+	wargearRepo *repositories.WarGearRepository,
+	unitRepo *repositories.UnitRepository,
+	armyBookRepo *repositories.ArmyBookRepository,
+	armyListRepo *repositories.ArmyListRepository,
+	factionRepo *repositories.FactionRepository,
+	purges *repositories.PurgeLogRepository,
+	unitOfWork UnitOfWork,
+	schemaVersion int,
+) *SnapshotService {
+	source := func(name string, repo *repositories.BaseRepository, indexes []repositories.IndexSpec) snapshotSource {
+		var unique []repositories.IndexSpec
+		for _, index := range indexes {
+			if index.Unique {
+				unique = append(unique, index)
+			}
+		}
+		return snapshotSource{name: name, repo: repo, unique: unique}
+	}
+
+	return &SnapshotService{
+		sources: []snapshotSource{
+			source("workspaces", workspaceRepo.BaseRepository, workspaceRepo.Indexes()),
+			source("factions", factionRepo.BaseRepository, factionRepo.Indexes()),
+			source("rules", ruleRepo.BaseRepository, ruleRepo.Indexes()),
+			source("weapons", weaponRepo.BaseRepository, weaponRepo.Indexes()),
+			source("wargear", wargearRepo.BaseRepository, wargearRepo.Indexes()),
+			source("units", unitRepo.BaseRepository, unitRepo.Indexes()),
+			source("armybooks", armyBookRepo.BaseRepository, armyBookRepo.Indexes()),
+			source("armylists", armyListRepo.BaseRepository, armyListRepo.Indexes()),
+		},
+		purges:        purges,
+		unitOfWork:    unitOfWork,
+		schemaVersion: schemaVersion,
+	}
+}
+
+// Export writes every document of every collection to w as an archive,
+// whichever workspace it belongs to. The collections are read one after the
+// other, so writes made during an export may be caught in some collections and
+// not others.
+func (s *SnapshotService) Export(ctx context.Context, w io.Writer) (*SnapshotManifest, error) {
+	ctx = repositories.AllWorkspaces(ctx)
+	manifest := &SnapshotManifest{
+		Format:        SnapshotFormat,
+		FormatVersion: SnapshotFormatVersion,
+		SchemaVersion: s.schemaVersion,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	files := make([][]byte, len(s.sources))
+	for i, source := range s.sources {
+		documents, err := source.repo.SnapshotAll(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", source.name, err)
+		}
+
+		var file bytes.Buffer
+		for _, document := range documents {
+			line, err := bson.MarshalExtJSON(document, true, false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode %s document %v: %w", source.name, document["_id"], err)
+			}
+			file.Write(line)
+			file.WriteByte('\n')
+		}
+
+		files[i] = file.Bytes()
+		sum := sha256.Sum256(files[i])
+		manifest.Collections = append(manifest.Collections, SnapshotCollection{
+			Name:   source.name,
+			File:   source.name + ".jsonl",
+			Count:  len(documents),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	write := func(name string, content []byte) error {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: manifest.CreatedAt}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := archive.Write(content)
+		return err
+	}
+
+	if err := write(snapshotManifestFile, manifestJSON); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	for i, collection := range manifest.Collections {
+		if err := write(collection.File, files[i]); err != nil {
+			return nil, fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// Verify reads and validates an archive without touching the database
+func (s *SnapshotService) Verify(r io.Reader) (*SnapshotManifest, error) {
+	archive, err := s.read(r)
+	if err != nil {
+		return nil, err
+	}
+	return &archive.manifest, nil
+}
+
+// Restore validates an archive and writes it to the database in a single
+// unit of work, so a failed restore leaves the database as it was.
+//
+// A replace empties the collections, trash included, and inserts every
+// archived document with its own ID. A merge keeps what is in the database:
+// archived documents whose ID is taken are skipped, and documents that share
+// a unique name with a different document are not inserted; references to
+// them are remapped to the document already there. Everything else is
+// inserted. Documents keep the workspace they were archived in. With dryRun
+// nothing is written.
+func (s *SnapshotService) Restore(ctx context.Context, r io.Reader, mode RestoreMode, dryRun bool) (*RestoreReport, error) {
+	ctx = repositories.AllWorkspaces(ctx)
+	archive, err := s.read(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RestoreReport{Mode: mode, DryRun: dryRun, Manifest: archive.manifest}
+	restore := func(ctx context.Context) error {
+		report.Collections = report.Collections[:0]
+		remapped := map[primitive.ObjectID]primitive.ObjectID{}
+		for _, source := range s.sources {
+			var (
+				result CollectionRestore
+				err    error
+			)
+			if mode == RestoreReplace {
+				result, err = s.replace(ctx, source, archive.documents[source.name], dryRun)
+			} else {
+				result, err = s.merge(ctx, source, archive.documents[source.name], remapped, dryRun)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to restore %s: %w", source.name, err)
+			}
+			report.Collections = append(report.Collections, result)
+		}
+		return nil
+	}
+
+	if dryRun {
+		err = restore(ctx)
+	} else {
+		err = s.unitOfWork.Do(ctx, restore)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *SnapshotService) replace(ctx context.Context, source snapshotSource, documents []bson.M, dryRun bool) (CollectionRestore, error) {
+	result := CollectionRestore{Name: source.name, Archived: len(documents), Inserted: len(documents)}
+	if dryRun {
+		removed, err := source.repo.CountStored(ctx)
+		result.Removed = removed
+		return result, err
+	}
+
+	inserts := make([]interface{}, len(documents))
+	for i, document := range documents {
+		inserts[i] = document
+	}
+	removed, err := source.repo.ReplaceAll(ctx, inserts)
+	result.Removed = removed
+	if err != nil || removed == 0 {
+		return result, err
+	}
+	// Sync can't report what the archive left out as deleted
+	return result, s.purges.RecordPurge(ctx, source.name, time.Now())
+}
+
+func (s *SnapshotService) merge(ctx context.Context, source snapshotSource, documents []bson.M, remapped map[primitive.ObjectID]primitive.ObjectID, dryRun bool) (CollectionRestore, error) {
+	result := CollectionRestore{Name: source.name, Archived: len(documents)}
+
+	var inserts []interface{}
+	for _, document := range documents {
+		id := document["_id"].(primitive.ObjectID)
+		stored, err := source.repo.Stored(ctx, id)
+		if err != nil {
+			return result, err
+		}
+		if stored {
+			result.Existing++
+			continue
+		}
+
+		document = remapReferences(document, remapped)
+		duplicate, err := s.findDuplicate(ctx, source, document)
+		if err != nil {
+			return result, err
+		}
+		if !duplicate.IsZero() {
+			remapped[id] = duplicate
+			name, _ := document["name"].(string)
+			result.Remapped = append(result.Remapped, RemappedID{From: id, To: duplicate, Name: name})
+			continue
+		}
+		inserts = append(inserts, document)
+	}
+
+	result.Inserted = len(inserts)
+	if dryRun || len(inserts) == 0 {
+		return result, nil
+	}
+	_, err := source.repo.BulkInsert(ctx, inserts)
+	return result, err
+}
+
+// findDuplicate returns the ID of the stored document document collides with
+// on one of the collection's unique indexes, or the nil ID if there is none
+func (s *SnapshotService) findDuplicate(ctx context.Context, source snapshotSource, document bson.M) (primitive.ObjectID, error) {
+	for _, index := range source.unique {
+		id, err := source.repo.FindDuplicate(ctx, index, document)
+		if err != nil || !id.IsZero() {
+			return id, err
+		}
+	}
+	return primitive.NilObjectID, nil
+}
+
+// remapReferences returns document with every ObjectID other than its own
+// _id replaced by the one it was remapped to
+func remapReferences(document bson.M, remapped map[primitive.ObjectID]primitive.ObjectID) bson.M {
+	if len(remapped) == 0 {
+		return document
+	}
+	result := make(bson.M, len(document))
+	for key, value := range document {
+		if key == "_id" {
+			result[key] = value
+			continue
+		}
+		result[key] = remapValue(value, remapped)
+	}
+	return result
+}
+
+func remapValue(value interface{}, remapped map[primitive.ObjectID]primitive.ObjectID) interface{} {
+	switch v := value.(type) {
+	case primitive.ObjectID:
+		if to, ok := remapped[v]; ok {
+			return to
+		}
+		return v
+	case bson.M:
+		result := make(bson.M, len(v))
+		for key, element := range v {
+			result[key] = remapValue(element, remapped)
+		}
+		return result
+	case bson.D:
+		result := make(bson.D, len(v))
+		for i, element := range v {
+			result[i] = bson.E{Key: element.Key, Value: remapValue(element.Value, remapped)}
+		}
+		return result
+	case bson.A:
+		result := make(bson.A, len(v))
+		for i, element := range v {
+			result[i] = remapValue(element, remapped)
+		}
+		return result
+	}
+	return value
+}
+
+// read reads a whole archive and checks it against its manifest
+func (s *SnapshotService) read(r io.Reader) (*snapshotArchive, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return utils.NewValidationError("archive", fmt.Sprintf(format, args...))
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, invalid("not a snapshot archive: %v", err)
+	}
+	defer gz.Close()
+
+	files := map[string][]byte{}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, invalid("not a snapshot archive: %v", err)
+		}
+		if _, seen := files[header.Name]; seen {
+			return nil, invalid("%s appears twice", header.Name)
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, invalid("failed to read %s: %v", header.Name, err)
+		}
+		files[header.Name] = content
+	}
+
+	manifestJSON, ok := files[snapshotManifestFile]
+	if !ok {
+		return nil, invalid("no %s", snapshotManifestFile)
+	}
+	var manifest SnapshotManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, invalid("invalid %s: %v", snapshotManifestFile, err)
+	}
+	if manifest.Format != SnapshotFormat {
+		return nil, invalid("unknown format %q", manifest.Format)
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > SnapshotFormatVersion {
+		return nil, invalid("format version %d is not supported; this server reads up to version %d", manifest.FormatVersion, SnapshotFormatVersion)
+	}
+	if manifest.SchemaVersion != s.schemaVersion {
+		return nil, invalid("snapshot has schema version %d but the database is at version %d; restore it with a server at the same version", manifest.SchemaVersion, s.schemaVersion)
+	}
+
+	listed := map[string]SnapshotCollection{}
+	for _, collection := range manifest.Collections {
+		if _, seen := listed[collection.Name]; seen {
+			return nil, invalid("collection %s is listed twice", collection.Name)
+		}
+		listed[collection.Name] = collection
+	}
+	if len(listed) != len(s.sources) {
+		return nil, invalid("expected %d collections, the manifest lists %d", len(s.sources), len(listed))
+	}
+
+	result := &snapshotArchive{manifest: manifest, documents: map[string][]bson.M{}}
+	used := map[string]bool{snapshotManifestFile: true}
+	seen := map[primitive.ObjectID]string{}
+	for _, source := range s.sources {
+		collection, ok := listed[source.name]
+		if !ok {
+			return nil, invalid("collection %s is missing", source.name)
+		}
+		content, ok := files[collection.File]
+		if !ok {
+			return nil, invalid("%s is missing", collection.File)
+		}
+		used[collection.File] = true
+
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != collection.SHA256 {
+			return nil, invalid("checksum of %s does not match the manifest", collection.File)
+		}
+
+		documents, err := readDocuments(content)
+		if err != nil {
+			return nil, invalid("%s: %v", collection.File, err)
+		}
+		if len(documents) != collection.Count {
+			return nil, invalid("%s holds %d documents, the manifest says %d", collection.File, len(documents), collection.Count)
+		}
+		for i, document := range documents {
+			id, ok := document["_id"].(primitive.ObjectID)
+			if !ok {
+				return nil, invalid("%s line %d has no ObjectID _id", collection.File, i+1)
+			}
+			if other, dup := seen[id]; dup {
+				return nil, invalid("ID %s appears in both %s and %s", id.Hex(), other, source.name)
+			}
+			seen[id] = source.name
+		}
+		result.documents[source.name] = documents
+	}
+
+	for name := range files {
+		if !used[name] {
+			return nil, invalid("unexpected file %s", name)
+		}
+	}
+	return result, nil
+}
+
+// readDocuments decodes a collection file, one Extended JSON document per line
+func readDocuments(content []byte) ([]bson.M, error) {
+	var documents []bson.M
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var document bson.M
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &document); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		documents = append(documents, document)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read documents: %w", err)
+	}
+	return documents, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"grimdank-database/models"
+)
+
+// Weapon pricing modes of a points season
+const (
+	WeaponPricingLegacy      = "legacy"
+	WeaponPricingStatistical = "statistical"
+)
+
+// WeaponRuleEffects are the effects of one of a weapon's rules, at the tier
+// the weapon has it
+type WeaponRuleEffects struct {
+	Tier    int                 `json:"tier"`
+	Effects []models.RuleEffect `json:"effects"`
+}
+
+// DefenseOutcome is how likely a hit is to wound one defensive profile
+type DefenseOutcome struct {
+	Defense     int     `json:"defense"`
+	Weight      float64 `json:"weight"`
+	Target      int     `json:"target"` // Defense + AP, the roll that avoids the wound
+	WoundChance float64 `json:"woundChance"`
+}
+
+// ExpectedWounds is the statistical pricing of a weapon: its stats after its
+// rules, the chances of each roll and the points they come to
+type ExpectedWounds struct {
+	Skill           int              `json:"skill"`
+	Attacks         float64          `json:"attacks"`
+	AP              float64          `json:"ap"`
+	Range           float64          `json:"range"`
+	HitChance       float64          `json:"hitChance"`
+	Defenses        []DefenseOutcome `json:"defenses"`
+	WoundChance     float64          `json:"woundChance"` // averaged over the defenses by weight
+	Wounds          float64          `json:"wounds"`      // per activation
+	RangeMultiplier float64          `json:"rangeMultiplier"`
+	Points          int              `json:"points"`
+}
+
+// checkChance is the chance of rolling at least target on a D10. A 1 always
+// fails and a 10 always succeeds, so it is never below 10% or above 90%.
+func checkChance(target float64) float64 {
+	return math.Min(0.9, math.Max(0.1, (11-target)/10))
+}
+
+// statistical returns the season's statistical settings, or the built-in
+// ones for seasons stored without them
+func (wpc *WeaponPointsCalculator) statistical() *models.StatisticalWeaponPricing {
+	if len(wpc.config.Statistical.Defenses) == 0 {
+		return DefaultStatisticalWeaponPricing()
+	}
+	return &wpc.config.Statistical
+}
+
+// CalculateExpectedWounds works out how many wounds a weapon is expected to
+// cause in one activation: each attack hits on the wielder's Ranged or Melee
+// skill, then wounds unless the target rolls its Defense plus the weapon's AP.
+// Rules whose effects are all priced into the weapon change its stats; the
+// others are left to their own points.
+func (wpc *WeaponPointsCalculator) CalculateExpectedWounds(stats WeaponStats) ExpectedWounds {
+	settings := wpc.statistical()
+	melee := strings.EqualFold(stats.Type, "Melee")
+
+	result := ExpectedWounds{
+		Skill:   stats.Skill,
+		Attacks: float64(parseAttacks(stats.Attacks)),
+		Range:   float64(stats.Range),
+	}
+	if result.Skill == 0 {
+		result.Skill = settings.Skill
+	}
+	if ap, err := strconv.Atoi(strings.TrimSpace(stats.AP)); err == nil {
+		result.AP = float64(ap)
+	}
+
+	skill := float64(result.Skill)
+	for _, rule := range stats.Rules {
+		if !effectsPricedIntoWeapon(rule.Effects, melee) {
+			continue
+		}
+		for _, effect := range rule.Effects {
+			amount, err := effectAmount(effect, rule.Tier)
+			if err != nil {
+				continue
+			}
+			switch strings.ToLower(effect.Stat) {
+			case "attacks":
+				result.Attacks += amount
+			case "ap":
+				result.AP += amount
+			case "range":
+				result.Range += amount
+			default:
+				// Better accuracy lowers the hit target, however it's worded
+				skill -= math.Abs(amount)
+			}
+		}
+	}
+	result.Attacks = math.Max(0, result.Attacks)
+	result.HitChance = checkChance(skill)
+
+	totalWeight := 0.0
+	for _, profile := range settings.Defenses {
+		target := float64(profile.Defense) + result.AP
+		outcome := DefenseOutcome{
+			Defense:     profile.Defense,
+			Weight:      profile.Weight,
+			Target:      int(math.Round(target)),
+			WoundChance: 1 - checkChance(target),
+		}
+		result.Defenses = append(result.Defenses, outcome)
+		result.WoundChance += outcome.WoundChance * profile.Weight
+		totalWeight += profile.Weight
+	}
+	if totalWeight > 0 {
+		result.WoundChance /= totalWeight
+	}
+	result.Wounds = result.Attacks * result.HitChance * result.WoundChance
+
+	result.RangeMultiplier = 1
+	if !melee {
+		result.RangeMultiplier += settings.RangeBonus * math.Min(result.Range, float64(wpc.config.MaxRange)) / 12
+	}
+	result.Points = int(math.Round(wpc.clamp(result.Wounds * settings.PointsPerWound * result.RangeMultiplier)))
+	return result
+}
+
+// pricedIntoWeapon reports whether statistical pricing counts a rule in a
+// weapon's points, so units don't pay for the rule again
+func pricedIntoWeapon(rule *models.Rule, weaponType string) bool {
+	return effectsPricedIntoWeapon(rule.Effects, strings.EqualFold(weaponType, "Melee"))
+}
+
+// effectsPricedIntoWeapon reports whether a rule's effects can all be counted
+// in a weapon's stats: untriggered changes to attacks, AP, range or the skill
+// the weapon hits with, that don't reach other units
+func effectsPricedIntoWeapon(effects []models.RuleEffect, melee bool) bool {
+	if len(effects) == 0 {
+		return false
+	}
+	for _, effect := range effects {
+		if effect.Trigger != "" || effect.Aura {
+			return false
+		}
+		switch strings.ToLower(effect.Stat) {
+		case "attacks", "ap", "range":
+		case "melee":
+			if !melee {
+				return false
+			}
+		case "ranged":
+			if melee {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseAttacks reads an Attacks value, counting "X" as 3 and anything else
+// that isn't a number as 1
+func parseAttacks(attacks string) int {
+	value, err := strconv.Atoi(strings.TrimSpace(attacks))
+	if err != nil {
+		if strings.ToUpper(strings.TrimSpace(attacks)) == "X" {
+			return 3
+		}
+		return 1
+	}
+	return value
+}
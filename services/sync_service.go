@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// syncSource ties an entity type to its repository and result type
+type syncSource struct {
+	repo    *repositories.BaseRepository
+	newList func() interface{}
+}
+
+// SyncChanges is what changed in one collection. Created and Updated hold
+// the entities as they are now; Deleted holds the IDs of entities moved to
+// the trash.
+type SyncChanges struct {
+	Created interface{}          `json:"created"`
+	Updated interface{}          `json:"updated"`
+	Deleted []primitive.ObjectID `json:"deleted"`
+}
+
+// SyncResult is everything that changed since a point in time
+type SyncResult struct {
+	Since time.Time `json:"since"`
+	// ServerTime is read before any collection, so passing it as since next
+	// time reports a change made during this sync again rather than losing it
+	ServerTime time.Time `json:"serverTime"`
+	// ResyncRequired is set when since is older than the trash retention or
+	// than the last purge by hand or snapshot restore: entities deleted since
+	// then may be gone and cannot be reported, so the client should download
+	// everything again
+	ResyncRequired bool                   `json:"resyncRequired"`
+	Changes        map[string]SyncChanges `json:"changes"`
+}
+
+// SyncService reports what changed since a point in time, so clients can
+// keep a local copy up to date without downloading every collection again
+type SyncService struct {
+	sources   map[string]syncSource
+	purges    *repositories.PurgeLogRepository
+	retention time.Duration
+}
+
+// NewSyncService creates a sync service. retention is the trash retention,
+// beyond which deletions can no longer be reported, and purges logs the
+// deletions that couldn't be reported sooner.
+func NewSyncService(
+	ruleRepo *repositories.RuleRepository,
+	weaponRepo *repositories.WeaponRepository,
+	wargearRepo *repositories.WarGearRepository,
+	unitRepo *repositories.UnitRepository,
+	armyBookRepo *repositories.ArmyBookRepository,
+	armyListRepo *repositories.ArmyListRepository,
+	factionRepo *repositories.FactionRepository,
+	purges *repositories.PurgeLogRepository,
+	retention time.Duration,
+) *SyncService {
+	return &SyncService{
+		sources: map[string]syncSource{
+			"rules":     {ruleRepo.BaseRepository, func() interface{} { return &[]models.Rule{} }},
+			"weapons":   {weaponRepo.BaseRepository, func() interface{} { return &[]models.Weapon{} }},
+			"wargear":   {wargearRepo.BaseRepository, func() interface{} { return &[]models.WarGear{} }},
+			"units":     {unitRepo.BaseRepository, func() interface{} { return &[]models.Unit{} }},
+			"armybooks": {armyBookRepo.BaseRepository, func() interface{} { return &[]models.ArmyBook{} }},
+			"armylists": {armyListRepo.BaseRepository, func() interface{} { return &[]models.ArmyList{} }},
+			"factions":  {factionRepo.BaseRepository, func() interface{} { return &[]models.Faction{} }},
+		},
+		purges:    purges,
+		retention: retention,
+	}
+}
+
+// ChangesSince returns every entity created, updated or deleted at or after
+// since. A zero since returns everything, for a client's first sync.
+//
+// Timestamps are stored to the millisecond, so since and the server time are
+// cut to the millisecond too and compared inclusively: a write in the same
+// millisecond as the server time is reported again next time rather than
+// never. Clients apply changes by ID, so a repeat is harmless.
+func (s *SyncService) ChangesSince(ctx context.Context, since time.Time) (*SyncResult, error) {
+	since = since.Truncate(time.Millisecond)
+	serverTime := time.Now().UTC().Truncate(time.Millisecond)
+	lastPurge, err := s.purges.LastPurge(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the purge log: %w", err)
+	}
+
+	expired := s.retention > 0 && since.Before(serverTime.Add(-s.retention))
+	result := &SyncResult{
+		Since:          since,
+		ServerTime:     serverTime,
+		ResyncRequired: !since.IsZero() && (expired || since.Before(lastPurge)),
+		Changes:        make(map[string]SyncChanges, len(s.sources)),
+	}
+
+	for entityType, source := range s.sources {
+		created, updated := source.newList(), source.newList()
+		if err := source.repo.ChangedSince(ctx, since, created, updated); err != nil {
+			return nil, fmt.Errorf("failed to read changed %s: %w", entityType, err)
+		}
+		deleted, err := source.repo.DeletedSince(ctx, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read deleted %s: %w", entityType, err)
+		}
+		result.Changes[entityType] = SyncChanges{Created: created, Updated: updated, Deleted: deleted}
+	}
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// ThumbnailSize is the longest side of a thumbnail in pixels
+const ThumbnailSize = 256
+
+// maxImagePixels bounds the images decoded for thumbnails. A small file can
+// declare a huge image, and decoding allocates for every pixel.
+const maxImagePixels = 40_000_000
+
+// makeThumbnail scales a PNG or JPEG down to fit ThumbnailSize and encodes
+// it in the same format. Images already small enough are scaled to their own
+// size, which still strips metadata and re-encodes them.
+func makeThumbnail(content []byte, contentType string) ([]byte, image.Point, error) {
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+
+	size := thumbnailBounds(src.Bounds().Size())
+	scaled := scaleDown(src, size)
+
+	var out bytes.Buffer
+	if contentType == "image/png" {
+		err = png.Encode(&out, scaled)
+	} else {
+		err = jpeg.Encode(&out, scaled, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	return out.Bytes(), size, nil
+}
+
+// thumbnailBounds fits size within ThumbnailSize, keeping its aspect ratio
+func thumbnailBounds(size image.Point) image.Point {
+	longest := size.X
+	if size.Y > longest {
+		longest = size.Y
+	}
+	if longest <= ThumbnailSize {
+		return size
+	}
+	fit := func(side int) int {
+		scaled := side * ThumbnailSize / longest
+		if scaled < 1 {
+			return 1
+		}
+		return scaled
+	}
+	return image.Point{X: fit(size.X), Y: fit(size.Y)}
+}
+
+// scaleDown resizes src to size by averaging the source pixels each
+// destination pixel covers
+func scaleDown(src image.Image, size image.Point) *image.RGBA {
+	// Work on premultiplied RGBA so transparent pixels don't bleed colour
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	for y := 0; y < size.Y; y++ {
+		y0, y1 := y*srcH/size.Y, (y+1)*srcH/size.Y
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < size.X; x++ {
+			x0, x1 := x*srcW/size.X, (x+1)*srcW/size.X
+			if x1 == x0 {
+				x1++
+			}
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+
+			count := (y1 - y0) * (x1 - x0)
+			offset := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+	return dst
+}
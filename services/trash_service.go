@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+)
+
+// trashBin ties an entity type to its repository and result type
+type trashBin struct {
+	repo    *repositories.BaseRepository
+	newList func() interface{}
+}
+
+// TrashService lists, restores and purges soft-deleted entities
+type TrashService struct {
+	bins      map[string]trashBin
+	revisions *RevisionService
+	purges    *repositories.PurgeLogRepository
+	retention time.Duration
+	caches    *Caches
+}
+
+// NewTrashService creates a trash service. Items older than retention are removed
+// by PurgeExpired; a retention of zero keeps them until purged by hand.
+func NewTrashService(
+	ruleRepo *repositories.RuleRepository,
+	weaponRepo *repositories.WeaponRepository,
+	wargearRepo *repositories.WarGearRepository,
+	unitRepo *repositories.UnitRepository,
+	armyBookRepo *repositories.ArmyBookRepository,
+	armyListRepo *repositories.ArmyListRepository,
+	factionRepo *repositories.FactionRepository,
+	revisions *RevisionService,
+	purges *repositories.PurgeLogRepository,
+	retention time.Duration,
+) *TrashService {
+	return &TrashService{
+		bins: map[string]trashBin{
+			"rules":     {ruleRepo.BaseRepository, func() interface{} { return &[]models.Rule{} }},
+			"weapons":   {weaponRepo.BaseRepository, func() interface{} { return &[]models.Weapon{} }},
+			"wargear":   {wargearRepo.BaseRepository, func() interface{} { return &[]models.WarGear{} }},
+			"units":     {unitRepo.BaseRepository, func() interface{} { return &[]models.Unit{} }},
+			"armybooks": {armyBookRepo.BaseRepository, func() interface{} { return &[]models.ArmyBook{} }},
+			"armylists": {armyListRepo.BaseRepository, func() interface{} { return &[]models.ArmyList{} }},
+			"factions":  {factionRepo.BaseRepository, func() interface{} { return &[]models.Faction{} }},
+		},
+		revisions: revisions,
+		purges:    purges,
+		retention: retention,
+	}
+}
+
+// UseCaches drops purged entities from caches
+func (s *TrashService) UseCaches(caches *Caches) {
+	s.caches = caches
+}
+
+// EntityTypes returns the entity types that have a trash, in alphabetical order
+func (s *TrashService) EntityTypes() []string {
+	types := make([]string, 0, len(s.bins))
+	for entityType := range s.bins {
+		types = append(types, entityType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Retention returns how long deleted items are kept before PurgeExpired removes them
+func (s *TrashService) Retention() time.Duration {
+	return s.retention
+}
+
+// ListTrash returns the deleted entities of one type, most recently deleted first
+func (s *TrashService) ListTrash(ctx context.Context, entityType string, limit, skip int64) (interface{}, int64, error) {
+	bin, err := s.binFor(entityType)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := bin.newList()
+	if err := bin.repo.GetDeleted(ctx, results, limit, skip); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := bin.repo.CountDeleted(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// Restore takes an entity out of the trash
+func (s *TrashService) Restore(ctx context.Context, entityType, id string) error {
+	bin, err := s.binFor(entityType)
+	if err != nil {
+		return err
+	}
+
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+
+	return s.revisions.Track(ctx, entityType, id, RevisionRestored, func(ctx context.Context) error {
+		return bin.repo.Restore(ctx, objectID)
+	})
+}
+
+// Purge permanently removes an entity that is in the trash. The purge is
+// logged so that sync can tell clients it missed the deletion.
+func (s *TrashService) Purge(ctx context.Context, entityType, id string) error {
+	bin, err := s.binFor(entityType)
+	if err != nil {
+		return err
+	}
+
+	objectID, err := utils.ParseObjectID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := bin.repo.Purge(ctx, objectID); err != nil {
+		return err
+	}
+	s.caches.Invalidate(entityType, id)
+	return s.purges.RecordPurge(ctx, entityType, time.Now())
+}
+
+// PurgeExpired permanently removes every entity that has been in the trash
+// longer than the retention period. It returns the number purged per type.
+func (s *TrashService) PurgeExpired(ctx context.Context) (map[string]int64, error) {
+	purged := make(map[string]int64, len(s.bins))
+	if s.retention <= 0 {
+		return purged, nil
+	}
+
+	cutoff := time.Now().Add(-s.retention)
+	for _, entityType := range s.EntityTypes() {
+		count, err := s.bins[entityType].repo.PurgeDeletedBefore(ctx, cutoff)
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge %s: %w", entityType, err)
+		}
+		purged[entityType] = count
+		if count > 0 {
+			// Which ones went isn't known, so nothing cached is trusted
+			s.caches.Flush()
+		}
+	}
+
+	return purged, nil
+}
+
+// RunPurgeLoop calls PurgeExpired every interval until ctx is cancelled
+func (s *TrashService) RunPurgeLoop(ctx context.Context, interval time.Duration) {
+	if s.retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := s.PurgeExpired(ctx)
+			if err != nil {
+				log.Printf("Error purging trash: %v", err)
+				continue
+			}
+			for entityType, count := range purged {
+				if count > 0 {
+					log.Printf("Purged %d expired %s from the trash", count, entityType)
+				}
+			}
+		}
+	}
+}
+
+func (s *TrashService) binFor(entityType string) (trashBin, error) {
+	bin, ok := s.bins[entityType]
+	if !ok {
+		return trashBin{}, utils.NewValidationError("type", fmt.Sprintf("unknown entity type %q", entityType))
+	}
+	return bin, nil
+}
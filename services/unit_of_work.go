@@ -0,0 +1,12 @@
+package services
+
+import "grimdank-database/database"
+
+// UnitOfWork groups service operations so that their writes take effect
+// together or not at all. main picks a transaction-backed unit when the
+// deployment supports transactions and the sequential fallback otherwise.
+type UnitOfWork = database.UnitOfWork
+
+// SequentialUnitOfWork undoes the writes of a failed unit one at a time; it
+// works with every backend, including the in-memory one
+type SequentialUnitOfWork = database.SequentialUnitOfWork
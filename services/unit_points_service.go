@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"grimdank-database/models"
 
@@ -14,6 +15,7 @@ type UnitPointsService struct {
 	ruleService    *RuleService
 	weaponService  *WeaponService
 	wargearService *WarGearService
+	seasons        *PointsSeasonService
 }
 
 // NewUnitPointsService creates a new unit points service
@@ -25,39 +27,140 @@ func NewUnitPointsService(ruleService *RuleService, weaponService *WeaponService
 	}
 }
 
+// UseSeasons makes the service calculate in the current points season
+// rather than the built-in one
+func (ups *UnitPointsService) UseSeasons(seasons *PointsSeasonService) {
+	ups.seasons = seasons
+}
+
+// season returns the points season calculations use by default
+func (ups *UnitPointsService) season() *models.PointsSeason {
+	return ups.seasons.Current()
+}
+
 // UnitPointsBreakdown represents the breakdown of unit costs
 type UnitPointsBreakdown struct {
-	BaseCost        int `json:"base_cost"`
-	UnitRulesCost   int `json:"unit_rules_cost"`
-	WeaponsCost     int `json:"weapons_cost"`
-	WeaponRulesCost int `json:"weapon_rules_cost"`
-	WargearCost     int `json:"wargear_cost"`
-	TotalPoints     int `json:"total_points"`
+	BaseCost        int    `json:"base_cost"`
+	UnitRulesCost   int    `json:"unit_rules_cost"`
+	WeaponsCost     int    `json:"weapons_cost"`
+	WeaponRulesCost int    `json:"weapon_rules_cost"`
+	WargearCost     int    `json:"wargear_cost"`
+	TotalPoints     int    `json:"total_points"`
+	Season          string `json:"season"`
 }
 
-// CalculateUnitPoints calculates the total points for a unit
+// CalculateUnitPoints calculates the total points for a unit in the current
+// season, using the points stored on its weapons. Under statistical pricing
+// a weapon costs what it does in the unit's hands, so it is priced from its
+// stats with the unit's skill instead.
 func (ups *UnitPointsService) CalculateUnitPoints(ctx context.Context, unit *models.Unit) (*UnitPointsBreakdown, error) {
+	season := ups.season()
+	weaponPoints := func(ctx context.Context, sources pointsSources, weapon *models.Weapon, skill int) int { return weapon.Points }
+	if season.Weapons.Pricing == WeaponPricingStatistical {
+		weaponPoints = seasonWeaponPoints(season)
+	}
+	return ups.calculateUnitPoints(ctx, unit, unitPricing{season: season, sources: ups, weaponPoints: weaponPoints})
+}
+
+// CalculateUnitPointsInSeason calculates the total points for a unit in the
+// named season, or the current one for an empty name. Weapons are priced from
+// their stats with the season's weights rather than by their stored points;
+// rules cost their stored points, which are not kept per season.
+func (ups *UnitPointsService) CalculateUnitPointsInSeason(ctx context.Context, unit *models.Unit, name string) (*UnitPointsBreakdown, error) {
+	if name == "" {
+		return ups.CalculateUnitPoints(ctx, unit)
+	}
+	season, err := ups.seasons.Season(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return ups.calculateUnitPoints(ctx, unit, unitPricing{season: season, sources: ups, weaponPoints: seasonWeaponPoints(season)})
+}
+
+// weaponPointsFunc prices a weapon, looking up its rules in sources if it
+// needs them. skill is the hit target of the unit wielding it, 0 for a weapon
+// priced on its own.
+type weaponPointsFunc func(ctx context.Context, sources pointsSources, weapon *models.Weapon, skill int) int
+
+// seasonWeaponPoints prices weapons from their stats the way season does.
+// Statistical pricing also counts the effects of their rules and hits on the
+// wielder's skill, or the season's when there is no wielder.
+func seasonWeaponPoints(season *models.PointsSeason) weaponPointsFunc {
+	calculator := NewWeaponPointsCalculatorWithConfig(&season.Weapons)
+	if season.Weapons.Pricing != WeaponPricingStatistical {
+		return func(ctx context.Context, sources pointsSources, weapon *models.Weapon, skill int) int {
+			return calculator.CalculateWeaponPoints(WeaponStatsOf(weapon))
+		}
+	}
+	return func(ctx context.Context, sources pointsSources, weapon *models.Weapon, skill int) int {
+		stats := WeaponStatsOf(weapon)
+		stats.Skill = skill
+		for _, ruleRef := range weapon.Rules {
+			rule, err := sources.rule(ctx, ruleRef.RuleID)
+			if err != nil || len(rule.Effects) == 0 {
+				continue
+			}
+			tier := ruleRef.Tier
+			if tier < 1 || tier > 3 {
+				tier = 1 // as tierPoints does
+			}
+			stats.Rules = append(stats.Rules, WeaponRuleEffects{Tier: tier, Effects: rule.Effects})
+		}
+		return calculator.CalculateWeaponPoints(stats)
+	}
+}
+
+// pointsSources looks up the rules, weapons and wargear units are priced from
+type pointsSources interface {
+	rule(ctx context.Context, id primitive.ObjectID) (*models.Rule, error)
+	weapon(ctx context.Context, id primitive.ObjectID) (*models.Weapon, error)
+	wargear(ctx context.Context, id primitive.ObjectID) (*models.WarGear, error)
+}
+
+func (ups *UnitPointsService) rule(ctx context.Context, id primitive.ObjectID) (*models.Rule, error) {
+	return ups.ruleService.GetRuleByID(ctx, id.Hex())
+}
+
+func (ups *UnitPointsService) weapon(ctx context.Context, id primitive.ObjectID) (*models.Weapon, error) {
+	return ups.weaponService.GetWeaponByID(ctx, id.Hex())
+}
+
+func (ups *UnitPointsService) wargear(ctx context.Context, id primitive.ObjectID) (*models.WarGear, error) {
+	return ups.wargearService.GetWarGearByID(ctx, id.Hex())
+}
+
+// unitPricing is what a unit's points are calculated from: the season, where
+// its rules, weapons and wargear are looked up and how its weapons are priced
+type unitPricing struct {
+	season       *models.PointsSeason
+	sources      pointsSources
+	weaponPoints weaponPointsFunc
+}
+
+// calculateUnitPoints calculates a unit's points with pricing
+func (ups *UnitPointsService) calculateUnitPoints(ctx context.Context, unit *models.Unit, pricing unitPricing) (*UnitPointsBreakdown, error) {
 	if unit == nil {
 		return nil, fmt.Errorf("unit cannot be nil")
 	}
 
-	breakdown := &UnitPointsBreakdown{}
+	breakdown := &UnitPointsBreakdown{Season: pricing.season.Name}
 
 	// Calculate base unit cost from stats
-	baseCost := ups.calculateBaseUnitCost(unit.Melee, unit.Ranged, unit.Morale, unit.Defense)
+	baseCost := calculateBaseUnitCost(&pricing.season.Units, unit.Melee, unit.Ranged, unit.Morale, unit.Defense)
 	breakdown.BaseCost = baseCost
 
 	// Calculate unit rules cost (rules × number of models)
-	unitRulesCost := ups.calculateUnitRulesCost(ctx, unit.Rules, unit.Amount)
+	unitRulesCost := ups.calculateUnitRulesCost(ctx, pricing.sources, unit.Rules, unit.Amount)
 	breakdown.UnitRulesCost = unitRulesCost
 
 	// Calculate weapons cost (weapon points × quantity + weapon rules × models)
-	weaponsCost, weaponRulesCost := ups.calculateWeaponsCost(ctx, unit.Weapons, unit.Amount)
+	weaponsCost, weaponRulesCost := ups.calculateWeaponsCost(ctx, pricing, unit)
 	breakdown.WeaponsCost = weaponsCost
 	breakdown.WeaponRulesCost = weaponRulesCost
 
 	// Calculate wargear cost (wargear rules × models)
-	wargearCost := ups.calculateWargearCost(ctx, unit.WarGear, unit.Amount)
+	wargearCost := ups.calculateWargearCost(ctx, pricing.sources, unit.WarGear, unit.Amount)
 	breakdown.WargearCost = wargearCost
 
 	// Calculate total points
@@ -67,39 +170,33 @@ func (ups *UnitPointsService) CalculateUnitPoints(ctx context.Context, unit *mod
 }
 
 // calculateBaseUnitCost calculates the base cost from unit stats
-func (ups *UnitPointsService) calculateBaseUnitCost(melee, ranged, morale, defense int) int {
-	// Base formula: (melee + ranged + morale + defense) * 2 + 10
+func calculateBaseUnitCost(config *models.UnitPointsConfig, melee, ranged, morale, defense int) int {
+	// Base formula: (melee + ranged + morale + defense) * 2 + 10 by default
 	// This gives a reasonable base cost that scales with stats
 	statSum := melee + ranged + morale + defense
-	baseCost := statSum*2 + 10
+	baseCost := statSum*config.StatMultiplier + config.BaseCost
 
-	// Ensure minimum cost of 5 points
-	if baseCost < 5 {
-		baseCost = 5
+	// Ensure the season's minimum cost (5 points by default)
+	if baseCost < config.MinCost {
+		baseCost = config.MinCost
 	}
 
 	return baseCost
 }
 
 // calculateUnitRulesCost calculates the cost of rules attached to the unit
-func (ups *UnitPointsService) calculateUnitRulesCost(ctx context.Context, rules []models.RuleReference, modelCount int) int {
+func (ups *UnitPointsService) calculateUnitRulesCost(ctx context.Context, sources pointsSources, rules []models.RuleReference, modelCount int) int {
 	totalCost := 0
 
 	for _, ruleRef := range rules {
 		// Get the rule to access its points
-		rule, err := ups.ruleService.GetRuleByID(ctx, ruleRef.RuleID.Hex())
+		rule, err := sources.rule(ctx, ruleRef.RuleID)
 		if err != nil {
 			// If rule not found, skip it
 			continue
 		}
 
-		// Get the appropriate tier cost
-		tierIndex := ruleRef.Tier - 1
-		if tierIndex < 0 || tierIndex >= len(rule.Points) {
-			tierIndex = 0 // Default to first tier if invalid
-		}
-
-		ruleCost := rule.Points[tierIndex]
+		ruleCost := tierPoints(rule, ruleRef.Tier)
 
 		// Multiply by number of models in the unit
 		totalCost += ruleCost * modelCount
@@ -108,39 +205,60 @@ func (ups *UnitPointsService) calculateUnitRulesCost(ctx context.Context, rules
 	return totalCost
 }
 
-// calculateWeaponsCost calculates the cost of weapons and their rules
-func (ups *UnitPointsService) calculateWeaponsCost(ctx context.Context, weapons []models.WeaponReference, modelCount int) (int, int) {
+// tierPoints returns what a rule costs at tier. An invalid tier costs the
+// first tier, and a rule without points costs nothing.
+func tierPoints(rule *models.Rule, tier int) int {
+	if len(rule.Points) == 0 {
+		return 0
+	}
+	tierIndex := tier - 1
+	if tierIndex < 0 || tierIndex >= len(rule.Points) {
+		tierIndex = 0 // Default to first tier if invalid
+	}
+	return rule.Points[tierIndex]
+}
+
+// wielderSkill is the skill a unit hits with using a weapon of weaponType
+func wielderSkill(unit *models.Unit, weaponType string) int {
+	if strings.EqualFold(weaponType, "Melee") {
+		return unit.Melee
+	}
+	return unit.Ranged
+}
+
+// calculateWeaponsCost calculates the cost of a unit's weapons and their rules
+func (ups *UnitPointsService) calculateWeaponsCost(ctx context.Context, pricing unitPricing, unit *models.Unit) (int, int) {
+	modelCount := unit.Amount
 	weaponsCost := 0
 	weaponRulesCost := 0
+	statistical := pricing.season.Weapons.Pricing == WeaponPricingStatistical
 
-	for _, weaponRef := range weapons {
+	for _, weaponRef := range unit.Weapons {
 		// Get the weapon to access its points and rules
-		weapon, err := ups.weaponService.GetWeaponByID(ctx, weaponRef.WeaponID.Hex())
+		weapon, err := pricing.sources.weapon(ctx, weaponRef.WeaponID)
 		if err != nil {
 			// If weapon not found, skip it
 			continue
 		}
 
 		// Calculate weapon base cost (weapon points × quantity)
-		weaponBaseCost := weapon.Points * weaponRef.Quantity
+		weaponBaseCost := pricing.weaponPoints(ctx, pricing.sources, weapon, wielderSkill(unit, weapon.Type)) * weaponRef.Quantity
 		weaponsCost += weaponBaseCost
 
 		// Calculate weapon rules cost (weapon rules × models)
 		for _, ruleRef := range weapon.Rules {
 			// Get the rule to access its points
-			rule, err := ups.ruleService.GetRuleByID(ctx, ruleRef.RuleID.Hex())
+			rule, err := pricing.sources.rule(ctx, ruleRef.RuleID)
 			if err != nil {
 				// If rule not found, skip it
 				continue
 			}
-
-			// Get the appropriate tier cost
-			tierIndex := ruleRef.Tier - 1
-			if tierIndex < 0 || tierIndex >= len(rule.Points) {
-				tierIndex = 0 // Default to first tier if invalid
+			if statistical && pricedIntoWeapon(rule, weapon.Type) {
+				// Already paid for in the weapon's points
+				continue
 			}
 
-			ruleCost := rule.Points[tierIndex]
+			ruleCost := tierPoints(rule, ruleRef.Tier)
 
 			// Multiply by number of models in the unit
 			weaponRulesCost += ruleCost * modelCount
@@ -151,12 +269,12 @@ func (ups *UnitPointsService) calculateWeaponsCost(ctx context.Context, weapons
 }
 
 // calculateWargearCost calculates the cost of wargear rules
-func (ups *UnitPointsService) calculateWargearCost(ctx context.Context, wargear []primitive.ObjectID, modelCount int) int {
+func (ups *UnitPointsService) calculateWargearCost(ctx context.Context, sources pointsSources, wargear []primitive.ObjectID, modelCount int) int {
 	totalCost := 0
 
 	for _, wargearID := range wargear {
 		// Get the wargear to access its rules
-		wargear, err := ups.wargearService.GetWarGearByID(ctx, wargearID.Hex())
+		wargear, err := sources.wargear(ctx, wargearID)
 		if err != nil {
 			// If wargear not found, skip it
 			continue
@@ -165,19 +283,13 @@ func (ups *UnitPointsService) calculateWargearCost(ctx context.Context, wargear
 		// Calculate wargear rules cost (wargear rules × models)
 		for _, ruleRef := range wargear.Rules {
 			// Get the rule to access its points
-			rule, err := ups.ruleService.GetRuleByID(ctx, ruleRef.RuleID.Hex())
+			rule, err := sources.rule(ctx, ruleRef.RuleID)
 			if err != nil {
 				// If rule not found, skip it
 				continue
 			}
 
-			// Get the appropriate tier cost
-			tierIndex := ruleRef.Tier - 1
-			if tierIndex < 0 || tierIndex >= len(rule.Points) {
-				tierIndex = 0 // Default to first tier if invalid
-			}
-
-			ruleCost := rule.Points[tierIndex]
+			ruleCost := tierPoints(rule, ruleRef.Tier)
 
 			// Multiply by number of models in the unit
 			totalCost += ruleCost * modelCount
@@ -4,14 +4,25 @@ import (
 	"math"
 	"strconv"
 	"strings"
+
+	"grimdank-database/models"
 )
 
 // WeaponPointsCalculator handles dynamic points calculation for weapons
-type WeaponPointsCalculator struct{}
+type WeaponPointsCalculator struct {
+	config *models.WeaponPointsConfig
+}
 
 // NewWeaponPointsCalculator creates a new weapon points calculator
 func NewWeaponPointsCalculator() *WeaponPointsCalculator {
-	return &WeaponPointsCalculator{}
+	return NewWeaponPointsCalculatorWithConfig(DefaultWeaponPointsConfig())
+}
+
+// NewWeaponPointsCalculatorWithConfig creates a weapon points calculator with the weights of a season
+func NewWeaponPointsCalculatorWithConfig(config *models.WeaponPointsConfig) *WeaponPointsCalculator {
+	return &WeaponPointsCalculator{
+		config: config,
+	}
 }
 
 // WeaponStats represents the key stats for weapon points calculation
@@ -20,10 +31,33 @@ type WeaponStats struct {
 	Attacks string `json:"attacks"` // Number of attacks (can be "1", "2", "3", "X", etc.)
 	AP      string `json:"ap"`      // Armor Piercing value (e.g., "0", "1", "2", "3")
 	Type    string `json:"type"`    // Weapon type (Ranged/Melee)
+
+	// Used by statistical pricing only
+	Skill int                 `json:"skill,omitempty"` // hit target of the wielder, 0 for the season's
+	Rules []WeaponRuleEffects `json:"rules,omitempty"` // effects of the weapon's rules
+}
+
+// WeaponStatsOf returns the stats of a stored weapon
+func WeaponStatsOf(weapon *models.Weapon) WeaponStats {
+	return WeaponStats{
+		Range:   weapon.Range,
+		Attacks: strconv.Itoa(weapon.Attacks),
+		AP:      weapon.AP,
+		Type:    weapon.Type,
+	}
 }
 
-// CalculateWeaponPoints calculates the base points for a weapon based on its stats
+// CalculateWeaponPoints calculates the base points for a weapon based on its
+// stats, the way the season prices weapons
 func (wpc *WeaponPointsCalculator) CalculateWeaponPoints(stats WeaponStats) int {
+	if wpc.config.Pricing == WeaponPricingStatistical {
+		return wpc.CalculateExpectedWounds(stats).Points
+	}
+	return wpc.legacyPoints(stats)
+}
+
+// legacyPoints prices a weapon by combining weighted scores of its stats
+func (wpc *WeaponPointsCalculator) legacyPoints(stats WeaponStats) int {
 	// Base calculation using logarithmic scaling
 	// This ensures we get reasonable point ranges from 1 to ~100
 
@@ -32,34 +66,38 @@ func (wpc *WeaponPointsCalculator) CalculateWeaponPoints(stats WeaponStats) int
 	attacksScore := wpc.calculateAttacksScore(stats.Attacks)
 	apScore := wpc.calculateAPScore(stats.AP)
 
-	// Combine scores with weighted importance
-	// Range is most important for ranged weapons, less for melee
-	// Attacks are important for all weapons
-	// AP is moderately important
-	var combinedScore float64
+	// Combine scores with the season's weights for the weapon type
+	weights := wpc.weights(stats.Type)
+	combinedScore := (rangeScore * weights.Range) + (attacksScore * weights.Attacks) + (apScore * weights.AP)
 
-	if stats.Type == "Ranged" || stats.Type == "Weapon" {
-		// For ranged weapons: Range (40%), Attacks (40%), AP (20%)
-		combinedScore = (rangeScore * 0.4) + (attacksScore * 0.4) + (apScore * 0.2)
-	} else {
-		// For melee weapons: Range (10%), Attacks (60%), AP (30%)
-		combinedScore = (rangeScore * 0.1) + (attacksScore * 0.6) + (apScore * 0.3)
+	// Round to nearest integer
+	return int(math.Round(wpc.scale(combinedScore)))
+}
+
+// weights returns the stat weights for a weapon type
+func (wpc *WeaponPointsCalculator) weights(weaponType string) models.StatWeights {
+	if weaponType == "Ranged" || weaponType == "Weapon" {
+		return wpc.config.RangedWeights
 	}
+	return wpc.config.MeleeWeights
+}
 
-	// Apply logarithmic scaling to get reasonable point values
-	// This gives us: 1 point at score 1, ~25 points at score 8, ~50 points at score 10
-	basePoints := math.Pow(1.6, combinedScore/2)
+// scale turns a combined score into points. Logarithmic scaling keeps the
+// points reasonable: with the default weights, 1 point at score 1, ~25 points
+// at score 8 and ~50 points at score 10.
+func (wpc *WeaponPointsCalculator) scale(combinedScore float64) float64 {
+	return wpc.clamp(math.Pow(wpc.config.ScaleBase, combinedScore/2))
+}
 
-	// Ensure minimum of 1 point and maximum of 50 points
-	if basePoints < 1 {
-		basePoints = 1
+// clamp keeps points within the season's minimum and maximum
+func (wpc *WeaponPointsCalculator) clamp(points float64) float64 {
+	if points < float64(wpc.config.MinPoints) {
+		points = float64(wpc.config.MinPoints)
 	}
-	if basePoints > 50 {
-		basePoints = 50
+	if points > float64(wpc.config.MaxPoints) {
+		points = float64(wpc.config.MaxPoints)
 	}
-
-	// Round to nearest integer
-	return int(math.Round(basePoints))
+	return points
 }
 
 // calculateRangeScore calculates the score for weapon range
@@ -69,29 +107,20 @@ func (wpc *WeaponPointsCalculator) calculateRangeScore(rangeValue int, weaponTyp
 		return 1.0
 	}
 
-	// Ranged weapons: cap at 48" and use linear scaling
+	// Ranged weapons: cap at the season's maximum range (48" by default) and use linear scaling
 	// 0-6": 1.0, 7-12": 2.0, 13-18": 3.0, 19-24": 4.0, 25-30": 5.0, 31-36": 6.0, 37-42": 7.0, 43-48": 8.0
 	cappedRange := rangeValue
-	if cappedRange > 48 {
-		cappedRange = 48
+	if cappedRange > wpc.config.MaxRange {
+		cappedRange = wpc.config.MaxRange
 	}
 
-	// Linear scaling: every 6" = 1 point, max 8 points at 48"
-	return math.Min(float64(cappedRange)/6.0, 8.0)
+	// Linear scaling: every 6" = 1 point
+	return float64(cappedRange) / 6.0
 }
 
 // calculateAttacksScore calculates the score for number of attacks
 func (wpc *WeaponPointsCalculator) calculateAttacksScore(attacks string) float64 {
-	// Parse attacks value (handle "1", "2", "3", "X", etc.)
-	attacksValue, err := strconv.Atoi(strings.TrimSpace(attacks))
-	if err != nil {
-		// If we can't parse (e.g., "X"), assume 3 attacks
-		if strings.ToUpper(strings.TrimSpace(attacks)) == "X" {
-			attacksValue = 3
-		} else {
-			attacksValue = 1
-		}
-	}
+	attacksValue := parseAttacks(attacks)
 
 	// Linear scaling with diminishing returns
 	// 1 attack: 1.0, 2 attacks: 2.0, 3 attacks: 2.8, 4 attacks: 3.5, 5+ attacks: 4.0
@@ -136,34 +165,37 @@ func (wpc *WeaponPointsCalculator) calculateAPScore(ap string) float64 {
 	}
 }
 
-// GetWeaponStatsBreakdown returns a detailed breakdown of the calculation
+// pricing returns how the season prices weapons
+func (wpc *WeaponPointsCalculator) pricing() string {
+	if wpc.config.Pricing == WeaponPricingStatistical {
+		return WeaponPricingStatistical
+	}
+	return WeaponPricingLegacy
+}
+
+// GetWeaponStatsBreakdown returns a detailed breakdown of the calculation,
+// with both the legacy and the statistical result
 func (wpc *WeaponPointsCalculator) GetWeaponStatsBreakdown(stats WeaponStats) map[string]interface{} {
 	rangeScore := wpc.calculateRangeScore(stats.Range, stats.Type)
 	attacksScore := wpc.calculateAttacksScore(stats.Attacks)
 	apScore := wpc.calculateAPScore(stats.AP)
 
-	var rangeWeight, attacksWeight, apWeight float64
-	if stats.Type == "Ranged" || stats.Type == "Weapon" {
-		rangeWeight = 0.4
-		attacksWeight = 0.4
-		apWeight = 0.2
-	} else {
-		rangeWeight = 0.1
-		attacksWeight = 0.6
-		apWeight = 0.3
-	}
+	weights := wpc.weights(stats.Type)
+	rangeWeight := weights.Range
+	attacksWeight := weights.Attacks
+	apWeight := weights.AP
 
 	weightedRange := rangeScore * rangeWeight
 	weightedAttacks := attacksScore * attacksWeight
 	weightedAP := apScore * apWeight
 	combinedScore := weightedRange + weightedAttacks + weightedAP
 
-	basePoints := math.Pow(1.6, combinedScore/2)
-	if basePoints < 1 {
-		basePoints = 1
-	}
-	if basePoints > 50 {
-		basePoints = 50
+	basePoints := wpc.scale(combinedScore)
+	legacyPoints := int(math.Round(basePoints))
+	expected := wpc.CalculateExpectedWounds(stats)
+	calculatedPoints := legacyPoints
+	if wpc.pricing() == WeaponPricingStatistical {
+		calculatedPoints = expected.Points
 	}
 
 	return map[string]interface{}{
@@ -185,8 +217,12 @@ func (wpc *WeaponPointsCalculator) GetWeaponStatsBreakdown(stats WeaponStats) ma
 			"weight":   apWeight,
 			"weighted": weightedAP,
 		},
-		"combinedScore":    combinedScore,
-		"calculatedPoints": int(math.Round(basePoints)),
-		"weaponType":       stats.Type,
+		"combinedScore":     combinedScore,
+		"calculatedPoints":  calculatedPoints,
+		"weaponType":        stats.Type,
+		"pricing":           wpc.pricing(),
+		"legacyPoints":      legacyPoints,
+		"statistical":       expected,
+		"statisticalPoints": expected.Points,
 	}
 }
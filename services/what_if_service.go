@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+)
+
+// WhatIfProposal is a set of balance changes to try out. Rules, weapons and
+// wargear replace the stored entities with their IDs. Season replaces the
+// current points season, and RepriceRules prices every rule from its effects
+// or text with the season's rule settings instead of using the points it has.
+type WhatIfProposal struct {
+	Rules        []models.Rule        `json:"rules"`
+	Weapons      []models.Weapon      `json:"weapons"`
+	WarGear      []models.WarGear     `json:"wargear"`
+	Season       *models.PointsSeason `json:"season,omitempty"`
+	RepriceRules bool                 `json:"repriceRules"`
+}
+
+// PointsDelta is an entity whose points the proposal would change
+type PointsDelta struct {
+	PointsChange
+	Delta int `json:"delta"`
+}
+
+// ListOverLimit is an army list that would cost more than its points limit
+type ListOverLimit struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Limit       int    `json:"limit"`
+	Points      int    `json:"points"`
+	Over        int    `json:"over"`
+	AlreadyOver bool   `json:"alreadyOver"`
+}
+
+// WhatIfReport says what a proposal would do to points, biggest changes
+// first. From and To are the points calculated before and after the
+// proposal, which match the stored points while those are up to date.
+type WhatIfReport struct {
+	Season    string          `json:"season"`
+	Checked   int             `json:"checked"`
+	Deltas    []PointsDelta   `json:"deltas"`
+	OverLimit []ListOverLimit `json:"overLimit"`
+}
+
+// WhatIfService works out what proposed balance changes would do to the
+// points of weapons, wargear, units and army lists. It calculates them the way
+// RecalculationService does, but in memory, and never writes anything.
+type WhatIfService struct {
+	unitRepo     *repositories.UnitRepository
+	armyListRepo *repositories.ArmyListRepository
+	repos        map[string]*repositories.BaseRepository
+	unitPoints   *UnitPointsService
+}
+
+func NewWhatIfService(
+	ruleRepo *repositories.RuleRepository,
+	weaponRepo *repositories.WeaponRepository,
+	wargearRepo *repositories.WarGearRepository,
+	unitRepo *repositories.UnitRepository,
+	armyListRepo *repositories.ArmyListRepository,
+	unitPoints *UnitPointsService,
+) *WhatIfService {
+	return &WhatIfService{
+		unitRepo:     unitRepo,
+		armyListRepo: armyListRepo,
+		repos: map[string]*repositories.BaseRepository{
+			"rules":     ruleRepo.BaseRepository,
+			"weapons":   weaponRepo.BaseRepository,
+			"wargear":   wargearRepo.BaseRepository,
+			"units":     unitRepo.BaseRepository,
+			"armylists": armyListRepo.BaseRepository,
+		},
+		unitPoints: unitPoints,
+	}
+}
+
+// WhatIf calculates the points of everything the proposal affects with and
+// without it. A new season or repriced rules affect everything; edits affect
+// the edited entities and what depends on them.
+func (s *WhatIfService) WhatIf(ctx context.Context, proposal *WhatIfProposal) (*WhatIfReport, error) {
+	edited, err := s.validateProposal(ctx, proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	before := s.scenario(s.unitPoints.season())
+	after := s.scenario(s.unitPoints.season())
+	if proposal.Season != nil {
+		after = s.scenario(proposal.Season)
+	}
+	if proposal.RepriceRules {
+		after.repriced = map[primitive.ObjectID]*models.Rule{}
+		after.ruleCalculator = NewPointsCalculatorWithConfig(&after.season.Rules)
+	}
+	for i := range proposal.Rules {
+		after.proposedRules[proposal.Rules[i].ID] = &proposal.Rules[i]
+	}
+	for i := range proposal.Weapons {
+		after.proposedWeapons[proposal.Weapons[i].ID] = &proposal.Weapons[i]
+	}
+	for i := range proposal.WarGear {
+		after.proposedWarGear[proposal.WarGear[i].ID] = &proposal.WarGear[i]
+	}
+
+	var affected map[string][]primitive.ObjectID
+	if proposal.Season != nil || proposal.RepriceRules {
+		affected, err = everythingWithPoints(ctx, s.repos)
+	} else {
+		affected, err = pointsDependentsOf(ctx, s.repos, edited)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := &WhatIfReport{Season: after.season.Name, Deltas: []PointsDelta{}, OverLimit: []ListOverLimit{}}
+	for _, collection := range recalculationOrder {
+		for _, id := range affected[collection] {
+			if err := s.compare(ctx, collection, id, before, after, report); err != nil {
+				return nil, fmt.Errorf("failed to calculate %s %s: %w", collection, id.Hex(), err)
+			}
+		}
+	}
+
+	sort.SliceStable(report.Deltas, func(i, j int) bool {
+		return abs(report.Deltas[i].Delta) > abs(report.Deltas[j].Delta)
+	})
+	sort.SliceStable(report.OverLimit, func(i, j int) bool {
+		return report.OverLimit[i].Over > report.OverLimit[j].Over
+	})
+	return report, nil
+}
+
+// validateProposal checks the proposed entities and season the way their
+// services would, and returns the edited entities. Only stored entities can
+// be edited.
+func (s *WhatIfService) validateProposal(ctx context.Context, proposal *WhatIfProposal) ([]graphNode, error) {
+	var edited []graphNode
+	check := func(collection string, id primitive.ObjectID, err error) error {
+		if err != nil {
+			return utils.NewValidationError(collection, err.Error())
+		}
+		if id.IsZero() {
+			return utils.NewValidationError(collection, "proposed changes need the ID of the entity they change")
+		}
+		stored, err := s.repos[collection].Stored(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !stored {
+			return fmt.Errorf("%s %s not found", collection, id.Hex())
+		}
+		edited = append(edited, graphNode{collection, id})
+		return nil
+	}
+
+	for i := range proposal.Rules {
+		if err := check("rules", proposal.Rules[i].ID, validateRule(&proposal.Rules[i])); err != nil {
+			return nil, err
+		}
+	}
+	for i := range proposal.Weapons {
+		if err := check("weapons", proposal.Weapons[i].ID, validateWeapon(&proposal.Weapons[i])); err != nil {
+			return nil, err
+		}
+	}
+	for i := range proposal.WarGear {
+		if err := check("wargear", proposal.WarGear[i].ID, validateWarGear(&proposal.WarGear[i])); err != nil {
+			return nil, err
+		}
+	}
+
+	if proposal.Season != nil {
+		if proposal.Season.Name == "" {
+			proposal.Season.Name = "proposal"
+		}
+		if err := ValidatePointsSeason(proposal.Season); err != nil {
+			return nil, err
+		}
+	}
+	return edited, nil
+}
+
+// compare calculates the points of one entity before and after the proposal
+// and adds them to the report. Entities in the trash are skipped.
+func (s *WhatIfService) compare(ctx context.Context, collection string, id primitive.ObjectID, before, after *whatIfScenario, report *WhatIfReport) error {
+	_, from, err := before.points(ctx, collection, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return err
+	}
+	name, to, err := after.points(ctx, collection, id)
+	if err != nil {
+		return err
+	}
+
+	report.Checked++
+	if to != from {
+		report.Deltas = append(report.Deltas, PointsDelta{
+			PointsChange: PointsChange{EntityType: collection, ID: id.Hex(), Name: name, From: from, To: to},
+			Delta:        to - from,
+		})
+	}
+
+	if collection != "armylists" {
+		return nil
+	}
+	armyList, err := s.armyListRepo.GetArmyListByID(ctx, id.Hex())
+	if err != nil {
+		return err
+	}
+	if armyList.PointsLimit > 0 && to > armyList.PointsLimit {
+		report.OverLimit = append(report.OverLimit, ListOverLimit{
+			ID:          id.Hex(),
+			Name:        name,
+			Limit:       armyList.PointsLimit,
+			Points:      to,
+			Over:        to - armyList.PointsLimit,
+			AlreadyOver: from > armyList.PointsLimit,
+		})
+	}
+	return nil
+}
+
+// whatIfScenario is the data points are calculated from on one side of a
+// proposal: the stored entities with the proposed ones laid over them, priced
+// in a season. It remembers unit points, which army lists repeat.
+type whatIfScenario struct {
+	service      *WhatIfService
+	season       *models.PointsSeason
+	weaponPoints weaponPointsFunc
+
+	proposedRules   map[primitive.ObjectID]*models.Rule
+	proposedWeapons map[primitive.ObjectID]*models.Weapon
+	proposedWarGear map[primitive.ObjectID]*models.WarGear
+
+	// ruleCalculator reprices rules from their text when it is set
+	ruleCalculator *PointsCalculator
+	repriced       map[primitive.ObjectID]*models.Rule
+
+	unitPoints map[primitive.ObjectID]int
+}
+
+func (s *WhatIfService) scenario(season *models.PointsSeason) *whatIfScenario {
+	return &whatIfScenario{
+		service:         s,
+		season:          season,
+		weaponPoints:    seasonWeaponPoints(season),
+		proposedRules:   map[primitive.ObjectID]*models.Rule{},
+		proposedWeapons: map[primitive.ObjectID]*models.Weapon{},
+		proposedWarGear: map[primitive.ObjectID]*models.WarGear{},
+		unitPoints:      map[primitive.ObjectID]int{},
+	}
+}
+
+func (sc *whatIfScenario) rule(ctx context.Context, id primitive.ObjectID) (*models.Rule, error) {
+	if rule, ok := sc.repriced[id]; ok {
+		return rule, nil
+	}
+	rule, ok := sc.proposedRules[id]
+	if !ok {
+		var err error
+		if rule, err = sc.service.unitPoints.rule(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	if sc.ruleCalculator == nil {
+		return rule, nil
+	}
+
+	// Rules handed out by the cache are shared, so reprice a copy
+	repriced := *rule
+	repriced.Points = sc.ruleCalculator.CalculateRulePoints(rule)
+	sc.repriced[id] = &repriced
+	return &repriced, nil
+}
+
+func (sc *whatIfScenario) weapon(ctx context.Context, id primitive.ObjectID) (*models.Weapon, error) {
+	if weapon, ok := sc.proposedWeapons[id]; ok {
+		return weapon, nil
+	}
+	return sc.service.unitPoints.weapon(ctx, id)
+}
+
+func (sc *whatIfScenario) wargear(ctx context.Context, id primitive.ObjectID) (*models.WarGear, error) {
+	if wargear, ok := sc.proposedWarGear[id]; ok {
+		return wargear, nil
+	}
+	return sc.service.unitPoints.wargear(ctx, id)
+}
+
+// points returns an entity's name and its calculated points in the scenario
+func (sc *whatIfScenario) points(ctx context.Context, collection string, id primitive.ObjectID) (string, int, error) {
+	switch collection {
+	case "weapons":
+		weapon, err := sc.weapon(ctx, id)
+		if err != nil {
+			return "", 0, err
+		}
+		return weapon.Name, sc.weaponPoints(ctx, sc, weapon, 0), nil
+
+	case "wargear":
+		wargear, err := sc.wargear(ctx, id)
+		if err != nil {
+			return "", 0, err
+		}
+		return wargear.Name, wargearPoints(ctx, sc, wargear), nil
+
+	case "units":
+		unit, err := sc.service.unitRepo.GetUnitByID(ctx, id.Hex())
+		if err != nil {
+			return "", 0, err
+		}
+		points, err := sc.unit(ctx, unit)
+		return unit.Name, points, err
+
+	case "armylists":
+		armyList, err := sc.service.armyListRepo.GetArmyListByID(ctx, id.Hex())
+		if err != nil {
+			return "", 0, err
+		}
+		units, err := sc.service.unitRepo.GetUnitsByIDs(ctx, armyList.Units)
+		if err != nil {
+			return "", 0, err
+		}
+		unitPoints := make(map[primitive.ObjectID]int, len(units))
+		for i := range units {
+			if unitPoints[units[i].ID], err = sc.unit(ctx, &units[i]); err != nil {
+				return "", 0, err
+			}
+		}
+		// A list pays for a unit each time it fields it
+		points := 0
+		for _, unitID := range armyList.Units {
+			points += unitPoints[unitID]
+		}
+		return armyList.Name, points, nil
+	}
+	return "", 0, fmt.Errorf("%s have no points", collection)
+}
+
+// unit returns the calculated points of a unit in the scenario
+func (sc *whatIfScenario) unit(ctx context.Context, unit *models.Unit) (int, error) {
+	if points, ok := sc.unitPoints[unit.ID]; ok {
+		return points, nil
+	}
+	pricing := unitPricing{season: sc.season, sources: sc, weaponPoints: sc.weaponPoints}
+	breakdown, err := sc.service.unitPoints.calculateUnitPoints(ctx, unit, pricing)
+	if err != nil {
+		return 0, err
+	}
+	sc.unitPoints[unit.ID] = breakdown.TotalPoints
+	return breakdown.TotalPoints, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
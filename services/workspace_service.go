@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DefaultWorkspace holds the data of requests that name no workspace. It is
+// open to everyone and is the only workspace that publishes official factions.
+const DefaultWorkspace = repositories.DefaultWorkspace
+
+// WithWorkspace returns a context whose reads and writes are scoped to workspace
+func WithWorkspace(ctx context.Context, workspace string) context.Context {
+	return repositories.WithWorkspace(ctx, workspace)
+}
+
+// WorkspaceFrom returns the workspace ctx is scoped to
+func WorkspaceFrom(ctx context.Context) string {
+	return repositories.WorkspaceFrom(ctx)
+}
+
+// workspaceSlug is what a workspace slug looks like, so it can also be a subdomain
+var workspaceSlug = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,38}[a-z0-9])?$`)
+
+// CollectionStats counts the entities of one type in a workspace
+type CollectionStats struct {
+	Live    int64 `json:"live"`
+	Trashed int64 `json:"trashed"`
+}
+
+// WorkspaceStats sums up what a workspace holds. Official factions shared
+// from the default workspace are not counted.
+type WorkspaceStats struct {
+	Workspace   string                     `json:"workspace"`
+	Members     int                        `json:"members"`
+	Collections map[string]CollectionStats `json:"collections"`
+}
+
+// WorkspaceService manages workspaces and their members. Who is asking is
+// taken from the actor of the request, which is not authenticated; membership
+// keeps groups apart rather than secures them.
+type WorkspaceService struct {
+	repo *repositories.WorkspaceRepository
+	// counted are the repositories whose entities the stats count
+	counted map[string]*repositories.BaseRepository
+}
+
+func NewWorkspaceService(
+	repo *repositories.WorkspaceRepository,
+	ruleRepo *repositories.RuleRepository,
+	weaponRepo *repositories.WeaponRepository,
+	wargearRepo *repositories.WarGearRepository,
+	unitRepo *repositories.UnitRepository,
+	armyBookRepo *repositories.ArmyBookRepository,
+	armyListRepo *repositories.ArmyListRepository,
+	factionRepo *repositories.FactionRepository,
+) *WorkspaceService {
+	return &WorkspaceService{
+		repo: repo,
+		counted: map[string]*repositories.BaseRepository{
+			"rules":     ruleRepo.BaseRepository,
+			"weapons":   weaponRepo.BaseRepository,
+			"wargear":   wargearRepo.BaseRepository,
+			"units":     unitRepo.BaseRepository,
+			"armybooks": armyBookRepo.BaseRepository,
+			"armylists": armyListRepo.BaseRepository,
+			"factions":  factionRepo.BaseRepository,
+		},
+	}
+}
+
+// CreateWorkspace creates a workspace owned by the actor of ctx
+func (s *WorkspaceService) CreateWorkspace(ctx context.Context, workspace *models.Workspace) (*models.Workspace, error) {
+	if !workspaceSlug.MatchString(workspace.Slug) {
+		return nil, utils.NewValidationError("slug", "must be 1 to 40 lowercase letters, digits or hyphens, not starting or ending with a hyphen")
+	}
+	if workspace.Slug == DefaultWorkspace {
+		return nil, utils.NewValidationError("slug", fmt.Sprintf("%q is reserved", DefaultWorkspace))
+	}
+	if workspace.Name == "" {
+		workspace.Name = workspace.Slug
+	}
+
+	actor := changeFrom(ctx).Actor
+	if actor == AnonymousActor {
+		return nil, utils.NewValidationError("actor", "name yourself in the X-Actor header to own the workspace")
+	}
+	workspace.Members = []models.WorkspaceMember{{Actor: actor, Role: models.WorkspaceRoleOwner, AddedAt: time.Now()}}
+
+	if err := s.repo.CreateWorkspace(ctx, workspace); err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return workspace, nil
+}
+
+// ListWorkspaces returns the workspaces the actor of ctx is a member of
+func (s *WorkspaceService) ListWorkspaces(ctx context.Context) ([]models.Workspace, error) {
+	workspaces, err := s.repo.ListWorkspacesOf(ctx, changeFrom(ctx).Actor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	return workspaces, nil
+}
+
+// Authorize checks that the actor of ctx may use the workspace. Everyone may
+// use the default workspace.
+func (s *WorkspaceService) Authorize(ctx context.Context, slug string) error {
+	if slug == DefaultWorkspace {
+		return nil
+	}
+	_, _, err := s.lookup(ctx, slug)
+	return err
+}
+
+// GetWorkspace returns a workspace the actor of ctx is a member of
+func (s *WorkspaceService) GetWorkspace(ctx context.Context, slug string) (*models.Workspace, error) {
+	if slug == DefaultWorkspace {
+		return nil, utils.NewValidationError("slug", "the default workspace has no members or settings")
+	}
+	workspace, _, err := s.lookup(ctx, slug)
+	return workspace, err
+}
+
+// SetMember adds actor to a workspace or changes their role. Only owners may
+// do so, and a workspace always keeps at least one owner.
+func (s *WorkspaceService) SetMember(ctx context.Context, slug, actor, role string) (*models.Workspace, error) {
+	if actor == "" || actor == AnonymousActor {
+		return nil, utils.NewValidationError("actor", "a member needs a name")
+	}
+	if role == "" {
+		role = models.WorkspaceRoleMember
+	}
+	if role != models.WorkspaceRoleOwner && role != models.WorkspaceRoleMember {
+		return nil, utils.NewValidationError("role", fmt.Sprintf("must be %q or %q", models.WorkspaceRoleOwner, models.WorkspaceRoleMember))
+	}
+
+	workspace, err := s.ownerOf(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]models.WorkspaceMember, 0, len(workspace.Members)+1)
+	found := false
+	for _, member := range workspace.Members {
+		if member.Actor == actor {
+			member.Role = role
+			found = true
+		}
+		members = append(members, member)
+	}
+	if !found {
+		members = append(members, models.WorkspaceMember{Actor: actor, Role: role, AddedAt: time.Now()})
+	}
+	return s.saveMembers(ctx, workspace, members)
+}
+
+// RemoveMember takes actor out of a workspace. Owners may remove anyone and
+// members may remove themselves, as long as an owner is left.
+func (s *WorkspaceService) RemoveMember(ctx context.Context, slug, actor string) (*models.Workspace, error) {
+	workspace, caller, err := s.lookup(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Role != models.WorkspaceRoleOwner && caller.Actor != actor {
+		return nil, utils.NewForbiddenError("only owners can remove other members")
+	}
+
+	members := make([]models.WorkspaceMember, 0, len(workspace.Members))
+	for _, member := range workspace.Members {
+		if member.Actor != actor {
+			members = append(members, member)
+		}
+	}
+	if len(members) == len(workspace.Members) {
+		return nil, fmt.Errorf("member %s not found", actor)
+	}
+	return s.saveMembers(ctx, workspace, members)
+}
+
+// Stats counts the entities of a workspace the actor of ctx may use
+func (s *WorkspaceService) Stats(ctx context.Context, slug string) (*WorkspaceStats, error) {
+	stats := &WorkspaceStats{Workspace: slug, Collections: make(map[string]CollectionStats, len(s.counted))}
+	if slug != DefaultWorkspace {
+		workspace, _, err := s.lookup(ctx, slug)
+		if err != nil {
+			return nil, err
+		}
+		stats.Members = len(workspace.Members)
+	}
+
+	scoped := WithWorkspace(ctx, slug)
+	own := repositories.OwnedBy(slug)
+	trashed := bson.M{"deletedAt": bson.M{"$exists": true}}
+	for key, value := range own {
+		trashed[key] = value
+	}
+	for entityType, repo := range s.counted {
+		live, err := repo.Count(scoped, own)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", entityType, err)
+		}
+		deleted, err := repo.Count(scoped, trashed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count trashed %s: %w", entityType, err)
+		}
+		stats.Collections[entityType] = CollectionStats{Live: live, Trashed: deleted}
+	}
+	return stats, nil
+}
+
+// lookup finds a workspace and the membership of the actor of ctx in it
+func (s *WorkspaceService) lookup(ctx context.Context, slug string) (*models.Workspace, models.WorkspaceMember, error) {
+	workspace, err := s.repo.GetWorkspaceBySlug(ctx, slug)
+	if err != nil {
+		return nil, models.WorkspaceMember{}, err
+	}
+
+	actor := changeFrom(ctx).Actor
+	for _, member := range workspace.Members {
+		if member.Actor == actor {
+			return workspace, member, nil
+		}
+	}
+	return nil, models.WorkspaceMember{}, utils.NewForbiddenError(fmt.Sprintf("%s is not a member of workspace %s", actor, slug))
+}
+
+func (s *WorkspaceService) ownerOf(ctx context.Context, slug string) (*models.Workspace, error) {
+	workspace, caller, err := s.lookup(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Role != models.WorkspaceRoleOwner {
+		return nil, utils.NewForbiddenError("only owners can manage members")
+	}
+	return workspace, nil
+}
+
+func (s *WorkspaceService) saveMembers(ctx context.Context, workspace *models.Workspace, members []models.WorkspaceMember) (*models.Workspace, error) {
+	owners := 0
+	for _, member := range members {
+		if member.Role == models.WorkspaceRoleOwner {
+			owners++
+		}
+	}
+	if owners == 0 {
+		return nil, utils.NewValidationError("members", "a workspace needs at least one owner")
+	}
+
+	if err := s.repo.SetMembers(ctx, workspace.Slug, members); err != nil {
+		return nil, fmt.Errorf("failed to update members: %w", err)
+	}
+	workspace.Members = members
+	return workspace, nil
+}
@@ -0,0 +1,291 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestAssetService stores asset blobs in a temporary directory, which it returns
+func newTestAssetService(t *testing.T, maxSize int64) (*services.AssetService, string) {
+	dir := t.TempDir()
+	blobs, err := repositories.NewFileBlobStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+	repo := repositories.NewAssetRepository(repositories.NewWorkspaceStore(testDB.Store).Collection("assets"))
+	return services.NewAssetService(repo, blobs, testRepos.UnitRepo, testRepos.WarGearRepo, testRepos.FactionRepo, maxSize), dir
+}
+
+// testImage encodes a width by height image with encode
+func testImage(t *testing.T, width, height int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(buf *bytes.Buffer, img image.Image) error  { return png.Encode(buf, img) }
+func encodeJPEG(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) }
+func encodeGIF(buf *bytes.Buffer, img image.Image) error  { return gif.Encode(buf, img, nil) }
+
+func TestAssets(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	assets, dir := newTestAssetService(t, 1<<20)
+
+	t.Run("PNG", func(t *testing.T) {
+		content := testImage(t, 600, 300, encodePNG)
+		asset, err := assets.UploadAsset(ctx, "../../banner.png", "image/png", bytes.NewReader(content))
+		if err != nil {
+			t.Fatalf("Failed to upload asset: %v", err)
+		}
+		if asset.Filename != "banner.png" || asset.ContentType != "image/png" || asset.Width != 600 || asset.Height != 300 {
+			t.Errorf("Unexpected asset metadata: %+v", asset)
+		}
+
+		_, stored, err := assets.ReadAsset(ctx, asset.ID.Hex(), false)
+		if err != nil || !bytes.Equal(stored, content) {
+			t.Fatalf("Expected the uploaded bytes back, got %d bytes, %v", len(stored), err)
+		}
+
+		_, thumbnail, err := assets.ReadAsset(ctx, asset.ID.Hex(), true)
+		if err != nil {
+			t.Fatalf("Failed to read thumbnail: %v", err)
+		}
+		decoded, err := png.Decode(bytes.NewReader(thumbnail))
+		if err != nil {
+			t.Fatalf("Expected a PNG thumbnail: %v", err)
+		}
+		if size := decoded.Bounds().Size(); size.X != services.ThumbnailSize || size.Y != services.ThumbnailSize/2 {
+			t.Errorf("Expected the thumbnail to keep the aspect ratio, got %v", size)
+		}
+		if asset.Thumbnail == nil || asset.Thumbnail.Width != decoded.Bounds().Dx() {
+			t.Errorf("Expected the thumbnail in the metadata, got %+v", asset.Thumbnail)
+		}
+	})
+
+	t.Run("JPEG", func(t *testing.T) {
+		asset, err := assets.UploadAsset(ctx, "card.jpg", "", bytes.NewReader(testImage(t, 100, 400, encodeJPEG)))
+		if err != nil {
+			t.Fatalf("Failed to upload asset: %v", err)
+		}
+		_, thumbnail, err := assets.ReadAsset(ctx, asset.ID.Hex(), true)
+		if err != nil {
+			t.Fatalf("Failed to read thumbnail: %v", err)
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail))
+		if err != nil || config.Width != 64 || config.Height != 256 {
+			t.Errorf("Expected a 64x256 JPEG thumbnail, got %+v, %v", config, err)
+		}
+	})
+
+	t.Run("GIF Has No Thumbnail", func(t *testing.T) {
+		asset, err := assets.UploadAsset(ctx, "icon.gif", "image/gif", bytes.NewReader(testImage(t, 32, 32, encodeGIF)))
+		if err != nil {
+			t.Fatalf("Failed to upload asset: %v", err)
+		}
+		if asset.Thumbnail != nil {
+			t.Errorf("Expected no thumbnail for a GIF, got %+v", asset.Thumbnail)
+		}
+		if _, _, err := assets.ReadAsset(ctx, asset.ID.Hex(), true); err == nil {
+			t.Error("Expected reading a missing thumbnail to fail")
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		pngContent := testImage(t, 8, 8, encodePNG)
+		cases := []struct {
+			name     string
+			declared string
+			content  []byte
+		}{
+			{"Empty", "", nil},
+			{"Not An Image", "text/plain", []byte("just some text")},
+			{"Declared Type Mismatch", "image/jpeg", pngContent},
+			{"Corrupt Image", "image/png", pngContent[:20]},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				if _, err := assets.UploadAsset(ctx, "bad", tc.declared, bytes.NewReader(tc.content)); !utils.IsValidationError(err) {
+					t.Errorf("Expected validation error, got %v", err)
+				}
+			})
+		}
+
+		tooLarge := testImage(t, 1000, 1000, encodePNG)
+		small, _ := newTestAssetService(t, int64(len(tooLarge))-1)
+		if _, err := small.UploadAsset(ctx, "large.png", "", bytes.NewReader(tooLarge)); !errors.Is(err, services.ErrAssetTooLarge) {
+			t.Errorf("Expected the size limit to apply, got %v", err)
+		}
+	})
+
+	t.Run("Workspaces", func(t *testing.T) {
+		asset, err := assets.UploadAsset(asMember("alpha", "alice"), "alpha.png", "", bytes.NewReader(testImage(t, 8, 8, encodePNG)))
+		if err != nil {
+			t.Fatalf("Failed to upload asset: %v", err)
+		}
+		if _, err := assets.GetAsset(asMember("beta", "bob"), asset.ID.Hex()); err == nil {
+			t.Error("Expected an asset to be invisible to other workspaces")
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		asset, err := assets.UploadAsset(ctx, "unit.png", "", bytes.NewReader(testImage(t, 300, 300, encodePNG)))
+		if err != nil {
+			t.Fatalf("Failed to upload asset: %v", err)
+		}
+		unit := CreateTestUnit()
+		unit.AssetIDs = []primitive.ObjectID{asset.ID}
+		unit, err = testServices.UnitService.CreateUnit(ctx, unit)
+		if err != nil {
+			t.Fatalf("Failed to create unit: %v", err)
+		}
+
+		err = assets.DeleteAsset(ctx, asset.ID.Hex())
+		var referencedErr services.ReferencedError
+		if !errors.As(err, &referencedErr) || len(referencedErr.References) != 1 || referencedErr.References[0].ID != unit.ID.Hex() {
+			t.Fatalf("Expected the unit to block the delete, got %v", err)
+		}
+
+		unit.AssetIDs = nil
+		if err := testServices.UnitService.UpdateUnit(ctx, unit.ID.Hex(), unit); err != nil {
+			t.Fatalf("Failed to update unit: %v", err)
+		}
+		if err := assets.DeleteAsset(ctx, asset.ID.Hex()); err != nil {
+			t.Fatalf("Failed to delete asset: %v", err)
+		}
+		if _, err := assets.GetAsset(ctx, asset.ID.Hex()); err == nil {
+			t.Error("Expected the asset to be gone")
+		}
+		for _, id := range []primitive.ObjectID{asset.ID, asset.Thumbnail.ID} {
+			if _, err := os.Stat(dir + "/" + id.Hex()); !os.IsNotExist(err) {
+				t.Errorf("Expected blob %s to be removed, got %v", id.Hex(), err)
+			}
+		}
+	})
+}
+
+// multipartUpload builds a multipart body with content in a "file" field
+func multipartUpload(t *testing.T, filename string, content []byte) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+	return &body, writer.FormDataContentType()
+}
+
+func TestAssetHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	assets, _ := newTestAssetService(t, 64<<10)
+	handler := handlers.NewAssetHandler(assets)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/assets", handler.UploadAsset).Methods("POST")
+	router.HandleFunc("/assets/{id}", handler.GetAsset).Methods("GET")
+	router.HandleFunc("/assets/{id}/content", handler.DownloadAsset).Methods("GET")
+	router.HandleFunc("/assets/{id}/thumbnail", handler.DownloadThumbnail).Methods("GET")
+	router.HandleFunc("/assets/{id}", handler.DeleteAsset).Methods("DELETE")
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	upload := func(filename string, content []byte) *httptest.ResponseRecorder {
+		body, contentType := multipartUpload(t, filename, content)
+		req := httptest.NewRequest("POST", "/assets", body)
+		req.Header.Set("Content-Type", contentType)
+		return serve(req)
+	}
+
+	w := upload("portrait.png", testImage(t, 64, 64, encodePNG))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var asset models.Asset
+	if err := json.NewDecoder(w.Body).Decode(&asset); err != nil {
+		t.Fatalf("Failed to decode asset: %v", err)
+	}
+	id := asset.ID.Hex()
+
+	t.Run("Upload Errors", func(t *testing.T) {
+		if w := upload("notes.txt", []byte("not an image")); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for a text file, got %d", w.Code)
+		}
+		if w := upload("huge.png", bytes.Repeat([]byte{0}, 65<<10)); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413 for a large file, got %d", w.Code)
+		}
+		if w := serve(httptest.NewRequest("POST", "/assets", bytes.NewReader([]byte("{}")))); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 without a multipart body, got %d", w.Code)
+		}
+	})
+
+	cases := []struct {
+		name        string
+		path        string
+		want        int
+		contentType string
+	}{
+		{"Metadata", "/assets/" + id, http.StatusOK, "application/json"},
+		{"Content", "/assets/" + id + "/content", http.StatusOK, "image/png"},
+		{"Thumbnail", "/assets/" + id + "/thumbnail", http.StatusOK, "image/png"},
+		{"Missing Asset", "/assets/" + primitive.NewObjectID().Hex() + "/content", http.StatusNotFound, ""},
+		{"Invalid ID", "/assets/not-an-id", http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serve(httptest.NewRequest("GET", tc.path, nil))
+			if w.Code != tc.want {
+				t.Fatalf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+			if tc.contentType != "" && w.Header().Get("Content-Type") != tc.contentType {
+				t.Errorf("Expected content type %s, got %s", tc.contentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+
+	t.Run("Delete", func(t *testing.T) {
+		if w := serve(httptest.NewRequest("DELETE", "/assets/"+id, nil)); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+		}
+		if w := serve(httptest.NewRequest("GET", "/assets/"+id+"/content", nil)); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 after the delete, got %d", w.Code)
+		}
+	})
+}
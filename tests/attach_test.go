@@ -0,0 +1,300 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAttachRules(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	population := testServices.PopulationService
+
+	weapon, err := testServices.WeaponService.CreateWeapon(ctx, CreateTestWeapon())
+	if err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+	weaponID := weapon.ID.Hex()
+
+	t.Run("Concurrent Attaches Keep Every Rule", func(t *testing.T) {
+		rules := make([]*models.Rule, 10)
+		for i := range rules {
+			rules[i], err = testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName(fmt.Sprintf("Concurrent Rule %d", i)))
+			if err != nil {
+				t.Fatalf("Failed to create rule: %v", err)
+			}
+		}
+
+		var wg sync.WaitGroup
+		for _, rule := range rules {
+			wg.Add(1)
+			go func(ruleID string) {
+				defer wg.Done()
+				if _, err := population.AddRuleToWeapon(ctx, weaponID, ruleID, 1); err != nil {
+					t.Errorf("Failed to attach rule: %v", err)
+				}
+			}(rule.ID.Hex())
+		}
+		wg.Wait()
+
+		stored, err := testServices.WeaponService.GetWeaponByID(ctx, weaponID)
+		if err != nil {
+			t.Fatalf("Failed to get weapon: %v", err)
+		}
+		if len(stored.Rules) != len(rules) {
+			t.Errorf("Expected %d rules, got %d", len(rules), len(stored.Rules))
+		}
+		if stored.Version != 1+len(rules) {
+			t.Errorf("Expected every attach to bump the version, got %d", stored.Version)
+		}
+	})
+
+	rule, err := testServices.RuleService.CreateRule(ctx, &models.Rule{Name: "Two Tier Rule", Points: []int{2, 4}})
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	t.Run("Returns Populated Weapon", func(t *testing.T) {
+		populated, err := population.AddRuleToWeapon(ctx, weaponID, rule.ID.Hex(), 2)
+		if err != nil {
+			t.Fatalf("Failed to attach rule: %v", err)
+		}
+		last := populated.PopulatedRules[len(populated.PopulatedRules)-1]
+		if last.Name != "Two Tier Rule" || last.Tier != 2 {
+			t.Errorf("Expected the attached rule at tier 2, got %+v", last)
+		}
+	})
+
+	t.Run("Duplicate Is Rejected", func(t *testing.T) {
+		_, err := population.AddRuleToWeapon(ctx, weaponID, rule.ID.Hex(), 1)
+		if !utils.IsAlreadyAttachedError(err) {
+			t.Errorf("Expected already attached error, got %v", err)
+		}
+	})
+
+	t.Run("Tier Checks", func(t *testing.T) {
+		wargear, err := testServices.WarGearService.CreateWarGear(ctx, CreateTestWarGear())
+		if err != nil {
+			t.Fatalf("Failed to create wargear: %v", err)
+		}
+		for _, tier := range []int{0, 3, 4} {
+			if _, err := population.AddRuleToWarGear(ctx, wargear.ID.Hex(), rule.ID.Hex(), tier); !utils.IsValidationError(err) {
+				t.Errorf("Expected validation error for tier %d, got %v", tier, err)
+			}
+		}
+		if _, err := population.AddRuleToWarGear(ctx, wargear.ID.Hex(), primitive.NewObjectID().Hex(), 1); !utils.IsValidationError(err) {
+			t.Errorf("Expected validation error for a missing rule, got %v", err)
+		}
+
+		untiered, err := testServices.RuleService.CreateRule(ctx, &models.Rule{Name: "Untiered Rule"})
+		if err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+		if _, err := population.AddRuleToWarGear(ctx, wargear.ID.Hex(), untiered.ID.Hex(), 1); !utils.IsValidationError(err) {
+			t.Errorf("Expected validation error for a rule without tier points, got %v", err)
+		}
+	})
+
+	t.Run("Detach", func(t *testing.T) {
+		populated, err := population.RemoveRuleFromWeapon(ctx, weaponID, rule.ID.Hex())
+		if err != nil {
+			t.Fatalf("Failed to detach rule: %v", err)
+		}
+		for _, ref := range populated.Rules {
+			if ref.RuleID == rule.ID {
+				t.Error("Expected the rule to be detached")
+			}
+		}
+
+		if _, err := population.RemoveRuleFromWeapon(ctx, weaponID, rule.ID.Hex()); err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("Expected not found when detaching twice, got %v", err)
+		}
+		if _, err := population.RemoveRuleFromWeapon(ctx, primitive.NewObjectID().Hex(), rule.ID.Hex()); err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("Expected not found for a missing weapon, got %v", err)
+		}
+	})
+}
+
+func TestAttachToUnit(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	population := testServices.PopulationService
+
+	unit, err := testServices.UnitService.CreateUnit(ctx, CreateTestUnit())
+	if err != nil {
+		t.Fatalf("Failed to create unit: %v", err)
+	}
+	unitID := unit.ID.Hex()
+	weapon, err := testServices.WeaponService.CreateWeapon(ctx, CreateTestWeapon())
+	if err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+	wargear, err := testServices.WarGearService.CreateWarGear(ctx, CreateTestWarGear())
+	if err != nil {
+		t.Fatalf("Failed to create wargear: %v", err)
+	}
+
+	populated, err := population.AddWeaponToUnit(ctx, unitID, weapon.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to attach weapon: %v", err)
+	}
+	if len(populated.PopulatedAvailableWeapons) != 1 {
+		t.Errorf("Expected the weapon to be available, got %+v", populated.PopulatedAvailableWeapons)
+	}
+	if _, err := population.AddWeaponToUnit(ctx, unitID, weapon.ID.Hex()); !utils.IsAlreadyAttachedError(err) {
+		t.Errorf("Expected already attached error, got %v", err)
+	}
+	if _, err := population.AddWarGearToUnit(ctx, unitID, primitive.NewObjectID().Hex()); !utils.IsValidationError(err) {
+		t.Errorf("Expected validation error for missing wargear, got %v", err)
+	}
+	if _, err := population.AddWarGearToUnit(ctx, unitID, wargear.ID.Hex()); err != nil {
+		t.Fatalf("Failed to attach wargear: %v", err)
+	}
+
+	// Equip the weapon, then check that detaching it unequips it too
+	stored, _ := testServices.UnitService.GetUnitByID(ctx, unitID)
+	stored.Weapons = []models.WeaponReference{{WeaponID: weapon.ID, Quantity: 1, Type: "ranged"}}
+	if err := testServices.UnitService.UpdateUnit(ctx, unitID, stored); err != nil {
+		t.Fatalf("Failed to equip weapon: %v", err)
+	}
+
+	populated, err = population.RemoveWeaponFromUnit(ctx, unitID, weapon.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to detach weapon: %v", err)
+	}
+	if len(populated.AvailableWeapons) != 0 || len(populated.Weapons) != 0 {
+		t.Errorf("Expected the weapon to be unavailable and unequipped, got %+v / %+v", populated.AvailableWeapons, populated.Weapons)
+	}
+	if len(populated.AvailableWarGear) != 1 {
+		t.Errorf("Expected the wargear to stay, got %+v", populated.AvailableWarGear)
+	}
+}
+
+func TestAttachHandlers(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	handler := handlers.NewPopulatedUnitHandler(testServices.PopulationService)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/units/{id}/rules", handler.AddRuleToUnit).Methods("POST")
+	router.HandleFunc("/units/{id}/rules/{ruleId}", handler.RemoveRuleFromUnit).Methods("DELETE")
+
+	unit, err := testServices.UnitService.CreateUnit(ctx, CreateTestUnit())
+	if err != nil {
+		t.Fatalf("Failed to create unit: %v", err)
+	}
+	rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRule())
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	unitPath := "/units/" + unit.ID.Hex() + "/rules"
+	attach := fmt.Sprintf(`{"ruleId": %q, "tier": 2}`, rule.ID.Hex())
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"Attach", "POST", unitPath, attach, http.StatusOK},
+		{"Attach Twice", "POST", unitPath, attach, http.StatusConflict},
+		{"Bad Tier", "POST", unitPath, fmt.Sprintf(`{"ruleId": %q, "tier": 5}`, rule.ID.Hex()), http.StatusBadRequest},
+		{"Missing Unit", "POST", "/units/" + primitive.NewObjectID().Hex() + "/rules", attach, http.StatusNotFound},
+		{"Invalid Unit ID", "POST", "/units/not-an-id/rules", attach, http.StatusBadRequest},
+		{"Detach", "DELETE", unitPath + "/" + rule.ID.Hex(), "", http.StatusOK},
+		{"Detach Twice", "DELETE", unitPath + "/" + rule.ID.Hex(), "", http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Errorf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPopulatedHandlers(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	unitHandler := handlers.NewPopulatedUnitHandler(testServices.PopulationService)
+	armyHandler := handlers.NewPopulatedArmyHandler(testServices.ArmyBookService, testServices.ArmyListService, testServices.PopulationService)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/units/{id}/populated", unitHandler.GetPopulatedUnit).Methods("GET")
+	router.HandleFunc("/armybooks/{id}/populated", armyHandler.GetPopulatedArmyBook).Methods("GET")
+	router.HandleFunc("/armylists/{id}/populated", armyHandler.GetPopulatedArmyList).Methods("GET")
+
+	unit, err := testServices.UnitService.CreateUnit(ctx, CreateTestUnit())
+	if err != nil {
+		t.Fatalf("Failed to create unit: %v", err)
+	}
+	armyBook := CreateTestArmyBook()
+	armyBook.Units = []primitive.ObjectID{unit.ID}
+	armyBookID, err := testRepos.ArmyBookRepo.CreateArmyBook(ctx, armyBook)
+	if err != nil {
+		t.Fatalf("Failed to create army book: %v", err)
+	}
+	armyList := CreateTestArmyList()
+	armyList.Units = []primitive.ObjectID{unit.ID, primitive.NewObjectID()}
+	armyListID, err := testRepos.ArmyListRepo.CreateArmyList(ctx, armyList)
+	if err != nil {
+		t.Fatalf("Failed to create army list: %v", err)
+	}
+
+	missing := primitive.NewObjectID().Hex()
+	cases := []struct {
+		name     string
+		path     string
+		want     int
+		contains string
+	}{
+		{"Unit", "/units/" + unit.ID.Hex() + "/populated", http.StatusOK, `"populatedRules"`},
+		{"Missing Unit", "/units/" + missing + "/populated", http.StatusNotFound, ""},
+		{"Invalid Unit ID", "/units/not-an-id/populated", http.StatusBadRequest, ""},
+		{"Army Book", "/armybooks/" + armyBookID + "/populated", http.StatusOK, `"populatedUnits"`},
+		{"Missing Army Book", "/armybooks/" + missing + "/populated", http.StatusNotFound, ""},
+		{"Army List Reports Missing Units", "/armylists/" + armyListID + "/populated", http.StatusOK, `"missingReferences"`},
+		{"Invalid Army List ID", "/armylists/not-an-id/populated", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Errorf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tc.contains) {
+				t.Errorf("Expected the body to contain %s, got %s", tc.contains, w.Body.String())
+			}
+		})
+	}
+}
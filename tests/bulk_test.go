@@ -0,0 +1,328 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestBulkService() *services.BulkService {
+	return services.NewBulkService(
+		testServices.RuleService,
+		testServices.WeaponService,
+		testServices.WarGearService,
+		testServices.UnitService,
+		testServices.ArmyBookService,
+		testServices.ArmyListService,
+		testServices.FactionService,
+		testServices.ReferenceService,
+	)
+}
+
+func createTestUnitWithPoints(t *testing.T, name string, points int) *models.Unit {
+	unit := CreateTestUnit()
+	unit.Name = name
+	unit.Points = points
+	created, err := testServices.UnitService.CreateUnit(context.Background(), unit)
+	if err != nil {
+		t.Fatalf("Failed to create unit %q: %v", name, err)
+	}
+	return created
+}
+
+func TestBulkUpdate(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	bulk := newTestBulkService()
+
+	cheap := createTestUnitWithPoints(t, "Bulk Grots", 10)
+	cheaper := createTestUnitWithPoints(t, "Bulk Snotlings", 5)
+	expensive := createTestUnitWithPoints(t, "Bulk Nobz", 30)
+
+	filter, err := services.ParseFilter([]string{"points:lt:20"})
+	if err != nil {
+		t.Fatalf("Failed to parse filter: %v", err)
+	}
+	set := map[string]interface{}{"defense": 4}
+
+	var confirmation string
+	t.Run("Dry Run", func(t *testing.T) {
+		report, err := bulk.Update(ctx, "units", filter, set, "")
+		if err != nil {
+			t.Fatalf("Dry run failed: %v", err)
+		}
+		if !report.DryRun || report.Matched != 2 || report.Changed != 2 || report.Confirmation == "" {
+			t.Fatalf("Expected a dry run planning 2 changes, got %+v", report)
+		}
+		for _, result := range report.Results {
+			if len(result.Changes) != 1 || result.Changes[0].Field != "defense" {
+				t.Errorf("Expected only defense to change on %s, got %+v", result.Name, result.Changes)
+			}
+		}
+
+		unchanged, _ := testServices.UnitService.GetUnitByID(ctx, cheap.ID.Hex())
+		if unchanged.Defense != 3 {
+			t.Errorf("Expected a dry run to change nothing, got defense %d", unchanged.Defense)
+		}
+		confirmation = report.Confirmation
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		report, err := bulk.Update(ctx, "units", filter, set, confirmation)
+		if err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if report.DryRun || report.Changed != 2 || report.Failed != 0 {
+			t.Fatalf("Expected 2 units updated, got %+v", report)
+		}
+
+		for _, unit := range []*models.Unit{cheap, cheaper} {
+			updated, err := testServices.UnitService.GetUnitByID(ctx, unit.ID.Hex())
+			if err != nil {
+				t.Fatalf("Failed to get unit: %v", err)
+			}
+			if updated.Defense != 4 || updated.Version != 2 {
+				t.Errorf("Expected %s at defense 4 and version 2, got %d and %d", updated.Name, updated.Defense, updated.Version)
+			}
+			revisions, _ := testServices.RevisionService.ListRevisions(ctx, "units", unit.ID.Hex(), 10, 0)
+			if len(revisions) != 2 || revisions[0].Reason != "bulk change of units" {
+				t.Errorf("Expected the bulk update to be recorded as a revision, got %+v", revisions)
+			}
+		}
+
+		untouched, _ := testServices.UnitService.GetUnitByID(ctx, expensive.ID.Hex())
+		if untouched.Defense != 3 {
+			t.Errorf("Expected unmatched units to be left alone, got defense %d", untouched.Defense)
+		}
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		report, err := bulk.Update(ctx, "units", filter, set, "")
+		if err != nil {
+			t.Fatalf("Dry run failed: %v", err)
+		}
+		if report.Unchanged != 2 || report.Changed != 0 {
+			t.Errorf("Expected both units to already match, got %+v", report)
+		}
+	})
+
+	t.Run("Stale Confirmation", func(t *testing.T) {
+		report, err := bulk.Update(ctx, "units", filter, map[string]interface{}{"morale": 8}, "")
+		if err != nil {
+			t.Fatalf("Dry run failed: %v", err)
+		}
+
+		edited, _ := testServices.UnitService.GetUnitByID(ctx, cheap.ID.Hex())
+		edited.Melee = 4
+		if err := testServices.UnitService.UpdateUnit(ctx, cheap.ID.Hex(), edited); err != nil {
+			t.Fatalf("Failed to update unit: %v", err)
+		}
+
+		_, err = bulk.Update(ctx, "units", filter, map[string]interface{}{"morale": 8}, report.Confirmation)
+		if !errors.Is(err, services.ErrBulkPlanChanged) {
+			t.Errorf("Expected a stale confirmation to be refused, got %v", err)
+		}
+	})
+
+	t.Run("Validation Per Document", func(t *testing.T) {
+		report, err := bulk.Update(ctx, "units", filter, map[string]interface{}{"name": ""}, "")
+		if err != nil {
+			t.Fatalf("Dry run failed: %v", err)
+		}
+		if report.Failed != 2 || report.Results[0].Error == "" {
+			t.Errorf("Expected the service's name validation to fail each unit, got %+v", report)
+		}
+	})
+
+	t.Run("Normalized Values", func(t *testing.T) {
+		weapon, err := testServices.WeaponService.CreateWeapon(ctx, CreateTestWeapon())
+		if err != nil {
+			t.Fatalf("Failed to create weapon: %v", err)
+		}
+		weaponFilter, _ := services.ParseFilter([]string{"points:eq:10"})
+		report, err := bulk.Update(ctx, "weapons", weaponFilter, map[string]interface{}{"type": weapon.Type + " "}, "")
+		if err != nil {
+			t.Fatalf("Dry run failed: %v", err)
+		}
+		if report.Unchanged != 1 {
+			t.Errorf("Expected a type that normalizes to the current one to change nothing, got %+v", report)
+		}
+	})
+
+	t.Run("Rejected Requests", func(t *testing.T) {
+		cases := []struct {
+			name       string
+			entityType string
+			filter     []string
+			set        map[string]interface{}
+		}{
+			{"No Filter", "units", nil, set},
+			{"No Fields", "units", []string{"points:lt:20"}, nil},
+			{"Server Managed Field", "units", []string{"points:lt:20"}, map[string]interface{}{"version": 7}},
+			{"Unknown Field", "units", []string{"points:lt:20"}, map[string]interface{}{"toughness": 4}},
+			{"Wrong Type", "units", []string{"points:lt:20"}, map[string]interface{}{"defense": "high"}},
+			{"Unknown Entity Type", "spaceships", []string{"points:lt:20"}, set},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				filter, err := services.ParseFilter(tc.filter)
+				if err != nil {
+					t.Fatalf("Failed to parse filter: %v", err)
+				}
+				_, err = bulk.Update(ctx, tc.entityType, filter, tc.set, "")
+				if !utils.IsValidationError(err) {
+					t.Errorf("Expected a validation error, got %v", err)
+				}
+			})
+		}
+	})
+}
+
+func TestBulkDelete(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	bulk := newTestBulkService()
+
+	referenced, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Bulk Referenced"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	loose, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Bulk Loose"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	weapon := CreateTestWeapon()
+	weapon.Rules = []models.RuleReference{{RuleID: referenced.ID, Tier: 1}}
+	if _, err := testServices.WeaponService.CreateWeapon(ctx, weapon); err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+
+	filter, _ := services.ParseFilter([]string{"name:in:Bulk Referenced,Bulk Loose"})
+
+	t.Run("Reject", func(t *testing.T) {
+		report, err := bulk.Delete(ctx, "rules", filter, services.DeletePolicyReject, "")
+		if err != nil {
+			t.Fatalf("Dry run failed: %v", err)
+		}
+		if report.Changed != 1 || report.Failed != 1 {
+			t.Fatalf("Expected one delete to go ahead and one to be rejected, got %+v", report)
+		}
+
+		report, err = bulk.Delete(ctx, "rules", filter, services.DeletePolicyReject, report.Confirmation)
+		if err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		for _, result := range report.Results {
+			switch result.ID {
+			case referenced.ID.Hex():
+				if result.Status != services.BulkFailed || len(result.References) != 1 {
+					t.Errorf("Expected the referenced rule to be kept and its reference listed, got %+v", result)
+				}
+			case loose.ID.Hex():
+				if result.Status != services.BulkChanged {
+					t.Errorf("Expected the loose rule to be deleted, got %+v", result)
+				}
+			}
+		}
+		if _, err := testServices.RuleService.GetRuleByID(ctx, loose.ID.Hex()); err == nil {
+			t.Error("Expected the loose rule to be in the trash")
+		}
+	})
+
+	t.Run("Detach", func(t *testing.T) {
+		report, err := bulk.Delete(ctx, "rules", filter, services.DeletePolicyDetach, "")
+		if err != nil {
+			t.Fatalf("Dry run failed: %v", err)
+		}
+		if report.Matched != 1 || len(report.Results[0].Delete.Detached) != 1 {
+			t.Fatalf("Expected the dry run to report the reference it would detach, got %+v", report)
+		}
+		if _, err := testServices.RuleService.GetRuleByID(ctx, referenced.ID.Hex()); err != nil {
+			t.Fatalf("Expected a dry run to delete nothing: %v", err)
+		}
+
+		report, err = bulk.Delete(ctx, "rules", filter, services.DeletePolicyDetach, report.Confirmation)
+		if err != nil || report.Changed != 1 {
+			t.Fatalf("Expected the referenced rule to be deleted, got %+v (%v)", report, err)
+		}
+	})
+}
+
+func TestBulkHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	handler := handlers.NewBulkHandler(newTestBulkService())
+	router := mux.NewRouter()
+	router.HandleFunc("/{type}/bulk/update", handler.BulkUpdate).Methods("POST")
+	router.HandleFunc("/{type}/bulk/delete", handler.BulkDelete).Methods("POST")
+
+	createTestUnitWithPoints(t, "Bulk Handler Unit", 10)
+
+	cases := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"Dry Run Update", "/units/bulk/update", `{"filter": ["points:lt:20"], "set": {"points": 12}}`, http.StatusOK},
+		{"Dry Run Delete", "/units/bulk/delete", `{"filter": ["points:lt:20"], "policy": "cascade"}`, http.StatusOK},
+		{"Stale Confirmation", "/units/bulk/update", `{"filter": ["points:lt:20"], "set": {"points": 12}, "confirm": "stale"}`, http.StatusConflict},
+		{"Malformed Filter", "/units/bulk/update", `{"filter": ["points"], "set": {"points": 12}}`, http.StatusBadRequest},
+		{"Unknown Policy", "/units/bulk/delete", `{"filter": ["points:lt:20"], "policy": "shred"}`, http.StatusBadRequest},
+		{"Unknown Type", "/spaceships/bulk/update", `{"filter": ["points:lt:20"], "set": {"points": 12}}`, http.StatusBadRequest},
+		{"Invalid JSON", "/units/bulk/update", `{`, http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tc.path, bytes.NewBufferString(tc.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Errorf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("Confirmed Update", func(t *testing.T) {
+		body := `{"filter": ["points:lt:20"], "set": {"points": 12}}`
+		req := httptest.NewRequest("POST", "/units/bulk/update", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var dryRun services.BulkReport
+		if err := json.NewDecoder(w.Body).Decode(&dryRun); err != nil {
+			t.Fatalf("Failed to decode dry run: %v", err)
+		}
+
+		body = `{"filter": ["points:lt:20"], "set": {"points": 12}, "confirm": "` + dryRun.Confirmation + `"}`
+		req = httptest.NewRequest("POST", "/units/bulk/update", bytes.NewBufferString(body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var applied services.BulkReport
+		if err := json.NewDecoder(w.Body).Decode(&applied); err != nil {
+			t.Fatalf("Failed to decode report: %v", err)
+		}
+		if w.Code != http.StatusOK || applied.DryRun || applied.Changed != 1 {
+			t.Errorf("Expected the confirmed update to apply, got %d: %+v", w.Code, applied)
+		}
+	})
+}
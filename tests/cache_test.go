@@ -0,0 +1,252 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grimdank-database/models"
+	"grimdank-database/services"
+)
+
+// newTestCaches puts caches in front of the test services
+func newTestCaches(ttl time.Duration, size int) *services.Caches {
+	caches := services.NewCaches(ttl, size)
+	caches.Subscribe(testServices.EventBus)
+	testServices.RuleService.UseCache(caches.Rules)
+	testServices.WeaponService.UseCache(caches.Weapons)
+	testServices.WarGearService.UseCache(caches.WarGear)
+	return caches
+}
+
+func TestCachedLookups(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	caches := newTestCaches(time.Minute, 100)
+
+	rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Cached Rule"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := testServices.RuleService.GetRuleByID(ctx, rule.ID.Hex()); err != nil {
+			t.Fatalf("Failed to get rule: %v", err)
+		}
+	}
+	if stats := caches.Rules.Stats(); stats.Misses != 1 || stats.Hits != 2 || stats.Entries != 1 {
+		t.Errorf("Expected one miss then hits, got %+v", stats)
+	}
+
+	t.Run("Updates Invalidate", func(t *testing.T) {
+		update := CreateTestRuleWithName("Renamed Rule")
+		if err := testServices.RuleService.UpdateRule(ctx, rule.ID.Hex(), update); err != nil {
+			t.Fatalf("Failed to update rule: %v", err)
+		}
+		got, err := testServices.RuleService.GetRuleByID(ctx, rule.ID.Hex())
+		if err != nil || got.Name != "Renamed Rule" {
+			t.Errorf("Expected the updated rule, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("Deletes Invalidate", func(t *testing.T) {
+		if err := testServices.RuleService.DeleteRule(ctx, rule.ID.Hex()); err != nil {
+			t.Fatalf("Failed to delete rule: %v", err)
+		}
+		if _, err := testServices.RuleService.GetRuleByID(ctx, rule.ID.Hex()); err == nil {
+			t.Error("Expected a deleted rule not to be served from the cache")
+		}
+	})
+
+	t.Run("Copies", func(t *testing.T) {
+		weapon, err := testServices.WeaponService.CreateWeapon(ctx, CreateTestWeapon())
+		if err != nil {
+			t.Fatalf("Failed to create weapon: %v", err)
+		}
+		first, _ := testServices.WeaponService.GetWeaponByID(ctx, weapon.ID.Hex())
+		first.Name = "Changed By Caller"
+		second, _ := testServices.WeaponService.GetWeaponByID(ctx, weapon.ID.Hex())
+		if second.Name != weapon.Name {
+			t.Errorf("Expected a caller's change not to reach the cache, got %q", second.Name)
+		}
+	})
+
+	t.Run("Workspaces", func(t *testing.T) {
+		wargear, err := testServices.WarGearService.CreateWarGear(ctx, &models.WarGear{Name: "Cached Shield"})
+		if err != nil {
+			t.Fatalf("Failed to create wargear: %v", err)
+		}
+		if _, err := testServices.WarGearService.GetWarGearByID(ctx, wargear.ID.Hex()); err != nil {
+			t.Fatalf("Failed to get wargear: %v", err)
+		}
+		if _, err := testServices.WarGearService.GetWarGearByID(asMember("alpha", "alice"), wargear.ID.Hex()); err == nil {
+			t.Error("Expected a cached entity to stay invisible to other workspaces")
+		}
+	})
+
+	t.Run("Purges Invalidate", func(t *testing.T) {
+		trash := newTestTrashService(time.Nanosecond)
+		trash.UseCaches(caches)
+
+		// Deleted without the services, so the cache isn't told until the purge
+		cachedThenDeleted := func(name string) string {
+			rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName(name))
+			if err != nil {
+				t.Fatalf("Failed to create rule: %v", err)
+			}
+			if _, err := testServices.RuleService.GetRuleByID(ctx, rule.ID.Hex()); err != nil {
+				t.Fatalf("Failed to get rule: %v", err)
+			}
+			if err := testRepos.RuleRepo.Delete(ctx, rule.ID); err != nil {
+				t.Fatalf("Failed to delete rule: %v", err)
+			}
+			return rule.ID.Hex()
+		}
+
+		purged := cachedThenDeleted("Purged Rule")
+		if err := trash.Purge(ctx, "rules", purged); err != nil {
+			t.Fatalf("Failed to purge rule: %v", err)
+		}
+		if _, err := testServices.RuleService.GetRuleByID(ctx, purged); err == nil {
+			t.Error("Expected a purged rule not to be served from the cache")
+		}
+
+		expired := cachedThenDeleted("Expired Rule")
+		time.Sleep(time.Millisecond)
+		if _, err := trash.PurgeExpired(ctx); err != nil {
+			t.Fatalf("Failed to purge expired rules: %v", err)
+		}
+		if _, err := testServices.RuleService.GetRuleByID(ctx, expired); err == nil {
+			t.Error("Expected an expired rule not to be served from the cache")
+		}
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		caches.Flush()
+		for entityType, stats := range caches.Stats() {
+			if stats.Entries != 0 {
+				t.Errorf("Expected %s to be empty after a flush, got %+v", entityType, stats)
+			}
+		}
+	})
+}
+
+func TestCacheLimits(t *testing.T) {
+	ctx := context.Background()
+	load := func(name string) func(ctx context.Context) (*models.Rule, error) {
+		return func(ctx context.Context) (*models.Rule, error) {
+			return &models.Rule{Name: name}, nil
+		}
+	}
+
+	t.Run("Size", func(t *testing.T) {
+		cache := services.NewCache[models.Rule](time.Minute, 2)
+		cache.Get(ctx, "a", load("a"))
+		cache.Get(ctx, "b", load("b"))
+		cache.Get(ctx, "a", load("a")) // a is now used more recently than b
+		cache.Get(ctx, "c", load("c"))
+
+		if stats := cache.Stats(); stats.Entries != 2 || stats.Evictions != 1 {
+			t.Errorf("Expected one eviction to stay at two entries, got %+v", stats)
+		}
+		cache.Get(ctx, "a", load("a"))
+		if stats := cache.Stats(); stats.Hits != 2 {
+			t.Errorf("Expected the least recently used entry to go, got %+v", stats)
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		cache := services.NewCache[models.Rule](20*time.Millisecond, 10)
+		cache.Get(ctx, "a", load("a"))
+		time.Sleep(30 * time.Millisecond)
+		cache.Get(ctx, "a", load("a"))
+		if stats := cache.Stats(); stats.Misses != 2 {
+			t.Errorf("Expected an expired entry to be loaded again, got %+v", stats)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		cache := services.NewCache[models.Rule](0, 10)
+		cache.Get(ctx, "a", load("a"))
+		if stats := cache.Stats(); stats.Entries != 0 {
+			t.Errorf("Expected a TTL of zero to cache nothing, got %+v", stats)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		cache := services.NewCache[models.Rule](time.Minute, 10)
+		failing := func(ctx context.Context) (*models.Rule, error) { return nil, errors.New("rule not found") }
+		if _, err := cache.Get(ctx, "a", failing); err == nil {
+			t.Fatal("Expected the load's error")
+		}
+		if got, err := cache.Get(ctx, "a", load("a")); err != nil || got.Name != "a" {
+			t.Errorf("Expected errors not to be cached, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("Concurrent Misses", func(t *testing.T) {
+		cache := services.NewCache[models.Rule](time.Minute, 10)
+		release := make(chan struct{})
+		var loads int32
+		slow := func(ctx context.Context) (*models.Rule, error) {
+			atomic.AddInt32(&loads, 1)
+			<-release
+			return &models.Rule{Name: "a"}, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cache.Get(ctx, "a", slow)
+			}()
+		}
+		// Give every caller time to miss before the load finishes
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if loads != 1 {
+			t.Errorf("Expected concurrent misses to share one load, got %d", loads)
+		}
+	})
+
+	t.Run("First Caller Gone", func(t *testing.T) {
+		cache := services.NewCache[models.Rule](time.Minute, 10)
+		first, cancel := context.WithCancel(services.WithWorkspace(ctx, "alpha"))
+		cancel()
+
+		var workspace string
+		got, err := cache.Get(first, "a", func(ctx context.Context) (*models.Rule, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			workspace = services.WorkspaceFrom(ctx)
+			return &models.Rule{Name: "a"}, nil
+		})
+		if err != nil || got.Name != "a" {
+			t.Errorf("Expected the shared load to outlive its first caller, got %+v, %v", got, err)
+		}
+		if workspace != "alpha" {
+			t.Errorf("Expected the shared load to keep the caller's workspace, got %q", workspace)
+		}
+	})
+
+	t.Run("Invalidated While Loading", func(t *testing.T) {
+		cache := services.NewCache[models.Rule](time.Minute, 10)
+		stale := func(ctx context.Context) (*models.Rule, error) {
+			cache.Invalidate("a")
+			return &models.Rule{Name: "stale"}, nil
+		}
+		cache.Get(ctx, "a", stale)
+		if got, _ := cache.Get(ctx, "a", load("fresh")); got.Name != "fresh" {
+			t.Errorf("Expected a load overtaken by a write not to be cached, got %q", got.Name)
+		}
+	})
+}
@@ -64,11 +64,11 @@ func (tcm *TestCleanupManager) CleanupAll(t *testing.T) {
 		if len(objectIDs) > 0 {
 			// Delete all tracked entities in one operation
 			filter := bson.M{"_id": bson.M{"$in": objectIDs}}
-			result, err := collection.DeleteMany(ctx, filter)
+			deleted, err := collection.DeleteMany(ctx, filter)
 			if err != nil {
 				t.Logf("Warning: Failed to delete entities from %s: %v", collectionName, err)
 			} else {
-				t.Logf("Cleaned up %d entities from %s", result.DeletedCount, collectionName)
+				t.Logf("Cleaned up %d entities from %s", deleted, collectionName)
 			}
 		}
 	}
@@ -89,11 +89,11 @@ func (tcm *TestCleanupManager) CleanupCollection(t *testing.T, collectionName st
 	}
 
 	// Delete all documents in the collection
-	result, err := collection.DeleteMany(ctx, bson.M{})
+	deleted, err := collection.DeleteMany(ctx, bson.M{})
 	if err != nil {
 		t.Logf("Warning: Failed to clean collection %s: %v", collectionName, err)
 	} else {
-		t.Logf("Cleaned up %d entities from %s", result.DeletedCount, collectionName)
+		t.Logf("Cleaned up %d entities from %s", deleted, collectionName)
 	}
 
 	// Remove from tracking
@@ -179,7 +179,7 @@ func (tcm *TestCleanupManager) EnsureEmptyDatabase(t *testing.T) {
 		}
 
 		// Recreate the collection
-		tcm.testDB.Collections[collectionName] = tcm.testDB.Database.Collection(collectionName)
+		tcm.testDB.Collections[collectionName] = tcm.testDB.Store.Collection(collectionName)
 	}
 
 	// Clear tracking
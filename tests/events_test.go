@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"grimdank-database/events"
+	"grimdank-database/services"
+)
+
+func TestServicesPublishEvents(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	ctx := services.WithChange(context.Background(), "alice", "")
+
+	var published []string
+	testServices.EventBus.SubscribeAll("recorder", func(ctx context.Context, event events.Event) error {
+		published = append(published, event.Name())
+		return nil
+	})
+	var update events.UnitUpdated
+	events.Subscribe(testServices.EventBus, "unit-updates", func(ctx context.Context, event events.UnitUpdated) error {
+		update = event
+		return nil
+	})
+
+	unit := CreateTestUnit()
+	unit.Defense = 4
+	unit, err := testServices.UnitService.CreateUnit(ctx, unit)
+	if err != nil {
+		t.Fatalf("Failed to create unit: %v", err)
+	}
+	unit.Defense = 3
+	if err := testServices.UnitService.UpdateUnit(ctx, unit.ID.Hex(), unit); err != nil {
+		t.Fatalf("Failed to update unit: %v", err)
+	}
+
+	if update.Before.Defense != 4 || update.After.Defense != 3 || update.Change().Actor != "alice" {
+		t.Errorf("Expected a UnitUpdated from defense 4 to 3 by alice, got %+v", update)
+	}
+
+	t.Run("Cascade publishes each change", func(t *testing.T) {
+		armyList := CreateTestArmyList()
+		armyList.Units = append(armyList.Units, unit.ID)
+		if _, err := testServices.ArmyListService.CreateArmyList(ctx, armyList); err != nil {
+			t.Fatalf("Failed to create army list: %v", err)
+		}
+
+		published = nil
+		if _, err := testServices.UnitService.DeleteUnitWithPolicy(ctx, unit.ID.Hex(), services.DeletePolicyCascade); err != nil {
+			t.Fatalf("Failed to delete unit: %v", err)
+		}
+		if len(published) != 2 || published[0] != "ArmyListDeleted" || published[1] != "UnitDeleted" {
+			t.Errorf("Expected ArmyListDeleted then UnitDeleted, got %v", published)
+		}
+	})
+
+	t.Run("Failed unit of work publishes nothing", func(t *testing.T) {
+		published = nil
+		failure := errors.New("step failed")
+		err := services.SequentialUnitOfWork{}.Do(ctx, func(ctx context.Context) error {
+			if _, err := testServices.RuleService.CreateRule(ctx, CreateTestRule()); err != nil {
+				return err
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("Expected the unit to fail, got %v", err)
+		}
+		if len(published) != 0 {
+			t.Errorf("Expected no events from a rolled back unit, got %v", published)
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -127,6 +128,54 @@ func TestRuleHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("Get Rules With Cursor", func(t *testing.T) {
+		for _, name := range []string{"Cursor A", "Cursor B", "Cursor C"} {
+			if _, err := testServices.RuleService.CreateRule(context.Background(), CreateTestRuleWithName(name)); err != nil {
+				t.Fatalf("Failed to create rule: %v", err)
+			}
+		}
+
+		var seen []string
+		cursor := ""
+		for page := 0; page < 5; page++ {
+			w := httptest.NewRecorder()
+			handler.GetRules(w, httptest.NewRequest("GET", "/rules?name=Cursor&sort=-name&limit=2&cursor="+cursor, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+
+			var response struct {
+				Data       []models.Rule `json:"data"`
+				NextCursor string        `json:"nextCursor"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			for _, rule := range response.Data {
+				seen = append(seen, rule.Name)
+			}
+			if response.NextCursor == "" {
+				break
+			}
+			if w.Header().Get("X-Next-Cursor") != response.NextCursor {
+				t.Error("Expected X-Next-Cursor header to match the response")
+			}
+			cursor = response.NextCursor
+		}
+
+		if len(seen) != 3 || seen[0] != "Cursor C" || seen[2] != "Cursor A" {
+			t.Errorf("Expected rules in descending name order, got %v", seen)
+		}
+	})
+
+	t.Run("Get Rules With Unknown Sort", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.GetRules(w, httptest.NewRequest("GET", "/rules?sort=weight", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
 	t.Run("Update Rule", func(t *testing.T) {
 		// Create a rule first
 		rule := CreateTestRule()
@@ -153,6 +202,78 @@ func TestRuleHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("Update Rule With If-Match", func(t *testing.T) {
+		rule := CreateTestRule()
+		createdRule, err := testServices.RuleService.CreateRule(context.Background(), rule)
+		if err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+
+		router := mux.NewRouter()
+		router.HandleFunc("/rules/{id}", handler.GetRule).Methods("GET")
+		router.HandleFunc("/rules/{id}", handler.UpdateRule).Methods("PUT")
+
+		// The ETag from a read is what clients send back
+		req := httptest.NewRequest("GET", "/rules/"+createdRule.ID.Hex(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		etag := w.Header().Get("ETag")
+		if etag != `"1"` {
+			t.Fatalf("Expected ETag \"1\", got %q", etag)
+		}
+
+		put := func(ifMatch string, body *models.Rule) *httptest.ResponseRecorder {
+			jsonData, _ := json.Marshal(body)
+			req := httptest.NewRequest("PUT", "/rules/"+createdRule.ID.Hex(), bytes.NewBuffer(jsonData))
+			req.Header.Set("Content-Type", "application/json")
+			if ifMatch != "" {
+				req.Header.Set("If-Match", ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		createdRule.Description = "First writer"
+		w = put(etag, createdRule)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if w.Header().Get("ETag") != `"2"` {
+			t.Errorf("Expected ETag \"2\" after update, got %q", w.Header().Get("ETag"))
+		}
+
+		// A second writer holding the old ETag must not overwrite the first
+		createdRule.Description = "Second writer"
+		w = put(etag, createdRule)
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("Expected status %d, got %d", http.StatusPreconditionFailed, w.Code)
+		}
+		if w.Header().Get("ETag") != `"2"` {
+			t.Errorf("Expected current ETag \"2\" on conflict, got %q", w.Header().Get("ETag"))
+		}
+
+		// A stale version in the body is a conflict rather than a failed precondition
+		createdRule.Version = 1
+		w = put("", createdRule)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+		}
+
+		w = put("not-an-etag", createdRule)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		stored, err := testServices.RuleService.GetRuleByID(context.Background(), createdRule.ID.Hex())
+		if err != nil {
+			t.Fatalf("Failed to get rule: %v", err)
+		}
+		if stored.Description != "First writer" || stored.Version != 2 {
+			t.Errorf("Expected first writer's update at version 2, got %q at version %d", stored.Description, stored.Version)
+		}
+	})
+
 	t.Run("Delete Rule", func(t *testing.T) {
 		// Create a rule first
 		rule := CreateTestRule()
@@ -240,6 +361,42 @@ func TestWeaponHandler(t *testing.T) {
 			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
 		}
 	})
+
+	t.Run("Get Weapons With Filter", func(t *testing.T) {
+		for _, points := range []int{5, 12, 30} {
+			weapon := CreateTestWeapon()
+			weapon.Name = fmt.Sprintf("Filtered Weapon %d", points)
+			weapon.Points = points
+			if _, err := testServices.WeaponService.CreateWeapon(context.Background(), weapon); err != nil {
+				t.Fatalf("Failed to create weapon: %v", err)
+			}
+		}
+
+		w := httptest.NewRecorder()
+		handler.GetWeapons(w, httptest.NewRequest("GET", "/weapons?name=Filtered&filter=points:gte:10&limit=1", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var response struct {
+			Data  []models.Weapon `json:"data"`
+			Total int64           `json:"total"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(response.Data) != 1 || response.Total != 2 {
+			t.Errorf("Expected 1 of 2 matching weapons, got %d of %d", len(response.Data), response.Total)
+		}
+	})
+
+	t.Run("Get Weapons With Invalid Filter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.GetWeapons(w, httptest.NewRequest("GET", "/weapons?filter=secret:eq:1", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
 }
 
 func TestPointsHandler(t *testing.T) {
@@ -291,7 +448,7 @@ func TestPointsHandler(t *testing.T) {
 }
 
 func TestWeaponPointsHandler(t *testing.T) {
-	handler := handlers.NewWeaponPointsHandler()
+	handler := handlers.NewWeaponPointsHandler(nil)
 
 	t.Run("Calculate Weapon Points", func(t *testing.T) {
 		stats := services.WeaponStats{
@@ -399,6 +556,7 @@ func TestImportHandler(t *testing.T) {
 		testServices.ArmyBookService,
 		testServices.ArmyListService,
 		testServices.FactionService,
+		services.SequentialUnitOfWork{},
 	)
 
 	t.Run("Import Rules", func(t *testing.T) {
@@ -0,0 +1,381 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// newTestSeasonService returns a points season service over the test store
+// with its unique name index in place
+func newTestSeasonService(t *testing.T) *services.PointsSeasonService {
+	repo := repositories.NewPointsSeasonRepository(testDB.Collections["pointsseasons"])
+	if _, err := repositories.ReconcileIndexes(context.Background(), map[string]repositories.IndexedRepository{"pointsseasons": repo}, true); err != nil {
+		t.Fatalf("Failed to sync indexes: %v", err)
+	}
+	return services.NewPointsSeasonService(repo, testServices.EventBus)
+}
+
+// testSeason returns a season with the built-in settings under another name
+func testSeason(name string) *models.PointsSeason {
+	season := services.DefaultPointsSeason()
+	season.Name = name
+	season.Description = ""
+	return season
+}
+
+func TestPointsSeasons(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	seasons := newTestSeasonService(t)
+	unitPoints := services.NewUnitPointsService(testServices.RuleService, testServices.WeaponService, testServices.WarGearService)
+	unitPoints.UseSeasons(seasons)
+
+	// 16 stat points cost 16*2+10 in the built-in season and 16*3+20 in the hardcore one
+	unit := &models.Unit{Name: "Season Unit", Melee: 3, Ranged: 3, Morale: 7, Defense: 3, Amount: 1}
+	baseCost := func(t *testing.T, season string) int {
+		breakdown, err := unitPoints.CalculateUnitPointsInSeason(ctx, unit, season)
+		if err != nil {
+			t.Fatalf("Failed to calculate unit points: %v", err)
+		}
+		return breakdown.BaseCost
+	}
+
+	if current := seasons.Current(); current.Name != services.DefaultSeasonName || !current.Current {
+		t.Fatalf("Expected the built-in season to be current, got %+v", current)
+	}
+	if got := baseCost(t, ""); got != 42 {
+		t.Errorf("Expected the built-in base cost of 42, got %d", got)
+	}
+
+	hardcore := testSeason("hardcore")
+	hardcore.Units = models.UnitPointsConfig{StatMultiplier: 3, BaseCost: 20, MinCost: 5}
+	created, err := seasons.CreateSeason(ctx, hardcore)
+	if err != nil {
+		t.Fatalf("Failed to create season: %v", err)
+	}
+	if created.Current || created.Version != 1 {
+		t.Errorf("Expected a historical season at version 1, got %+v", created)
+	}
+
+	t.Run("Historical", func(t *testing.T) {
+		if got := baseCost(t, "hardcore"); got != 68 {
+			t.Errorf("Expected 68 in the hardcore season, got %d", got)
+		}
+		if got := baseCost(t, ""); got != 42 {
+			t.Errorf("Expected the current season to be unchanged, got %d", got)
+		}
+		if _, err := unitPoints.CalculateUnitPointsInSeason(ctx, unit, "missing"); err == nil {
+			t.Error("Expected an unknown season to be refused")
+		}
+	})
+
+	t.Run("Weapons Priced In Season", func(t *testing.T) {
+		weapon := CreateTestWeapon()
+		weapon.Points = 99
+		weapon, err := testServices.WeaponService.CreateWeapon(ctx, weapon)
+		if err != nil {
+			t.Fatalf("Failed to create weapon: %v", err)
+		}
+		armed := *unit
+		armed.Weapons = []models.WeaponReference{{WeaponID: weapon.ID, Quantity: 1, Type: weapon.Type}}
+
+		stored, _ := unitPoints.CalculateUnitPoints(ctx, &armed)
+		priced, _ := unitPoints.CalculateUnitPointsInSeason(ctx, &armed, services.DefaultSeasonName)
+		want := services.NewWeaponPointsCalculator().CalculateWeaponPoints(services.WeaponStatsOf(weapon))
+		if stored.WeaponsCost != 99 || priced.WeaponsCost != want {
+			t.Errorf("Expected the stored 99 and the calculated %d, got %d and %d", want, stored.WeaponsCost, priced.WeaponsCost)
+		}
+	})
+
+	t.Run("Activate", func(t *testing.T) {
+		activated, err := seasons.ActivateSeason(ctx, "hardcore")
+		if err != nil {
+			t.Fatalf("Failed to activate season: %v", err)
+		}
+		if !activated.Current || activated.Version != 2 || activated.ActivatedAt.IsZero() {
+			t.Errorf("Expected activating to mark the season current at version 2, got %+v", activated)
+		}
+		if got := baseCost(t, ""); got != 68 {
+			t.Errorf("Expected the hardcore season to apply straight away, got %d", got)
+		}
+		if err := seasons.DeleteSeason(ctx, "hardcore"); !utils.IsValidationError(err) {
+			t.Errorf("Expected the current season not to be deleted, got %v", err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		update := testSeason("hardcore")
+		update.Units = models.UnitPointsConfig{StatMultiplier: 3, BaseCost: 30, MinCost: 5}
+		update.Version = 2
+		updated, err := seasons.UpdateSeason(ctx, "hardcore", update)
+		if err != nil {
+			t.Fatalf("Failed to update season: %v", err)
+		}
+		if updated.Version != 3 || !updated.Current {
+			t.Errorf("Expected the season to stay current at version 3, got %+v", updated)
+		}
+		if got := baseCost(t, ""); got != 78 {
+			t.Errorf("Expected the edit to apply straight away, got %d", got)
+		}
+
+		stale := testSeason("hardcore")
+		stale.Version = 1
+		if _, err := seasons.UpdateSeason(ctx, "hardcore", stale); !utils.IsVersionConflictError(err) {
+			t.Errorf("Expected a version conflict, got %v", err)
+		}
+		if _, err := seasons.UpdateSeason(ctx, services.DefaultSeasonName, testSeason(services.DefaultSeasonName)); !utils.IsValidationError(err) {
+			t.Errorf("Expected the built-in season to be read-only, got %v", err)
+		}
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		edit := func(units models.UnitPointsConfig) {
+			collection := testDB.Collections["pointsseasons"]
+			var stored models.PointsSeason
+			if err := collection.FindOne(ctx, bson.M{"name": "hardcore"}, &stored); err != nil {
+				t.Fatalf("Failed to read season: %v", err)
+			}
+			stored.Units = units
+			if _, err := collection.ReplaceOne(ctx, bson.M{"_id": stored.ID}, stored); err != nil {
+				t.Fatalf("Failed to edit season: %v", err)
+			}
+			if err := seasons.Reload(ctx); err != nil {
+				t.Fatalf("Failed to reload seasons: %v", err)
+			}
+		}
+
+		edit(models.UnitPointsConfig{StatMultiplier: 1, BaseCost: 0, MinCost: 0})
+		if got := baseCost(t, ""); got != 16 {
+			t.Errorf("Expected a direct edit to apply after a reload, got %d", got)
+		}
+		edit(models.UnitPointsConfig{StatMultiplier: -1})
+		if got := baseCost(t, ""); got != 16 {
+			t.Errorf("Expected an invalid edit to keep the last good settings, got %d", got)
+		}
+	})
+
+	t.Run("Back To Built-In", func(t *testing.T) {
+		if _, err := seasons.ActivateSeason(ctx, services.DefaultSeasonName); err != nil {
+			t.Fatalf("Failed to activate the built-in season: %v", err)
+		}
+		if got := baseCost(t, ""); got != 42 {
+			t.Errorf("Expected the built-in season again, got %d", got)
+		}
+		if err := seasons.DeleteSeason(ctx, "hardcore"); err != nil {
+			t.Fatalf("Failed to delete season: %v", err)
+		}
+		if list := seasons.ListSeasons(); len(list) != 1 || list[0].Name != services.DefaultSeasonName {
+			t.Errorf("Expected only the built-in season to be left, got %+v", list)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		if _, err := seasons.CreateSeason(ctx, testSeason("twice")); err != nil {
+			t.Fatalf("Failed to create season: %v", err)
+		}
+		if _, err := seasons.CreateSeason(ctx, testSeason("twice")); !utils.IsDuplicateError(err) {
+			t.Errorf("Expected a duplicate season name to be refused, got %v", err)
+		}
+	})
+
+	t.Run("Overlapping Activations", func(t *testing.T) {
+		collection := testDB.Collections["pointsseasons"]
+		for _, name := range []string{"spring", "summer"} {
+			if _, err := seasons.CreateSeason(ctx, testSeason(name)); err != nil {
+				t.Fatalf("Failed to create season: %v", err)
+			}
+		}
+		spring, err := seasons.ActivateSeason(ctx, "spring")
+		if err != nil {
+			t.Fatalf("Failed to activate season: %v", err)
+		}
+
+		// An activation of summer that started earlier marks it current
+		// only after spring's activation has cleared the others
+		if _, err := collection.UpdateOne(ctx, bson.M{"name": "summer"}, bson.M{
+			"$set": bson.M{"current": true, "activatedAt": spring.ActivatedAt.Add(-time.Second)},
+		}); err != nil {
+			t.Fatalf("Failed to mark season current: %v", err)
+		}
+		if err := seasons.Reload(ctx); err != nil {
+			t.Fatalf("Failed to reload seasons: %v", err)
+		}
+		if current := seasons.Current(); current.Name != "spring" {
+			t.Errorf("Expected the season activated last to be current, got %s", current.Name)
+		}
+		currentSeasons := 0
+		for _, season := range seasons.ListSeasons() {
+			if season.Current {
+				currentSeasons++
+			}
+		}
+		if currentSeasons != 1 {
+			t.Errorf("Expected one season to be listed as current, got %d", currentSeasons)
+		}
+
+		if _, err := seasons.ActivateSeason(ctx, "twice"); err != nil {
+			t.Fatalf("Failed to activate season: %v", err)
+		}
+		var stored []models.PointsSeason
+		if err := collection.Find(ctx, bson.M{"current": true}, &stored, nil); err != nil {
+			t.Fatalf("Failed to read seasons: %v", err)
+		}
+		if len(stored) != 1 || stored[0].Name != "twice" {
+			t.Errorf("Expected the next activation to clear both, got %+v", stored)
+		}
+		cleared, err := seasons.Season("spring")
+		if err != nil || cleared.Current || cleared.Version != spring.Version+1 {
+			t.Errorf("Expected clearing spring to bump its version past %d, got %+v, %v", spring.Version, cleared, err)
+		}
+	})
+}
+
+func TestPointsSeasonValidation(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(season *models.PointsSeason)
+	}{
+		{"Name", func(s *models.PointsSeason) { s.Name = "Spring 2025" }},
+		{"Keyword Thresholds", func(s *models.PointsSeason) { s.Rules.StrongThreshold = s.Rules.HighImpactThreshold + 1 }},
+		{"Multiplier Bounds", func(s *models.PointsSeason) { s.Rules.MinMultiplier = 0 }},
+		{"Empty Keyword", func(s *models.PointsSeason) { s.Rules.LimitedKeywords = append(s.Rules.LimitedKeywords, "") }},
+		{"Effectiveness Level", func(s *models.PointsSeason) { s.Rules.BaseEffectivenessKeywords = map[string]string{"mighty": "huge"} }},
+		{"Negative Weight", func(s *models.PointsSeason) { s.Weapons.MeleeWeights.AP = -0.1 }},
+		{"Zero Weights", func(s *models.PointsSeason) { s.Weapons.RangedWeights = models.StatWeights{} }},
+		{"Scale Base", func(s *models.PointsSeason) { s.Weapons.ScaleBase = 1 }},
+		{"Points Bounds", func(s *models.PointsSeason) { s.Weapons.MinPoints = s.Weapons.MaxPoints + 1 }},
+		{"Unit Formula", func(s *models.PointsSeason) { s.Units.BaseCost = -10 }},
+	}
+
+	if err := services.ValidatePointsSeason(testSeason("spring-2025")); err != nil {
+		t.Fatalf("Expected the built-in settings to be valid, got %v", err)
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			season := testSeason("spring-2025")
+			tc.change(season)
+			if err := services.ValidatePointsSeason(season); !utils.IsValidationError(err) {
+				t.Errorf("Expected a validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRulePointsInSeason(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	seasons := newTestSeasonService(t)
+	rulePoints := services.NewRulePointsService(testServices.RuleService)
+	rulePoints.UseSeasons(seasons)
+	rule := &models.Rule{Name: "Grimdank", Description: "grimdank"}
+
+	before := rulePoints.CalculateRulePoints(rule)
+
+	season := testSeason("grim")
+	season.Current = true
+	season.Rules.OverpoweredKeywords = []string{"grimdank"}
+	if _, err := seasons.CreateSeason(ctx, season); err != nil {
+		t.Fatalf("Failed to create season: %v", err)
+	}
+
+	after := rulePoints.CalculateRulePoints(rule)
+	if after[0] <= before[0] {
+		t.Errorf("Expected the season's keywords to make the rule dearer, got %v then %v", before, after)
+	}
+}
+
+func TestPointsSeasonHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	seasons := newTestSeasonService(t)
+	handler := handlers.NewPointsSeasonHandler(seasons)
+	unitPoints := services.NewUnitPointsService(testServices.RuleService, testServices.WeaponService, testServices.WarGearService)
+	unitPoints.UseSeasons(seasons)
+	unitPointsHandler := handlers.NewUnitPointsHandler(unitPoints, false)
+	weaponPointsHandler := handlers.NewWeaponPointsHandler(seasons)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/points/seasons", handler.GetSeasons).Methods("GET")
+	router.HandleFunc("/points/seasons/{name}", handler.GetSeason).Methods("GET")
+	router.HandleFunc("/calculate-unit-points", unitPointsHandler.CalculateUnitPoints).Methods("POST")
+	router.HandleFunc("/weapon-points/calculate", weaponPointsHandler.CalculateWeaponPoints).Methods("POST")
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(handlers.RequireAdminToken("secret"))
+	admin.HandleFunc("/points/seasons", handler.CreateSeason).Methods("POST")
+	admin.HandleFunc("/points/seasons/reload", handler.ReloadSeasons).Methods("POST")
+	admin.HandleFunc("/points/seasons/{name}", handler.UpdateSeason).Methods("PUT")
+	admin.HandleFunc("/points/seasons/{name}", handler.DeleteSeason).Methods("DELETE")
+	admin.HandleFunc("/points/seasons/{name}/activate", handler.ActivateSeason).Methods("POST")
+
+	encode := func(v interface{}) []byte {
+		body, _ := json.Marshal(v)
+		return body
+	}
+	invalid := testSeason("broken")
+	invalid.Units.BaseCost = -1
+	unitBody := encode(map[string]interface{}{"unit": models.Unit{Name: "Handler Unit", Melee: 3, Ranged: 3, Morale: 7, Defense: 3, Amount: 1}})
+	weaponBody := encode(services.WeaponStats{Range: 24, Attacks: "2", AP: "1", Type: "Ranged"})
+
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		body    []byte
+		ifMatch string
+		want    int
+	}{
+		{"List", "GET", "/points/seasons", nil, "", http.StatusOK},
+		{"Get Built-In", "GET", "/points/seasons/default", nil, "", http.StatusOK},
+		{"Get Missing", "GET", "/points/seasons/missing", nil, "", http.StatusNotFound},
+		{"Create", "POST", "/admin/points/seasons", encode(testSeason("autumn")), "", http.StatusCreated},
+		{"Create Duplicate", "POST", "/admin/points/seasons", encode(testSeason("autumn")), "", http.StatusConflict},
+		{"Create Invalid", "POST", "/admin/points/seasons", encode(invalid), "", http.StatusBadRequest},
+		{"Update", "PUT", "/admin/points/seasons/autumn", encode(testSeason("autumn")), `"1"`, http.StatusOK},
+		{"Update Stale", "PUT", "/admin/points/seasons/autumn", encode(testSeason("autumn")), `"1"`, http.StatusPreconditionFailed},
+		{"Update Missing", "PUT", "/admin/points/seasons/missing", encode(testSeason("missing")), "", http.StatusNotFound},
+		{"Activate", "POST", "/admin/points/seasons/autumn/activate", nil, "", http.StatusOK},
+		{"Delete Current", "DELETE", "/admin/points/seasons/autumn", nil, "", http.StatusBadRequest},
+		{"Reload", "POST", "/admin/points/seasons/reload", nil, "", http.StatusOK},
+		{"Unit In Season", "POST", "/calculate-unit-points?season=default", unitBody, "", http.StatusOK},
+		{"Unit In Missing Season", "POST", "/calculate-unit-points?season=missing", unitBody, "", http.StatusNotFound},
+		{"Weapon In Season", "POST", "/weapon-points/calculate?season=autumn", weaponBody, "", http.StatusOK},
+		{"Weapon In Missing Season", "POST", "/weapon-points/calculate?season=missing", weaponBody, "", http.StatusNotFound},
+		{"Back To Built-In", "POST", "/admin/points/seasons/default/activate", nil, "", http.StatusOK},
+		{"Delete", "DELETE", "/admin/points/seasons/autumn", nil, "", http.StatusNoContent},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, bytes.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Errorf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
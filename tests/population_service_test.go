@@ -200,10 +200,14 @@ func TestPopulationService(t *testing.T) {
 			t.Fatalf("Failed to create weapon: %v", err)
 		}
 
-		// Test population should fail
-		_, err = testServices.PopulationService.PopulateWeaponRules(ctx, createdWeapon)
-		if err == nil {
-			t.Error("Expected error when populating weapon with non-existent rule, but got none")
+		// The missing rule is reported rather than failing the population
+		populatedWeapon, err := testServices.PopulationService.PopulateWeaponRules(ctx, createdWeapon)
+		if err != nil {
+			t.Fatalf("Failed to populate weapon rules: %v", err)
+		}
+		want := models.MissingReference{Field: "rules", ID: weapon.Rules[0].RuleID}
+		if len(populatedWeapon.PopulatedRules) != 0 || len(populatedWeapon.MissingReferences) != 1 || populatedWeapon.MissingReferences[0] != want {
+			t.Errorf("Expected %+v to be reported missing, got %+v", want, populatedWeapon.MissingReferences)
 		}
 	})
 
@@ -223,10 +227,84 @@ func TestPopulationService(t *testing.T) {
 			t.Fatalf("Failed to create wargear: %v", err)
 		}
 
-		// Test population should fail
-		_, err = testServices.PopulationService.PopulateWarGearRules(ctx, createdWarGear)
-		if err == nil {
-			t.Error("Expected error when populating wargear with non-existent rule, but got none")
+		// The missing rule is reported rather than failing the population
+		populatedWarGear, err := testServices.PopulationService.PopulateWarGearRules(ctx, createdWarGear)
+		if err != nil {
+			t.Fatalf("Failed to populate wargear rules: %v", err)
+		}
+		want := models.MissingReference{Field: "rules", ID: wargear.Rules[0].RuleID}
+		if len(populatedWarGear.PopulatedRules) != 0 || len(populatedWarGear.MissingReferences) != 1 || populatedWarGear.MissingReferences[0] != want {
+			t.Errorf("Expected %+v to be reported missing, got %+v", want, populatedWarGear.MissingReferences)
+		}
+	})
+}
+
+func TestPopulateMissingReferences(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	ctx := context.Background()
+
+	rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRule())
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	weapon, err := testServices.WeaponService.CreateWeapon(ctx, CreateTestWeapon())
+	if err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+
+	// The repository skips the service's reference checks, leaving a dangling weapon
+	goneWeapon := primitive.NewObjectID()
+	unit := CreateTestUnit()
+	unit.Rules = []models.RuleReference{{RuleID: rule.ID, Tier: 1}}
+	unit.AvailableWeapons = []primitive.ObjectID{weapon.ID, goneWeapon}
+	unit.Weapons = []models.WeaponReference{{WeaponID: weapon.ID, Quantity: 2, Type: "ranged"}}
+	unitID, err := testRepos.UnitRepo.CreateUnit(ctx, unit)
+	if err != nil {
+		t.Fatalf("Failed to create unit: %v", err)
+	}
+
+	t.Run("Unit", func(t *testing.T) {
+		populated, err := testServices.PopulationService.GetPopulatedUnit(ctx, unitID)
+		if err != nil {
+			t.Fatalf("Failed to populate unit: %v", err)
+		}
+		if len(populated.PopulatedRules) != 1 || len(populated.PopulatedAvailableWeapons) != 1 || len(populated.PopulatedWeapons) != 1 {
+			t.Errorf("Expected the existing references to be populated, got %+v", populated)
+		}
+		want := models.MissingReference{Field: "availableWeaponIds", ID: goneWeapon}
+		if len(populated.MissingReferences) != 1 || populated.MissingReferences[0] != want {
+			t.Errorf("Expected %+v to be reported missing, got %+v", want, populated.MissingReferences)
+		}
+	})
+
+	t.Run("Army Book", func(t *testing.T) {
+		armyBook := CreateTestArmyBook()
+		armyBook.Units = []primitive.ObjectID{unit.ID, primitive.NewObjectID()}
+		armyBook.Rules = []models.RuleReference{{RuleID: rule.ID, Tier: 1}}
+
+		populated, err := testServices.PopulationService.PopulateArmyBook(ctx, armyBook)
+		if err != nil {
+			t.Fatalf("Failed to populate army book: %v", err)
+		}
+		if len(populated.PopulatedUnits) != 1 || len(populated.PopulatedRules) != 1 {
+			t.Errorf("Expected one unit and one rule, got %d and %d", len(populated.PopulatedUnits), len(populated.PopulatedRules))
+		}
+		if len(populated.MissingReferences) != 1 || populated.MissingReferences[0].Field != "unitIds" {
+			t.Errorf("Expected the missing unit to be reported, got %+v", populated.MissingReferences)
+		}
+	})
+
+	t.Run("Army List Repeats Units", func(t *testing.T) {
+		armyList := CreateTestArmyList()
+		armyList.Units = []primitive.ObjectID{unit.ID, unit.ID}
+
+		populated, err := testServices.PopulationService.PopulateArmyList(ctx, armyList)
+		if err != nil {
+			t.Fatalf("Failed to populate army list: %v", err)
+		}
+		if len(populated.PopulatedUnits) != 2 || len(populated.MissingReferences) != 0 {
+			t.Errorf("Expected the unit twice and nothing missing, got %d units and %+v", len(populated.PopulatedUnits), populated.MissingReferences)
 		}
 	})
 }
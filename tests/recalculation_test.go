@@ -0,0 +1,402 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/services"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestRecalculationService() *services.RecalculationService {
+	unitPoints := services.NewUnitPointsService(testServices.RuleService, testServices.WeaponService, testServices.WarGearService)
+	return services.NewRecalculationService(
+		testRepos.RuleRepo,
+		testRepos.WeaponRepo,
+		testRepos.WarGearRepo,
+		testRepos.UnitRepo,
+		testRepos.ArmyListRepo,
+		unitPoints,
+		testServices.RevisionService,
+	)
+}
+
+// pointsGraph is a rule used by a weapon, a wargear item and a unit that
+// carries both, fielded twice by an army list
+type pointsGraph struct {
+	rule     *models.Rule
+	weapon   *models.Weapon
+	wargear  *models.WarGear
+	unit     *models.Unit
+	armyList *models.ArmyList
+}
+
+func createPointsGraph(t *testing.T, ctx context.Context) *pointsGraph {
+	var g pointsGraph
+	var err error
+	if g.rule, err = testServices.RuleService.CreateRule(ctx, &models.Rule{Name: "Rending", Points: []int{5, 7, 9}}); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	ruleAt := func(tier int) []models.RuleReference {
+		return []models.RuleReference{{RuleID: g.rule.ID, Tier: tier}}
+	}
+
+	if g.weapon, err = testServices.WeaponService.CreateWeapon(ctx, &models.Weapon{Name: "Rending Rifle", Type: "Ranged", Range: 24, Attacks: 2, AP: "1", Rules: ruleAt(1)}); err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+	if g.wargear, err = testServices.WarGearService.CreateWarGear(ctx, &models.WarGear{Name: "Rending Claws", Rules: ruleAt(2)}); err != nil {
+		t.Fatalf("Failed to create wargear: %v", err)
+	}
+
+	unit := CreateTestUnit()
+	unit.Amount, unit.Max = 2, 2
+	unit.Rules = ruleAt(3)
+	unit.Weapons = []models.WeaponReference{{WeaponID: g.weapon.ID, Quantity: 2, Type: "Ranged"}}
+	unit.WarGear = []primitive.ObjectID{g.wargear.ID}
+	if g.unit, err = testServices.UnitService.CreateUnit(ctx, unit); err != nil {
+		t.Fatalf("Failed to create unit: %v", err)
+	}
+
+	armyList := CreateTestArmyList()
+	armyList.Units = []primitive.ObjectID{g.unit.ID, g.unit.ID}
+	if g.armyList, err = testServices.ArmyListService.CreateArmyList(ctx, armyList); err != nil {
+		t.Fatalf("Failed to create army list: %v", err)
+	}
+	return &g
+}
+
+// storedPoints reads the points of the graph's weapon, wargear, unit and army list
+func (g *pointsGraph) storedPoints(t *testing.T, ctx context.Context) (int, int, int, int) {
+	weapon, err1 := testRepos.WeaponRepo.GetWeaponByID(ctx, g.weapon.ID.Hex())
+	wargear, err2 := testRepos.WarGearRepo.GetWarGearByID(ctx, g.wargear.ID.Hex())
+	unit, err3 := testRepos.UnitRepo.GetUnitByID(ctx, g.unit.ID.Hex())
+	armyList, err4 := testRepos.ArmyListRepo.GetArmyListByID(ctx, g.armyList.ID.Hex())
+	for _, err := range []error{err1, err2, err3, err4} {
+		if err != nil {
+			t.Fatalf("Failed to read points: %v", err)
+		}
+	}
+	return weapon.Points, wargear.Points, unit.Points, armyList.Points
+}
+
+// expectedUnitPoints works out the unit's cost by hand: base stats, then the
+// unit, weapon and wargear rules per model, then the weapons
+func expectedUnitPoints(rulePoints []int, weaponPoints int) int {
+	const models = 2
+	base := (3+3+7+3)*2 + 10
+	return base + rulePoints[2]*models + weaponPoints*2 + rulePoints[0]*models + rulePoints[1]*models
+}
+
+func TestPointsRecalculation(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	recalculation := newTestRecalculationService()
+	g := createPointsGraph(t, ctx)
+	weaponPoints := services.NewWeaponPointsCalculator().CalculateWeaponPoints(services.WeaponStats{Range: 24, Attacks: "2", AP: "1", Type: "Ranged"})
+
+	t.Run("Everything", func(t *testing.T) {
+		report, err := recalculation.RecalculateEverything(ctx)
+		if err != nil {
+			t.Fatalf("Failed to recalculate: %v", err)
+		}
+		if report.Checked != 4 || len(report.Changed) != 4 {
+			t.Errorf("Expected four entities checked and changed, got %+v", report)
+		}
+
+		weapon, wargear, unit, armyList := g.storedPoints(t, ctx)
+		wantUnit := expectedUnitPoints([]int{5, 7, 9}, weaponPoints)
+		if weapon != weaponPoints || wargear != 7 || unit != wantUnit || armyList != 2*wantUnit {
+			t.Errorf("Expected %d/7/%d/%d, got %d/%d/%d/%d", weaponPoints, wantUnit, 2*wantUnit, weapon, wargear, unit, armyList)
+		}
+
+		again, err := recalculation.RecalculateEverything(ctx)
+		if err != nil || len(again.Changed) != 0 {
+			t.Errorf("Expected a second run to change nothing, got %+v, %v", again, err)
+		}
+	})
+
+	t.Run("From A Rule", func(t *testing.T) {
+		rule := *g.rule
+		rule.Points = []int{6, 8, 10}
+		if err := testServices.RuleService.UpdateRule(ctx, rule.ID.Hex(), &rule); err != nil {
+			t.Fatalf("Failed to update rule: %v", err)
+		}
+
+		report, err := recalculation.Recalculate(ctx, "rules", rule.ID.Hex())
+		if err != nil {
+			t.Fatalf("Failed to recalculate: %v", err)
+		}
+		// The weapon's own points come from its stats, so only its unit changes
+		changed := map[string]bool{}
+		for _, change := range report.Changed {
+			changed[change.EntityType] = true
+		}
+		if report.Checked != 4 || len(report.Changed) != 3 || changed["weapons"] {
+			t.Errorf("Expected the wargear, unit and army list to change, got %+v", report)
+		}
+
+		_, wargear, unit, armyList := g.storedPoints(t, ctx)
+		wantUnit := expectedUnitPoints(rule.Points, weaponPoints)
+		if wargear != 8 || unit != wantUnit || armyList != 2*wantUnit {
+			t.Errorf("Expected 8/%d/%d, got %d/%d/%d", wantUnit, 2*wantUnit, wargear, unit, armyList)
+		}
+
+		revisions, err := testServices.RevisionService.ListRevisions(ctx, "armylists", g.armyList.ID.Hex(), 1, 0)
+		if err != nil || len(revisions) != 1 || revisions[0].Reason != services.RecalculationReason {
+			t.Errorf("Expected the change to be recorded as a recalculation, got %+v, %v", revisions, err)
+		}
+	})
+
+	t.Run("Automatic", func(t *testing.T) {
+		recalculation.Subscribe(testServices.EventBus)
+
+		unit, err := testServices.UnitService.GetUnitByID(ctx, g.unit.ID.Hex())
+		if err != nil {
+			t.Fatalf("Failed to get unit: %v", err)
+		}
+		unit.Defense = 5
+		if err := testServices.UnitService.UpdateUnit(ctx, unit.ID.Hex(), unit); err != nil {
+			t.Fatalf("Failed to update unit: %v", err)
+		}
+		testServices.EventBus.Wait()
+
+		_, _, unitPoints, armyList := g.storedPoints(t, ctx)
+		wantUnit := expectedUnitPoints([]int{6, 8, 10}, weaponPoints) + 2*2
+		if unitPoints != wantUnit || armyList != 2*wantUnit {
+			t.Errorf("Expected the unit and army list to follow the change, got %d/%d", unitPoints, armyList)
+		}
+	})
+
+	// followsUnit checks that the stored points of the unit and army list are
+	// what the unit's current stats come to
+	followsUnit := func(t *testing.T) {
+		unit, err := testServices.UnitService.GetUnitByID(ctx, g.unit.ID.Hex())
+		if err != nil {
+			t.Fatalf("Failed to get unit: %v", err)
+		}
+		unitPoints := services.NewUnitPointsService(testServices.RuleService, testServices.WeaponService, testServices.WarGearService)
+		breakdown, err := unitPoints.CalculateUnitPoints(ctx, unit)
+		if err != nil {
+			t.Fatalf("Failed to calculate unit points: %v", err)
+		}
+		_, _, stored, armyList := g.storedPoints(t, ctx)
+		if stored != breakdown.TotalPoints || armyList != 2*breakdown.TotalPoints {
+			t.Errorf("Expected the unit and army list at %d/%d, got %d/%d", breakdown.TotalPoints, 2*breakdown.TotalPoints, stored, armyList)
+		}
+	}
+
+	t.Run("Reason Is Not A Marker", func(t *testing.T) {
+		// A client can send the engine's reason, but its edits still count
+		posing := services.WithChange(ctx, "mallory", services.RecalculationReason)
+		unit, err := testServices.UnitService.GetUnitByID(posing, g.unit.ID.Hex())
+		if err != nil {
+			t.Fatalf("Failed to get unit: %v", err)
+		}
+		unit.Defense = 6
+		if err := testServices.UnitService.UpdateUnit(posing, unit.ID.Hex(), unit); err != nil {
+			t.Fatalf("Failed to update unit: %v", err)
+		}
+		testServices.EventBus.Wait()
+		followsUnit(t)
+	})
+
+	t.Run("Burst Of Edits", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			unit, err := testServices.UnitService.GetUnitByID(ctx, g.unit.ID.Hex())
+			if err != nil {
+				t.Fatalf("Failed to get unit: %v", err)
+			}
+			unit.Defense = 3 + i%5
+			unit.Version = 0 // the engine writes to it too
+			if err := testServices.UnitService.UpdateUnit(ctx, unit.ID.Hex(), unit); err != nil {
+				t.Fatalf("Failed to update unit: %v", err)
+			}
+		}
+		testServices.EventBus.Wait()
+		followsUnit(t)
+	})
+
+	t.Run("Trashed Entity", func(t *testing.T) {
+		if err := testServices.ArmyListService.DeleteArmyList(ctx, g.armyList.ID.Hex()); err != nil {
+			t.Fatalf("Failed to delete army list: %v", err)
+		}
+		report, err := recalculation.Recalculate(ctx, "armylists", g.armyList.ID.Hex())
+		if err != nil || report.Checked != 0 {
+			t.Errorf("Expected a trashed army list to be skipped, got %+v, %v", report, err)
+		}
+	})
+}
+
+func TestRecalculationHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	handler := handlers.NewRecalculationHandler(newTestRecalculationService())
+	g := createPointsGraph(t, ctx)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/points/recalculate", handler.RecalculateEverything).Methods("POST")
+	router.HandleFunc("/points/recalculate/{type}/{id}", handler.Recalculate).Methods("POST")
+
+	cases := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"Everything", "/points/recalculate", http.StatusOK},
+		{"From A Rule", "/points/recalculate/rules/" + g.rule.ID.Hex(), http.StatusOK},
+		{"Unknown Type", "/points/recalculate/factions/" + g.rule.ID.Hex(), http.StatusBadRequest},
+		{"Invalid ID", "/points/recalculate/rules/not-an-id", http.StatusBadRequest},
+		{"Missing Entity", "/points/recalculate/units/" + primitive.NewObjectID().Hex(), http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tc.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Errorf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSeasonChangeRecalculation(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	seasons := newTestSeasonService(t)
+	unitPoints := services.NewUnitPointsService(testServices.RuleService, testServices.WeaponService, testServices.WarGearService)
+	unitPoints.UseSeasons(seasons)
+	recalculation := services.NewRecalculationService(testRepos.RuleRepo, testRepos.WeaponRepo, testRepos.WarGearRepo, testRepos.UnitRepo, testRepos.ArmyListRepo, unitPoints, testServices.RevisionService)
+	recalculation.Subscribe(testServices.EventBus)
+
+	// The same weapon in the default workspace and in another one
+	other := services.WithWorkspace(ctx, "other")
+	var weapons []*models.Weapon
+	for _, workspaceCtx := range []context.Context{ctx, other} {
+		weapon, err := testServices.WeaponService.CreateWeapon(workspaceCtx, &models.Weapon{Name: "Rifle", Type: "Ranged", Range: 24, Attacks: 2, AP: "1"})
+		if err != nil {
+			t.Fatalf("Failed to create weapon: %v", err)
+		}
+		weapons = append(weapons, weapon)
+	}
+	testServices.EventBus.Wait()
+
+	stats := services.WeaponStatsOf(weapons[0])
+	storedPoints := func(t *testing.T) []int {
+		var points []int
+		for i, workspaceCtx := range []context.Context{ctx, other} {
+			weapon, err := testRepos.WeaponRepo.GetWeaponByID(workspaceCtx, weapons[i].ID.Hex())
+			if err != nil {
+				t.Fatalf("Failed to get weapon: %v", err)
+			}
+			points = append(points, weapon.Points)
+		}
+		return points
+	}
+	builtIn := services.NewWeaponPointsCalculator().CalculateWeaponPoints(stats)
+	if points := storedPoints(t); points[0] != builtIn || points[1] != builtIn {
+		t.Fatalf("Expected both weapons at %d, got %v", builtIn, points)
+	}
+
+	season := testSeason("pricey")
+	season.Weapons.RangedWeights.Attacks *= 3
+	season.Weapons.MeleeWeights.Attacks *= 3
+	season.Current = true
+	if _, err := seasons.CreateSeason(ctx, season); err != nil {
+		t.Fatalf("Failed to create season: %v", err)
+	}
+	testServices.EventBus.Wait()
+
+	pricey := services.NewWeaponPointsCalculatorWithConfig(&seasons.Current().Weapons).CalculateWeaponPoints(stats)
+	if pricey == builtIn {
+		t.Fatalf("Expected the season to change the weapon's cost from %d", builtIn)
+	}
+	if points := storedPoints(t); points[0] != pricey || points[1] != pricey {
+		t.Errorf("Expected activating the season to reprice both workspaces at %d, got %v", pricey, points)
+	}
+
+	if _, err := seasons.ActivateSeason(ctx, services.DefaultSeasonName); err != nil {
+		t.Fatalf("Failed to activate the built-in season: %v", err)
+	}
+	testServices.EventBus.Wait()
+	if points := storedPoints(t); points[0] != builtIn || points[1] != builtIn {
+		t.Errorf("Expected going back to the built-in season to reprice both workspaces at %d, got %v", builtIn, points)
+	}
+}
+
+func TestRecalculationDropsFailingEntities(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	unitPoints := services.NewUnitPointsService(testServices.RuleService, testServices.WeaponService, testServices.WarGearService)
+	recalculation := services.NewRecalculationService(testRepos.RuleRepo, testRepos.WeaponRepo, testRepos.WarGearRepo, testRepos.UnitRepo, testRepos.ArmyListRepo, unitPoints, testServices.RevisionService)
+	recalculation.Subscribe(testServices.EventBus)
+
+	// A rule carried by a unit, and by a wargear item that can't be read
+	unitWithRule := func(name string) (*models.Rule, *models.Unit) {
+		rule, err := testServices.RuleService.CreateRule(ctx, &models.Rule{Name: name, Points: []int{5}})
+		if err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+		unit := CreateTestUnit()
+		unit.Rules = []models.RuleReference{{RuleID: rule.ID, Tier: 1}}
+		if unit, err = testServices.UnitService.CreateUnit(ctx, unit); err != nil {
+			t.Fatalf("Failed to create unit: %v", err)
+		}
+		return rule, unit
+	}
+	rule, unit := unitWithRule("Stubborn")
+	if _, err := testDB.Collections["wargear"].InsertOne(ctx, bson.M{
+		"name": "Corrupted", "points": "lots", "version": 1,
+		"rules": bson.A{bson.M{"ruleId": rule.ID, "tier": 1}},
+	}); err != nil {
+		t.Fatalf("Failed to insert wargear: %v", err)
+	}
+	testServices.EventBus.Wait()
+
+	repriced := func(t *testing.T, rule *models.Rule, unit *models.Unit) {
+		rule.Points = []int{15}
+		if err := testServices.RuleService.UpdateRule(ctx, rule.ID.Hex(), rule); err != nil {
+			t.Fatalf("Failed to update rule: %v", err)
+		}
+		testServices.EventBus.Wait()
+
+		stored, err := testRepos.UnitRepo.GetUnitByID(ctx, unit.ID.Hex())
+		if err != nil {
+			t.Fatalf("Failed to get unit: %v", err)
+		}
+		breakdown, err := unitPoints.CalculateUnitPoints(ctx, stored)
+		if err != nil {
+			t.Fatalf("Failed to calculate unit points: %v", err)
+		}
+		if stored.Points != breakdown.TotalPoints {
+			t.Errorf("Expected the unit to be repriced at %d, got %d", breakdown.TotalPoints, stored.Points)
+		}
+	}
+
+	t.Run("Alongside The Failing Entity", func(t *testing.T) {
+		repriced(t, rule, unit)
+	})
+
+	t.Run("Changed Later", func(t *testing.T) {
+		rule, unit := unitWithRule("Relentless")
+		testServices.EventBus.Wait()
+		repriced(t, rule, unit)
+	})
+}
@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/services"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// referenceGraph is a rule used by a weapon and wargear, all three used by a
+// unit, and the unit used by an army book and an army list
+type referenceGraph struct {
+	rule     *models.Rule
+	weapon   *models.Weapon
+	wargear  *models.WarGear
+	unit     *models.Unit
+	armyBook *models.ArmyBook
+	armyList *models.ArmyList
+}
+
+func createReferenceGraph(t *testing.T, ctx context.Context) *referenceGraph {
+	g := &referenceGraph{}
+	var err error
+
+	if g.rule, err = testServices.RuleService.CreateRule(ctx, CreateTestRule()); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	ruleRefs := []models.RuleReference{{RuleID: g.rule.ID, Tier: 1}}
+
+	weapon := CreateTestWeapon()
+	weapon.Rules = ruleRefs
+	if g.weapon, err = testServices.WeaponService.CreateWeapon(ctx, weapon); err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+
+	wargear := CreateTestWarGear()
+	wargear.Rules = ruleRefs
+	if g.wargear, err = testServices.WarGearService.CreateWarGear(ctx, wargear); err != nil {
+		t.Fatalf("Failed to create wargear: %v", err)
+	}
+
+	unit := CreateTestUnit()
+	unit.Rules = ruleRefs
+	unit.AvailableWeapons = []primitive.ObjectID{g.weapon.ID}
+	unit.Weapons = []models.WeaponReference{{WeaponID: g.weapon.ID, Quantity: 1, Type: "Ranged"}}
+	unit.WarGear = []primitive.ObjectID{g.wargear.ID}
+	if g.unit, err = testServices.UnitService.CreateUnit(ctx, unit); err != nil {
+		t.Fatalf("Failed to create unit: %v", err)
+	}
+
+	armyBook := CreateTestArmyBook()
+	armyBook.Units = []primitive.ObjectID{g.unit.ID}
+	armyBook.Rules = ruleRefs
+	if g.armyBook, err = testServices.ArmyBookService.CreateArmyBook(ctx, armyBook); err != nil {
+		t.Fatalf("Failed to create army book: %v", err)
+	}
+
+	armyList := CreateTestArmyList()
+	armyList.Units = []primitive.ObjectID{g.unit.ID}
+	if g.armyList, err = testServices.ArmyListService.CreateArmyList(ctx, armyList); err != nil {
+		t.Fatalf("Failed to create army list: %v", err)
+	}
+
+	return g
+}
+
+func TestReferentialIntegrity(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	ctx := context.Background()
+
+	t.Run("Reject Referenced Rule", func(t *testing.T) {
+		g := createReferenceGraph(t, ctx)
+
+		err := testServices.RuleService.DeleteRule(ctx, g.rule.ID.Hex())
+		if !services.IsReferencedError(err) {
+			t.Fatalf("Expected referenced error, got %v", err)
+		}
+
+		referencedErr := err.(services.ReferencedError)
+		if len(referencedErr.References) != 4 {
+			t.Errorf("Expected 4 referencing documents, got %v", referencedErr.References)
+		}
+
+		if _, err := testServices.RuleService.GetRuleByID(ctx, g.rule.ID.Hex()); err != nil {
+			t.Errorf("Expected rejected rule to still exist: %v", err)
+		}
+	})
+
+	t.Run("Detach Weapon", func(t *testing.T) {
+		g := createReferenceGraph(t, ctx)
+
+		report, err := testServices.WeaponService.DeleteWeaponWithPolicy(ctx, g.weapon.ID.Hex(), services.DeletePolicyDetach)
+		if err != nil {
+			t.Fatalf("Failed to delete weapon: %v", err)
+		}
+		if len(report.Detached) != 2 {
+			t.Errorf("Expected 2 detached references, got %v", report.Detached)
+		}
+
+		unit, err := testServices.UnitService.GetUnitByID(ctx, g.unit.ID.Hex())
+		if err != nil {
+			t.Fatalf("Expected unit to survive detach: %v", err)
+		}
+		if len(unit.AvailableWeapons) != 0 || len(unit.Weapons) != 0 {
+			t.Errorf("Expected weapon references to be removed, got %v and %v", unit.AvailableWeapons, unit.Weapons)
+		}
+		if len(unit.WarGear) != 1 || len(unit.Rules) != 1 {
+			t.Error("Expected unrelated references to be kept")
+		}
+		if unit.Version != g.unit.Version+1 {
+			t.Errorf("Expected detach to bump unit version to %d, got %d", g.unit.Version+1, unit.Version)
+		}
+	})
+
+	t.Run("Cascade Unit", func(t *testing.T) {
+		g := createReferenceGraph(t, ctx)
+
+		report, err := testServices.UnitService.DeleteUnitWithPolicy(ctx, g.unit.ID.Hex(), services.DeletePolicyCascade)
+		if err != nil {
+			t.Fatalf("Failed to delete unit: %v", err)
+		}
+		if len(report.Cascaded) != 2 {
+			t.Errorf("Expected army book and army list to be cascaded, got %v", report.Cascaded)
+		}
+
+		if _, err := testServices.ArmyBookService.GetArmyBookByID(ctx, g.armyBook.ID.Hex()); err == nil {
+			t.Error("Expected army book to be deleted by cascade")
+		}
+		if _, err := testServices.ArmyListService.GetArmyListByID(ctx, g.armyList.ID.Hex()); err == nil {
+			t.Error("Expected army list to be deleted by cascade")
+		}
+		if _, err := testServices.WeaponService.GetWeaponByID(ctx, g.weapon.ID.Hex()); err != nil {
+			t.Error("Expected weapons used by the unit to be untouched")
+		}
+	})
+
+	t.Run("Cascade Rule Through Unit", func(t *testing.T) {
+		g := createReferenceGraph(t, ctx)
+
+		report, err := testServices.RuleService.DeleteRuleWithPolicy(ctx, g.rule.ID.Hex(), services.DeletePolicyCascade)
+		if err != nil {
+			t.Fatalf("Failed to delete rule: %v", err)
+		}
+
+		// weapon, wargear, unit, army book and the army list reached through the unit
+		if len(report.Cascaded) != 5 {
+			t.Errorf("Expected 5 cascaded documents, got %d: %v", len(report.Cascaded), report.Cascaded)
+		}
+		if _, err := testServices.ArmyListService.GetArmyListByID(ctx, g.armyList.ID.Hex()); err == nil {
+			t.Error("Expected army list to be deleted by cascade")
+		}
+	})
+
+	t.Run("Unreferenced Delete", func(t *testing.T) {
+		rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Unused Rule"))
+		if err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+
+		report, err := testServices.RuleService.DeleteRuleWithPolicy(ctx, rule.ID.Hex(), services.DeletePolicyReject)
+		if err != nil {
+			t.Fatalf("Failed to delete rule: %v", err)
+		}
+		if len(report.Detached) != 0 || len(report.Cascaded) != 0 {
+			t.Errorf("Expected empty report, got %+v", report)
+		}
+	})
+}
+
+func TestDeletePolicyHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	ctx := context.Background()
+
+	handler := handlers.NewRuleHandler(testServices.RuleService)
+	router := mux.NewRouter()
+	router.HandleFunc("/rules/{id}", handler.DeleteRule).Methods("DELETE")
+
+	g := createReferenceGraph(t, ctx)
+	path := "/rules/" + g.rule.ID.Hex()
+
+	t.Run("Reject", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("DELETE", path, nil))
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("Expected status %d, got %d", http.StatusConflict, w.Code)
+		}
+
+		var response struct {
+			References []services.Reference `json:"references"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(response.References) != 4 {
+			t.Errorf("Expected 4 references in response, got %v", response.References)
+		}
+	})
+
+	t.Run("Unknown Policy", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("DELETE", path+"?policy=shred", nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("Detach", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("DELETE", path+"?policy=detach", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var report services.DeleteReport
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if report.Policy != services.DeletePolicyDetach || len(report.Detached) != 4 {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+
+		weapon, err := testServices.WeaponService.GetWeaponByID(ctx, g.weapon.ID.Hex())
+		if err != nil || len(weapon.Rules) != 0 {
+			t.Errorf("Expected rule reference to be removed from weapon, got %v (%v)", weapon, err)
+		}
+	})
+}
@@ -17,7 +17,7 @@ func TestBaseRepository(t *testing.T) {
 	ctx := context.Background()
 
 	// Get a collection for testing
-	collection := testDB.Database.Collection("test_collection")
+	collection := testDB.Store.Collection("test_collection")
 	baseRepo := repositories.NewBaseRepository(collection)
 
 	t.Run("Create Document", func(t *testing.T) {
@@ -182,7 +182,7 @@ func TestRuleRepository(t *testing.T) {
 	defer CleanupTestDB(t)
 	ctx := context.Background()
 
-	collection := testDB.Database.Collection("rules")
+	collection := testDB.Store.Collection("rules")
 	repo := repositories.NewRuleRepository(collection)
 
 	t.Run("Create Rule", func(t *testing.T) {
@@ -364,7 +364,7 @@ func TestWeaponRepository(t *testing.T) {
 	defer CleanupTestDB(t)
 	ctx := context.Background()
 
-	collection := testDB.Database.Collection("weapons")
+	collection := testDB.Store.Collection("weapons")
 	repo := repositories.NewWeaponRepository(collection)
 
 	t.Run("Create Weapon", func(t *testing.T) {
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"grimdank-database/services"
+)
+
+func TestRevisionHistory(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	ctx := context.Background()
+
+	unit := CreateTestUnit()
+	unit.Defense = 4
+	unit, err := testServices.UnitService.CreateUnit(services.WithChange(ctx, "alice", "new unit"), unit)
+	if err != nil {
+		t.Fatalf("Failed to create unit: %v", err)
+	}
+	id := unit.ID.Hex()
+
+	unit.Defense = 3
+	if err := testServices.UnitService.UpdateUnit(services.WithChange(ctx, "bob", "too tough"), id, unit); err != nil {
+		t.Fatalf("Failed to update unit: %v", err)
+	}
+
+	t.Run("Lists revisions newest first", func(t *testing.T) {
+		revisions, err := testServices.RevisionService.ListRevisions(ctx, "units", id, 0, 0)
+		if err != nil {
+			t.Fatalf("Failed to list revisions: %v", err)
+		}
+		if len(revisions) != 2 {
+			t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+		}
+		latest := revisions[0]
+		if latest.Version != 2 || latest.Action != "update" || latest.Actor != "bob" || latest.Reason != "too tough" {
+			t.Errorf("Unexpected latest revision: %+v", latest)
+		}
+		if latest.Timestamp.IsZero() || latest.Document["name"] != "Test Unit" {
+			t.Errorf("Expected the revision to hold a timestamp and the full document, got %+v", latest)
+		}
+		if revisions[1].Action != "create" || revisions[1].Actor != "alice" {
+			t.Errorf("Unexpected first revision: %+v", revisions[1])
+		}
+	})
+
+	t.Run("Diffs two revisions", func(t *testing.T) {
+		diff, err := testServices.RevisionService.Diff(ctx, "units", id, 1, 2)
+		if err != nil {
+			t.Fatalf("Failed to diff revisions: %v", err)
+		}
+		if len(diff.Changes) != 1 || diff.Changes[0].Field != "defense" {
+			t.Fatalf("Expected only defense to change, got %+v", diff.Changes)
+		}
+	})
+
+	t.Run("Reverts to an earlier revision", func(t *testing.T) {
+		revision, err := testServices.RevisionService.Revert(services.WithChange(ctx, "carol", ""), "units", id, 1)
+		if err != nil {
+			t.Fatalf("Failed to revert: %v", err)
+		}
+		if revision.Version != 3 || revision.Action != "revert" || revision.Reason != "revert to version 1" {
+			t.Errorf("Unexpected revert revision: %+v", revision)
+		}
+
+		reverted, err := testServices.UnitService.GetUnitByID(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to get unit: %v", err)
+		}
+		if reverted.Defense != 4 || reverted.Version != 3 {
+			t.Errorf("Expected defense 4 at version 3, got defense %d at version %d", reverted.Defense, reverted.Version)
+		}
+	})
+
+	t.Run("Revert restores a deleted entity", func(t *testing.T) {
+		if err := testServices.UnitService.DeleteUnit(ctx, id); err != nil {
+			t.Fatalf("Failed to delete unit: %v", err)
+		}
+		revisions, err := testServices.RevisionService.ListRevisions(ctx, "units", id, 1, 0)
+		if err != nil {
+			t.Fatalf("Failed to list revisions: %v", err)
+		}
+		if len(revisions) != 1 || revisions[0].Action != "delete" || revisions[0].Actor != services.AnonymousActor {
+			t.Fatalf("Expected an anonymous delete revision, got %+v", revisions)
+		}
+
+		if _, err := testServices.RevisionService.Revert(ctx, "units", id, revisions[0].Version); err == nil {
+			t.Error("Expected reverting to a deletion to fail")
+		}
+		if _, err := testServices.RevisionService.Revert(ctx, "units", id, 2); err != nil {
+			t.Fatalf("Failed to revert: %v", err)
+		}
+		restored, err := testServices.UnitService.GetUnitByID(ctx, id)
+		if err != nil {
+			t.Fatalf("Expected the unit to be restored: %v", err)
+		}
+		if restored.Defense != 3 {
+			t.Errorf("Expected defense 3, got %d", restored.Defense)
+		}
+	})
+
+	t.Run("Unknown entity type", func(t *testing.T) {
+		if _, err := testServices.RevisionService.ListRevisions(ctx, "dice", id, 0, 0); err == nil {
+			t.Error("Expected an unknown entity type to fail")
+		}
+	})
+}
+
+func TestDetachRecordsRevision(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	ctx := context.Background()
+
+	rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRule())
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	unit := CreateTestUnit()
+	unit, err = testServices.UnitService.CreateUnit(ctx, unit)
+	if err != nil {
+		t.Fatalf("Failed to create unit: %v", err)
+	}
+	if _, err := testServices.PopulationService.AddRuleToUnit(ctx, unit.ID.Hex(), rule.ID.Hex(), 1); err != nil {
+		t.Fatalf("Failed to attach rule: %v", err)
+	}
+	if _, err := testServices.RuleService.DeleteRuleWithPolicy(ctx, rule.ID.Hex(), services.DeletePolicyDetach); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+
+	revisions, err := testServices.RevisionService.ListRevisions(ctx, "units", unit.ID.Hex(), 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to list revisions: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("Expected create, attach and detach revisions, got %d", len(revisions))
+	}
+	diff, err := testServices.RevisionService.Diff(ctx, "units", unit.ID.Hex(), revisions[1].Version, revisions[0].Version)
+	if err != nil {
+		t.Fatalf("Failed to diff revisions: %v", err)
+	}
+	if len(diff.Changes) != 1 || diff.Changes[0].Field != "rules" {
+		t.Errorf("Expected only rules to change, got %+v", diff.Changes)
+	}
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRuleEffectsPoints(t *testing.T) {
+	calculator := services.NewPointsCalculator()
+
+	cases := []struct {
+		name    string
+		effects []models.RuleEffect
+		want    []int
+	}{
+		{"Defense", []models.RuleEffect{{Stat: "Defense", Modifier: "+tier"}}, []int{4, 8, 12}},
+		{"Range", []models.RuleEffect{{Stat: "Range", Add: "6*tier"}}, []int{2, 3, 5}},
+		{"Leadership", []models.RuleEffect{{Stat: "Morale", Modifier: "-tier", Aura: true}}, []int{4, 8, 12}},
+		{"Fixed Amount", []models.RuleEffect{{Stat: "AP", Add: "+1"}}, []int{3, 3, 3}},
+		{"Once Per Game", []models.RuleEffect{{Stat: "Attacks", Add: "tier"}, {Trigger: "once_per_game"}}, []int{1, 2, 4}},
+		{"Limited Effect", []models.RuleEffect{{Stat: "Defense", Modifier: "+tier"}, {Stat: "Attacks", Add: "tier", Trigger: "once_per_game"}}, []int{5, 10, 16}},
+		{"Unknown Trigger", []models.RuleEffect{{Stat: "Defense", Modifier: "+tier"}, {Trigger: "when_charging"}}, []int{3, 6, 8}},
+		{"Only A Trigger", []models.RuleEffect{{Trigger: "once_per_turn"}}, []int{1, 1, 1}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			points := calculator.CalculateRulePoints(&models.Rule{Name: tc.name, Effects: tc.effects})
+			if !reflect.DeepEqual(points, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, points)
+			}
+		})
+	}
+
+	t.Run("Wording Doesn't Matter", func(t *testing.T) {
+		effects := []models.RuleEffect{{Stat: "Defense", Modifier: "+tier"}}
+		plain := calculator.CalculateRulePoints(&models.Rule{Name: "Armoured", Description: "Add the tier to Defense rolls.", Effects: effects})
+		wordy := calculator.CalculateRulePoints(&models.Rule{Name: "Armoured", Description: "If fired upon, when in cover or if charged, this invulnerable unit adds the tier to Defense rolls.", Effects: effects})
+		if !reflect.DeepEqual(plain, wordy) {
+			t.Errorf("Expected the same points whatever the wording, got %v and %v", plain, wordy)
+		}
+	})
+
+	t.Run("Text Fallback", func(t *testing.T) {
+		rule := &models.Rule{Name: "Rending", Description: "Once per game, re-roll failed wounds."}
+		want := calculator.CalculatePointsFromDescription(rule.Name, rule.Description)
+		if points := calculator.CalculateRulePoints(rule); !reflect.DeepEqual(points, want) {
+			t.Errorf("Expected rules without effects to be priced from their text (%v), got %v", want, points)
+		}
+	})
+
+	t.Run("Season Weights", func(t *testing.T) {
+		config := services.DefaultPointsCalculatorConfig()
+		config.EffectWeights = map[string]float64{"defense": 10}
+		config.TriggerMultipliers = nil
+		config.AuraMultiplier = 0
+		seasonal := services.NewPointsCalculatorWithConfig(config)
+
+		rule := &models.Rule{Name: "Bulwark", Effects: []models.RuleEffect{
+			{Stat: "Defense", Modifier: "+tier"},
+			{Stat: "Morale", Modifier: "-1", Aura: true, Trigger: "once_per_turn"},
+		}}
+		// Morale, the aura and the trigger fall back to the built-in values: 2 * 2 * 0.7
+		if points := seasonal.CalculateRulePoints(rule); !reflect.DeepEqual(points, []int{13, 23, 33}) {
+			t.Errorf("Expected [13 23 33], got %v", points)
+		}
+	})
+}
+
+func TestRuleEffectsValidation(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+
+	invalid := []struct {
+		name   string
+		effect models.RuleEffect
+	}{
+		{"Empty", models.RuleEffect{}},
+		{"Unknown Stat", models.RuleEffect{Stat: "Toughness", Modifier: "+tier"}},
+		{"No Amount", models.RuleEffect{Stat: "Defense"}},
+		{"Modifier And Add", models.RuleEffect{Stat: "Range", Modifier: "+1", Add: "6*tier"}},
+		{"Bad Amount", models.RuleEffect{Stat: "Range", Add: "6+tier"}},
+		{"Bad Trigger", models.RuleEffect{Trigger: "Once per game"}},
+		{"Aura Without Stat", models.RuleEffect{Trigger: "once_per_game", Aura: true}},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			rule := &models.Rule{Name: "Broken", Effects: []models.RuleEffect{tc.effect}}
+			if _, err := testServices.RuleService.CreateRule(ctx, rule); !utils.IsValidationError(err) {
+				t.Errorf("Expected a validation error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("Stored", func(t *testing.T) {
+		rule := &models.Rule{Name: "Defense", Effects: []models.RuleEffect{{Stat: "Defense", Modifier: "+tier"}}}
+		created, err := testServices.RuleService.CreateRule(ctx, rule)
+		if err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+		stored, err := testRepos.RuleRepo.GetRuleByID(ctx, created.ID.Hex())
+		if err != nil || !reflect.DeepEqual(stored.Effects, rule.Effects) {
+			t.Fatalf("Expected the effects to be stored, got %+v, %v", stored, err)
+		}
+
+		// Removing the effects goes back to pricing the text
+		stored.Effects = nil
+		if err := testServices.RuleService.UpdateRule(ctx, stored.ID.Hex(), stored); err != nil {
+			t.Fatalf("Failed to update rule: %v", err)
+		}
+		stored, err = testRepos.RuleRepo.GetRuleByID(ctx, created.ID.Hex())
+		if err != nil || len(stored.Effects) != 0 {
+			t.Errorf("Expected the effects to be removed, got %+v, %v", stored, err)
+		}
+	})
+
+	t.Run("Season Settings", func(t *testing.T) {
+		season := testSeason("effects")
+		season.Rules.EffectWeights = map[string]float64{"defense": -1}
+		if err := services.ValidatePointsSeason(season); !utils.IsValidationError(err) {
+			t.Errorf("Expected negative weights to be rejected, got %v", err)
+		}
+		season.Rules.EffectWeights = map[string]float64{"Toughness": 1}
+		if err := services.ValidatePointsSeason(season); !utils.IsValidationError(err) {
+			t.Errorf("Expected unknown stats to be rejected, got %v", err)
+		}
+	})
+}
+
+func TestRuleEffectsHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	handler := handlers.NewPointsHandler(services.NewRulePointsService(testServices.RuleService))
+	router := mux.NewRouter()
+	router.HandleFunc("/points/calculate", handler.CalculatePoints).Methods("POST")
+
+	cases := []struct {
+		name     string
+		body     string
+		want     int
+		contains string
+	}{
+		{"Effects", `{"name": "Defense", "effects": [{"stat": "Defense", "modifier": "+tier"}]}`, http.StatusOK, `"calculated_points":[4,8,12]`},
+		{"Text", `{"name": "Rending", "description": "Re-roll failed wounds."}`, http.StatusOK, `"source":"text"`},
+		{"Invalid Effect", `{"name": "Defense", "effects": [{"stat": "Defense"}]}`, http.StatusBadRequest, "modifier"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/points/calculate", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want || !strings.Contains(w.Body.String(), tc.contains) {
+				t.Errorf("Expected status %d with %s, got %d: %s", tc.want, tc.contains, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/services"
+)
+
+// syncTestIndexes creates the declared indexes, which text search and unique
+// names need
+func syncTestIndexes(t *testing.T) {
+	repos := map[string]repositories.IndexedRepository{
+		"rules":      testRepos.RuleRepo,
+		"weapons":    testRepos.WeaponRepo,
+		"wargear":    testRepos.WarGearRepo,
+		"units":      testRepos.UnitRepo,
+		"armybooks":  testRepos.ArmyBookRepo,
+		"armylists":  testRepos.ArmyListRepo,
+		"factions":   testRepos.FactionRepo,
+		"workspaces": testRepos.WorkspaceRepo,
+	}
+	if _, err := repositories.ReconcileIndexes(context.Background(), repos, true); err != nil {
+		t.Fatalf("Failed to sync indexes: %v", err)
+	}
+}
+
+func newTestSearchService() *services.SearchService {
+	return services.NewSearchService(
+		testRepos.RuleRepo,
+		testRepos.WeaponRepo,
+		testRepos.WarGearRepo,
+		testRepos.UnitRepo,
+		testRepos.ArmyBookRepo,
+		testRepos.FactionRepo,
+	)
+}
+
+func TestSearch(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	syncTestIndexes(t)
+
+	ctx := context.Background()
+	search := newTestSearchService()
+
+	rending, err := testServices.RuleService.CreateRule(ctx, &models.Rule{
+		Name:        "Rending",
+		Description: "Hit rolls of 6 ignore armour.",
+		Points:      []int{5},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	if _, err := testServices.RuleService.CreateRule(ctx, &models.Rule{
+		Name:        "Shred",
+		Description: "Like <b>rending</b>, but only against vehicles.",
+		Points:      []int{3},
+	}); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	weapon := CreateTestWeapon()
+	weapon.Name = "Rending Claws"
+	if _, err := testServices.WeaponService.CreateWeapon(ctx, weapon); err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+	if _, err := testServices.FactionService.CreateFaction(ctx, CreateTestFaction()); err != nil {
+		t.Fatalf("Failed to create faction: %v", err)
+	}
+
+	t.Run("Ranked Typed Hits", func(t *testing.T) {
+		results, err := search.Search(ctx, "rends", nil, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results.Hits) != 3 {
+			t.Fatalf("Expected 3 hits, got %+v", results.Hits)
+		}
+
+		top := results.Hits[0]
+		if top.Type != "rules" || top.ID != rending.ID.Hex() || top.Highlights["name"] != "<mark>Rending</mark>" {
+			t.Errorf("Expected the Rending rule first, got %+v", top)
+		}
+		if results.Hits[1].Type != "weapons" {
+			t.Errorf("Expected a name match to outrank a description match, got %+v", results.Hits)
+		}
+
+		shred := results.Hits[2]
+		if _, ok := shred.Highlights["name"]; ok {
+			t.Errorf("Expected no name highlight for Shred, got %+v", shred.Highlights)
+		}
+		want := "Like &lt;b&gt;<mark>rending</mark>&lt;/b&gt;, but only against vehicles."
+		if shred.Highlights["description"] != want {
+			t.Errorf("Expected escaped description snippet %q, got %q", want, shred.Highlights["description"])
+		}
+	})
+
+	t.Run("Narrowed By Type", func(t *testing.T) {
+		results, err := search.Search(ctx, "rending", []string{"weapons"}, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results.Hits) != 1 || results.Hits[0].Name != "Rending Claws" {
+			t.Errorf("Expected only the weapon, got %+v", results.Hits)
+		}
+	})
+
+	t.Run("Trashed Entities Are Hidden", func(t *testing.T) {
+		if err := testServices.RuleService.DeleteRule(ctx, rending.ID.Hex()); err != nil {
+			t.Fatalf("Failed to delete rule: %v", err)
+		}
+		results, err := search.Search(ctx, "rending", []string{"rules"}, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results.Hits) != 1 || results.Hits[0].Name != "Shred" {
+			t.Errorf("Expected only Shred, got %+v", results.Hits)
+		}
+	})
+}
+
+func TestSearchSnippet(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	syncTestIndexes(t)
+
+	ctx := context.Background()
+	wargear := CreateTestWarGear()
+	wargear.Description = strings.Repeat("Filler words pad out this description. ", 10) +
+		"The bearer has a storm shield. " + strings.Repeat("More filler follows it. ", 10)
+	if _, err := testServices.WarGearService.CreateWarGear(ctx, wargear); err != nil {
+		t.Fatalf("Failed to create wargear: %v", err)
+	}
+
+	results, err := newTestSearchService().Search(ctx, "storm", nil, 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results.Hits) != 1 {
+		t.Fatalf("Expected 1 hit, got %+v", results.Hits)
+	}
+
+	snippet := results.Hits[0].Highlights["description"]
+	if !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") || !strings.Contains(snippet, "<mark>storm</mark>") {
+		t.Errorf("Expected a trimmed snippet around the match, got %q", snippet)
+	}
+	if len(snippet) > 200 {
+		t.Errorf("Expected a short snippet, got %d bytes", len(snippet))
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	syncTestIndexes(t)
+
+	ctx := context.Background()
+	handler := handlers.NewSearchHandler(newTestSearchService())
+
+	if _, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Deep Strike")); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		query string
+		want  int
+		hits  int
+	}{
+		{"Match", "?q=strike", http.StatusOK, 1},
+		{"No Match", "?q=teleport", http.StatusOK, 0},
+		{"Missing Query", "", http.StatusBadRequest, 0},
+		{"Unknown Type", "?q=strike&types=spaceships", http.StatusBadRequest, 0},
+		{"Invalid Limit", "?q=strike&limit=lots", http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/search"+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.Search(w, req)
+
+			if w.Code != tc.want {
+				t.Fatalf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var results services.SearchResults
+			if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(results.Hits) != tc.hits {
+				t.Errorf("Expected %d hits, got %+v", tc.hits, results.Hits)
+			}
+		})
+	}
+}
+
+func TestSearchByNameIsLiteral(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	for _, name := range []string{"+1 Strength (Melee)", "Strength Bonus"} {
+		if _, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName(name)); err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+	}
+
+	rules, err := testServices.RuleService.SearchRulesByName(ctx, "+1 strength (", 0, 0)
+	if err != nil {
+		t.Fatalf("Expected metacharacters to be matched literally, got %v", err)
+	}
+	if len(rules) != 1 || rules[0].Name != "+1 Strength (Melee)" {
+		t.Errorf("Expected only the +1 Strength rule, got %v", rules)
+	}
+
+	rules, err = testServices.RuleService.SearchRulesByName(ctx, ".*", 0, 0)
+	if err != nil || len(rules) != 0 {
+		t.Errorf("Expected .* to match no names, got %v (%v)", rules, err)
+	}
+}
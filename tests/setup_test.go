@@ -8,30 +8,36 @@ import (
 	"time"
 
 	"grimdank-database/database"
+	"grimdank-database/events"
 	"grimdank-database/models"
 	"grimdank-database/repositories"
 	"grimdank-database/services"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// TestDB represents the test database connection
+// TestDB represents the test storage backend. Client is nil when the
+// in-memory backend is used.
 type TestDB struct {
 	Client      *mongo.Client
-	Database    *mongo.Database
-	Collections map[string]*mongo.Collection
+	Store       repositories.Store
+	Collections map[string]repositories.Collection
 }
 
 // TestRepositories holds all repository instances for testing
 type TestRepositories struct {
-	RuleRepo     *repositories.RuleRepository
-	WeaponRepo   *repositories.WeaponRepository
-	WarGearRepo  *repositories.WarGearRepository
-	UnitRepo     *repositories.UnitRepository
-	ArmyBookRepo *repositories.ArmyBookRepository
-	ArmyListRepo *repositories.ArmyListRepository
-	FactionRepo  *repositories.FactionRepository
+	RuleRepo      *repositories.RuleRepository
+	WeaponRepo    *repositories.WeaponRepository
+	WarGearRepo   *repositories.WarGearRepository
+	UnitRepo      *repositories.UnitRepository
+	ArmyBookRepo  *repositories.ArmyBookRepository
+	ArmyListRepo  *repositories.ArmyListRepository
+	FactionRepo   *repositories.FactionRepository
+	RevisionRepo  *repositories.RevisionRepository
+	WorkspaceRepo *repositories.WorkspaceRepository
+	PurgeLogRepo  *repositories.PurgeLogRepository
 }
 
 // TestServices holds all service instances for testing
@@ -44,6 +50,10 @@ type TestServices struct {
 	ArmyListService   *services.ArmyListService
 	FactionService    *services.FactionService
 	PopulationService *services.PopulationService
+	ReferenceService  *services.ReferenceService
+	RevisionService   *services.RevisionService
+	WorkspaceService  *services.WorkspaceService
+	EventBus          *events.Bus
 }
 
 var (
@@ -51,14 +61,24 @@ var (
 	testRepos           *TestRepositories
 	testServices        *TestServices
 	testCollectionNames = []string{
-		"rules", "weapons", "wargear", "units", "armybooks", "armylists", "factions",
+		"rules", "weapons", "wargear", "units", "armybooks", "armylists", "factions", "revisions", "workspaces", "assets", "pointsseasons", "purges",
 	}
 	// Track created entities for cleanup
 	createdEntities = make(map[string][]string) // collection -> []entityIDs
 )
 
-// SetupTestDB initializes the test database connection
+// SetupTestDB initializes the test storage backend. Tests run against the
+// in-memory backend unless STORAGE_BACKEND=mongo is set.
 func SetupTestDB(t *testing.T) *TestDB {
+	if os.Getenv("STORAGE_BACKEND") != "mongo" {
+		store := repositories.NewMemoryStore()
+		testDB = &TestDB{
+			Store:       store,
+			Collections: newTestCollections(store),
+		}
+		return testDB
+	}
+
 	// Use the same MONGODB_URI environment variable as the main application
 	mongoURI := os.Getenv("MONGODB_URI")
 	if mongoURI == "" {
@@ -74,35 +94,45 @@ func SetupTestDB(t *testing.T) *TestDB {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
-	// Create collections map
-	collections := make(map[string]*mongo.Collection)
-	for _, name := range testCollectionNames {
-		collections[name] = db.Database.Collection(name)
-	}
-
+	store := repositories.NewMongoStore(db.Database)
 	testDB = &TestDB{
 		Client:      db.Client,
-		Database:    db.Database,
-		Collections: collections,
+		Store:       store,
+		Collections: newTestCollections(store),
 	}
 
 	return testDB
 }
 
+// newTestCollections builds the collections map for the given store
+func newTestCollections(store repositories.Store) map[string]repositories.Collection {
+	collections := make(map[string]repositories.Collection)
+	for _, name := range testCollectionNames {
+		collections[name] = store.Collection(name)
+	}
+	return collections
+}
+
 // SetupTestRepositories initializes all repository instances
 func SetupTestRepositories(t *testing.T) *TestRepositories {
 	if testDB == nil {
 		SetupTestDB(t)
 	}
 
+	// Entity repositories are scoped to workspaces as they are in the server,
+	// while testDB.Collections see every document
+	scoped := repositories.NewWorkspaceStore(testDB.Store)
 	testRepos = &TestRepositories{
-		RuleRepo:     repositories.NewRuleRepository(testDB.Collections["rules"]),
-		WeaponRepo:   repositories.NewWeaponRepository(testDB.Collections["weapons"]),
-		WarGearRepo:  repositories.NewWarGearRepository(testDB.Collections["wargear"]),
-		UnitRepo:     repositories.NewUnitRepository(testDB.Collections["units"]),
-		ArmyBookRepo: repositories.NewArmyBookRepository(testDB.Collections["armybooks"]),
-		ArmyListRepo: repositories.NewArmyListRepository(testDB.Collections["armylists"]),
-		FactionRepo:  repositories.NewFactionRepository(testDB.Collections["factions"]),
+		RuleRepo:      repositories.NewRuleRepository(scoped.Collection("rules")),
+		WeaponRepo:    repositories.NewWeaponRepository(scoped.Collection("weapons")),
+		WarGearRepo:   repositories.NewWarGearRepository(scoped.Collection("wargear")),
+		UnitRepo:      repositories.NewUnitRepository(scoped.Collection("units")),
+		ArmyBookRepo:  repositories.NewArmyBookRepository(scoped.Collection("armybooks")),
+		ArmyListRepo:  repositories.NewArmyListRepository(scoped.Collection("armylists")),
+		FactionRepo:   repositories.NewFactionRepository(scoped.Collection("factions")),
+		RevisionRepo:  repositories.NewRevisionRepository(scoped.Collection("revisions")),
+		WorkspaceRepo: repositories.NewWorkspaceRepository(testDB.Collections["workspaces"]),
+		PurgeLogRepo:  repositories.NewPurgeLogRepository(testDB.Collections["purges"]),
 	}
 
 	return testRepos
@@ -117,20 +147,58 @@ func SetupTestServices(t *testing.T) *TestServices {
 	// Clean up any existing data before starting tests
 	CleanupTestDB(t)
 
+	bus := events.NewBus()
+	revisions := services.NewRevisionService(
+		testRepos.RevisionRepo,
+		testRepos.RuleRepo,
+		testRepos.WeaponRepo,
+		testRepos.WarGearRepo,
+		testRepos.UnitRepo,
+		testRepos.ArmyBookRepo,
+		testRepos.ArmyListRepo,
+		testRepos.FactionRepo,
+		services.SequentialUnitOfWork{},
+		bus,
+	)
+
+	references := services.NewReferenceService(
+		testRepos.RuleRepo,
+		testRepos.WeaponRepo,
+		testRepos.WarGearRepo,
+		testRepos.UnitRepo,
+		testRepos.ArmyBookRepo,
+		testRepos.ArmyListRepo,
+		revisions,
+		services.SequentialUnitOfWork{},
+	)
+
 	testServices = &TestServices{
-		RuleService:     services.NewRuleService(testRepos.RuleRepo),
-		WeaponService:   services.NewWeaponService(testRepos.WeaponRepo),
-		WarGearService:  services.NewWarGearService(testRepos.WarGearRepo),
-		UnitService:     services.NewUnitService(testRepos.UnitRepo),
-		ArmyBookService: services.NewArmyBookService(testRepos.ArmyBookRepo),
-		ArmyListService: services.NewArmyListService(testRepos.ArmyListRepo),
-		FactionService:  services.NewFactionService(testRepos.FactionRepo),
+		RuleService:     services.NewRuleService(testRepos.RuleRepo, references, revisions),
+		WeaponService:   services.NewWeaponService(testRepos.WeaponRepo, references, revisions),
+		WarGearService:  services.NewWarGearService(testRepos.WarGearRepo, references, revisions),
+		UnitService:     services.NewUnitService(testRepos.UnitRepo, references, revisions),
+		ArmyBookService: services.NewArmyBookService(testRepos.ArmyBookRepo, revisions),
+		ArmyListService: services.NewArmyListService(testRepos.ArmyListRepo, revisions),
+		FactionService:  services.NewFactionService(testRepos.FactionRepo, revisions),
 		PopulationService: services.NewPopulationService(
-			services.NewRuleService(testRepos.RuleRepo),
-			services.NewWeaponService(testRepos.WeaponRepo),
-			services.NewWarGearService(testRepos.WarGearRepo),
-			services.NewUnitService(testRepos.UnitRepo),
+			services.NewRuleService(testRepos.RuleRepo, references, revisions),
+			services.NewWeaponService(testRepos.WeaponRepo, references, revisions),
+			services.NewWarGearService(testRepos.WarGearRepo, references, revisions),
+			services.NewUnitService(testRepos.UnitRepo, references, revisions),
 		),
+		ReferenceService: references,
+		RevisionService:  revisions,
+		WorkspaceService: services.NewWorkspaceService(
+			testRepos.WorkspaceRepo,
+			testRepos.RuleRepo,
+			testRepos.WeaponRepo,
+			testRepos.WarGearRepo,
+			testRepos.UnitRepo,
+			testRepos.ArmyBookRepo,
+			testRepos.ArmyListRepo,
+			testRepos.FactionRepo,
+		),
+		EventBus: bus,
 	}
 
 	return testServices
@@ -174,7 +242,7 @@ func cleanupCreatedEntities(t *testing.T, ctx context.Context) {
 
 		// Delete all tracked entities
 		for _, entityID := range entityIDs {
-			_, err := collection.DeleteOne(ctx, map[string]interface{}{"_id": entityID})
+			_, err := collection.DeleteOne(ctx, bson.M{"_id": entityID})
 			if err != nil {
 				t.Logf("Warning: Failed to delete entity %s from %s: %v", entityID, collectionName, err)
 			}
@@ -217,15 +285,11 @@ func EnsureTestDatabaseIsolation(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Drop the entire test database to ensure complete isolation
-	err := testDB.Database.Drop(ctx)
-	if err != nil {
-		t.Logf("Warning: Failed to drop test database: %v", err)
-	}
-
-	// Recreate collections
+	// Drop every test collection to ensure complete isolation
 	for _, name := range testCollectionNames {
-		testDB.Collections[name] = testDB.Database.Collection(name)
+		if err := testDB.Collections[name].Drop(ctx); err != nil {
+			t.Logf("Warning: Failed to drop collection %s: %v", name, err)
+		}
 	}
 
 	// Clear tracking
@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/migrations"
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestSnapshotService(store repositories.Store) *services.SnapshotService {
+	return services.NewSnapshotService(
+		repositories.NewWorkspaceRepository(store.Collection("workspaces")),
+		repositories.NewRuleRepository(store.Collection("rules")),
+		repositories.NewWeaponRepository(store.Collection("weapons")),
+		repositories.NewWarGearRepository(store.Collection("wargear")),
+		repositories.NewUnitRepository(store.Collection("units")),
+		repositories.NewArmyBookRepository(store.Collection("armybooks")),
+		repositories.NewArmyListRepository(store.Collection("armylists")),
+		repositories.NewFactionRepository(store.Collection("factions")),
+		repositories.NewPurgeLogRepository(store.Collection("purges")),
+		services.SequentialUnitOfWork{},
+		migrations.Latest(),
+	)
+}
+
+// rewriteArchive rebuilds an archive with edit applied to every file's content
+func rewriteArchive(t *testing.T, archive []byte, edit func(name string, content []byte) []byte) []byte {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	reader := tar.NewReader(gz)
+
+	var out bytes.Buffer
+	outGz := gzip.NewWriter(&out)
+	writer := tar.NewWriter(outGz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		content, _ := io.ReadAll(reader)
+		content = edit(header.Name, content)
+		header.Size = int64(len(content))
+		writer.WriteHeader(header)
+		writer.Write(content)
+	}
+	writer.Close()
+	outGz.Close()
+	return out.Bytes()
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	snapshots := newTestSnapshotService(testDB.Store)
+
+	rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Snapshot Rule"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	trashed, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Snapshot Trashed"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	if err := testServices.RuleService.DeleteRule(ctx, trashed.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+	weapon := CreateTestWeapon()
+	weapon.Rules = []models.RuleReference{{RuleID: rule.ID, Tier: 1}}
+	weapon, err = testServices.WeaponService.CreateWeapon(ctx, weapon)
+	if err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+	// Read it back for the stored timestamps
+	weapon, _ = testServices.WeaponService.GetWeaponByID(ctx, weapon.ID.Hex())
+
+	var archive bytes.Buffer
+	manifest, err := snapshots.Export(ctx, &archive)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if len(manifest.Collections) != 8 || manifest.SchemaVersion != migrations.Latest() {
+		t.Fatalf("Expected eight collections at the latest schema version, got %+v", manifest)
+	}
+	for _, collection := range manifest.Collections {
+		want := map[string]int{"rules": 2, "weapons": 1}[collection.Name]
+		if collection.Count != want || len(collection.SHA256) != 64 {
+			t.Errorf("Expected %d %s with a checksum, got %+v", want, collection.Name, collection)
+		}
+	}
+
+	t.Run("Replace", func(t *testing.T) {
+		target := repositories.NewMemoryStore()
+		stale := repositories.NewRuleRepository(target.Collection("rules"))
+		if _, err := stale.CreateRule(ctx, CreateTestRuleWithName("Stale")); err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+
+		report, err := newTestSnapshotService(target).Restore(ctx, bytes.NewReader(archive.Bytes()), services.RestoreReplace, false)
+		if err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+		if rules := report.Collections[2]; rules.Name != "rules" || rules.Inserted != 2 || rules.Removed != 1 {
+			t.Errorf("Expected the stale rule replaced by both archived ones, got %+v", rules)
+		}
+
+		restored := repositories.NewWeaponRepository(target.Collection("weapons"))
+		copied, err := restored.GetWeaponByID(ctx, weapon.ID.Hex())
+		if err != nil {
+			t.Fatalf("Expected the weapon restored under its own ID: %v", err)
+		}
+		if copied.Version != weapon.Version || !copied.CreatedAt.Equal(weapon.CreatedAt) || copied.Rules[0].RuleID != rule.ID {
+			t.Errorf("Expected the weapon restored exactly, got %+v", copied)
+		}
+		if deleted, _ := stale.CountDeleted(ctx); deleted != 1 {
+			t.Errorf("Expected the trashed rule to stay in the trash, got %d trashed", deleted)
+		}
+		if purged, _ := repositories.NewPurgeLogRepository(target.Collection("purges")).LastPurge(ctx); purged.IsZero() {
+			t.Error("Expected replacing documents to be logged as a purge for sync")
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		target := repositories.NewMemoryStore()
+		targetRules := repositories.NewRuleRepository(target.Collection("rules"))
+		sameName := CreateTestRuleWithName("SNAPSHOT RULE")
+		existingID, err := targetRules.CreateRule(ctx, sameName)
+		if err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+
+		restorer := newTestSnapshotService(target)
+		preview, err := restorer.Restore(ctx, bytes.NewReader(archive.Bytes()), services.RestoreMerge, true)
+		if err != nil {
+			t.Fatalf("Failed to preview restore: %v", err)
+		}
+		if rules := preview.Collections[2]; rules.Inserted != 1 || len(rules.Remapped) != 1 || rules.Remapped[0].From != rule.ID {
+			t.Fatalf("Expected the rule with a taken name to be remapped, got %+v", rules)
+		}
+		if count, _ := targetRules.CountStored(ctx); count != 1 {
+			t.Fatalf("Expected a dry run to write nothing, got %d rules", count)
+		}
+
+		if _, err := restorer.Restore(ctx, bytes.NewReader(archive.Bytes()), services.RestoreMerge, false); err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+		merged, err := repositories.NewWeaponRepository(target.Collection("weapons")).GetWeaponByID(ctx, weapon.ID.Hex())
+		if err != nil {
+			t.Fatalf("Expected the weapon to be added: %v", err)
+		}
+		if merged.Rules[0].RuleID.Hex() != existingID {
+			t.Errorf("Expected the weapon's rule remapped to %s, got %s", existingID, merged.Rules[0].RuleID.Hex())
+		}
+
+		again, err := restorer.Restore(ctx, bytes.NewReader(archive.Bytes()), services.RestoreMerge, false)
+		if err != nil {
+			t.Fatalf("Failed to restore again: %v", err)
+		}
+		for _, collection := range again.Collections {
+			if collection.Inserted != 0 {
+				t.Errorf("Expected a second merge to add nothing, got %+v", collection)
+			}
+		}
+	})
+
+	t.Run("Rejected Archives", func(t *testing.T) {
+		cases := map[string][]byte{
+			"Not An Archive": []byte("not gzip"),
+			"Wrong Checksum": rewriteArchive(t, archive.Bytes(), func(name string, content []byte) []byte {
+				if name == "rules.jsonl" {
+					return bytes.Replace(content, []byte("Snapshot Rule"), []byte("Snapshot Fule"), 1)
+				}
+				return content
+			}),
+			"Wrong Schema Version": rewriteArchive(t, archive.Bytes(), func(name string, content []byte) []byte {
+				if name == "manifest.json" {
+					return bytes.Replace(content, []byte(`"schemaVersion": `), []byte(`"schemaVersion": 9`), 1)
+				}
+				return content
+			}),
+			"Missing Collection": rewriteArchive(t, archive.Bytes(), func(name string, content []byte) []byte {
+				if name == "manifest.json" {
+					return bytes.Replace(content, []byte(`"name": "units"`), []byte(`"name": "vehicles"`), 1)
+				}
+				return content
+			}),
+		}
+
+		target := repositories.NewMemoryStore()
+		for name, corrupt := range cases {
+			t.Run(name, func(t *testing.T) {
+				_, err := newTestSnapshotService(target).Restore(ctx, bytes.NewReader(corrupt), services.RestoreReplace, false)
+				if !utils.IsValidationError(err) {
+					t.Errorf("Expected the archive to be rejected, got %v", err)
+				}
+			})
+		}
+		if count, _ := repositories.NewRuleRepository(target.Collection("rules")).CountStored(ctx); count != 0 {
+			t.Errorf("Expected rejected archives to write nothing, got %d rules", count)
+		}
+	})
+}
+
+func TestSnapshotHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	handler := handlers.NewSnapshotHandler(newTestSnapshotService(testDB.Store), nil)
+	router := mux.NewRouter()
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(handlers.RequireAdminToken("secret"))
+	admin.HandleFunc("/snapshot", handler.ExportSnapshot).Methods("GET")
+	admin.HandleFunc("/snapshot/verify", handler.VerifySnapshot).Methods("POST")
+	admin.HandleFunc("/snapshot/restore", handler.RestoreSnapshot).Methods("POST")
+
+	if _, err := testServices.RuleService.CreateRule(context.Background(), CreateTestRuleWithName("Snapshot Handler Rule")); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/snapshot", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("Expected an archive, got %d: %s", w.Code, w.Body.String())
+	}
+	archive := w.Body.Bytes()
+
+	cases := []struct {
+		name  string
+		path  string
+		token string
+		body  []byte
+		want  int
+	}{
+		{"No Token", "/admin/snapshot", "", nil, http.StatusUnauthorized},
+		{"Wrong Token", "/admin/snapshot/verify", "guess", archive, http.StatusUnauthorized},
+		{"Verify", "/admin/snapshot/verify", "secret", archive, http.StatusOK},
+		{"Verify Garbage", "/admin/snapshot/verify", "secret", []byte("garbage"), http.StatusBadRequest},
+		{"Dry Run Replace", "/admin/snapshot/restore?mode=replace&dryRun=true", "secret", archive, http.StatusOK},
+		{"Merge", "/admin/snapshot/restore", "secret", archive, http.StatusOK},
+		{"Unknown Mode", "/admin/snapshot/restore?mode=overwrite", "secret", archive, http.StatusBadRequest},
+		{"Invalid Dry Run", "/admin/snapshot/restore?dryRun=maybe", "secret", archive, http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := "POST"
+			if tc.body == nil {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, tc.path, bytes.NewReader(tc.body))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Errorf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
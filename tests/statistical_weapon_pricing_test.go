@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// statisticalConfig returns the built-in weapon settings with statistical pricing
+func statisticalConfig() *models.WeaponPointsConfig {
+	config := services.DefaultWeaponPointsConfig()
+	config.Pricing = services.WeaponPricingStatistical
+	return config
+}
+
+func TestExpectedWounds(t *testing.T) {
+	calculator := services.NewWeaponPointsCalculatorWithConfig(statisticalConfig())
+	apRule := func(tier int, trigger string) services.WeaponRuleEffects {
+		return services.WeaponRuleEffects{Tier: tier, Effects: []models.RuleEffect{{Stat: "AP", Add: "tier", Trigger: trigger}}}
+	}
+	attacksRule := services.WeaponRuleEffects{Tier: 1, Effects: []models.RuleEffect{{Stat: "Attacks", Add: "tier"}}}
+
+	// Against the built-in Defense 4+ to 7+ spread, weighted 1/2/2/1, a hit
+	// from an AP 1 weapon needs 5+, 6+, 7+ or 8+ to save
+	cases := []struct {
+		name        string
+		stats       services.WeaponStats
+		hitChance   float64
+		woundChance float64
+		points      int
+	}{
+		{"Rifle", services.WeaponStats{Type: "ranged", Range: 24, Attacks: "2", AP: "1"}, 0.6, 0.55, 4},
+		{"Marksman", services.WeaponStats{Type: "ranged", Range: 24, Attacks: "2", AP: "1", Skill: 1}, 0.9, 0.55, 6},
+		{"Ones Always Fail", services.WeaponStats{Type: "ranged", Range: 24, Attacks: "2", AP: "1", Skill: -3}, 0.9, 0.55, 6},
+		{"Tens Always Save", services.WeaponStats{Type: "ranged", Range: 0, Attacks: "1", AP: "10"}, 0.6, 0.9, 3},
+		{"AP And Attacks Rules", services.WeaponStats{Type: "ranged", Range: 24, Attacks: "2", AP: "1", Rules: []services.WeaponRuleEffects{apRule(2, ""), attacksRule}}, 0.6, 0.75, 8},
+		{"Triggered Rule", services.WeaponStats{Type: "ranged", Range: 24, Attacks: "2", AP: "1", Rules: []services.WeaponRuleEffects{apRule(2, "once_per_game")}}, 0.6, 0.55, 4},
+		{"Melee", services.WeaponStats{Type: "melee", Range: 24, Attacks: "2", AP: "1"}, 0.6, 0.55, 3},
+		{"Melee Accuracy", services.WeaponStats{Type: "melee", Attacks: "2", AP: "1", Rules: []services.WeaponRuleEffects{{Tier: 2, Effects: []models.RuleEffect{{Stat: "Melee", Modifier: "-tier"}}}}}, 0.8, 0.55, 4},
+		{"Wrong Accuracy", services.WeaponStats{Type: "melee", Attacks: "2", AP: "1", Rules: []services.WeaponRuleEffects{{Tier: 2, Effects: []models.RuleEffect{{Stat: "Ranged", Modifier: "-tier"}}}}}, 0.6, 0.55, 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expected := calculator.CalculateExpectedWounds(tc.stats)
+			if math.Abs(expected.HitChance-tc.hitChance) > 1e-9 || math.Abs(expected.WoundChance-tc.woundChance) > 1e-9 {
+				t.Errorf("Expected to hit %.2f and wound %.2f, got %.2f and %.2f", tc.hitChance, tc.woundChance, expected.HitChance, expected.WoundChance)
+			}
+			if expected.Points != tc.points {
+				t.Errorf("Expected %d points, got %d (%+v)", tc.points, expected.Points, expected)
+			}
+			if points := calculator.CalculateWeaponPoints(tc.stats); points != tc.points {
+				t.Errorf("Expected statistical pricing to give %d points, got %d", tc.points, points)
+			}
+		})
+	}
+
+	t.Run("Breakdown", func(t *testing.T) {
+		stats := services.WeaponStats{Type: "ranged", Range: 24, Attacks: "2", AP: "1"}
+		legacy := services.NewWeaponPointsCalculator()
+		breakdown := calculator.GetWeaponStatsBreakdown(stats)
+		if breakdown["pricing"] != services.WeaponPricingStatistical || breakdown["calculatedPoints"] != 4 || breakdown["statisticalPoints"] != 4 {
+			t.Errorf("Expected the statistical result to be used, got %+v", breakdown)
+		}
+		if breakdown["legacyPoints"] != legacy.CalculateWeaponPoints(stats) {
+			t.Errorf("Expected the legacy result alongside, got %v", breakdown["legacyPoints"])
+		}
+		if legacy.GetWeaponStatsBreakdown(stats)["pricing"] != services.WeaponPricingLegacy {
+			t.Error("Expected the built-in season to keep legacy pricing")
+		}
+	})
+}
+
+func TestStatisticalUnitPoints(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	seasons := newTestSeasonService(t)
+	season := testSeason("statistical")
+	season.Weapons = *statisticalConfig()
+	if _, err := seasons.CreateSeason(ctx, season); err != nil {
+		t.Fatalf("Failed to create season: %v", err)
+	}
+	unitPoints := services.NewUnitPointsService(testServices.RuleService, testServices.WeaponService, testServices.WarGearService)
+	unitPoints.UseSeasons(seasons)
+
+	rule, err := testServices.RuleService.CreateRule(ctx, &models.Rule{Name: "AP", Points: []int{3, 6, 9}, Effects: []models.RuleEffect{{Stat: "AP", Add: "tier"}}})
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	weapon, err := testServices.WeaponService.CreateWeapon(ctx, &models.Weapon{Name: "Rifle", Type: "ranged", Range: 24, Attacks: 2, AP: "1", Rules: []models.RuleReference{{RuleID: rule.ID, Tier: 2}}})
+	if err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+	unit := CreateTestUnit()
+	unit.Amount = 2
+	unit.Weapons = []models.WeaponReference{{WeaponID: weapon.ID, Quantity: 1, Type: "ranged"}}
+
+	legacy, err := unitPoints.CalculateUnitPointsInSeason(ctx, unit, services.DefaultSeasonName)
+	if err != nil {
+		t.Fatalf("Failed to calculate: %v", err)
+	}
+	if legacy.WeaponRulesCost != 12 {
+		t.Errorf("Expected legacy pricing to charge AP(2) per model, got %d", legacy.WeaponRulesCost)
+	}
+
+	statistical, err := unitPoints.CalculateUnitPointsInSeason(ctx, unit, "statistical")
+	if err != nil {
+		t.Fatalf("Failed to calculate: %v", err)
+	}
+	want := services.NewWeaponPointsCalculatorWithConfig(statisticalConfig()).CalculateWeaponPoints(services.WeaponStats{Type: "ranged", Range: 24, Attacks: "2", AP: "3", Skill: unit.Ranged})
+	if statistical.WeaponRulesCost != 0 || statistical.WeaponsCost != want {
+		t.Errorf("Expected AP(2) to be priced into the weapon at %d points, got %+v", want, statistical)
+	}
+
+	t.Run("Skill Of The Wielder", func(t *testing.T) {
+		clumsy := *unit
+		clumsy.Ranged = 8
+		unskilled, err := unitPoints.CalculateUnitPointsInSeason(ctx, &clumsy, "statistical")
+		if err != nil {
+			t.Fatalf("Failed to calculate: %v", err)
+		}
+		if unskilled.WeaponsCost >= statistical.WeaponsCost {
+			t.Errorf("Expected the rifle to cost more on a unit hitting on 3+ than on 8+, got %d and %d", statistical.WeaponsCost, unskilled.WeaponsCost)
+		}
+
+		// Melee skill doesn't change what a ranged weapon costs
+		brawler := *unit
+		brawler.Melee = 8
+		same, err := unitPoints.CalculateUnitPointsInSeason(ctx, &brawler, "statistical")
+		if err != nil || same.WeaponsCost != statistical.WeaponsCost {
+			t.Errorf("Expected the rifle to cost %d whatever the melee skill, got %+v, %v", statistical.WeaponsCost, same, err)
+		}
+
+		if _, err := seasons.ActivateSeason(ctx, "statistical"); err != nil {
+			t.Fatalf("Failed to activate season: %v", err)
+		}
+		current, err := unitPoints.CalculateUnitPoints(ctx, unit)
+		if err != nil || current.WeaponsCost != statistical.WeaponsCost {
+			t.Errorf("Expected the current season to price the rifle in the unit's hands at %d, got %+v, %v", statistical.WeaponsCost, current, err)
+		}
+	})
+
+	t.Run("Season Validation", func(t *testing.T) {
+		bad := testSeason("bad")
+		bad.Weapons.Pricing = "guesswork"
+		if err := services.ValidatePointsSeason(bad); !utils.IsValidationError(err) {
+			t.Errorf("Expected unknown pricing to be rejected, got %v", err)
+		}
+		bad = testSeason("bad")
+		bad.Weapons.Statistical.Defenses = []models.DefenseProfile{{Defense: 11, Weight: 1}}
+		if err := services.ValidatePointsSeason(bad); !utils.IsValidationError(err) {
+			t.Errorf("Expected a Defense off the D10 to be rejected, got %v", err)
+		}
+		old := testSeason("old")
+		old.Weapons.Pricing = ""
+		old.Weapons.Statistical = models.StatisticalWeaponPricing{}
+		if err := services.ValidatePointsSeason(old); err != nil {
+			t.Errorf("Expected seasons stored without statistical settings to validate, got %v", err)
+		}
+	})
+}
+
+func TestStatisticalWeaponPointsHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	handler := handlers.NewWeaponPointsHandler(nil)
+	router := mux.NewRouter()
+	router.HandleFunc("/weapon-points/calculate", handler.CalculateWeaponPoints).Methods("POST")
+
+	cases := []struct {
+		name     string
+		body     string
+		want     int
+		contains string
+	}{
+		{"Both Results", `{"type": "ranged", "range": 24, "attacks": "2", "ap": "1"}`, http.StatusOK, `"statisticalPoints":4`},
+		{"With Rules", `{"type": "ranged", "range": 24, "attacks": "2", "ap": "1", "rules": [{"tier": 2, "effects": [{"stat": "AP", "add": "tier"}]}]}`, http.StatusOK, `"ap":3`},
+		{"Invalid Rule", `{"type": "ranged", "rules": [{"tier": 1, "effects": [{"stat": "Toughness", "add": "1"}]}]}`, http.StatusBadRequest, "Toughness"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/weapon-points/calculate", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want || !strings.Contains(w.Body.String(), tc.contains) {
+				t.Errorf("Expected status %d with %s, got %d: %s", tc.want, tc.contains, w.Code, w.Body.String())
+			}
+		})
+	}
+}
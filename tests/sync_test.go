@@ -0,0 +1,248 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/services"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestSyncService(retention time.Duration) *services.SyncService {
+	return services.NewSyncService(
+		testRepos.RuleRepo,
+		testRepos.WeaponRepo,
+		testRepos.WarGearRepo,
+		testRepos.UnitRepo,
+		testRepos.ArmyBookRepo,
+		testRepos.ArmyListRepo,
+		testRepos.FactionRepo,
+		testRepos.PurgeLogRepo,
+		retention,
+	)
+}
+
+func TestTimestamps(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+
+	rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Timestamped"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	created, err := testServices.RuleService.GetRuleByID(ctx, rule.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to get rule: %v", err)
+	}
+	if created.CreatedAt.IsZero() || !created.UpdatedAt.Equal(created.CreatedAt) {
+		t.Fatalf("Expected a new rule to be created and updated at the same time, got %v / %v", created.CreatedAt, created.UpdatedAt)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	update := *created
+	update.Description = "Changed"
+	update.CreatedAt = time.Time{} // clients cannot overwrite it
+	if err := testServices.RuleService.UpdateRule(ctx, rule.ID.Hex(), &update); err != nil {
+		t.Fatalf("Failed to update rule: %v", err)
+	}
+
+	updated, err := testServices.RuleService.GetRuleByID(ctx, rule.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to get rule: %v", err)
+	}
+	if !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Expected createdAt to stay %v, got %v", created.CreatedAt, updated.CreatedAt)
+	}
+	if !updated.UpdatedAt.After(created.UpdatedAt) {
+		t.Errorf("Expected updatedAt to move past %v, got %v", created.UpdatedAt, updated.UpdatedAt)
+	}
+}
+
+func TestSyncChangesSince(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	syncService := newTestSyncService(30 * 24 * time.Hour)
+
+	before, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Sync Before"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	weapon, err := testServices.WeaponService.CreateWeapon(ctx, CreateTestWeapon())
+	if err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+	untouched, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Sync Untouched"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	checkpoint := time.Now()
+	time.Sleep(5 * time.Millisecond)
+
+	after, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Sync After"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	before.Description = "Changed after the checkpoint"
+	if err := testServices.RuleService.UpdateRule(ctx, before.ID.Hex(), before); err != nil {
+		t.Fatalf("Failed to update rule: %v", err)
+	}
+	if err := testServices.WeaponService.DeleteWeapon(ctx, weapon.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete weapon: %v", err)
+	}
+
+	t.Run("Since Checkpoint", func(t *testing.T) {
+		result, err := syncService.ChangesSince(ctx, checkpoint)
+		if err != nil {
+			t.Fatalf("Failed to sync: %v", err)
+		}
+		if result.ResyncRequired {
+			t.Error("Expected a recent checkpoint not to require a full resync")
+		}
+		if !result.ServerTime.After(checkpoint) {
+			t.Errorf("Expected server time after the checkpoint, got %v", result.ServerTime)
+		}
+
+		rules := result.Changes["rules"]
+		created := *rules.Created.(*[]models.Rule)
+		updated := *rules.Updated.(*[]models.Rule)
+		if len(created) != 1 || created[0].ID != after.ID {
+			t.Errorf("Expected only %q to be created, got %+v", after.Name, created)
+		}
+		if len(updated) != 1 || updated[0].ID != before.ID {
+			t.Errorf("Expected only %q to be updated, got %+v", before.Name, updated)
+		}
+		for _, rule := range append(created, updated...) {
+			if rule.ID == untouched.ID {
+				t.Error("Expected the untouched rule to be left out")
+			}
+		}
+
+		weapons := result.Changes["weapons"]
+		if len(weapons.Deleted) != 1 || weapons.Deleted[0] != weapon.ID {
+			t.Errorf("Expected the weapon to be reported deleted, got %v", weapons.Deleted)
+		}
+		if len(*weapons.Created.(*[]models.Weapon))+len(*weapons.Updated.(*[]models.Weapon)) != 0 {
+			t.Error("Expected the deleted weapon not to be reported as changed")
+		}
+	})
+
+	t.Run("First Sync", func(t *testing.T) {
+		result, err := syncService.ChangesSince(ctx, time.Time{})
+		if err != nil {
+			t.Fatalf("Failed to sync: %v", err)
+		}
+		if created := *result.Changes["rules"].Created.(*[]models.Rule); len(created) != 3 {
+			t.Errorf("Expected every rule on a first sync, got %d", len(created))
+		}
+		if result.ResyncRequired {
+			t.Error("Expected a first sync not to require a resync")
+		}
+	})
+
+	t.Run("Older Than Retention", func(t *testing.T) {
+		result, err := syncService.ChangesSince(ctx, time.Now().Add(-31*24*time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to sync: %v", err)
+		}
+		if !result.ResyncRequired {
+			t.Error("Expected a checkpoint older than the trash retention to require a resync")
+		}
+	})
+}
+
+func TestSyncAfterPurge(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	syncService := newTestSyncService(30 * 24 * time.Hour)
+	trashService := newTestTrashService(30 * 24 * time.Hour)
+
+	rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Sync Purged"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	// A write in the same millisecond as the checkpoint is still reported
+	stored, err := testServices.RuleService.GetRuleByID(ctx, rule.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to get rule: %v", err)
+	}
+	result, err := syncService.ChangesSince(ctx, stored.UpdatedAt.Add(500*time.Microsecond))
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if created := *result.Changes["rules"].Created.(*[]models.Rule); len(created) != 1 {
+		t.Errorf("Expected the rule written in the checkpoint's millisecond, got %+v", created)
+	}
+	if !result.ServerTime.Equal(result.ServerTime.Truncate(time.Millisecond)) {
+		t.Errorf("Expected the server time in whole milliseconds, got %v", result.ServerTime)
+	}
+
+	checkpoint := result.ServerTime
+	time.Sleep(5 * time.Millisecond)
+	if err := testServices.RuleService.DeleteRule(ctx, rule.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+	if result, err := syncService.ChangesSince(ctx, checkpoint); err != nil || result.ResyncRequired {
+		t.Fatalf("Expected a deletion in the trash to be reported, got %+v, %v", result, err)
+	}
+
+	if err := trashService.Purge(ctx, "rules", rule.ID.Hex()); err != nil {
+		t.Fatalf("Failed to purge rule: %v", err)
+	}
+	result, err = syncService.ChangesSince(ctx, checkpoint)
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if !result.ResyncRequired {
+		t.Error("Expected a checkpoint before a purge by hand to require a resync")
+	}
+	if result, err := syncService.ChangesSince(ctx, result.ServerTime.Add(time.Millisecond)); err != nil || result.ResyncRequired {
+		t.Errorf("Expected a checkpoint after the purge not to require a resync, got %+v, %v", result, err)
+	}
+}
+
+func TestSyncHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	handler := handlers.NewSyncHandler(newTestSyncService(30 * 24 * time.Hour))
+	router := mux.NewRouter()
+	router.HandleFunc("/sync", handler.GetChanges).Methods("GET")
+
+	cases := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"Everything", "/sync", http.StatusOK},
+		{"Since", "/sync?since=2024-01-02T15:04:05Z", http.StatusOK},
+		{"Since With Offset", "/sync?since=2024-01-02T15:04:05.123%2B02:00", http.StatusOK},
+		{"Invalid Since", "/sync?since=yesterday", http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Errorf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/services"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestTrashService(retention time.Duration) *services.TrashService {
+	return services.NewTrashService(
+		testRepos.RuleRepo,
+		testRepos.WeaponRepo,
+		testRepos.WarGearRepo,
+		testRepos.UnitRepo,
+		testRepos.ArmyBookRepo,
+		testRepos.ArmyListRepo,
+		testRepos.FactionRepo,
+		testServices.RevisionService,
+		testRepos.PurgeLogRepo,
+		retention,
+	)
+}
+
+func TestSoftDelete(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	trash := newTestTrashService(30 * 24 * time.Hour)
+
+	kept, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Soft Delete Kept"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	deleted, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Soft Delete Trashed"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	if err := testServices.RuleService.DeleteRule(ctx, deleted.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+
+	t.Run("Deleted Rule Is Hidden", func(t *testing.T) {
+		if _, err := testServices.RuleService.GetRuleByID(ctx, deleted.ID.Hex()); err == nil {
+			t.Error("Expected deleted rule to be hidden from GetRuleByID")
+		}
+
+		rules, err := testServices.RuleService.GetAllRules(ctx, 0, 0)
+		if err != nil {
+			t.Fatalf("Failed to get rules: %v", err)
+		}
+		if len(rules) != 1 || rules[0].ID != kept.ID {
+			t.Errorf("Expected only the kept rule, got %d rules", len(rules))
+		}
+
+		found, err := testServices.RuleService.SearchRulesByName(ctx, "Soft Delete", 0, 0)
+		if err != nil {
+			t.Fatalf("Failed to search rules: %v", err)
+		}
+		if len(found) != 1 {
+			t.Errorf("Expected search to find 1 rule, got %d", len(found))
+		}
+
+		count, err := testRepos.RuleRepo.CountRules(ctx)
+		if err != nil || count != 1 {
+			t.Errorf("Expected count 1, got %d (%v)", count, err)
+		}
+
+		if err := testServices.RuleService.DeleteRule(ctx, deleted.ID.Hex()); err == nil {
+			t.Error("Expected error deleting a rule that is already in the trash")
+		}
+	})
+
+	t.Run("List Trash", func(t *testing.T) {
+		items, total, err := trash.ListTrash(ctx, "rules", 50, 0)
+		if err != nil {
+			t.Fatalf("Failed to list trash: %v", err)
+		}
+		rules := *items.(*[]models.Rule)
+		if total != 1 || len(rules) != 1 || rules[0].ID != deleted.ID {
+			t.Fatalf("Expected the deleted rule in the trash, got %d (total %d)", len(rules), total)
+		}
+		if rules[0].DeletedAt == nil {
+			t.Error("Expected deletedAt to be set on trashed rule")
+		}
+
+		if _, _, err := trash.ListTrash(ctx, "spaceships", 50, 0); err == nil {
+			t.Error("Expected error for unknown entity type")
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		if err := trash.Restore(ctx, "rules", deleted.ID.Hex()); err != nil {
+			t.Fatalf("Failed to restore rule: %v", err)
+		}
+
+		restored, err := testServices.RuleService.GetRuleByID(ctx, deleted.ID.Hex())
+		if err != nil {
+			t.Fatalf("Expected restored rule to be visible: %v", err)
+		}
+		if restored.DeletedAt != nil {
+			t.Error("Expected deletedAt to be cleared on restore")
+		}
+
+		if err := trash.Restore(ctx, "rules", deleted.ID.Hex()); err == nil {
+			t.Error("Expected error restoring a rule that is not in the trash")
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		if err := trash.Purge(ctx, "rules", kept.ID.Hex()); err == nil {
+			t.Error("Expected purge to refuse a rule that is not in the trash")
+		}
+
+		if err := testServices.RuleService.DeleteRule(ctx, deleted.ID.Hex()); err != nil {
+			t.Fatalf("Failed to delete rule: %v", err)
+		}
+		if err := trash.Purge(ctx, "rules", deleted.ID.Hex()); err != nil {
+			t.Fatalf("Failed to purge rule: %v", err)
+		}
+
+		_, total, _ := trash.ListTrash(ctx, "rules", 50, 0)
+		if total != 0 {
+			t.Errorf("Expected empty trash after purge, got %d", total)
+		}
+		if err := trash.Restore(ctx, "rules", deleted.ID.Hex()); err == nil {
+			t.Error("Expected purged rule to be gone for good")
+		}
+	})
+}
+
+func TestTrashRetention(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+
+	weapon, err := testServices.WeaponService.CreateWeapon(ctx, CreateTestWeapon())
+	if err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+	if err := testServices.WeaponService.DeleteWeapon(ctx, weapon.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete weapon: %v", err)
+	}
+
+	// Freshly deleted items are within the retention period
+	purged, err := newTestTrashService(time.Hour).PurgeExpired(ctx)
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purged["weapons"] != 0 {
+		t.Errorf("Expected nothing purged within retention, got %d", purged["weapons"])
+	}
+
+	// A zero retention never purges automatically
+	purged, _ = newTestTrashService(0).PurgeExpired(ctx)
+	if purged["weapons"] != 0 {
+		t.Errorf("Expected zero retention to keep items, got %d purged", purged["weapons"])
+	}
+
+	// A retention shorter than the item's age removes it
+	time.Sleep(5 * time.Millisecond)
+	purged, err = newTestTrashService(time.Millisecond).PurgeExpired(ctx)
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purged["weapons"] != 1 {
+		t.Errorf("Expected 1 weapon purged, got %d", purged["weapons"])
+	}
+}
+
+func TestTrashHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	handler := handlers.NewTrashHandler(newTestTrashService(30 * 24 * time.Hour))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/trash/{type}", handler.GetTrash).Methods("GET")
+	router.HandleFunc("/trash/{type}/{id}", handler.PurgeFromTrash).Methods("DELETE")
+	router.HandleFunc("/trash/{type}/{id}/restore", handler.RestoreFromTrash).Methods("POST")
+
+	faction, err := testServices.FactionService.CreateFaction(ctx, CreateTestFaction())
+	if err != nil {
+		t.Fatalf("Failed to create faction: %v", err)
+	}
+	if err := testServices.FactionService.DeleteFaction(ctx, faction.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete faction: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"List Trash", "GET", "/trash/factions", http.StatusOK},
+		{"Unknown Type", "GET", "/trash/spaceships", http.StatusBadRequest},
+		{"Invalid ID", "POST", "/trash/factions/not-an-id/restore", http.StatusBadRequest},
+		{"Restore", "POST", "/trash/factions/" + faction.ID.Hex() + "/restore", http.StatusNoContent},
+		{"Restore Twice", "POST", "/trash/factions/" + faction.ID.Hex() + "/restore", http.StatusNotFound},
+		{"Purge Live Faction", "DELETE", "/trash/factions/" + faction.ID.Hex(), http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Errorf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/services"
+)
+
+func TestSequentialUnitOfWorkRollback(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	ctx := context.Background()
+
+	rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Unit Of Work Rule"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	weapon := CreateTestWeapon()
+	weapon.Rules = []models.RuleReference{{RuleID: rule.ID, Tier: 1}}
+	weapon, err = testServices.WeaponService.CreateWeapon(ctx, weapon)
+	if err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+
+	failure := errors.New("step failed")
+	var created *models.Weapon
+	err = services.SequentialUnitOfWork{}.Do(ctx, func(ctx context.Context) error {
+		if _, err := testServices.RuleService.DeleteRuleWithPolicy(ctx, rule.ID.Hex(), services.DeletePolicyDetach); err != nil {
+			return err
+		}
+		created, err = testServices.WeaponService.CreateWeapon(ctx, CreateTestWeapon())
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the unit to fail with %v, got %v", failure, err)
+	}
+
+	if _, err := testServices.RuleService.GetRuleByID(ctx, rule.ID.Hex()); err != nil {
+		t.Errorf("Expected the deleted rule to be restored: %v", err)
+	}
+	restored, err := testServices.WeaponService.GetWeaponByID(ctx, weapon.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to get weapon: %v", err)
+	}
+	if len(restored.Rules) != 1 || restored.Rules[0].RuleID != rule.ID {
+		t.Errorf("Expected the detached rule reference to be restored, got %v", restored.Rules)
+	}
+	if _, err := testServices.WeaponService.GetWeaponByID(ctx, created.ID.Hex()); err == nil {
+		t.Error("Expected the weapon created in the failed unit to be removed")
+	}
+}
+
+func TestSequentialUnitOfWorkUndoesUpdates(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	ctx := context.Background()
+
+	rule, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Undone Update"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	kept, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Undone Detach"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	trashed, err := testServices.RuleService.CreateRule(ctx, CreateTestRuleWithName("Undone Restore"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	if err := testServices.RuleService.DeleteRule(ctx, trashed.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+	weapon := CreateTestWeapon()
+	weapon.Rules = []models.RuleReference{{RuleID: kept.ID, Tier: 1}}
+	weapon, err = testServices.WeaponService.CreateWeapon(ctx, weapon)
+	if err != nil {
+		t.Fatalf("Failed to create weapon: %v", err)
+	}
+
+	failure := errors.New("step failed")
+	err = services.SequentialUnitOfWork{}.Do(ctx, func(ctx context.Context) error {
+		changed := *rule
+		changed.Description = "Changed in a failed unit"
+		if err := testServices.RuleService.UpdateRule(ctx, rule.ID.Hex(), &changed); err != nil {
+			return err
+		}
+		if err := testServices.WeaponService.DetachRule(ctx, weapon.ID.Hex(), kept.ID); err != nil {
+			return err
+		}
+		if err := testServices.WeaponService.AttachRule(ctx, weapon.ID.Hex(), models.RuleReference{RuleID: rule.ID, Tier: 1}); err != nil {
+			return err
+		}
+		if err := testRepos.RuleRepo.Restore(ctx, trashed.ID); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the unit to fail with %v, got %v", failure, err)
+	}
+
+	stored, err := testServices.RuleService.GetRuleByID(ctx, rule.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to get rule: %v", err)
+	}
+	if stored.Description != rule.Description || stored.Version != rule.Version {
+		t.Errorf("Expected the update to be undone, got %q at version %d", stored.Description, stored.Version)
+	}
+	restored, err := testServices.WeaponService.GetWeaponByID(ctx, weapon.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to get weapon: %v", err)
+	}
+	if len(restored.Rules) != 1 || restored.Rules[0].RuleID != kept.ID {
+		t.Errorf("Expected the attach and detach to be undone, got %v", restored.Rules)
+	}
+	if _, err := testServices.RuleService.GetRuleByID(ctx, trashed.ID.Hex()); err == nil {
+		t.Error("Expected the restore to be undone")
+	}
+}
+
+func TestAtomicImport(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	syncTestIndexes(t)
+
+	handler := handlers.NewImportHandler(
+		testServices.RuleService,
+		testServices.WeaponService,
+		testServices.WarGearService,
+		testServices.UnitService,
+		testServices.ArmyBookService,
+		testServices.ArmyListService,
+		testServices.FactionService,
+		services.SequentialUnitOfWork{},
+	)
+
+	// The second rule collides with the first on the unique name index
+	rules := []models.Rule{
+		{Name: "Atomic Rule", Description: "First rule", Points: []int{5}},
+		{Name: "atomic rule", Description: "Duplicate rule", Points: []int{5}},
+	}
+	importRules := func(url string) int {
+		body, _ := json.Marshal(rules)
+		req := httptest.NewRequest("POST", url, bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		handler.ImportRules(w, req)
+		return w.Code
+	}
+	countRules := func() int {
+		found, err := testServices.RuleService.SearchRulesByName(context.Background(), "Atomic Rule", 10, 0)
+		if err != nil {
+			t.Fatalf("Failed to search rules: %v", err)
+		}
+		return len(found)
+	}
+
+	t.Run("Atomic import keeps nothing on failure", func(t *testing.T) {
+		if code := importRules("/import/rules?atomic=true"); code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, code)
+		}
+		if count := countRules(); count != 0 {
+			t.Errorf("Expected no rules after a failed atomic import, got %d", count)
+		}
+	})
+
+	t.Run("Plain import keeps the documents before the failure", func(t *testing.T) {
+		if code := importRules("/import/rules"); code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, code)
+		}
+		if count := countRules(); count != 1 {
+			t.Errorf("Expected 1 rule after a failed plain import, got %d", count)
+		}
+	})
+
+	t.Run("Invalid atomic flag", func(t *testing.T) {
+		if code := importRules("/import/rules?atomic=maybe"); code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, code)
+		}
+	})
+}
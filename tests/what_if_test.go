@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestWhatIfService() *services.WhatIfService {
+	unitPoints := services.NewUnitPointsService(testServices.RuleService, testServices.WeaponService, testServices.WarGearService)
+	return services.NewWhatIfService(
+		testRepos.RuleRepo,
+		testRepos.WeaponRepo,
+		testRepos.WarGearRepo,
+		testRepos.UnitRepo,
+		testRepos.ArmyListRepo,
+		unitPoints,
+	)
+}
+
+func TestPointsWhatIf(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	whatIf := newTestWhatIfService()
+	g := createPointsGraph(t, ctx)
+	weaponPoints := services.NewWeaponPointsCalculator().CalculateWeaponPoints(services.WeaponStats{Range: 24, Attacks: "2", AP: "1", Type: "Ranged"})
+	unitPoints := expectedUnitPoints([]int{5, 7, 9}, weaponPoints)
+
+	// The list fits its limit now, but not once the rule costs a point more per tier
+	armyList := *g.armyList
+	armyList.PointsLimit = 2*unitPoints + 5
+	if err := testServices.ArmyListService.UpdateArmyList(ctx, armyList.ID.Hex(), &armyList); err != nil {
+		t.Fatalf("Failed to set points limit: %v", err)
+	}
+
+	t.Run("Rule Change", func(t *testing.T) {
+		rule := *g.rule
+		rule.Points = []int{6, 8, 10}
+		report, err := whatIf.WhatIf(ctx, &services.WhatIfProposal{Rules: []models.Rule{rule}})
+		if err != nil {
+			t.Fatalf("Failed to try the proposal: %v", err)
+		}
+
+		// The weapon's own points come from its stats, so it is checked but unchanged
+		if report.Checked != 4 {
+			t.Errorf("Expected four entities checked, got %d", report.Checked)
+		}
+		want := []struct {
+			entityType string
+			delta      int
+		}{{"armylists", 12}, {"units", 6}, {"wargear", 1}}
+		if len(report.Deltas) != len(want) {
+			t.Fatalf("Expected %d deltas, got %+v", len(want), report.Deltas)
+		}
+		for i, w := range want {
+			if report.Deltas[i].EntityType != w.entityType || report.Deltas[i].Delta != w.delta {
+				t.Errorf("Expected delta %d to be %s %+d, got %+v", i, w.entityType, w.delta, report.Deltas[i])
+			}
+		}
+
+		if len(report.OverLimit) != 1 {
+			t.Fatalf("Expected one list over its limit, got %+v", report.OverLimit)
+		}
+		if over := report.OverLimit[0]; over.ID != armyList.ID.Hex() || over.Over != 7 || over.AlreadyOver {
+			t.Errorf("Expected the list to go 7 over its limit, got %+v", over)
+		}
+
+		// Nothing is written
+		stored, err := testServices.RuleService.GetRuleByID(ctx, rule.ID.Hex())
+		if err != nil || stored.Points[0] != 5 {
+			t.Errorf("Expected the stored rule to be unchanged, got %+v, %v", stored, err)
+		}
+	})
+
+	t.Run("Season", func(t *testing.T) {
+		season := services.DefaultPointsSeason()
+		season.Name = ""
+		season.Units.BaseCost += 5
+		report, err := whatIf.WhatIf(ctx, &services.WhatIfProposal{Season: season})
+		if err != nil {
+			t.Fatalf("Failed to try the proposal: %v", err)
+		}
+		if report.Season != "proposal" || len(report.Deltas) != 2 {
+			t.Fatalf("Expected the unit and list to change in the proposed season, got %+v", report)
+		}
+		if report.Deltas[0].EntityType != "armylists" || report.Deltas[0].Delta != 10 || report.Deltas[1].Delta != 5 {
+			t.Errorf("Expected the list to rise by 10 and the unit by 5, got %+v", report.Deltas)
+		}
+	})
+
+	t.Run("Repriced Rules", func(t *testing.T) {
+		report, err := whatIf.WhatIf(ctx, &services.WhatIfProposal{RepriceRules: true})
+		if err != nil {
+			t.Fatalf("Failed to try the proposal: %v", err)
+		}
+		if report.Checked != 4 {
+			t.Errorf("Expected everything to be checked, got %d", report.Checked)
+		}
+	})
+
+	t.Run("Invalid Proposals", func(t *testing.T) {
+		unknown := *g.weapon
+		unknown.ID = primitive.NewObjectID()
+		badSeason := services.DefaultPointsSeason()
+		badSeason.Weapons.ScaleBase = 1
+
+		cases := []struct {
+			name       string
+			proposal   services.WhatIfProposal
+			validation bool
+		}{
+			{"Missing ID", services.WhatIfProposal{Rules: []models.Rule{{Name: "Rending"}}}, true},
+			{"Invalid Entity", services.WhatIfProposal{WarGear: []models.WarGear{{ID: g.wargear.ID}}}, true},
+			{"Invalid Season", services.WhatIfProposal{Season: badSeason}, true},
+			{"Unknown Entity", services.WhatIfProposal{Weapons: []models.Weapon{unknown}}, false},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := whatIf.WhatIf(ctx, &tc.proposal)
+				if err == nil {
+					t.Fatal("Expected the proposal to be rejected")
+				}
+				if tc.validation != utils.IsValidationError(err) {
+					t.Errorf("Expected validation error %v, got %v", tc.validation, err)
+				}
+			})
+		}
+	})
+
+	t.Run("Negative Points Limit", func(t *testing.T) {
+		armyList := CreateTestArmyList()
+		armyList.PointsLimit = -1
+		if _, err := testServices.ArmyListService.CreateArmyList(ctx, armyList); !utils.IsValidationError(err) {
+			t.Errorf("Expected a validation error, got %v", err)
+		}
+	})
+}
+
+func TestWhatIfHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	ctx := context.Background()
+	handler := handlers.NewWhatIfHandler(newTestWhatIfService())
+	g := createPointsGraph(t, ctx)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/points/what-if", handler.WhatIf).Methods("POST")
+
+	cases := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"Rule Change", `{"rules": [{"id": "` + g.rule.ID.Hex() + `", "name": "Rending", "points": [6, 8, 10]}]}`, http.StatusOK},
+		{"Nothing", `{}`, http.StatusOK},
+		{"Invalid JSON", `{"rules":`, http.StatusBadRequest},
+		{"Invalid Season", `{"season": {"name": "Not A Name"}}`, http.StatusBadRequest},
+		{"Unknown Rule", `{"rules": [{"id": "` + primitive.NewObjectID().Hex() + `", "name": "Rending"}]}`, http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/points/what-if", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Errorf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
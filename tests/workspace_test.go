@@ -0,0 +1,363 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grimdank-database/handlers"
+	"grimdank-database/models"
+	"grimdank-database/repositories"
+	"grimdank-database/services"
+	"grimdank-database/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// asMember returns a context of actor working in workspace
+func asMember(workspace, actor string) context.Context {
+	return services.WithWorkspace(services.WithChange(context.Background(), actor, ""), workspace)
+}
+
+func TestWorkspaceIsolation(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	syncTestIndexes(t)
+
+	defaultCtx := context.Background()
+	alpha := asMember("alpha", "alice")
+
+	mine, err := testServices.RuleService.CreateRule(alpha, CreateTestRuleWithName("Stealth"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	if mine.Workspace != "alpha" {
+		t.Errorf("Expected the rule stamped with its workspace, got %q", mine.Workspace)
+	}
+	theirs, err := testServices.RuleService.CreateRule(defaultCtx, CreateTestRuleWithName("Stealth"))
+	if err != nil {
+		t.Fatalf("Expected the same name to be free in another workspace: %v", err)
+	}
+	if _, err := testServices.RuleService.CreateRule(alpha, CreateTestRuleWithName("STEALTH")); !utils.IsDuplicateError(err) {
+		t.Errorf("Expected names to stay unique within a workspace, got %v", err)
+	}
+
+	rules, err := testServices.RuleService.GetAllRules(alpha, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to list rules: %v", err)
+	}
+	if len(rules) != 1 || rules[0].ID != mine.ID {
+		t.Errorf("Expected only alpha's rule, got %+v", rules)
+	}
+
+	if _, err := testServices.RuleService.GetRuleByID(alpha, theirs.ID.Hex()); err == nil {
+		t.Error("Expected another workspace's rule to be invisible")
+	}
+	if err := testServices.RuleService.UpdateRule(alpha, theirs.ID.Hex(), CreateTestRuleWithName("Taken")); err == nil {
+		t.Error("Expected another workspace's rule to be out of reach")
+	}
+	if err := testServices.RuleService.DeleteRule(alpha, theirs.ID.Hex()); err == nil {
+		t.Error("Expected another workspace's rule to be out of reach")
+	}
+	if rule, err := testServices.RuleService.GetRuleByID(defaultCtx, theirs.ID.Hex()); err != nil || rule.Name != "Stealth" {
+		t.Errorf("Expected the default rule untouched, got %+v, %v", rule, err)
+	}
+
+	// A workspace cannot move its documents elsewhere
+	moved := CreateTestRuleWithName("Stealth")
+	moved.Workspace = services.DefaultWorkspace
+	if err := testServices.RuleService.UpdateRule(alpha, mine.ID.Hex(), moved); err != nil {
+		t.Fatalf("Failed to update rule: %v", err)
+	}
+	if rule, err := testServices.RuleService.GetRuleByID(alpha, mine.ID.Hex()); err != nil || rule.Workspace != "alpha" {
+		t.Errorf("Expected the rule to stay in alpha, got %+v, %v", rule, err)
+	}
+}
+
+func TestOfficialFactionsAreShared(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	alpha := asMember("alpha", "alice")
+
+	official, err := testServices.FactionService.CreateFaction(context.Background(), CreateTestFaction())
+	if err != nil {
+		t.Fatalf("Failed to create faction: %v", err)
+	}
+	custom := CreateTestFaction()
+	custom.Name = "Homebrew"
+	custom.Type = models.FactionTypeCustom
+	if _, err := testServices.FactionService.CreateFaction(context.Background(), custom); err != nil {
+		t.Fatalf("Failed to create faction: %v", err)
+	}
+
+	visible, err := testServices.FactionService.GetFactionByID(alpha, official.ID.Hex())
+	if err != nil || visible.Name != official.Name {
+		t.Fatalf("Expected the official faction to be visible in every workspace, got %+v, %v", visible, err)
+	}
+	factions, err := testServices.FactionService.GetAllFactions(alpha, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to list factions: %v", err)
+	}
+	if len(factions) != 1 {
+		t.Errorf("Expected only the official faction, got %+v", factions)
+	}
+
+	update := CreateTestFaction()
+	update.Name = "Renamed"
+	if err := testServices.FactionService.UpdateFaction(alpha, official.ID.Hex(), update); !utils.IsForbiddenError(err) {
+		t.Errorf("Expected the official faction to be read-only, got %v", err)
+	}
+	if err := testServices.FactionService.DeleteFaction(alpha, official.ID.Hex()); !utils.IsForbiddenError(err) {
+		t.Errorf("Expected the official faction to be read-only, got %v", err)
+	}
+
+	published := CreateTestFaction()
+	published.Name = "Alpha Official"
+	if _, err := testServices.FactionService.CreateFaction(alpha, published); !utils.IsForbiddenError(err) {
+		t.Errorf("Expected only the default workspace to publish official factions, got %v", err)
+	}
+}
+
+func TestUnmigratedFactions(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	// Written before workspaces existed, so it has no workspace field
+	id, err := testDB.Collections["factions"].InsertOne(context.Background(), bson.M{
+		"name": "Legacy", "type": models.FactionTypeOfficial, "version": 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert faction: %v", err)
+	}
+
+	update := CreateTestFaction()
+	update.Name = "Renamed"
+	if err := testServices.FactionService.UpdateFaction(asMember("alpha", "alice"), id.Hex(), update); !utils.IsForbiddenError(err) {
+		t.Errorf("Expected other workspaces to find the faction read-only, got %v", err)
+	}
+	if err := testServices.FactionService.UpdateFaction(context.Background(), id.Hex(), update); err != nil {
+		t.Errorf("Expected the default workspace to own the faction, got %v", err)
+	}
+	if err := testServices.FactionService.DeleteFaction(context.Background(), id.Hex()); err != nil {
+		t.Errorf("Expected the default workspace to delete the faction, got %v", err)
+	}
+}
+
+func TestCreateInAllWorkspaces(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	all := repositories.AllWorkspaces(context.Background())
+	if _, err := testRepos.RuleRepo.CreateRule(all, CreateTestRule()); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	kept := CreateTestRuleWithName("Alpha Rule")
+	kept.Workspace = "alpha"
+	if _, err := testRepos.RuleRepo.CreateRule(all, kept); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	if rules, err := testServices.RuleService.GetAllRules(context.Background(), 0, 0); err != nil || len(rules) != 1 || rules[0].Workspace != repositories.DefaultWorkspace {
+		t.Errorf("Expected a rule created without a workspace to land in the default one, got %+v, %v", rules, err)
+	}
+	if rules, err := testServices.RuleService.GetAllRules(asMember("alpha", "alice"), 0, 0); err != nil || len(rules) != 1 || rules[0].Name != "Alpha Rule" {
+		t.Errorf("Expected a rule naming its workspace to keep it, got %+v, %v", rules, err)
+	}
+}
+
+func TestWorkspaceMembership(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	syncTestIndexes(t)
+
+	workspaces := testServices.WorkspaceService
+	alice := services.WithChange(context.Background(), "alice", "")
+	bob := services.WithChange(context.Background(), "bob", "")
+
+	if _, err := workspaces.CreateWorkspace(context.Background(), &models.Workspace{Slug: "alpha"}); !utils.IsValidationError(err) {
+		t.Errorf("Expected an anonymous workspace to be refused, got %v", err)
+	}
+	for _, slug := range []string{"default", "Alpha", "-alpha", ""} {
+		if _, err := workspaces.CreateWorkspace(alice, &models.Workspace{Slug: slug}); !utils.IsValidationError(err) {
+			t.Errorf("Expected slug %q to be refused, got %v", slug, err)
+		}
+	}
+
+	created, err := workspaces.CreateWorkspace(alice, &models.Workspace{Slug: "alpha", Name: "Alpha Company"})
+	if err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	if len(created.Members) != 1 || created.Members[0].Role != models.WorkspaceRoleOwner {
+		t.Errorf("Expected the creator to own the workspace, got %+v", created.Members)
+	}
+	if _, err := workspaces.CreateWorkspace(alice, &models.Workspace{Slug: "alpha"}); !utils.IsDuplicateError(err) {
+		t.Errorf("Expected a taken slug to be refused, got %v", err)
+	}
+
+	if err := workspaces.Authorize(bob, "alpha"); !utils.IsForbiddenError(err) {
+		t.Errorf("Expected a non-member to be refused, got %v", err)
+	}
+	if err := workspaces.Authorize(bob, "missing"); err == nil || utils.IsForbiddenError(err) {
+		t.Errorf("Expected an unknown workspace to be not found, got %v", err)
+	}
+	if err := workspaces.Authorize(bob, services.DefaultWorkspace); err != nil {
+		t.Errorf("Expected the default workspace to be open, got %v", err)
+	}
+
+	if _, err := workspaces.SetMember(alice, "alpha", "bob", ""); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+	if err := workspaces.Authorize(bob, "alpha"); err != nil {
+		t.Errorf("Expected a member to be let in, got %v", err)
+	}
+	if _, err := workspaces.SetMember(bob, "alpha", "carol", ""); !utils.IsForbiddenError(err) {
+		t.Errorf("Expected only owners to add members, got %v", err)
+	}
+	if _, err := workspaces.RemoveMember(alice, "alpha", "alice"); !utils.IsValidationError(err) {
+		t.Errorf("Expected the last owner to stay, got %v", err)
+	}
+
+	listed, err := workspaces.ListWorkspaces(bob)
+	if err != nil || len(listed) != 1 || listed[0].Slug != "alpha" {
+		t.Errorf("Expected bob to see alpha, got %+v, %v", listed, err)
+	}
+
+	if _, err := workspaces.RemoveMember(bob, "alpha", "bob"); err != nil {
+		t.Fatalf("Expected a member to leave: %v", err)
+	}
+	if err := workspaces.Authorize(bob, "alpha"); !utils.IsForbiddenError(err) {
+		t.Errorf("Expected a former member to be refused, got %v", err)
+	}
+}
+
+func TestWorkspaceStats(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+
+	alice := services.WithChange(context.Background(), "alice", "")
+	if _, err := testServices.WorkspaceService.CreateWorkspace(alice, &models.Workspace{Slug: "alpha"}); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+
+	alpha := asMember("alpha", "alice")
+	for _, name := range []string{"Stealth", "Fearless"} {
+		if _, err := testServices.RuleService.CreateRule(alpha, CreateTestRuleWithName(name)); err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+	}
+	trashed, err := testServices.RuleService.CreateRule(alpha, CreateTestRuleWithName("Deep Strike"))
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	if err := testServices.RuleService.DeleteRule(alpha, trashed.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+	if _, err := testServices.RuleService.CreateRule(context.Background(), CreateTestRuleWithName("Elsewhere")); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	if _, err := testServices.FactionService.CreateFaction(context.Background(), CreateTestFaction()); err != nil {
+		t.Fatalf("Failed to create faction: %v", err)
+	}
+
+	stats, err := testServices.WorkspaceService.Stats(alice, "alpha")
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if rules := stats.Collections["rules"]; rules.Live != 2 || rules.Trashed != 1 {
+		t.Errorf("Expected 2 live and 1 trashed rule, got %+v", rules)
+	}
+	if factions := stats.Collections["factions"]; factions.Live != 0 {
+		t.Errorf("Expected shared factions not to be counted, got %+v", factions)
+	}
+	if stats.Members != 1 {
+		t.Errorf("Expected 1 member, got %d", stats.Members)
+	}
+
+	if _, err := testServices.WorkspaceService.Stats(services.WithChange(context.Background(), "bob", ""), "alpha"); !utils.IsForbiddenError(err) {
+		t.Errorf("Expected a non-member to be refused, got %v", err)
+	}
+}
+
+func TestWorkspaceHandler(t *testing.T) {
+	SetupTestServices(t)
+	defer CleanupTestDB(t)
+	syncTestIndexes(t)
+
+	workspaceHandler := handlers.NewWorkspaceHandler(testServices.WorkspaceService)
+	ruleHandler := handlers.NewRuleHandler(testServices.RuleService)
+	router := mux.NewRouter()
+	router.HandleFunc("/workspaces", workspaceHandler.CreateWorkspace).Methods("POST")
+	router.HandleFunc("/workspaces", workspaceHandler.GetWorkspaces).Methods("GET")
+	router.HandleFunc("/workspaces/{slug}", workspaceHandler.GetWorkspace).Methods("GET")
+	router.HandleFunc("/workspaces/{slug}/members/{actor}", workspaceHandler.SetMember).Methods("PUT")
+	router.HandleFunc("/workspaces/{slug}/members/{actor}", workspaceHandler.RemoveMember).Methods("DELETE")
+	router.HandleFunc("/workspaces/{slug}/stats", workspaceHandler.GetStats).Methods("GET")
+	router.HandleFunc("/rules", ruleHandler.CreateRule).Methods("POST")
+	router.HandleFunc("/rules", ruleHandler.GetRules).Methods("GET")
+	router.Use(handlers.WithChange)
+	router.Use(handlers.ResolveWorkspace(testServices.WorkspaceService, "example.com"))
+
+	rule, _ := json.Marshal(CreateTestRuleWithName("Handler Rule"))
+	cases := []struct {
+		name      string
+		method    string
+		host      string
+		path      string
+		actor     string
+		workspace string
+		body      []byte
+		want      int
+	}{
+		{"Create Workspace", "POST", "", "/workspaces", "alice", "", []byte(`{"slug": "alpha"}`), http.StatusCreated},
+		{"Create Taken Slug", "POST", "", "/workspaces", "alice", "", []byte(`{"slug": "alpha"}`), http.StatusConflict},
+		{"Create Invalid Slug", "POST", "", "/workspaces", "alice", "", []byte(`{"slug": "Not A Slug"}`), http.StatusBadRequest},
+		{"Get Workspace", "GET", "", "/workspaces/alpha", "alice", "", nil, http.StatusOK},
+		{"Get Workspace As Stranger", "GET", "", "/workspaces/alpha", "bob", "", nil, http.StatusForbidden},
+		{"Get Missing Workspace", "GET", "", "/workspaces/missing", "alice", "", nil, http.StatusNotFound},
+		{"Header As Stranger", "GET", "", "/rules", "bob", "alpha", nil, http.StatusForbidden},
+		{"Header Unknown Workspace", "GET", "", "/rules", "alice", "missing", nil, http.StatusNotFound},
+		{"Create Rule By Header", "POST", "", "/rules", "alice", "alpha", rule, http.StatusCreated},
+		{"Subdomain As Stranger", "GET", "alpha.example.com:8080", "/rules", "bob", "", nil, http.StatusForbidden},
+		{"Add Member", "PUT", "", "/workspaces/alpha/members/bob", "alice", "", []byte(`{"role": "member"}`), http.StatusOK},
+		{"Subdomain As Member", "GET", "alpha.example.com:8080", "/rules", "bob", "", nil, http.StatusOK},
+		{"Invalid Role", "PUT", "", "/workspaces/alpha/members/bob", "alice", "", []byte(`{"role": "admin"}`), http.StatusBadRequest},
+		{"Add Member As Member", "PUT", "", "/workspaces/alpha/members/carol", "bob", "", nil, http.StatusForbidden},
+		{"Remove Missing Member", "DELETE", "", "/workspaces/alpha/members/carol", "alice", "", nil, http.StatusNotFound},
+		{"Stats", "GET", "", "/workspaces/alpha/stats", "bob", "", nil, http.StatusOK},
+		{"Leave", "DELETE", "", "/workspaces/alpha/members/bob", "bob", "", nil, http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, bytes.NewReader(tc.body))
+			if tc.host != "" {
+				req.Host = tc.host
+			}
+			req.Header.Set(handlers.ActorHeader, tc.actor)
+			if tc.workspace != "" {
+				req.Header.Set(handlers.WorkspaceHeader, tc.workspace)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Errorf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// The rule created through the header lives in alpha only
+	req := httptest.NewRequest("GET", "/rules", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var defaultRules []models.Rule
+	json.NewDecoder(w.Body).Decode(&defaultRules)
+	if len(defaultRules) != 0 {
+		t.Errorf("Expected the default workspace to have no rules, got %+v", defaultRules)
+	}
+}
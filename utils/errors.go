@@ -26,6 +26,104 @@ func NewValidationError(field, message string) ValidationError {
 	}
 }
 
+// VersionConflictError is returned when an update was based on a stale document version
+type VersionConflictError struct {
+	ID              string
+	ExpectedVersion int
+	CurrentVersion  int
+}
+
+func (e VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict on document %s: expected version %d but current version is %d",
+		e.ID, e.ExpectedVersion, e.CurrentVersion)
+}
+
+// NewVersionConflictError creates a new version conflict error
+func NewVersionConflictError(id string, expectedVersion, currentVersion int) VersionConflictError {
+	return VersionConflictError{
+		ID:              id,
+		ExpectedVersion: expectedVersion,
+		CurrentVersion:  currentVersion,
+	}
+}
+
+// DuplicateError is returned when a write would break a unique index
+type DuplicateError struct {
+	Index string
+	Err   error // the underlying driver error
+}
+
+func (e DuplicateError) Error() string {
+	if e.Index != "" {
+		return fmt.Sprintf("a document with the same unique key already exists (index %s)", e.Index)
+	}
+	return "a document with the same unique key already exists"
+}
+
+func (e DuplicateError) Unwrap() error {
+	return e.Err
+}
+
+// NewDuplicateError creates a new duplicate error
+func NewDuplicateError(index string, err error) DuplicateError {
+	return DuplicateError{
+		Index: index,
+		Err:   err,
+	}
+}
+
+// AlreadyAttachedError is returned when attaching a reference that a document already holds
+type AlreadyAttachedError struct {
+	Field string // the array holding the references, e.g. rules
+	ID    string // the referenced document
+}
+
+func (e AlreadyAttachedError) Error() string {
+	return fmt.Sprintf("%s already holds %s", e.Field, e.ID)
+}
+
+// NewAlreadyAttachedError creates a new already attached error
+func NewAlreadyAttachedError(field, id string) AlreadyAttachedError {
+	return AlreadyAttachedError{
+		Field: field,
+		ID:    id,
+	}
+}
+
+// ForbiddenError is returned when the caller may not do what they asked, such
+// as changing an official faction from another workspace
+type ForbiddenError struct {
+	Message string
+}
+
+func (e ForbiddenError) Error() string {
+	return e.Message
+}
+
+// NewForbiddenError creates a new forbidden error
+func NewForbiddenError(message string) ForbiddenError {
+	return ForbiddenError{
+		Message: message,
+	}
+}
+
+// NotFoundError is returned when a document does not exist, or is in the
+// trash where the caller wanted a live one
+type NotFoundError struct {
+	Message string
+}
+
+func (e NotFoundError) Error() string {
+	return e.Message
+}
+
+// NewNotFoundError creates a new not found error
+func NewNotFoundError(message string) NotFoundError {
+	return NotFoundError{
+		Message: message,
+	}
+}
+
 // WrapError wraps an error with additional context
 func WrapError(err error, context string) error {
 	if err == nil {
@@ -40,6 +138,36 @@ func IsValidationError(err error) bool {
 	return errors.As(err, &validationErr)
 }
 
+// IsVersionConflictError checks if an error is a version conflict error
+func IsVersionConflictError(err error) bool {
+	var conflictErr VersionConflictError
+	return errors.As(err, &conflictErr)
+}
+
+// IsDuplicateError checks if an error is a duplicate error
+func IsDuplicateError(err error) bool {
+	var duplicateErr DuplicateError
+	return errors.As(err, &duplicateErr)
+}
+
+// IsAlreadyAttachedError checks if an error is an already attached error
+func IsAlreadyAttachedError(err error) bool {
+	var attachedErr AlreadyAttachedError
+	return errors.As(err, &attachedErr)
+}
+
+// IsForbiddenError checks if an error is a forbidden error
+func IsForbiddenError(err error) bool {
+	var forbiddenErr ForbiddenError
+	return errors.As(err, &forbiddenErr)
+}
+
+// IsNotFoundError checks if an error is a not found error
+func IsNotFoundError(err error) bool {
+	var notFoundErr NotFoundError
+	return errors.As(err, &notFoundErr)
+}
+
 // CombineErrors combines multiple errors into a single error
 func CombineErrors(errs ...error) error {
 	var nonNilErrs []error
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Word is a word of a text and where it sits, as byte offsets
+type Word struct {
+	Text       string
+	Start, End int
+}
+
+// Words splits text into its words. Anything that is not a letter or a digit
+// separates words.
+func Words(text string) []Word {
+	words := make([]Word, 0)
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWordRune && start < 0:
+			start = i
+		case !isWordRune && start >= 0:
+			words = append(words, Word{Text: text[start:i], Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, Word{Text: text[start:], Start: start, End: len(text)})
+	}
+	return words
+}
+
+// stopWords are too common to be worth searching for
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "with": true,
+}
+
+// Stem reduces a word to a crude lower-case stem so that plurals and simple
+// verb forms find each other: "Rending", "rends" and "rended" all become "rend"
+func Stem(word string) string {
+	word = strings.ToLower(word)
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if suffix == "s" && strings.HasSuffix(word, "ss") {
+			continue
+		}
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+// SearchTerms returns the distinct stems of the words in a search query,
+// leaving out stop words
+func SearchTerms(query string) []string {
+	seen := make(map[string]bool)
+	terms := make([]string, 0)
+	for _, word := range Words(query) {
+		lower := strings.ToLower(word.Text)
+		if stopWords[lower] {
+			continue
+		}
+		stem := Stem(lower)
+		if !seen[stem] {
+			seen[stem] = true
+			terms = append(terms, stem)
+		}
+	}
+	return terms
+}